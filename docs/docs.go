@@ -15,15 +15,70 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/availabilities": {
-            "get": {
-                "description": "Get availabilities by single date or date range",
+        "/api-keys": {
+            "post": {
+                "description": "Issues a bearer API key for a reseller, whose bookings are stamped with its resellerId, or for an admin. The key is only returned by this request; the API keeps a hash of it. Needs an admin API key.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Issue an API key",
+                "parameters": [
+                    {
+                        "description": "Key holder and role",
+                        "name": "APIKeyPayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.APIKeyPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "API key issued",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIKeyPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/availability": {
+            "get": {
+                "description": "Get availabilities by single date or date range, one per timeslot ordered by start time, optionally for one product",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "availability"
                 ],
@@ -31,17 +86,91 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Capability",
-                        "name": "Capability",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Single local date (YYYY-MM-DD)",
+                        "name": "localDate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First local date of the range (YYYY-MM-DD)",
+                        "name": "localDateStart",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last local date of the range (YYYY-MM-DD)",
+                        "name": "localDateEnd",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Product ID",
+                        "name": "productId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.AvailabilityPayload_Rs_Pricing"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Returns the timeslots of a product option, by availabilityIds or within a single date or date range, that have room for the requested units, each priced for them with the octo/pricing capability.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "availability"
+                ],
+                "summary": "Check availability",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
                         "in": "header"
                     },
                     {
                         "description": "Request Payload",
-                        "name": "AvailabilityPayload_Rq",
+                        "name": "AvailabilityCheckPayload_Rq",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.AvailabilityPayload_Rq"
+                            "$ref": "#/definitions/model.AvailabilityCheckPayload_Rq"
                         }
                     }
                 ],
@@ -51,28 +180,28 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.AvailabilityPayload_Rs_Pricing"
+                                "$ref": "#/definitions/model.AvailabilityCheckPayload_Rs_Pricing"
                             }
                         }
                     },
                     "400": {
                         "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
         },
-        "/availabilities/add": {
+        "/availability/add": {
             "post": {
-                "description": "Adds availabilities for a product within a single date or date range",
+                "description": "Adds availabilities for a product within a single date or date range. START_TIME products get one availability per start time; OPENING_HOURS products get one per day.",
                 "consumes": [
                     "application/json"
                 ],
@@ -104,21 +233,21 @@ const docTemplate = `{
                     "400": {
                         "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
         },
-        "/bookings/all": {
-            "get": {
-                "description": "Retrieves all bookings, with the option to filter by pricing mode",
+        "/availability/calendar": {
+            "post": {
+                "description": "Summarises the availability of a product option per day between two local dates, e.g. for a month view. When units are given, a day is only available if one of its timeslots has room for all of them.",
                 "consumes": [
                     "application/json"
                 ],
@@ -126,39 +255,54 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "booking"
+                    "availability"
                 ],
-                "summary": "Get all bookings",
+                "summary": "Get the availability calendar",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Capability to filter by pricing mode",
-                        "name": "Capability",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
                         "in": "header"
+                    },
+                    {
+                        "description": "Request Payload",
+                        "name": "AvailabilityCalendarPayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.AvailabilityCalendarPayload_Rq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success - Return all bookings in pricing mode",
+                        "description": "Success",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.Booking"
+                                "$ref": "#/definitions/model.AvailabilityCalendarPayload_Rs_Pricing"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
         },
-        "/bookings/confirm/{id}": {
-            "put": {
-                "description": "Confirms a booking by its ID",
+        "/availability/{id}": {
+            "patch": {
+                "description": "Closes, reopens or switches an availability to FREESALE, or corrects its remaining vacancies. AVAILABLE, LIMITED and SOLD_OUT follow from the vacancies.",
                 "consumes": [
                     "application/json"
                 ],
@@ -166,43 +310,58 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "booking"
+                    "availability"
                 ],
-                "summary": "Confirm a booking",
+                "summary": "Update an availability",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Booking ID to confirm",
+                        "description": "Availability ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Request Payload for Updating an Availability",
+                        "name": "AvailabilityUpdatePayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.AvailabilityUpdatePayload_Rq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Booking confirmed successfully",
+                        "description": "Availability successfully updated",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.Availability"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "404": {
-                        "description": "Booking not found after confirmation",
+                        "description": "Availability not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
         },
-        "/bookings/post": {
+        "/bookings": {
             "post": {
-                "description": "Creates a new booking and updates the availability accordingly",
+                "description": "Creates a new reservation for the requested unit items and takes its units off the availability. The reservation expires after expirationMinutes (default 30) unless it is confirmed. Repeating a request with the same uuid returns the original booking; reusing the uuid for a different request is a conflict.",
                 "consumes": [
                     "application/json"
                 ],
@@ -225,6 +384,12 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "Booking already created with this uuid",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
                     "201": {
                         "description": "Booking successfully created",
                         "schema": {
@@ -234,21 +399,27 @@ const docTemplate = `{
                     "400": {
                         "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Availability is closed or has too few vacancies, or the uuid was used for another request",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
         },
-        "/bookings/{id}": {
+        "/bookings/all": {
             "get": {
-                "description": "Fetches a booking by its ID, with the option to filter by pricing mode",
+                "description": "Retrieves all bookings made with the caller's reseller, or every booking for admin API keys, with prices when the octo/pricing capability is requested",
                 "consumes": [
                     "application/json"
                 ],
@@ -258,47 +429,37 @@ const docTemplate = `{
                 "tags": [
                     "booking"
                 ],
-                "summary": "Get a booking by ID",
+                "summary": "Get all bookings",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Booking ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Capability to filter by pricing mode",
-                        "name": "Capability",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
                         "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success - Return booking in pricing mode",
-                        "schema": {
-                            "$ref": "#/definitions/model.Booking"
-                        }
-                    },
-                    "404": {
-                        "description": "Booking not found",
+                        "description": "Success",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.BookingPayload_Rs"
+                            }
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
         },
-        "/products": {
+        "/bookings/{id}": {
             "get": {
-                "description": "Retrieves all products, with the option to filter by pricing mode",
+                "description": "Fetches a booking by its ID, with prices when the octo/pricing capability is requested",
                 "consumes": [
                     "application/json"
                 ],
@@ -306,39 +467,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "product"
+                    "booking"
                 ],
-                "summary": "Get all products",
+                "summary": "Get a booking by ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Capability to filter by pricing mode",
-                        "name": "Capability",
+                        "description": "Booking ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
                         "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success - Return all products in pricing mode",
+                        "description": "Success",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Product"
-                            }
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
+                    "404": {
+                        "description": "Booking not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
-            }
-        },
-        "/products/add": {
-            "post": {
-                "description": "Adds a new product to the database",
+            },
+            "delete": {
+                "description": "Cancels a booking by its ID and gives its units back to the availability. Cancellation is refused once the product's cancellation cutoff before the availability has passed. Cancelling a cancelled booking returns it unchanged.",
                 "consumes": [
                     "application/json"
                 ],
@@ -346,45 +515,61 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "product"
+                    "booking"
                 ],
-                "summary": "Add a product",
+                "summary": "Cancel a booking",
                 "parameters": [
                     {
-                        "description": "Request Payload for Adding a Product",
-                        "name": "ProductPayload_Rq",
+                        "type": "string",
+                        "description": "Booking ID to cancel",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cancellation reason",
+                        "name": "BookingCancellationPayload_Rq",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.ProductPayload_Rq"
+                            "$ref": "#/definitions/model.BookingCancellationPayload_Rq"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "successfully created",
+                    "200": {
+                        "description": "Booking cancelled successfully",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request body or cancellation cutoff passed",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Booking not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking has expired",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
-            }
-        },
-        "/products/{id}": {
-            "get": {
-                "description": "Fetches a product by its ID, with the option to filter by pricing mode",
+            },
+            "patch": {
+                "description": "Amends a booking: moves it to another availabilityId of the same product, changes its option or unitItems, or sets its notes or lead contact. Changing the availability, option or units re-prices the booking and moves its vacancies. RESERVED bookings can be updated until they expire, CONFIRMED ones until the product's cancellation cutoff.",
                 "consumes": [
                     "application/json"
                 ],
@@ -392,229 +577,2191 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "product"
+                    "booking"
                 ],
-                "summary": "Get a product by ID",
+                "summary": "Update a booking",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Product ID",
+                        "description": "Booking ID to update",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Capability to filter by pricing mode",
-                        "name": "Capability",
-                        "in": "header"
+                        "description": "Fields to change",
+                        "name": "BookingUpdatePayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingUpdatePayload_Rq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success - Return product in pricing mode",
+                        "description": "Booking updated successfully",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or amendment cutoff passed",
                         "schema": {
-                            "$ref": "#/definitions/model.Product"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "404": {
-                        "description": "Product not found",
+                        "description": "Booking not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking is cancelled or expired, the availability is closed or has too few vacancies, or a redeemed unit would be moved or dropped",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/octoerr.Error"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.AvailabilityNewPayload_Rq": {
+        },
+        "/bookings/{id}/cancel": {
+            "post": {
+                "description": "Cancels a booking by its ID and gives its units back to the availability. Cancellation is refused once the product's cancellation cutoff before the availability has passed. Cancelling a cancelled booking returns it unchanged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Cancel a booking",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Booking ID to cancel",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cancellation reason",
+                        "name": "BookingCancellationPayload_Rq",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingCancellationPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Booking cancelled successfully",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or cancellation cutoff passed",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Booking not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking has expired",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/bookings/{id}/confirm": {
+            "post": {
+                "description": "Confirms a booking by its ID, capturing the lead traveller's contact and, optionally, the guest holding each unit",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Confirm a booking",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Booking ID to confirm",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Contacts",
+                        "name": "BookingConfirmationPayload_Rq",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingConfirmationPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Booking confirmed successfully",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or contact",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Booking not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking has been cancelled or has expired",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/bookings/{id}/extend": {
+            "patch": {
+                "description": "Pushes the expiry of a RESERVED booking to expirationMinutes from now (default 30)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Extend a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Booking ID to extend",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New hold time",
+                        "name": "BookingExtendPayload_Rq",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingExtendPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reservation extended successfully",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Booking not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking is not reserved or has expired",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/bookings/{id}/voucher": {
+            "get": {
+                "description": "Renders the voucher of a confirmed booking, or with unitItemId the ticket of one of its units, as a printable PDF. This is the document PDF_URL delivery options link to.",
+                "produces": [
+                    "application/pdf"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Get a voucher as PDF",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Booking ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Booking unit ID, to render that unit's ticket",
+                        "name": "unitItemId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PDF voucher",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Booking or unit item not found, or the product does not deliver this voucher as PDF",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking is not confirmed",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/products": {
+            "get": {
+                "description": "Retrieves all products, with prices when the octo/pricing capability is requested",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "product"
+                ],
+                "summary": "Get all products",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ProductPayload_Rs_Pricing"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/add": {
+            "post": {
+                "description": "Adds a new product to the database",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "product"
+                ],
+                "summary": "Add a product",
+                "parameters": [
+                    {
+                        "description": "Request Payload for Adding a Product",
+                        "name": "ProductPayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "successfully created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}": {
+            "get": {
+                "description": "Fetches a product by its ID, with prices when the octo/pricing capability is requested",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "product"
+                ],
+                "summary": "Get a product by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated OCTO capabilities, e.g. octo/pricing",
+                        "name": "Octo-Capabilities",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductPayload_Rs_Pricing"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/schedules": {
+            "get": {
+                "description": "Get the recurring schedule rules availabilities of a product are generated from",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedule"
+                ],
+                "summary": "Get schedule rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ScheduleRule"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a recurring schedule rule to a product and generates its availabilities up to the schedule horizon",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedule"
+                ],
+                "summary": "Add a schedule rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request Payload for Adding a Schedule Rule",
+                        "name": "ScheduleRulePayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduleRulePayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Schedule rule successfully created",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduleRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules/{id}": {
+            "put": {
+                "description": "Replaces a schedule rule and regenerates its availabilities. Availabilities that already have bookings are left unchanged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedule"
+                ],
+                "summary": "Update a schedule rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request Payload for Updating a Schedule Rule",
+                        "name": "ScheduleRulePayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduleRulePayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Schedule rule successfully updated",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduleRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a schedule rule and the upcoming availabilities it generated that have no bookings",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedule"
+                ],
+                "summary": "Delete a schedule rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "successfully deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Schedule rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/supplier": {
+            "get": {
+                "description": "Returns the supplier (operator) this API acts for, per OCTO: the API key's supplier, or the configured one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "supplier"
+                ],
+                "summary": "Get the supplier",
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/model.Supplier"
+                        }
+                    },
+                    "404": {
+                        "description": "Supplier not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickets/redeem": {
+            "post": {
+                "description": "Checks guests in at the gate. Scanning a ticket reference admits its unit; scanning a booking's voucher reference admits every unit not yet admitted. The booking must be CONFIRMED and for today's availability in the supplier's timezone, and each ticket can be redeemed once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ticket"
+                ],
+                "summary": "Redeem a ticket",
+                "parameters": [
+                    {
+                        "description": "Scanned reference and scanning device",
+                        "name": "TicketRedemptionPayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TicketRedemptionPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tickets redeemed",
+                        "schema": {
+                            "$ref": "#/definitions/model.BookingPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown voucher or ticket reference",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking is not confirmed or not for today, or the ticket was already redeemed",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "Lists every webhook subscription, without their secrets. Needs an admin API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "Subscriptions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WebhookSubscription"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Subscribes an endpoint to booking and availability events. Every delivery is signed with the subscription's secret, which is generated unless one is sent and is only returned by this request. Needs an admin API key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Subscribe to webhook events",
+                "parameters": [
+                    {
+                        "description": "Endpoint and events",
+                        "name": "WebhookSubscriptionPayload_Rq",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookSubscriptionPayload_Rq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Subscription created",
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookSubscriptionPayload_Rs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters": {
+            "get": {
+                "description": "Lists the webhook deliveries that ran out of attempts, with the event, endpoint, payload and last response of each. Needs an admin API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List dead webhook deliveries",
+                "responses": {
+                    "200": {
+                        "description": "Dead deliveries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters/{id}/retry": {
+            "post": {
+                "description": "Queues a dead delivery again with fresh attempts; it is sent on the next dispatch. Needs an admin API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Retry a dead webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "queued",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Dead delivery not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "delete": {
+                "description": "Deletes a webhook subscription together with its pending and dead deliveries. Needs an admin API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Unsubscribe a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "successfully deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/octoerr.Error"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.APIKeyPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "resellerId": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "supplierId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.APIKeyPayload_Rs": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "resellerId": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "supplierId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Availability": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "localDate": {
+                    "type": "string"
+                },
+                "localDateTimeEnd": {
+                    "type": "string"
+                },
+                "localDateTimeStart": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "vacancies": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AvailabilityCalendarPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "localDateEnd": {
+                    "type": "string"
+                },
+                "localDateStart": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "productId": {
+                    "type": "string"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitQuantityPayload_Rq"
+                    }
+                }
+            }
+        },
+        "model.AvailabilityCalendarPayload_Rs_Pricing": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "localDate": {
+                    "type": "string"
+                },
+                "openingHours": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OpeningHours"
+                    }
+                },
+                "pricingFrom": {
+                    "$ref": "#/definitions/model.Pricing"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "unitPricingFrom": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitPricing"
+                    }
+                },
+                "vacancies": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AvailabilityCheckPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "availabilityIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "localDate": {
+                    "type": "string"
+                },
+                "localDateEnd": {
+                    "type": "string"
+                },
+                "localDateStart": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "productId": {
+                    "type": "string"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitQuantityPayload_Rq"
+                    }
+                }
+            }
+        },
+        "model.AvailabilityCheckPayload_Rs_Pricing": {
+            "type": "object",
+            "properties": {
+                "allDay": {
+                    "type": "boolean"
+                },
+                "available": {
+                    "type": "boolean"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "localDateTimeEnd": {
+                    "type": "string"
+                },
+                "localDateTimeStart": {
+                    "type": "string"
+                },
+                "openingHours": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OpeningHours"
+                    }
+                },
+                "pricing": {
+                    "$ref": "#/definitions/model.Pricing"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "unitPricing": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitPricing"
+                    }
+                },
+                "vacancies": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AvailabilityNewPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "durationMinutes": {
+                    "type": "integer"
+                },
+                "localDate": {
+                    "type": "string"
+                },
+                "localDateEnd": {
+                    "type": "string"
+                },
+                "localDateStart": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "productId": {
+                    "type": "string"
+                },
+                "startTimes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.AvailabilityPayload_Rs_Pricing": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "localDate": {
+                    "type": "string"
+                },
+                "localDateTimeEnd": {
+                    "type": "string"
+                },
+                "localDateTimeStart": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "productName": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "vacancies": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AvailabilityUpdatePayload_Rq": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "vacancies": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Booking": {
+            "type": "object",
+            "properties": {
+                "availabilityId": {
+                    "type": "string"
+                },
+                "cancellation": {
+                    "$ref": "#/definitions/model.Cancellation"
+                },
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "unitItems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BookingUnit"
+                    }
+                },
+                "units": {
+                    "type": "integer"
+                },
+                "utcExpiresAt": {
+                    "type": "string"
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BookingCancellationPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BookingConfirmationPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "unitItems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BookingUnitContactPayload_Rq"
+                    }
+                }
+            }
+        },
+        "model.BookingExtendPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "expirationMinutes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.BookingPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "availabilityId": {
+                    "type": "string"
+                },
+                "expirationMinutes": {
+                    "description": "ExpirationMinutes is how long the reservation holds its vacancies before\nit expires. Zero means the server default.",
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "productId": {
+                    "type": "string"
+                },
+                "unitItems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitItemPayload_Rq"
+                    }
+                },
+                "uuid": {
+                    "description": "UUID is the reseller's own reference. Repeating a request with the same\nUUID returns the original booking instead of booking again.",
+                    "type": "string"
+                }
+            }
+        },
+        "model.BookingPayload_Rs": {
+            "type": "object",
+            "properties": {
+                "availabilityId": {
+                    "type": "string"
+                },
+                "cancellation": {
+                    "$ref": "#/definitions/model.Cancellation"
+                },
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BookingUnitPayload_Rs"
+                    }
+                },
+                "utcExpiresAt": {
+                    "type": "string"
+                },
+                "uuid": {
+                    "type": "string"
+                },
+                "voucher": {
+                    "$ref": "#/definitions/model.Voucher"
+                }
+            }
+        },
+        "model.BookingUnit": {
+            "type": "object",
+            "properties": {
+                "bookingId": {
+                    "type": "string"
+                },
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "pricing": {
+                    "$ref": "#/definitions/model.Pricing"
+                },
+                "redeemedBy": {
+                    "type": "string"
+                },
+                "ticket": {
+                    "type": "string"
+                },
+                "unitId": {
+                    "type": "string"
+                },
+                "utcRedeemedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BookingUnitContactPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BookingUnitPayload_Rs": {
+            "type": "object",
+            "properties": {
+                "bookingId": {
+                    "type": "string"
+                },
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "pricing": {
+                    "$ref": "#/definitions/model.Pricing"
+                },
+                "ticket": {
+                    "$ref": "#/definitions/model.Voucher"
+                },
+                "unitId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BookingUpdatePayload_Rq": {
+            "type": "object",
+            "properties": {
+                "availabilityId": {
+                    "type": "string"
+                },
+                "contact": {
+                    "$ref": "#/definitions/model.Contact"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "unitItems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitItemPayload_Rq"
+                    }
+                }
+            }
+        },
+        "model.Cancellation": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "refund": {
+                    "type": "string"
+                },
+                "utcCancelledAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Contact": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "type": "string"
+                },
+                "emailAddress": {
+                    "type": "string"
+                },
+                "fullName": {
+                    "type": "string"
+                },
+                "locales": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "phoneNumber": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.DeliveryOption": {
+            "type": "object",
+            "properties": {
+                "deliveryFormat": {
+                    "type": "string"
+                },
+                "deliveryValue": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OpeningHours": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OptionPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "type": "boolean"
+                },
+                "internalName": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitPayload_Rq"
+                    }
+                }
+            }
+        },
+        "model.OptionPayload_Rs_Pricing": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "internalName": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UnitPayload_Rs_Pricing"
+                    }
+                }
+            }
+        },
+        "model.Pricing": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "currencyPrecision": {
+                    "type": "integer"
+                },
+                "includedTaxes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Tax"
+                    }
+                },
+                "net": {
+                    "type": "integer"
+                },
+                "original": {
+                    "type": "integer"
+                },
+                "retail": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ProductPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "availabilityType": {
+                    "type": "string"
+                },
+                "cancellationCutoffAmount": {
+                    "type": "integer"
+                },
+                "cancellationCutoffUnit": {
+                    "type": "string"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "deliveryFormats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "deliveryMethods": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OptionPayload_Rq"
+                    }
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "supplierId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProductPayload_Rs_Pricing": {
             "type": "object",
             "properties": {
+                "availabilityType": {
+                    "type": "string"
+                },
+                "cancellationCutoffAmount": {
+                    "type": "integer"
+                },
+                "cancellationCutoffUnit": {
+                    "type": "string"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
                 "currency": {
                     "type": "string"
                 },
-                "localDate": {
+                "deliveryFormats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "deliveryMethods": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
                     "type": "string"
                 },
-                "localDateEnd": {
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OptionPayload_Rs_Pricing"
+                    }
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "supplierId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ScheduleRule": {
+            "type": "object",
+            "properties": {
+                "blackoutDates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "currency": {
                     "type": "string"
                 },
-                "localDateStart": {
+                "durationMinutes": {
+                    "type": "integer"
+                },
+                "id": {
                     "type": "string"
                 },
                 "price": {
-                    "type": "number"
+                    "type": "integer"
                 },
                 "productId": {
                     "type": "string"
+                },
+                "startTimes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "validFrom": {
+                    "type": "string"
+                },
+                "validUntil": {
+                    "type": "string"
+                },
+                "weekdays": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "model.AvailabilityPayload_Rq": {
+        "model.ScheduleRulePayload_Rq": {
             "type": "object",
             "properties": {
-                "localDate": {
+                "blackoutDates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "currency": {
                     "type": "string"
                 },
-                "localDateEnd": {
+                "durationMinutes": {
+                    "type": "integer"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "startTimes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "validFrom": {
                     "type": "string"
                 },
-                "localDateStart": {
+                "validUntil": {
                     "type": "string"
+                },
+                "weekdays": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "model.AvailabilityPayload_Rs_Pricing": {
+        "model.Supplier": {
             "type": "object",
             "properties": {
-                "available": {
-                    "type": "boolean"
+                "contact": {
+                    "$ref": "#/definitions/model.SupplierContact"
                 },
-                "currency": {
+                "endpoint": {
                     "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
-                "localDate": {
+                "name": {
                     "type": "string"
                 },
-                "price": {
-                    "type": "number"
+                "timezone": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SupplierContact": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
                 },
-                "productName": {
+                "email": {
                     "type": "string"
                 },
-                "status": {
+                "telephone": {
                     "type": "string"
                 },
-                "vacancies": {
+                "website": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Tax": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "net": {
+                    "type": "integer"
+                },
+                "retail": {
                     "type": "integer"
                 }
             }
         },
-        "model.Booking": {
+        "model.TicketRedemptionPayload_Rq": {
             "type": "object",
             "properties": {
-                "availabilityId": {
+                "device": {
                     "type": "string"
                 },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UnitItemPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "unitId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UnitPayload_Rq": {
+            "type": "object",
+            "properties": {
                 "currency": {
                     "type": "string"
                 },
-                "id": {
+                "internalName": {
                     "type": "string"
                 },
                 "price": {
-                    "type": "number"
+                    "type": "integer"
                 },
-                "status": {
+                "reference": {
                     "type": "string"
                 },
-                "units": {
-                    "type": "integer"
+                "restrictions": {
+                    "$ref": "#/definitions/model.UnitRestrictions"
+                },
+                "type": {
+                    "type": "string"
                 }
             }
         },
-        "model.BookingPayload_Rq": {
+        "model.UnitPayload_Rs_Pricing": {
             "type": "object",
             "properties": {
-                "availabilityId": {
+                "currency": {
                     "type": "string"
                 },
-                "productId": {
+                "id": {
                     "type": "string"
                 },
-                "units": {
+                "internalName": {
+                    "type": "string"
+                },
+                "price": {
                     "type": "integer"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "restrictions": {
+                    "$ref": "#/definitions/model.UnitRestrictions"
+                },
+                "type": {
+                    "type": "string"
                 }
             }
         },
-        "model.BookingPayload_Rs_NonPricing": {
+        "model.UnitPricing": {
             "type": "object",
             "properties": {
-                "availabilityId": {
+                "currency": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "string"
+                "currencyPrecision": {
+                    "type": "integer"
                 },
-                "status": {
+                "includedTaxes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Tax"
+                    }
+                },
+                "net": {
+                    "type": "integer"
+                },
+                "original": {
+                    "type": "integer"
+                },
+                "retail": {
+                    "type": "integer"
+                },
+                "unitId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UnitQuantityPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "id": {
                     "type": "string"
                 },
-                "units": {
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.UnitRestrictions": {
+            "type": "object",
+            "properties": {
+                "accompaniedBy": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.BookingUnitPayload_Rs_NonPricing"
+                        "type": "string"
                     }
+                },
+                "idRequired": {
+                    "type": "boolean"
+                },
+                "maxAge": {
+                    "type": "integer"
+                },
+                "maxQuantity": {
+                    "type": "integer"
+                },
+                "minAge": {
+                    "type": "integer"
+                },
+                "minQuantity": {
+                    "type": "integer"
+                },
+                "paxCount": {
+                    "type": "integer"
                 }
             }
         },
-        "model.BookingUnitPayload_Rs_NonPricing": {
+        "model.Voucher": {
             "type": "object",
             "properties": {
-                "bookingId": {
+                "deliveryOptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.DeliveryOption"
+                    }
+                },
+                "redeemedBy": {
                     "type": "string"
                 },
-                "id": {
+                "redemptionMethod": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "utcRedeemedAt": {
                     "type": "string"
                 }
             }
         },
-        "model.Product": {
+        "model.WebhookDelivery": {
             "type": "object",
             "properties": {
-                "capacity": {
+                "attempts": {
                     "type": "integer"
                 },
-                "currency": {
+                "createdAt": {
                     "type": "string"
                 },
+                "deliveredAt": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "eventId": {
+                    "type": "integer"
+                },
                 "id": {
+                    "type": "integer"
+                },
+                "lastError": {
                     "type": "string"
                 },
-                "name": {
+                "lastStatusCode": {
+                    "type": "integer"
+                },
+                "nextAttemptAt": {
                     "type": "string"
                 },
-                "price": {
-                    "type": "number"
+                "payload": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "subscriptionId": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
         },
-        "model.ProductPayload_Rq": {
+        "model.WebhookSubscription": {
             "type": "object",
             "properties": {
-                "capacity": {
-                    "type": "integer"
+                "createdAt": {
+                    "type": "string"
                 },
-                "currency": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
                     "type": "string"
                 },
-                "name": {
+                "url": {
                     "type": "string"
+                }
+            }
+        },
+        "model.WebhookSubscriptionPayload_Rq": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "price": {
-                    "type": "number"
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
         },
-        "model.ProductPayload_Rs_NonPricing": {
+        "model.WebhookSubscriptionPayload_Rs": {
             "type": "object",
             "properties": {
-                "capacity": {
-                    "type": "integer"
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "id": {
                     "type": "string"
                 },
-                "name": {
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "octoerr.Code": {
+            "type": "string",
+            "enum": [
+                "INVALID_PRODUCT_ID",
+                "INVALID_OPTION_ID",
+                "INVALID_UNIT_ID",
+                "INVALID_AVAILABILITY_ID",
+                "INVALID_BOOKING_UUID",
+                "UNPROCESSABLE_ENTITY",
+                "BAD_REQUEST",
+                "UNAUTHORIZED",
+                "FORBIDDEN",
+                "INTERNAL_SERVER_ERROR"
+            ],
+            "x-enum-varnames": [
+                "InvalidProductIDCode",
+                "InvalidOptionIDCode",
+                "InvalidUnitIDCode",
+                "InvalidAvailabilityIDCode",
+                "InvalidBookingUUIDCode",
+                "UnprocessableEntityCode",
+                "BadRequestCode",
+                "UnauthorizedCode",
+                "ForbiddenCode",
+                "InternalServerErrorCode"
+            ]
+        },
+        "octoerr.Error": {
+            "type": "object",
+            "properties": {
+                "availabilityId": {
+                    "type": "string"
+                },
+                "error": {
+                    "$ref": "#/definitions/octoerr.Code"
+                },
+                "errorMessage": {
+                    "type": "string"
+                },
+                "optionId": {
+                    "type": "string"
+                },
+                "productId": {
+                    "type": "string"
+                },
+                "unitId": {
+                    "type": "string"
+                },
+                "uuid": {
                     "type": "string"
                 }
             }
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"strings"
+)
+
+// PostAPIKey godoc
+// @Summary Issue an API key
+// @Description Issues a bearer API key for a reseller, whose bookings are stamped with its resellerId, or for an admin. The key is only returned by this request; the API keeps a hash of it. Needs an admin API key.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   APIKeyPayload_Rq body model.APIKeyPayload_Rq true "Key holder and role"
+// @Success 201 {object} model.APIKeyPayload_Rs "API key issued"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 401 {object} octoerr.Error "Missing or invalid API key"
+// @Failure 403 {object} octoerr.Error "Not an admin API key"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /api-keys [post]
+func (s *Server) PostAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	var req model.APIKeyPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println(err.Error())
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+
+	key := model.APIKey{
+		Name:       strings.TrimSpace(req.Name),
+		Role:       strings.ToUpper(req.Role),
+		ResellerId: strings.TrimSpace(req.ResellerId),
+		SupplierId: strings.TrimSpace(req.SupplierId),
+		CreatedAt:  s.now().UTC(),
+	}
+	if key.Role == "" {
+		key.Role = model.APIKeyRoleReseller
+	}
+	switch {
+	case key.Name == "":
+		octoerr.Write(w, octoerr.BadRequest("name is required"))
+		return
+	case key.Role != model.APIKeyRoleReseller && key.Role != model.APIKeyRoleAdmin:
+		octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("role must be %s or %s", model.APIKeyRoleReseller, model.APIKeyRoleAdmin)))
+		return
+	case key.Role == model.APIKeyRoleReseller && key.ResellerId == "":
+		octoerr.Write(w, octoerr.BadRequest("resellerId is required for reseller keys"))
+		return
+	}
+	if key.SupplierId != "" {
+		if _, err := s.repo.GetSupplier(key.SupplierId); errors.Is(err, sql.ErrNoRows) {
+			octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("Unknown supplierId %q", key.SupplierId)))
+			return
+		} else if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+	}
+
+	secret, err := newSecret("octo_")
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	key.KeyHash = hashAPIKey(secret)
+	if err := s.repo.InsertAPIKey(key); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(model.APIKeyPayload_Rs{APIKey: key, Key: secret})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// publicRoutes are served without an API key. PDF_URL vouchers are opened by
+// travellers, who have no key, and the API docs are open to everyone.
+var publicRoutes = map[string]bool{
+	"/bookings/{id}/voucher": true,
+	"/swagger/":              true,
+}
+
+// callerKey is the request context key of the authenticated API key.
+type callerKey struct{}
+
+// authenticate requires an OCTO bearer token, "Authorization: Bearer <key>",
+// on every route but the public ones, and records the caller's API key in
+// the request context.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil && publicRoutes[tmpl] {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			unauthorized(w, "Missing API key: send Authorization: Bearer <key>")
+			return
+		}
+
+		key, err := s.apiKey(hashAPIKey(token))
+		if errors.Is(err, sql.ErrNoRows) {
+			unauthorized(w, "Invalid API key")
+			return
+		}
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, key)))
+	})
+}
+
+// apiKey returns the API key with the given hash: the configured admin key,
+// or one on record.
+func (s *Server) apiKey(keyHash string) (*model.APIKey, error) {
+	if s.adminKeyHash != "" && subtle.ConstantTimeCompare([]byte(keyHash), []byte(s.adminKeyHash)) == 1 {
+		return &model.APIKey{KeyHash: keyHash, Name: "ADMIN_API_KEY", Role: model.APIKeyRoleAdmin}, nil
+	}
+	return s.repo.GetAPIKey(keyHash)
+}
+
+// adminOnly refuses requests made with a reseller key.
+func (s *Server) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := caller(r); key == nil || key.Role != model.APIKeyRoleAdmin {
+			octoerr.Write(w, octoerr.Forbidden("This request needs an admin API key"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// unauthorized answers a request without a valid API key.
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	octoerr.Write(w, octoerr.Unauthorized(message))
+}
+
+// caller returns the API key the request was made with, or nil on public
+// routes.
+func caller(r *http.Request) *model.APIKey {
+	key, _ := r.Context().Value(callerKey{}).(*model.APIKey)
+	return key
+}
+
+// resellerScope returns the reseller whose bookings the caller sees, or ""
+// for admins, who see every booking.
+func resellerScope(r *http.Request) string {
+	if key := caller(r); key != nil && key.Role != model.APIKeyRoleAdmin {
+		return key.ResellerId
+	}
+	return ""
+}
+
+// visible reports whether the caller may see a booking: admins see every
+// booking, resellers only their own.
+func visible(r *http.Request, booking *model.BookingPayload_Rs) bool {
+	key := caller(r)
+	return key != nil && (key.Role == model.APIKeyRoleAdmin || key.ResellerId == booking.ResellerId)
+}
+
+// hashAPIKey returns the hex SHA-256 hash API keys are stored and looked up
+// by.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecret returns 32 random bytes, URL-safe base64 encoded after prefix,
+// for API keys and webhook secrets.
+func newSecret(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
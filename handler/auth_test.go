@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// issueKey issues an API key through POST /api-keys and returns the
+// Authorization header to send it with.
+func issueKey(t *testing.T, h http.Handler, req model.APIKeyPayload_Rq) map[string]string {
+	t.Helper()
+
+	rec := doRequest(t, h, "POST", "/api-keys", req, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var issued model.APIKeyPayload_Rs
+	decodeBody(t, rec, &issued)
+	if issued.Key == "" || issued.Role != req.Role || issued.ResellerId != req.ResellerId {
+		t.Fatalf("unexpected API key %+v", issued)
+	}
+	return map[string]string{"Authorization": "Bearer " + issued.Key}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doRequest(t, h, "GET", "/products", nil, map[string]string{"Authorization": ""})
+	expectError(t, rec, http.StatusUnauthorized, octoerr.UnauthorizedCode)
+	if rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("expected a Bearer challenge, got %q", rec.Header().Get("WWW-Authenticate"))
+	}
+	rec = doRequest(t, h, "GET", "/products", nil, map[string]string{"Authorization": "Bearer unknown"})
+	expectError(t, rec, http.StatusUnauthorized, octoerr.UnauthorizedCode)
+	rec = doRequest(t, h, "GET", "/products", nil, map[string]string{"Authorization": "Basic " + testAdminKey})
+	expectError(t, rec, http.StatusUnauthorized, octoerr.UnauthorizedCode)
+
+	// Voucher PDFs are opened by travellers without a key
+	rec = doRequest(t, h, "GET", "/bookings/missing/voucher", nil, map[string]string{"Authorization": ""})
+	expectError(t, rec, http.StatusNotFound, octoerr.InvalidBookingUUIDCode)
+
+	rec = doRequest(t, h, "POST", "/api-keys", model.APIKeyPayload_Rq{Name: "Reseller A", Role: "RESELLER"}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+	rec = doRequest(t, h, "POST", "/api-keys", model.APIKeyPayload_Rq{Name: "Reseller A", Role: "OWNER", ResellerId: "reseller_a"}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+	rec = doRequest(t, h, "POST", "/api-keys", model.APIKeyPayload_Rq{Name: "Reseller A", ResellerId: "reseller_a", SupplierId: "missing"}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+
+	reseller := issueKey(t, h, model.APIKeyPayload_Rq{Name: "Reseller A", Role: "RESELLER", ResellerId: "reseller_a"})
+	rec = doRequest(t, h, "GET", "/products", nil, reseller)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected resellers to list products, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Only admins manage the catalogue, availability and keys
+	rec = doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{Name: "Ferry", Capacity: 5}, reseller)
+	expectError(t, rec, http.StatusForbidden, octoerr.ForbiddenCode)
+	rec = doRequest(t, h, "POST", "/availability/add", nil, reseller)
+	expectError(t, rec, http.StatusForbidden, octoerr.ForbiddenCode)
+	rec = doRequest(t, h, "POST", "/api-keys", model.APIKeyPayload_Rq{Name: "Mine", Role: "ADMIN"}, reseller)
+	expectError(t, rec, http.StatusForbidden, octoerr.ForbiddenCode)
+
+	admin := issueKey(t, h, model.APIKeyPayload_Rq{Name: "Back office", Role: "ADMIN"})
+	rec = doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{Name: "Ferry", Capacity: 5, Price: 1500}, admin)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected issued admin keys to add products, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServerResellerBookings(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	resellerA := issueKey(t, h, model.APIKeyPayload_Rq{Name: "Reseller A", Role: "RESELLER", ResellerId: "reseller_a"})
+	resellerB := issueKey(t, h, model.APIKeyPayload_Rq{Name: "Reseller B", Role: "RESELLER", ResellerId: "reseller_b"})
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	request := model.BookingPayload_Rq{
+		UUID:           uuid.NewString(),
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}
+	rec := doRequest(t, h, "POST", "/bookings", request, resellerA)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	if stored, _ := repo.GetBookingByID(booking.ID); stored.ResellerId != "reseller_a" {
+		t.Errorf("expected the booking to be stamped with reseller_a, got %q", stored.ResellerId)
+	}
+	doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{AvailabilityId: availabilities[0].ID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}, resellerB)
+
+	var bookings []model.BookingPayload_Rs
+	decodeBody(t, doRequest(t, h, "GET", "/bookings/all", nil, resellerA), &bookings)
+	if len(bookings) != 1 || bookings[0].ID != booking.ID {
+		t.Errorf("expected only reseller_a's booking, got %+v", bookings)
+	}
+	decodeBody(t, doRequest(t, h, "GET", "/bookings/all", nil, nil), &bookings)
+	if len(bookings) != 2 {
+		t.Errorf("expected admins to see both bookings, got %d", len(bookings))
+	}
+
+	// Other resellers' bookings do not exist for reseller_b
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID, nil, resellerB)
+	expectError(t, rec, http.StatusNotFound, octoerr.InvalidBookingUUIDCode)
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, resellerB)
+	expectError(t, rec, http.StatusNotFound, octoerr.InvalidBookingUUIDCode)
+	rec = doRequest(t, h, "POST", "/bookings", request, resellerB)
+	expectError(t, rec, http.StatusConflict, octoerr.InvalidBookingUUIDCode)
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, resellerA)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected reseller_a to confirm its booking, got %d: %s", rec.Code, rec.Body)
+	}
+}
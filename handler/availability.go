@@ -1,143 +1,273 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"octo-api/model"
+	"octo-api/money"
+	"octo-api/octoerr"
 	"octo-api/store"
 	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 // GetAvailabilities godoc
 // @Summary Get availabilities
-// @Description Get availabilities by single date or date range
+// @Description Get availabilities by single date or date range, one per timeslot ordered by start time, optionally for one product
 // @Tags availability
-// @Accept  json
 // @Produce  json
-// @Param   Capability header string false "Capability"
-// @Param   AvailabilityPayload_Rq body model.AvailabilityPayload_Rq true "Request Payload"
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Param   localDate query string false "Single local date (YYYY-MM-DD)"
+// @Param   localDateStart query string false "First local date of the range (YYYY-MM-DD)"
+// @Param   localDateEnd query string false "Last local date of the range (YYYY-MM-DD)"
+// @Param   productId query string false "Product ID"
 // @Success 200 {object} []model.AvailabilityPayload_Rs_Pricing "Success"
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal Server Error"
-// @Router /availabilities [get]
-func GetAvailabilities(w http.ResponseWriter, r *http.Request) {
+// @Failure 400 {object} octoerr.Error "Invalid query parameters"
+// @Failure 404 {object} octoerr.Error "Product not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /availability [get]
+func (s *Server) GetAvailabilities(w http.ResponseWriter, r *http.Request) {
+
+	// Read date information from the query string
+	query := r.URL.Query()
+	startDate, endDate, err := s.parseLocalDates(query.Get("localDate"), query.Get("localDateStart"), query.Get("localDateEnd"))
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	// Get Availability Data
+	var availabilities []model.AvailabilityShow
+	if productID := query.Get("productId"); productID != "" {
+		availabilities, err = s.productAvailabilities(productID, startDate, endDate)
+	} else {
+		availabilities, err = s.repo.GetAvailabilities(startDate, endDate)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.InvalidProductID(query.Get("productId")).WithStatus(http.StatusNotFound))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	availabilityOutputs := []model.AvailabilityPayload_Rs_Pricing{}
+	for _, availability := range availabilities {
+		availabilityOutputs = append(availabilityOutputs, model.AvailabilityPayload_Rs_Pricing{
+			Id:                 availability.ID,
+			LocalDate:          availability.LocalDate,
+			LocalDateTimeStart: availability.LocalDateTimeStart,
+			LocalDateTimeEnd:   availability.LocalDateTimeEnd,
+			Status:             availability.Status,
+			ProductName:        availability.ProductName,
+			Vacancies:          availability.Vacancies,
+			Available:          availability.Available,
+			Price:              availability.Price,
+			Currency:           availability.Currency,
+		})
+	}
+	writeOcto(w, r, http.StatusOK, availabilityOutputs)
+}
+
+// productAvailabilities returns the availabilities of one product between
+// two local dates in the shape of GetAvailabilities.
+func (s *Server) productAvailabilities(productID string, startDate, endDate time.Time) ([]model.AvailabilityShow, error) {
+	product, err := s.repo.GetProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+	availabilities, err := s.repo.GetProductAvailabilities(product.ID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
 
-	capHeader := r.Header.Get("Capability")
-	// Check if pricing mode
-	isExt := (strings.ToLower(capHeader) == "pricing")
+	shows := make([]model.AvailabilityShow, 0, len(availabilities))
+	for _, a := range availabilities {
+		shows = append(shows, model.AvailabilityShow{
+			ID:                 a.ID,
+			LocalDate:          a.LocalDate,
+			LocalDateTimeStart: a.LocalDateTimeStart,
+			LocalDateTimeEnd:   a.LocalDateTimeEnd,
+			Status:             a.Status,
+			ProductName:        product.Name,
+			Vacancies:          a.Vacancies,
+			Available:          a.Available,
+			Price:              a.Price,
+			Currency:           a.Currency,
+		})
+	}
+	return shows, nil
+}
 
-	// Decode date information from request
-	var req model.AvailabilityPayload_Rq
+// CheckAvailability godoc
+// @Summary Check availability
+// @Description Returns the timeslots of a product option, by availabilityIds or within a single date or date range, that have room for the requested units, each priced for them with the octo/pricing capability.
+// @Tags availability
+// @Accept  json
+// @Produce  json
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Param   AvailabilityCheckPayload_Rq body model.AvailabilityCheckPayload_Rq true "Request Payload"
+// @Success 200 {object} []model.AvailabilityCheckPayload_Rs_Pricing "Success"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /availability [post]
+func (s *Server) CheckAvailability(w http.ResponseWriter, r *http.Request) {
+
+	var req model.AvailabilityCheckPayload_Rq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		fmt.Println(err.Error())
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+	if req.ProductId == "" {
+		octoerr.Write(w, octoerr.InvalidProductID(req.ProductId))
+		return
+	}
+	if req.OptionId == "" {
+		octoerr.Write(w, octoerr.InvalidOptionID(req.OptionId))
 		return
 	}
 
-	var startDate, endDate time.Time
-	var err error
+	product, err := s.repo.GetProduct(req.ProductId)
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.InvalidProductID(req.ProductId))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	option, err := findOption(product, req.OptionId)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	units, err := resolveUnitQuantities(option, req.Units)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
 
-	if req.LocalDate != "" {
-		// Single date query
-		startDate, err = time.Parse("2006-01-02", req.LocalDate)
-		if err != nil {
-			fmt.Println(err.Error())
-			http.Error(w, "Invalid localDate format. Please use YYYY-MM-DD.", http.StatusBadRequest)
-			return
+	var availabilities []model.Availability
+	if len(req.AvailabilityIds) > 0 {
+		for _, id := range req.AvailabilityIds {
+			availability, err := s.repo.GetAvailabilityByID(id)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && availability.ProductId != product.ID) {
+				octoerr.Write(w, octoerr.InvalidAvailabilityID(id))
+				return
+			}
+			if err != nil {
+				octoerr.Write(w, err)
+				return
+			}
+			availabilities = append(availabilities, *availability)
 		}
-		endDate = startDate // Single date, so start and end are the same
 	} else {
-		// Date range query
-		startDate, err = time.Parse("2006-01-02", req.LocalDateStart)
+		startDate, endDate, err := s.parseLocalDates(req.LocalDate, req.LocalDateStart, req.LocalDateEnd)
 		if err != nil {
-			fmt.Println(err.Error())
-			http.Error(w, "Invalid localDateStart format. Please use YYYY-MM-DD.", http.StatusBadRequest)
+			octoerr.Write(w, err)
 			return
 		}
-		endDate, err = time.Parse("2006-01-02", req.LocalDateEnd)
+		availabilities, err = s.repo.GetProductAvailabilities(product.ID, startDate, endDate)
 		if err != nil {
-			fmt.Println(err.Error())
-			http.Error(w, "Invalid localDateEnd format. Please use YYYY-MM-DD.", http.StatusBadRequest)
+			octoerr.Write(w, err)
 			return
 		}
 	}
 
-	database := store.ConnectToDB()
-	defer database.Close()
+	priced := capable(r, CapabilityPricing)
+	outputs := []model.AvailabilityCheckPayload_Rs_Pricing{}
+	allDay := isOpeningHours(product.AvailabilityType)
+	for i, availability := range availabilities {
+		// Only timeslots with room for every requested unit are returned
+		if !fitsUnits(availability, len(units)) {
+			continue
+		}
+		hours := []model.OpeningHours{}
+		if allDay {
+			hours = append(hours, openingHours(availability))
+		}
 
-	// Get Availability Data
-	availabilities, err := store.GetAvailabilitiesFromDB(database, startDate, endDate)
-	if err != nil {
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+		output := model.AvailabilityCheckPayload_Rs_Pricing{
+			Id:                 availability.ID,
+			LocalDateTimeStart: availability.LocalDateTimeStart,
+			LocalDateTimeEnd:   availability.LocalDateTimeEnd,
+			AllDay:             allDay,
+			Available:          availability.Available,
+			Status:             availability.Status,
+			Vacancies:          availability.Vacancies,
+			Capacity:           availability.Capacity,
+			OpeningHours:       hours,
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	// Prepare output data according to mode
-	if isExt { // Pricing mode
-		var availabilityOutputs []model.AvailabilityPayload_Rs_Pricing
-		for _, availability := range availabilities {
-			availabilityOutputs = append(
-				availabilityOutputs,
-				model.AvailabilityPayload_Rs_Pricing{
-					Id:          availability.ID,
-					LocalDate:   availability.LocalDate,
-					Status:      availability.Status,
-					ProductName: availability.ProductName,
-					Vacancies:   availability.Vacancies,
-					Available:   availability.Available,
-					Price:       availability.Price,
-					Currency:    availability.Currency,
-				},
-			)
+		// Pricing may need exchange rates, so skip it unless it is asked for
+		if priced {
+			output.UnitPricing, output.Pricing, err = slotPricing(s.rates, option, &availabilities[i], units)
+			if err != nil {
+				octoerr.Write(w, err)
+				return
+			}
 		}
-		json.NewEncoder(w).Encode(availabilityOutputs)
-	} else { // Non-Pricing mode
-		var availabilityOutputs []model.AvailabilityPayload_Rs_NonPricing
-		for _, availability := range availabilities {
-			availabilityOutputs = append(
-				availabilityOutputs,
-				model.AvailabilityPayload_Rs_NonPricing{
-					Id:          availability.ID,
-					LocalDate:   availability.LocalDate,
-					Status:      availability.Status,
-					ProductName: availability.ProductName,
-					Vacancies:   availability.Vacancies,
-					Available:   availability.Available,
-				},
-			)
+		outputs = append(outputs, output)
+	}
+
+	writeOcto(w, r, http.StatusOK, outputs)
+}
+
+// parseLocalDates returns the local dates of a single localDate, or of a
+// localDateStart to localDateEnd range of at most maxDateRangeDays days.
+func (s *Server) parseLocalDates(localDate, localDateStart, localDateEnd string) (time.Time, time.Time, error) {
+	if localDate != "" {
+		date, err := time.Parse("2006-01-02", localDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, octoerr.BadRequest("Invalid localDate format. Please use YYYY-MM-DD.")
 		}
-		json.NewEncoder(w).Encode(availabilityOutputs)
+		return date, date, nil
+	}
+
+	startDate, err := time.Parse("2006-01-02", localDateStart)
+	if err != nil {
+		return time.Time{}, time.Time{}, octoerr.BadRequest("Invalid localDateStart format. Please use YYYY-MM-DD.")
 	}
+	endDate, err := time.Parse("2006-01-02", localDateEnd)
+	if err != nil {
+		return time.Time{}, time.Time{}, octoerr.BadRequest("Invalid localDateEnd format. Please use YYYY-MM-DD.")
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, octoerr.BadRequest("localDateEnd must not be before localDateStart")
+	}
+	if endDate.After(startDate.AddDate(0, 0, s.maxDateRangeDays-1)) {
+		return time.Time{}, time.Time{}, octoerr.BadRequest(fmt.Sprintf("date range must not exceed %d days", s.maxDateRangeDays))
+	}
+	return startDate, endDate, nil
 }
 
 // AddAvailabilities godoc
 // @Summary Add availabilities
-// @Description Adds availabilities for a product within a single date or date range
+// @Description Adds availabilities for a product within a single date or date range. START_TIME products get one availability per start time; OPENING_HOURS products get one per day.
 // @Tags availability
 // @Accept  json
 // @Produce  json
 // @Param   AvailabilityNewPayload_Rq body model.AvailabilityNewPayload_Rq true "Request Payload for Adding Availabilities"
 // @Success 201 {string} string "successfully added"
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal Server Error"
-// @Router /availabilities/add [post]
-func AddAvailabilities(w http.ResponseWriter, r *http.Request) {
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /availability/add [post]
+func (s *Server) AddAvailabilities(w http.ResponseWriter, r *http.Request) {
 
 	var req model.AvailabilityNewPayload_Rq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		fmt.Println(err.Error())
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
 		return
 	}
 
-	database := store.ConnectToDB()
-	defer database.Close()
-
 	var startDate, endDate time.Time
 	var err error
 
@@ -146,7 +276,7 @@ func AddAvailabilities(w http.ResponseWriter, r *http.Request) {
 		startDate, err = time.Parse("2006-01-02", req.LocalDate)
 		if err != nil {
 			fmt.Println(err.Error())
-			http.Error(w, "Invalid localDate format. Please use YYYY-MM-DD.", http.StatusBadRequest)
+			octoerr.Write(w, octoerr.BadRequest("Invalid localDate format. Please use YYYY-MM-DD."))
 			return
 		}
 		endDate = startDate // Single date, so start and end are the same
@@ -155,13 +285,13 @@ func AddAvailabilities(w http.ResponseWriter, r *http.Request) {
 		startDate, err = time.Parse("2006-01-02", req.LocalDateStart)
 		if err != nil {
 			fmt.Println(err.Error())
-			http.Error(w, "Invalid localDateStart format. Please use YYYY-MM-DD.", http.StatusBadRequest)
+			octoerr.Write(w, octoerr.BadRequest("Invalid localDateStart format. Please use YYYY-MM-DD."))
 			return
 		}
 		endDate, err = time.Parse("2006-01-02", req.LocalDateEnd)
 		if err != nil {
 			fmt.Println(err.Error())
-			http.Error(w, "Invalid localDateEnd format. Please use YYYY-MM-DD.", http.StatusBadRequest)
+			octoerr.Write(w, octoerr.BadRequest("Invalid localDateEnd format. Please use YYYY-MM-DD."))
 			return
 		}
 	}
@@ -171,10 +301,25 @@ func AddAvailabilities(w http.ResponseWriter, r *http.Request) {
 		req.Currency = "USD"
 	}
 
-	err = store.AddAvailabilityIntoDB(database, req.ProductId, startDate, endDate, req.Price, req.Currency)
+	if req.DurationMinutes < 0 {
+		octoerr.Write(w, octoerr.BadRequest("durationMinutes must not be negative"))
+		return
+	}
+	timeslots := store.Timeslots{
+		StartTimes: req.StartTimes,
+		Duration:   time.Duration(req.DurationMinutes) * time.Minute,
+	}
+
+	err = s.repo.AddAvailability(req.ProductId, startDate, endDate, timeslots, money.New(req.Price, req.Currency))
 	if err != nil {
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			octoerr.Write(w, octoerr.InvalidProductID(req.ProductId))
+		case errors.Is(err, store.ErrInvalidTimeslots):
+			octoerr.Write(w, octoerr.BadRequest(err.Error()))
+		default:
+			octoerr.Write(w, err)
+		}
 		return
 	}
 
@@ -182,3 +327,52 @@ func AddAvailabilities(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode("successfully added")
 }
+
+// UpdateAvailability godoc
+// @Summary Update an availability
+// @Description Closes, reopens or switches an availability to FREESALE, or corrects its remaining vacancies. AVAILABLE, LIMITED and SOLD_OUT follow from the vacancies.
+// @Tags availability
+// @Accept  json
+// @Produce  json
+// @Param   id path string true "Availability ID"
+// @Param   AvailabilityUpdatePayload_Rq body model.AvailabilityUpdatePayload_Rq true "Request Payload for Updating an Availability"
+// @Success 200 {object} model.Availability "Availability successfully updated"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 404 {object} octoerr.Error "Availability not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /availability/{id} [patch]
+func (s *Server) UpdateAvailability(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	availabilityID := vars["id"]
+
+	var req model.AvailabilityUpdatePayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println(err.Error())
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+
+	err := s.repo.UpdateAvailability(availabilityID, strings.ToUpper(req.Status), req.Vacancies)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			octoerr.Write(w, octoerr.InvalidAvailabilityID(availabilityID).WithStatus(http.StatusNotFound))
+		case errors.Is(err, store.ErrInvalidAvailabilityUpdate):
+			octoerr.Write(w, octoerr.BadRequest(err.Error()))
+		default:
+			octoerr.Write(w, err)
+		}
+		return
+	}
+
+	availability, err := s.repo.GetAvailabilityByID(availabilityID)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(availability)
+}
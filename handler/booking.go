@@ -1,13 +1,21 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"octo-api/helper"
+	"net/mail"
 	"octo-api/model"
+	"octo-api/money"
+	"octo-api/octoerr"
 	"octo-api/store"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -15,269 +23,786 @@ import (
 
 // PostBooking godoc
 // @Summary Post a booking
-// @Description Creates a new booking and updates the availability accordingly
+// @Description Creates a new reservation for the requested unit items and takes its units off the availability. The reservation expires after expirationMinutes (default 30) unless it is confirmed. Repeating a request with the same uuid returns the original booking; reusing the uuid for a different request is a conflict.
 // @Tags booking
 // @Accept  json
 // @Produce  json
 // @Param   BookingPayload_Rq body model.BookingPayload_Rq true "Request Payload for Posting a Booking"
 // @Success 201 {object} model.Booking "Booking successfully created"
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal Server Error"
-// @Router /bookings/post [post]
-func PostBooking(w http.ResponseWriter, r *http.Request) {
+// @Success 200 {object} model.BookingPayload_Rs "Booking already created with this uuid"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 409 {object} octoerr.Error "Availability is closed or has too few vacancies, or the uuid was used for another request"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /bookings [post]
+func (s *Server) PostBooking(w http.ResponseWriter, r *http.Request) {
 
 	// Decode Booking info from request
 	var bookingSchema model.BookingPayload_Rq
 	if err := json.NewDecoder(r.Body).Decode(&bookingSchema); err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
 		return
 	}
 
-	database := store.ConnectToDB()
-	defer database.Close()
+	if bookingSchema.ExpirationMinutes < 0 {
+		octoerr.Write(w, octoerr.BadRequest("expirationMinutes must not be negative"))
+		return
+	}
+
+	// A reseller UUID makes the request safe to retry
+	requestHash := bookingRequestHash(bookingSchema)
+	if bookingSchema.UUID != "" {
+		if _, err := uuid.Parse(bookingSchema.UUID); err != nil {
+			octoerr.Write(w, octoerr.InvalidBookingUUID(bookingSchema.UUID))
+			return
+		}
+		if s.replayBooking(w, r, bookingSchema.UUID, requestHash) {
+			return
+		}
+	}
 
 	// Check if availabilityId is Valid & Check Price and Currency
 	// Get Availability with certain AvailabilityID
-	availability, err := store.GetAvailabilityByIdFromDB(database, bookingSchema.AvailabilityId)
+	availability, err := s.repo.GetAvailabilityByID(bookingSchema.AvailabilityId)
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.InvalidAvailabilityID(bookingSchema.AvailabilityId))
+		return
+	}
 	if err != nil {
-		// log.Fatal(err)
-		fmt.Println(err.Error())
-		http.Error(w, "Invalid AvailabilityID", http.StatusBadRequest)
+		octoerr.Write(w, err)
+		return
+	}
+	if len(bookingSchema.ProductId) > 0 && bookingSchema.ProductId != availability.ProductId {
+		octoerr.Write(w, octoerr.InvalidAvailabilityID(bookingSchema.AvailabilityId))
 		return
 	}
 	// Get Product information with certain ProductID
-	product, err := store.GetProductFromDB(database, availability.ProductId)
+	product, err := s.repo.GetProduct(availability.ProductId)
 	if err != nil {
-		// log.Fatal(err)
-		fmt.Println(err.Error())
-		http.Error(w, "Internal DB Error", http.StatusInternalServerError)
+		octoerr.Write(w, err)
+		return
+	}
+
+	// Resolve the option and the requested units
+	option, err := findOption(product, bookingSchema.OptionId)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	units, err := resolveUnitItems(option, bookingSchema.UnitItems)
+	if err != nil {
+		octoerr.Write(w, err)
 		return
 	}
 
 	var booking model.Booking
 
 	booking.AvailabilityId = bookingSchema.AvailabilityId
-	booking.Units = bookingSchema.Units
+	booking.OptionId = option.ID
+	booking.Units = len(units)
 
 	// Generate a unique ID for the new booking
 	booking.ID = uuid.New().String()
-	booking.Status = "RESERVED"
+	booking.UUID = bookingSchema.UUID
+	if booking.UUID == "" {
+		booking.UUID = booking.ID
+	}
+	booking.RequestHash = requestHash
+	booking.ResellerId = caller(r).ResellerId
+	booking.Status = model.BookingStatusReserved
+	booking.Notes = bookingSchema.Notes
+
+	// Hold the vacancies until the reservation expires
+	expiry := s.reservationExpiry
+	if bookingSchema.ExpirationMinutes > 0 {
+		expiry = time.Duration(bookingSchema.ExpirationMinutes) * time.Minute
+	}
+	expiresAt := s.now().Add(expiry).UTC()
+	booking.UtcExpiresAt = &expiresAt
 
 	// Calculate Price
-	// Currency check
-	// LOGIC : If currency of product and availability is not matched together, set booking currency as USD. Else, continue with the same currency
-	if product.Currency != availability.Currency {
-		// Set USD as currency unit for booking
-		booking.Currency = "USD"
-		booking.Price = 0
-		if strings.ToLower(product.Currency) == "usd" {
-			booking.Price = booking.Price + product.Price*float64(bookingSchema.Units)
-		} else {
-			// Make conversion
-			converted_amount, err := helper.Rate_Convert(product.Currency, booking.Currency, product.Price)
-			if err != nil {
-				// log.Fatal(err)
-				fmt.Println(err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+	prices, total, err := priceUnits(s.rates, availability, units)
+	if err != nil {
+		// log.Fatal(err)
+		octoerr.Write(w, err)
+		return
+	}
+
+	// Every unit is priced once, at reservation time, and the units add up exactly to the booking total
+	booking.Price = total.Amount
+	booking.Currency = total.Currency
+	for i, unit := range units {
+		booking.UnitItems = append(booking.UnitItems, model.BookingUnit{
+			ID:        uuid.NewString(),
+			BookingId: booking.ID,
+			UnitId:    unit.ID,
+			Pricing:   unitPricing(prices[i]),
+		})
+	}
 
-			booking.Price = booking.Price + converted_amount*float64(bookingSchema.Units)
+	if err := s.repo.CreateBooking(booking); err != nil {
+		if errors.Is(err, store.ErrInsufficientVacancies) || errors.Is(err, store.ErrAvailabilityClosed) {
+			octoerr.Write(w, octoerr.UnprocessableEntity(err.Error()).WithStatus(http.StatusConflict))
+			return
+		}
+		// A concurrent retry created the booking first
+		if errors.Is(err, store.ErrDuplicateBookingUUID) && s.replayBooking(w, r, booking.UUID, requestHash) {
+			return
 		}
+		octoerr.Write(w, err)
+		return
+	}
 
-		if strings.ToLower(availability.Currency) == "usd" {
-			booking.Price = booking.Price + availability.Price*float64(bookingSchema.Units)
-		} else {
-			// Make conversion
-			converted_amount, err := helper.Rate_Convert(availability.Currency, booking.Currency, availability.Price)
-			if err != nil {
-				// log.Fatal(err)
-				fmt.Println(err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+	writeOcto(w, r, http.StatusCreated, booking)
+}
+
+// bookingRequestHash fingerprints a booking request so a retry can be told
+// apart from a different request reusing the same UUID.
+func bookingRequestHash(req model.BookingPayload_Rq) string {
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayBooking writes the booking already created under bookingUUID, or a
+// conflict if it was created by a different request or another reseller. It
+// reports whether it wrote a response; false means no booking has the UUID
+// yet.
+func (s *Server) replayBooking(w http.ResponseWriter, r *http.Request, bookingUUID, requestHash string) bool {
+	existing, err := s.repo.GetBookingByUUID(bookingUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return true
+	}
+	if existing.RequestHash != requestHash || !visible(r, existing) {
+		octoerr.Write(w, octoerr.InvalidBookingUUID(bookingUUID).WithStatus(http.StatusConflict))
+		return true
+	}
+	if err := s.deliver(existing); err != nil {
+		octoerr.Write(w, err)
+		return true
+	}
+
+	writeOcto(w, r, http.StatusOK, existing)
+	return true
+}
 
-			booking.Price = booking.Price + converted_amount*float64(bookingSchema.Units)
+// findOption returns the option with the given ID, or the product's default
+// option when optionID is empty.
+func findOption(product *model.Product, optionID string) (*model.Option, error) {
+	for i, o := range product.Options {
+		if o.ID == optionID || (len(optionID) == 0 && o.Default) {
+			return &product.Options[i], nil
 		}
-	} else {
-		// Set the booking currency type as product's and availability's currency type
-		booking.Currency = product.Currency
-		booking.Price = (product.Price + availability.Price) * float64(bookingSchema.Units)
 	}
+	if len(optionID) == 0 && len(product.Options) > 0 {
+		return &product.Options[0], nil
+	}
+	return nil, octoerr.InvalidOptionID(optionID)
+}
 
-	if err := store.CreateBooking(database, booking); err != nil {
-		// log.Fatal(err)
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// resolveUnitItems maps the requested unit items onto the option's units and
+// enforces the per-unit quantity and accompaniment restrictions.
+func resolveUnitItems(option *model.Option, items []model.UnitItemPayload_Rq) ([]model.Unit, error) {
+	if len(items) == 0 {
+		return nil, octoerr.BadRequest("unitItems must not be empty")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(booking)
+	unitsByID := map[string]model.Unit{}
+	for _, u := range option.Units {
+		unitsByID[u.ID] = u
+	}
+
+	var units []model.Unit
+	counts := map[string]int{}
+	for _, item := range items {
+		unit, ok := unitsByID[item.UnitId]
+		if !ok {
+			return nil, octoerr.InvalidUnitID(item.UnitId)
+		}
+		units = append(units, unit)
+		counts[unit.ID]++
+	}
+
+	for unitID, count := range counts {
+		restrictions := unitsByID[unitID].Restrictions
+		if restrictions.MinQuantity != nil && count < *restrictions.MinQuantity {
+			return nil, octoerr.UnprocessableEntity(fmt.Sprintf("unit %s requires at least %d per booking", unitID, *restrictions.MinQuantity))
+		}
+		if restrictions.MaxQuantity != nil && count > *restrictions.MaxQuantity {
+			return nil, octoerr.UnprocessableEntity(fmt.Sprintf("unit %s allows at most %d per booking", unitID, *restrictions.MaxQuantity))
+		}
+		if len(restrictions.AccompaniedBy) > 0 {
+			accompanied := false
+			for _, companionID := range restrictions.AccompaniedBy {
+				accompanied = accompanied || counts[companionID] > 0
+			}
+			if !accompanied {
+				return nil, octoerr.UnprocessableEntity(fmt.Sprintf("unit %s must be accompanied by one of %s", unitID, strings.Join(restrictions.AccompaniedBy, ", ")))
+			}
+		}
+	}
+	return units, nil
 }
 
 // GetAllBookings godoc
 // @Summary Get all bookings
-// @Description Retrieves all bookings, with the option to filter by pricing mode
+// @Description Retrieves all bookings made with the caller's reseller, or every booking for admin API keys, with prices when the octo/pricing capability is requested
 // @Tags booking
 // @Accept  json
 // @Produce  json
-// @Param   Capability header string false "Capability to filter by pricing mode"
-// @Success 200 {array} model.BookingPayload_Rs_NonPricing "Success - Return all bookings in non-pricing mode"
-// @Success 200 {array} model.Booking "Success - Return all bookings in pricing mode"
-// @Failure 500 {string} string "Internal Server Error"
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Success 200 {array} model.BookingPayload_Rs "Success"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
 // @Router /bookings/all [get]
-func GetAllBookings(w http.ResponseWriter, r *http.Request) {
-
-	capHeader := r.Header.Get("Capability")
-	// Check if pricing mode
-	isExt := (strings.ToLower(capHeader) == "pricing")
-
-	database := store.ConnectToDB()
-	defer database.Close()
+func (s *Server) GetAllBookings(w http.ResponseWriter, r *http.Request) {
 
 	// Get All Booking lists
-	bookings, err := store.GetAllBookings(database)
+	bookings, err := s.repo.GetAllBookings(resellerScope(r))
 	if err != nil {
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-
-	// Prepare Out data according to the mode
-	if !isExt {
-		// non-pricing mode : remove all data related to pricing in output
-		var nonPricingBookings []model.BookingPayload_Rs_NonPricing
-		for _, booking := range bookings {
-			var nonPricingBookingUnits []model.BookingUnitPayload_Rs_NonPricing
-			for _, booking_unit := range booking.Units {
-				nonPricingBookingUnits = append(nonPricingBookingUnits, model.BookingUnitPayload_Rs_NonPricing{
-					ID:        booking_unit.ID,
-					BookingId: booking_unit.BookingId,
-				})
-			}
-
-			nonPricingBookings = append(nonPricingBookings, model.BookingPayload_Rs_NonPricing{
-				ID:             booking.ID,
-				Status:         booking.Status,
-				AvailabilityId: booking.AvailabilityId,
-				Units:          nonPricingBookingUnits,
-			})
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(nonPricingBookings)
+		octoerr.Write(w, err)
 		return
 	}
+	for i := range bookings {
+		if err := s.deliver(&bookings[i]); err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+	}
 
-	// Pricing Mode
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(bookings)
+	if bookings == nil {
+		bookings = []model.BookingPayload_Rs{}
+	}
+	writeOcto(w, r, http.StatusOK, bookings)
 }
 
 // GetBooking godoc
 // @Summary Get a booking by ID
-// @Description Fetches a booking by its ID, with the option to filter by pricing mode
+// @Description Fetches a booking by its ID, with prices when the octo/pricing capability is requested
 // @Tags booking
 // @Accept  json
 // @Produce  json
 // @Param   id path string true "Booking ID"
-// @Param   Capability header string false "Capability to filter by pricing mode"
-// @Success 200 {object} model.BookingPayload_Rs_NonPricing "Success - Return booking in non-pricing mode"
-// @Success 200 {object} model.Booking "Success - Return booking in pricing mode"
-// @Failure 404 {string} string "Booking not found"
-// @Failure 500 {string} string "Internal Server Error"
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Success 200 {object} model.BookingPayload_Rs "Success"
+// @Failure 404 {object} octoerr.Error "Booking not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
 // @Router /bookings/{id} [get]
-func GetBooking(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetBooking(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	// Get booking info with Id
+	booking, err := s.getBooking(r, bookingID)
+	if err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+	if err := s.deliver(booking); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	writeOcto(w, r, http.StatusOK, booking)
+}
 
-	capHeader := r.Header.Get("Capability")
-	// Check if pricing mode
-	isExt := (strings.ToLower(capHeader) == "pricing")
+// ConfirmBooking godoc
+// @Summary Confirm a booking
+// @Description Confirms a booking by its ID, capturing the lead traveller's contact and, optionally, the guest holding each unit
+// @Tags booking
+// @Accept  json
+// @Produce  json
+// @Param   id path string true "Booking ID to confirm"
+// @Param   BookingConfirmationPayload_Rq body model.BookingConfirmationPayload_Rq false "Contacts"
+// @Success 200 {object} model.BookingPayload_Rs "Booking confirmed successfully"
+// @Failure 400 {object} octoerr.Error "Invalid request body or contact"
+// @Failure 404 {object} octoerr.Error "Booking not found"
+// @Failure 409 {object} octoerr.Error "Booking has been cancelled or has expired"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /bookings/{id}/confirm [post]
+func (s *Server) ConfirmBooking(w http.ResponseWriter, r *http.Request) {
 
+	// Get ID from Request URL
 	vars := mux.Vars(r)
 	bookingID := vars["id"]
 
-	database := store.ConnectToDB()
-	defer database.Close()
+	// Contacts are optional, so the body may be empty
+	var confirmation model.BookingConfirmationPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&confirmation); err != nil && err != io.EOF {
+		fmt.Println(err.Error())
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+	if confirmation.Contact != nil {
+		if err := validateContact(*confirmation.Contact); err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+	}
 
-	// Get booking info with Id
-	booking, err := store.GetBookingByID(database, bookingID)
+	// A reservation past its expiry can no longer be confirmed, even before the sweeper releases it
+	booking, err := s.getBooking(r, bookingID)
 	if err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+	if s.holdExpired(booking) {
+		writeBookingError(w, bookingID, store.ErrBookingExpired)
+		return
+	}
+
+	// Match the unit contacts to the booking's units
+	unitContacts := map[string]model.Contact{}
+	for _, item := range confirmation.UnitItems {
+		if !hasUnit(booking, item.ID) {
+			octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("Unit item %s is not part of the booking", item.ID)))
+			return
+		}
+		if err := validateContact(item.Contact); err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		unitContacts[item.ID] = item.Contact
+	}
+
+	// Confirm Booking with id
+	if err := s.repo.ConfirmBooking(bookingID, confirmation.Contact, unitContacts); err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+
+	// Get booking with ID
+	booking, err = s.repo.GetBookingByID(bookingID)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if err := s.deliver(booking); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	writeOcto(w, r, http.StatusOK, booking)
+}
+
+// CancelBooking godoc
+// @Summary Cancel a booking
+// @Description Cancels a booking by its ID and gives its units back to the availability. Cancellation is refused once the product's cancellation cutoff before the availability has passed. Cancelling a cancelled booking returns it unchanged.
+// @Tags booking
+// @Accept  json
+// @Produce  json
+// @Param   id path string true "Booking ID to cancel"
+// @Param   BookingCancellationPayload_Rq body model.BookingCancellationPayload_Rq false "Cancellation reason"
+// @Success 200 {object} model.BookingPayload_Rs "Booking cancelled successfully"
+// @Failure 400 {object} octoerr.Error "Invalid request body or cancellation cutoff passed"
+// @Failure 404 {object} octoerr.Error "Booking not found"
+// @Failure 409 {object} octoerr.Error "Booking has expired"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /bookings/{id}/cancel [post]
+// @Router /bookings/{id} [delete]
+func (s *Server) CancelBooking(w http.ResponseWriter, r *http.Request) {
+
+	// Get ID from Request URL
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	// The reason is optional, and DELETE requests usually carry no body
+	var cancellation model.BookingCancellationPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&cancellation); err != nil && err != io.EOF {
 		fmt.Println(err.Error())
-		http.Error(w, "Booking not found", http.StatusNotFound)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
 		return
 	}
 
-	// Prepare Out data according to the mode
-	if !isExt {
-		// non-pricing mode : remove all data related to pricing in output
+	booking, err := s.getBooking(r, bookingID)
+	if err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
 
-		var nonPricingBookingUnits []model.BookingUnitPayload_Rs_NonPricing
-		for _, booking_unit := range booking.Units {
-			nonPricingBookingUnits = append(nonPricingBookingUnits, model.BookingUnitPayload_Rs_NonPricing{
-				ID:        booking_unit.ID,
-				BookingId: booking_unit.BookingId,
-			})
+	if booking.Status != model.BookingStatusCancelled {
+		// Check the product's cancellation cutoff
+		deadline, err := s.cancellationDeadline(booking.AvailabilityId)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		if s.now().After(deadline) {
+			octoerr.Write(w, octoerr.UnprocessableEntity("Cancellation cutoff has passed"))
+			return
 		}
 
-		nonPricingBooking := model.BookingPayload_Rs_NonPricing{
-			ID:             booking.ID,
-			Status:         booking.Status,
-			AvailabilityId: booking.AvailabilityId,
-			Units:          nonPricingBookingUnits,
+		if err := s.repo.CancelBooking(bookingID, cancellation.Reason); err != nil {
+			writeBookingError(w, bookingID, err)
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(nonPricingBooking)
+		if booking, err = s.repo.GetBookingByID(bookingID); err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+	}
+	if err := s.deliver(booking); err != nil {
+		octoerr.Write(w, err)
 		return
 	}
 
-	// Pricing Mode
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(booking)
+	writeOcto(w, r, http.StatusOK, booking)
 }
 
-// ConfirmBooking godoc
-// @Summary Confirm a booking
-// @Description Confirms a booking by its ID
+// ExtendBooking godoc
+// @Summary Extend a reservation
+// @Description Pushes the expiry of a RESERVED booking to expirationMinutes from now (default 30)
 // @Tags booking
 // @Accept  json
 // @Produce  json
-// @Param   id path string true "Booking ID to confirm"
-// @Success 200 {string} string "Booking confirmed successfully"
-// @Failure 404 {string} string "Booking not found after confirmation"
-// @Failure 500 {string} string "Internal Server Error"
-// @Router /bookings/confirm/{id} [put]
-func ConfirmBooking(w http.ResponseWriter, r *http.Request) {
+// @Param   id path string true "Booking ID to extend"
+// @Param   BookingExtendPayload_Rq body model.BookingExtendPayload_Rq false "New hold time"
+// @Success 200 {object} model.BookingPayload_Rs "Reservation extended successfully"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 404 {object} octoerr.Error "Booking not found"
+// @Failure 409 {object} octoerr.Error "Booking is not reserved or has expired"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /bookings/{id}/extend [patch]
+func (s *Server) ExtendBooking(w http.ResponseWriter, r *http.Request) {
 
 	// Get ID from Request URL
 	vars := mux.Vars(r)
 	bookingID := vars["id"]
 
-	database := store.ConnectToDB()
-	defer database.Close()
-
-	// Confirm Booking with id
-	if err := store.ConfirmBooking(database, bookingID); err != nil {
+	var extension model.BookingExtendPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&extension); err != nil && err != io.EOF {
 		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+	if extension.ExpirationMinutes < 0 {
+		octoerr.Write(w, octoerr.BadRequest("expirationMinutes must not be negative"))
 		return
 	}
 
-	// Get booking with ID
-	booking, err := store.GetBookingByID(database, bookingID)
+	booking, err := s.getBooking(r, bookingID)
 	if err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+	if s.holdExpired(booking) {
+		writeBookingError(w, bookingID, store.ErrBookingExpired)
+		return
+	}
+
+	expiry := s.reservationExpiry
+	if extension.ExpirationMinutes > 0 {
+		expiry = time.Duration(extension.ExpirationMinutes) * time.Minute
+	}
+	if err := s.repo.ExtendBooking(bookingID, s.now().Add(expiry).UTC()); err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+
+	if booking, err = s.repo.GetBookingByID(bookingID); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if err := s.deliver(booking); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	writeOcto(w, r, http.StatusOK, booking)
+}
+
+// UpdateBooking godoc
+// @Summary Update a booking
+// @Description Amends a booking: moves it to another availabilityId of the same product, changes its option or unitItems, or sets its notes or lead contact. Changing the availability, option or units re-prices the booking and moves its vacancies. RESERVED bookings can be updated until they expire, CONFIRMED ones until the product's cancellation cutoff.
+// @Tags booking
+// @Accept  json
+// @Produce  json
+// @Param   id path string true "Booking ID to update"
+// @Param   BookingUpdatePayload_Rq body model.BookingUpdatePayload_Rq true "Fields to change"
+// @Success 200 {object} model.BookingPayload_Rs "Booking updated successfully"
+// @Failure 400 {object} octoerr.Error "Invalid request body or amendment cutoff passed"
+// @Failure 404 {object} octoerr.Error "Booking not found"
+// @Failure 409 {object} octoerr.Error "Booking is cancelled or expired, the availability is closed or has too few vacancies, or a redeemed unit would be moved or dropped"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /bookings/{id} [patch]
+func (s *Server) UpdateBooking(w http.ResponseWriter, r *http.Request) {
+
+	// Get ID from Request URL
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	var update model.BookingUpdatePayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
 		fmt.Println(err.Error())
-		http.Error(w, "Booking not found after confirmation", http.StatusNotFound)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+
+	current, err := s.getBooking(r, bookingID)
+	if err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+	switch {
+	case s.holdExpired(current):
+		writeBookingError(w, bookingID, store.ErrBookingExpired)
+		return
+	case current.Status == model.BookingStatusCancelled:
+		writeBookingError(w, bookingID, store.ErrBookingCancelled)
+		return
+	case current.Status == model.BookingStatusExpired:
+		writeBookingError(w, bookingID, store.ErrBookingExpired)
+		return
+	}
+
+	availabilityID := current.AvailabilityId
+	if update.AvailabilityId != "" {
+		availabilityID = update.AvailabilityId
+	}
+	availability, err := s.repo.GetAvailabilityByID(availabilityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.InvalidAvailabilityID(availabilityID))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	// Confirmed bookings can be amended until the cancellation cutoff of both
+	// the slot they leave and the slot they move to. The store enforces it
+	// against the status it locks, so a booking confirmed meanwhile is covered.
+	var amendableUntil time.Time
+	for i, id := range []string{current.AvailabilityId, availabilityID} {
+		deadline, err := s.cancellationDeadline(id)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		if i == 0 || deadline.Before(amendableUntil) {
+			amendableUntil = deadline
+		}
+	}
+
+	booking := model.Booking{
+		ID:             current.ID,
+		AvailabilityId: current.AvailabilityId,
+		OptionId:       current.OptionId,
+		Units:          len(current.Units),
+		Price:          current.Price,
+		Currency:       current.Currency,
+		Notes:          current.Notes,
+		Contact:        current.Contact,
+		AmendableUntil: &amendableUntil,
+	}
+	if update.Notes != nil {
+		booking.Notes = update.Notes
+	}
+	if update.Contact != nil {
+		if err := validateContact(*update.Contact); err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		booking.Contact = *update.Contact
+	}
+	for _, unit := range current.Units {
+		kept := model.BookingUnit{ID: unit.ID, BookingId: unit.BookingId, UnitId: unit.UnitId, Pricing: unit.Pricing, Contact: unit.Contact}
+		if unit.Ticket != nil {
+			kept.UtcRedeemedAt = unit.Ticket.UtcRedeemedAt
+		}
+		booking.UnitItems = append(booking.UnitItems, kept)
+	}
+
+	// Re-price the booking when what was booked changes
+	if update.AvailabilityId != "" || update.OptionId != "" || update.UnitItems != nil {
+		product, err := s.repo.GetProduct(availability.ProductId)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		currentAvailability, err := s.repo.GetAvailabilityByID(current.AvailabilityId)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		if currentAvailability.ProductId != availability.ProductId {
+			octoerr.Write(w, octoerr.InvalidAvailabilityID(availabilityID))
+			return
+		}
+
+		optionID := current.OptionId
+		if update.OptionId != "" {
+			optionID = update.OptionId
+		}
+		option, err := findOption(product, optionID)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		items := update.UnitItems
+		if items == nil {
+			for _, unit := range current.Units {
+				items = append(items, model.UnitItemPayload_Rq{UnitId: unit.UnitId})
+			}
+		}
+		units, err := resolveUnitItems(option, items)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		prices, total, err := priceUnits(s.rates, availability, units)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+
+		booking.AvailabilityId = availabilityID
+		booking.OptionId = option.ID
+		booking.Units = len(units)
+		booking.Price = total.Amount
+		booking.Currency = total.Currency
+		booking.UnitItems = matchUnits(booking.ID, booking.UnitItems, units, prices)
+	}
+
+	if err := s.repo.UpdateBooking(booking, s.now()); err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+
+	updated, err := s.repo.GetBookingByID(bookingID)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if err := s.deliver(updated); err != nil {
+		octoerr.Write(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(booking)
+	writeOcto(w, r, http.StatusOK, updated)
+}
+
+// matchUnits prices the units of an amended booking. Each requested unit
+// takes over a current unit of the same type, keeping its ID, and with it its
+// ticket and guest contact; the rest are new units. Redeemed units are taken
+// over first, so dropping a unit drops one that has not been checked in.
+func matchUnits(bookingID string, current []model.BookingUnit, units []model.Unit, prices []money.Money) []model.BookingUnit {
+	byType := map[string][]model.BookingUnit{}
+	for _, unit := range current {
+		if unit.UtcRedeemedAt != nil {
+			byType[unit.UnitId] = append([]model.BookingUnit{unit}, byType[unit.UnitId]...)
+		} else {
+			byType[unit.UnitId] = append(byType[unit.UnitId], unit)
+		}
+	}
+
+	matched := make([]model.BookingUnit, 0, len(units))
+	for i, unit := range units {
+		item := model.BookingUnit{ID: uuid.NewString(), BookingId: bookingID, UnitId: unit.ID}
+		if same := byType[unit.ID]; len(same) > 0 {
+			item, byType[unit.ID] = same[0], same[1:]
+		}
+		item.Pricing = unitPricing(prices[i])
+		matched = append(matched, item)
+	}
+	return matched
+}
+
+// validateContact checks the fields of an OCTO contact that have a format.
+func validateContact(contact model.Contact) error {
+	if contact.EmailAddress != "" {
+		if _, err := mail.ParseAddress(contact.EmailAddress); err != nil {
+			return octoerr.BadRequest(fmt.Sprintf("Invalid emailAddress %q", contact.EmailAddress))
+		}
+	}
+	if contact.Country != "" && len(contact.Country) != 2 {
+		return octoerr.BadRequest(fmt.Sprintf("country %q must be an ISO 3166-1 alpha-2 code", contact.Country))
+	}
+	return nil
+}
+
+// hasUnit reports whether the booking has a unit item with the given ID.
+func hasUnit(booking *model.BookingPayload_Rs, unitItemID string) bool {
+	for _, unit := range booking.Units {
+		if unit.ID == unitItemID {
+			return true
+		}
+	}
+	return false
+}
+
+// holdExpired reports whether a reservation is past its expiry. The sweeper
+// may not have released it yet.
+func (s *Server) holdExpired(booking *model.BookingPayload_Rs) bool {
+	return booking.Status == model.BookingStatusReserved && booking.UtcExpiresAt != nil && !s.now().Before(*booking.UtcExpiresAt)
+}
+
+// getBooking retrieves a booking the caller may see. Other resellers'
+// bookings are reported as sql.ErrNoRows so their IDs cannot be probed.
+func (s *Server) getBooking(r *http.Request, bookingID string) (*model.BookingPayload_Rs, error) {
+	booking, err := s.repo.GetBookingByID(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible(r, booking) {
+		return nil, sql.ErrNoRows
+	}
+	return booking, nil
+}
+
+// writeBookingError responds to a failed booking lookup or state change.
+func writeBookingError(w http.ResponseWriter, bookingID string, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		octoerr.Write(w, octoerr.InvalidBookingUUID(bookingID).WithStatus(http.StatusNotFound))
+	case errors.Is(err, store.ErrAmendmentCutoff):
+		octoerr.Write(w, octoerr.UnprocessableEntity("Amendment cutoff has passed"))
+	case errors.Is(err, store.ErrBookingCancelled), errors.Is(err, store.ErrBookingExpired), errors.Is(err, store.ErrBookingNotReserved),
+		errors.Is(err, store.ErrInsufficientVacancies), errors.Is(err, store.ErrAvailabilityClosed),
+		errors.Is(err, store.ErrBookingNotConfirmed), errors.Is(err, store.ErrTicketRedeemed):
+		octoerr.Write(w, octoerr.UnprocessableEntity(err.Error()).WithStatus(http.StatusConflict))
+	default:
+		octoerr.Write(w, err)
+	}
+}
+
+// cancellationDeadline returns the last moment a booking on the availability
+// may be cancelled: the start of the availability less the product's
+// cancellation cutoff.
+func (s *Server) cancellationDeadline(availabilityID string) (time.Time, error) {
+	availability, err := s.repo.GetAvailabilityByID(availabilityID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	product, err := s.repo.GetProduct(availability.ProductId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cutoff, err := cutoffDuration(product.CancellationCutoffAmount, product.CancellationCutoffUnit)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return availability.LocalDateTimeStart.Add(-cutoff), nil
+}
+
+// cutoffDuration converts an OCTO cutoff amount and unit into a duration.
+func cutoffDuration(amount int, unit string) (time.Duration, error) {
+	if amount < 0 {
+		return 0, fmt.Errorf("cancellationCutoffAmount must not be negative")
+	}
+	switch unit {
+	case model.CutoffUnitMinute:
+		return time.Duration(amount) * time.Minute, nil
+	case model.CutoffUnitHour, "":
+		return time.Duration(amount) * time.Hour, nil
+	case model.CutoffUnitDay:
+		return time.Duration(amount) * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid cancellationCutoffUnit %q", unit)
 }
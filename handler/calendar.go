@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"octo-api/helper"
+	"octo-api/model"
+	"octo-api/money"
+	"octo-api/octoerr"
+)
+
+// GetAvailabilityCalendar godoc
+// @Summary Get the availability calendar
+// @Description Summarises the availability of a product option per day between two local dates, e.g. for a month view. When units are given, a day is only available if one of its timeslots has room for all of them.
+// @Tags availability
+// @Accept  json
+// @Produce  json
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Param   AvailabilityCalendarPayload_Rq body model.AvailabilityCalendarPayload_Rq true "Request Payload"
+// @Success 200 {object} []model.AvailabilityCalendarPayload_Rs_Pricing "Success"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /availability/calendar [post]
+func (s *Server) GetAvailabilityCalendar(w http.ResponseWriter, r *http.Request) {
+
+	var req model.AvailabilityCalendarPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println(err.Error())
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+
+	startDate, endDate, err := s.parseLocalDates("", req.LocalDateStart, req.LocalDateEnd)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	product, err := s.repo.GetProduct(req.ProductId)
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.InvalidProductID(req.ProductId))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	option, err := findOption(product, req.OptionId)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	units, err := resolveUnitQuantities(option, req.Units)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	availabilities, err := s.repo.GetProductAvailabilities(product.ID, startDate, endDate)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	slotsByDate := map[string][]model.Availability{}
+	for _, a := range availabilities {
+		localDate := a.LocalDate.Format("2006-01-02")
+		slotsByDate[localDate] = append(slotsByDate[localDate], a)
+	}
+
+	priced := capable(r, CapabilityPricing)
+	days := []model.AvailabilityCalendarPayload_Rs_Pricing{}
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		localDate := day.Format("2006-01-02")
+		summary, err := summarizeDay(s.rates, slotsByDate[localDate], len(units), product.AvailabilityType)
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+
+		cur := model.AvailabilityCalendarPayload_Rs_Pricing{
+			LocalDate:       localDate,
+			Available:       summary.available,
+			Status:          summary.status,
+			Vacancies:       summary.vacancies,
+			Capacity:        summary.capacity,
+			OpeningHours:    summary.openingHours,
+			UnitPricingFrom: []model.UnitPricing{},
+		}
+		// Prices start from the cheapest timeslot that fits the units
+		if priced && summary.cheapest != nil {
+			cur.UnitPricingFrom, cur.PricingFrom, err = slotPricing(s.rates, option, summary.cheapest, units)
+			if err != nil {
+				octoerr.Write(w, err)
+				return
+			}
+		}
+		days = append(days, cur)
+	}
+
+	writeOcto(w, r, http.StatusOK, days)
+}
+
+// resolveUnitQuantities expands OCTO unit quantities into one unit per
+// person and checks them against the option. No quantities means no units.
+func resolveUnitQuantities(option *model.Option, quantities []model.UnitQuantityPayload_Rq) ([]model.Unit, error) {
+	var items []model.UnitItemPayload_Rq
+	for _, q := range quantities {
+		if q.Quantity < 0 {
+			return nil, octoerr.BadRequest(fmt.Sprintf("quantity of unit %s must not be negative", q.Id))
+		}
+		for i := 0; i < q.Quantity; i++ {
+			items = append(items, model.UnitItemPayload_Rq{UnitId: q.Id})
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return resolveUnitItems(option, items)
+}
+
+// daySummary aggregates the timeslots of one day.
+type daySummary struct {
+	available    bool
+	status       string
+	vacancies    int
+	capacity     int
+	openingHours []model.OpeningHours
+	// cheapest is the lowest priced timeslot with room for the units.
+	cheapest *model.Availability
+}
+
+// statusRank orders the statuses of bookable timeslots, best first.
+var statusRank = map[string]int{
+	model.AvailabilityStatusFreesale:  0,
+	model.AvailabilityStatusAvailable: 1,
+	model.AvailabilityStatusLimited:   2,
+}
+
+// summarizeDay aggregates a day's timeslots for a booking of the given number
+// of units. The day takes the best status among the timeslots with room for
+// them, SOLD_OUT if none has room, or CLOSED if every timeslot is closed.
+// Timeslots priced in different currencies are compared using rates.
+func summarizeDay(rates helper.RateProvider, slots []model.Availability, units int, availabilityType string) (daySummary, error) {
+	summary := daySummary{status: model.AvailabilityStatusClosed, openingHours: []model.OpeningHours{}}
+
+	for i, a := range slots {
+		if a.Status == model.AvailabilityStatusClosed {
+			continue
+		}
+		if summary.status == model.AvailabilityStatusClosed {
+			summary.status = model.AvailabilityStatusSoldOut
+		}
+		summary.capacity += a.Capacity
+		if a.Status != model.AvailabilityStatusFreesale {
+			summary.vacancies += a.Vacancies
+		}
+		if isOpeningHours(availabilityType) {
+			summary.openingHours = append(summary.openingHours, openingHours(a))
+		}
+
+		if !fitsUnits(a, units) {
+			continue
+		}
+		if !summary.available || statusRank[a.Status] < statusRank[summary.status] {
+			summary.status = a.Status
+		}
+		summary.available = true
+		if summary.cheapest == nil {
+			summary.cheapest = &slots[i]
+			continue
+		}
+		lower, err := cheaper(rates, &slots[i], summary.cheapest)
+		if err != nil {
+			return daySummary{}, err
+		}
+		if lower {
+			summary.cheapest = &slots[i]
+		}
+	}
+	return summary, nil
+}
+
+// cheaper reports whether timeslot a costs less than timeslot b, comparing
+// their prices in b's currency.
+func cheaper(rates helper.RateProvider, a, b *model.Availability) (bool, error) {
+	price, err := toCurrency(rates, money.New(a.Price, a.Currency), b.Currency)
+	if err != nil {
+		return false, err
+	}
+	return price.Amount < b.Price, nil
+}
+
+// fitsUnits reports whether a timeslot can take a booking of the given number
+// of units; any open timeslot can take none. FREESALE timeslots take any number.
+func fitsUnits(a model.Availability, units int) bool {
+	if _, open := statusRank[a.Status]; !open {
+		return false
+	}
+	return a.Status == model.AvailabilityStatusFreesale || (a.Vacancies >= units && a.Vacancies > 0)
+}
+
+// isOpeningHours reports whether a product availability type has opening
+// hours rather than start times.
+func isOpeningHours(availabilityType string) bool {
+	return availabilityType == model.AvailabilityTypeOpeningHours || availabilityType == ""
+}
+
+// openingHours returns the local opening hours of a timeslot. A timeslot that
+// runs to midnight closes at 24:00.
+func openingHours(a model.Availability) model.OpeningHours {
+	to := a.LocalDateTimeEnd.Format("15:04")
+	if to == "00:00" && a.LocalDateTimeEnd.After(a.LocalDateTimeStart) {
+		to = "24:00"
+	}
+	return model.OpeningHours{From: a.LocalDateTimeStart.Format("15:04"), To: to}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"octo-api/helper"
+	"octo-api/model"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeDayCheapestAcrossCurrencies(t *testing.T) {
+	rates, err := helper.ParseRatesJSON(strings.NewReader(`{"base": "USD", "rates": {"EUR": 0.5}}`))
+	if err != nil {
+		t.Fatalf("error was not expected while parsing rates: %s", err)
+	}
+	slots := []model.Availability{
+		{ID: "eur", Status: model.AvailabilityStatusAvailable, Vacancies: 5, Price: 1500, Currency: "EUR"},
+		{ID: "usd", Status: model.AvailabilityStatusAvailable, Vacancies: 5, Price: 2000, Currency: "USD"},
+	}
+
+	// 15.00 EUR is 30.00 USD, so the USD timeslot is cheaper despite the
+	// larger amount
+	summary, err := summarizeDay(rates, slots, 1, model.AvailabilityTypeStartTime)
+	if err != nil {
+		t.Fatalf("error was not expected while summarizing day: %s", err)
+	}
+	if summary.cheapest == nil || summary.cheapest.ID != "usd" {
+		t.Errorf("expected the USD timeslot to be cheapest, got %+v", summary.cheapest)
+	}
+
+	// Without a provider mixed currencies cannot be compared
+	if _, err := summarizeDay(nil, slots, 1, model.AvailabilityTypeStartTime); err == nil {
+		t.Error("expected an error without an exchange-rate provider")
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"octo-api/octoerr"
+	"strings"
+)
+
+// OctoCapabilitiesHeader lists the OCTO capabilities a request opts in to,
+// comma separated, e.g. "octo/pricing, octo/content". Responses list the
+// ones that were applied.
+const OctoCapabilitiesHeader = "Octo-Capabilities"
+
+// CapabilityPricing adds prices to products, availability and bookings.
+const CapabilityPricing = "octo/pricing"
+
+// Capability is an OCTO capability responses can be negotiated with.
+type Capability struct {
+	ID string
+
+	// Fields are the response fields the capability adds. They are stripped,
+	// at any depth, from responses to requests that do not ask for it.
+	Fields []string
+
+	// Enrich, if set, adds to the decoded response body of requests that ask
+	// for the capability.
+	Enrich func(r *http.Request, body interface{})
+}
+
+// capabilities is the registry of capabilities this API supports, by ID.
+var capabilities = map[string]Capability{}
+
+// RegisterCapability adds a capability to the registry, replacing any with
+// the same ID.
+func RegisterCapability(c Capability) {
+	capabilities[strings.ToLower(c.ID)] = c
+}
+
+func init() {
+	RegisterCapability(Capability{
+		ID:     CapabilityPricing,
+		Fields: []string{"price", "currency", "pricing", "pricingFrom", "unitPricing", "unitPricingFrom"},
+	})
+}
+
+// negotiate returns the IDs of the supported capabilities the request asks
+// for, in the order asked. Unknown capabilities are ignored.
+func negotiate(r *http.Request) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, header := range r.Header.Values(OctoCapabilitiesHeader) {
+		for _, id := range strings.Split(header, ",") {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if _, ok := capabilities[id]; ok && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// capable reports whether the request asks for the capability, so handlers
+// can skip work whose fields would be stripped.
+func capable(r *http.Request, id string) bool {
+	for _, c := range negotiate(r) {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOcto sends v as a JSON response shaped by the request's capabilities:
+// fields of capabilities it did not ask for are stripped, the ones it asked
+// for may enrich the body, and the Octo-Capabilities header lists them.
+func writeOcto(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	applied := negotiate(r)
+	body, err := shape(r, v, applied)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(applied) > 0 {
+		w.Header().Set(OctoCapabilitiesHeader, strings.Join(applied, ", "))
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// shape round-trips v through JSON so capabilities can work on its fields
+// generically.
+func shape(r *http.Request, v interface{}, applied []string) (interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	// Fields of an applied capability are kept even if another one lists them
+	kept := map[string]bool{}
+	for _, id := range applied {
+		for _, field := range capabilities[id].Fields {
+			kept[field] = true
+		}
+	}
+	strip := map[string]bool{}
+	for id, c := range capabilities {
+		if contains(applied, id) {
+			continue
+		}
+		for _, field := range c.Fields {
+			if !kept[field] {
+				strip[field] = true
+			}
+		}
+	}
+	stripFields(body, strip)
+
+	for _, id := range applied {
+		if enrich := capabilities[id].Enrich; enrich != nil {
+			enrich(r, body)
+		}
+	}
+	return body, nil
+}
+
+// stripFields deletes the given fields from every object in a decoded JSON
+// value.
+func stripFields(value interface{}, fields map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field := range fields {
+			delete(v, field)
+		}
+		for _, child := range v {
+			stripFields(child, fields)
+		}
+	case []interface{}:
+		for _, child := range v {
+			stripFields(child, fields)
+		}
+	}
+}
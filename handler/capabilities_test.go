@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"strings"
+	"testing"
+)
+
+func TestServerOctoCapabilities(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	// Without octo/pricing every price is stripped, however deep
+	rec := doRequest(t, h, "GET", "/products/product_id", nil, nil)
+	if rec.Header().Get(OctoCapabilitiesHeader) != "" {
+		t.Errorf("expected no capabilities to be applied, got %q", rec.Header().Get(OctoCapabilitiesHeader))
+	}
+	if body := rec.Body.String(); strings.Contains(body, `"price"`) || strings.Contains(body, `"currency"`) || !strings.Contains(body, `"units"`) {
+		t.Errorf("expected a product without prices, got %s", body)
+	}
+
+	// Unknown capabilities are ignored and names are case-insensitive
+	rec = doRequest(t, h, "GET", "/products/product_id", nil, map[string]string{OctoCapabilitiesHeader: "octo/content, OCTO/Pricing ,octo/pickups"})
+	if got := rec.Header().Get(OctoCapabilitiesHeader); got != CapabilityPricing {
+		t.Errorf("expected %q to be applied, got %q", CapabilityPricing, got)
+	}
+	var product model.ProductPayload_Rs_Pricing
+	decodeBody(t, rec, &product)
+	if product.Price != 5000 || product.Currency != "USD" || product.Options[0].Units[1].Price != 2500 {
+		t.Errorf("expected a priced product, got %+v", product)
+	}
+
+	calendar := model.AvailabilityCalendarPayload_Rq{ProductId: "product_id", LocalDateStart: "2024-03-01", LocalDateEnd: "2024-03-01"}
+	rec = doRequest(t, h, "POST", "/availability/calendar", calendar, nil)
+	if body := rec.Body.String(); rec.Code != http.StatusOK || strings.Contains(body, "unitPricingFrom") || !strings.Contains(body, `"vacancies"`) {
+		t.Errorf("expected a calendar without prices, got %d: %s", rec.Code, body)
+	}
+
+	// A retry must ask for the same capabilities as the response it replays
+	key := map[string]string{IdempotencyKeyHeader: "book-once", OctoCapabilitiesHeader: CapabilityPricing}
+	booking := model.BookingPayload_Rq{ProductId: "product_id", AvailabilityId: product.Id, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}
+	doRequest(t, h, "POST", "/bookings", booking, key)
+	rec = doRequest(t, h, "POST", "/bookings", booking, map[string]string{IdempotencyKeyHeader: "book-once"})
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+}
+
+func TestWriteOctoCustomCapability(t *testing.T) {
+	RegisterCapability(Capability{
+		ID:     "octo/content",
+		Fields: []string{"description"},
+		Enrich: func(r *http.Request, body interface{}) {
+			body.(map[string]interface{})["language"] = r.Header.Get("Accept-Language")
+		},
+	})
+	defer delete(capabilities, "octo/content")
+
+	v := map[string]interface{}{"id": "product_id", "description": "Sunset cruise", "price": 5000}
+	for _, tt := range []struct {
+		header string
+		want   string
+	}{
+		{"", `{"id":"product_id"}`},
+		{"octo/content", `{"description":"Sunset cruise","id":"product_id","language":"en"}`},
+		{"octo/content, octo/pricing", `{"description":"Sunset cruise","id":"product_id","language":"en","price":5000}`},
+	} {
+		r := httptest.NewRequest("GET", "/products/product_id", nil)
+		r.Header.Set(OctoCapabilitiesHeader, tt.header)
+		r.Header.Set("Accept-Language", "en")
+		rec := httptest.NewRecorder()
+		writeOcto(rec, r, http.StatusOK, v)
+
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		got, _ := json.Marshal(body)
+		if string(got) != tt.want {
+			t.Errorf("with %q expected %s, got %s", tt.header, tt.want, got)
+		}
+		if applied := rec.Header().Get(OctoCapabilitiesHeader); applied != tt.header {
+			t.Errorf("expected %q to be applied, got %q", tt.header, applied)
+		}
+	}
+}
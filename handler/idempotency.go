@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"strings"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header that makes a mutating request
+// safe to retry: the first response sent under a key is replayed for every
+// later request with the same key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from an earlier
+// request with the same Idempotency-Key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyClaimTimeout is how long a request may hold its Idempotency-Key
+// without finishing. A retry after that takes the key over, so a request that
+// died mid-way does not block its key forever.
+const IdempotencyClaimTimeout = 5 * time.Minute
+
+// idempotent replays the recorded response of POST, PUT, PATCH and DELETE
+// requests that repeat an Idempotency-Key. Keys are scoped to the caller's API
+// key, method and path, and reusing one with a different body or
+// Octo-Capabilities, which shape the recorded response, is a conflict. Server errors
+// and panics are not recorded so the request can be retried.
+func (s *Server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(strings.Join(negotiate(r), ",")+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		scoped := r.Method + " " + r.URL.Path + " " + key
+		if c := caller(r); c != nil {
+			scoped = c.KeyHash + " " + scoped
+		}
+		now := s.now().UTC()
+		prior, err := s.repo.ClaimIdempotencyKey(scoped, requestHash, now, now.Add(-IdempotencyClaimTimeout))
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		if prior != nil {
+			replayIdempotent(w, prior.RequestHash == requestHash, prior)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if p := recover(); p != nil {
+				// Free the key before the panic goes on, so a retry is not
+				// refused as still in progress
+				if err := s.repo.ReleaseIdempotencyKey(scoped); err != nil {
+					fmt.Println(err.Error())
+				}
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			err = s.repo.ReleaseIdempotencyKey(scoped)
+		} else {
+			err = s.repo.SaveIdempotentResponse(scoped, rec.status, rec.header, rec.body.Bytes())
+		}
+		if err != nil {
+			// The response is already sent, so only log it
+			fmt.Println(err.Error())
+		}
+	})
+}
+
+// replayIdempotent answers a request whose Idempotency-Key was seen before
+// with the response recorded for it.
+func replayIdempotent(w http.ResponseWriter, sameRequest bool, prior *model.IdempotentResponse) {
+	if !sameRequest {
+		octoerr.Write(w, octoerr.UnprocessableEntity("Idempotency-Key was already used for a different request").WithStatus(http.StatusConflict))
+		return
+	}
+	if prior.Status == 0 {
+		octoerr.Write(w, octoerr.UnprocessableEntity("A request with this Idempotency-Key is still in progress").WithStatus(http.StatusConflict))
+		return
+	}
+
+	for k, v := range prior.Header {
+		w.Header()[k] = v
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(prior.Status)
+	w.Write(prior.Body)
+}
+
+// isMutating reports whether requests with the given method change state.
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status, headers and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.header = r.Header().Clone()
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.header == nil {
+		r.header = r.Header().Clone()
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"fmt"
+	"octo-api/helper"
+	"octo-api/model"
+	"octo-api/money"
+	"strings"
+)
+
+// priceUnits prices each requested unit for the given availability. A unit
+// costs its own price plus the availability price.
+//
+// LOGIC : If the units and the availability are not all priced in the same
+// currency, the booking is priced in USD. Else, it continues with the same
+// currency.
+func priceUnits(rates helper.RateProvider, availability *model.Availability, units []model.Unit) ([]money.Money, money.Money, error) {
+	currency := strings.ToUpper(availability.Currency)
+	for _, unit := range units {
+		if !strings.EqualFold(unit.Currency, availability.Currency) {
+			// Set USD as currency unit for booking
+			currency = "USD"
+			break
+		}
+	}
+
+	availabilityPrice, err := toCurrency(rates, money.New(availability.Price, availability.Currency), currency)
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+
+	prices := make([]money.Money, 0, len(units))
+	unitPrices := map[string]money.Money{}
+	for _, unit := range units {
+		price, ok := unitPrices[unit.ID]
+		if !ok {
+			// Make conversion once per unit type
+			converted, err := toCurrency(rates, money.New(unit.Price, unit.Currency), currency)
+			if err != nil {
+				return nil, money.Money{}, err
+			}
+			if price, err = converted.Add(availabilityPrice); err != nil {
+				return nil, money.Money{}, err
+			}
+			unitPrices[unit.ID] = price
+		}
+		prices = append(prices, price)
+	}
+
+	total, err := money.Sum(currency, prices...)
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+	return prices, total, nil
+}
+
+// slotPricing prices a timeslot for each unit of the option on its own and,
+// when units are requested, for all of them together.
+func slotPricing(rates helper.RateProvider, option *model.Option, availability *model.Availability, units []model.Unit) ([]model.UnitPricing, *model.Pricing, error) {
+	perUnit := make([]model.UnitPricing, 0, len(option.Units))
+	for _, unit := range option.Units {
+		_, total, err := priceUnits(rates, availability, []model.Unit{unit})
+		if err != nil {
+			return nil, nil, err
+		}
+		perUnit = append(perUnit, model.UnitPricing{UnitId: unit.ID, Pricing: unitPricing(total)})
+	}
+	if len(units) == 0 {
+		return perUnit, nil, nil
+	}
+
+	_, total, err := priceUnits(rates, availability, units)
+	if err != nil {
+		return nil, nil, err
+	}
+	pricing := unitPricing(total)
+	return perUnit, &pricing, nil
+}
+
+// toCurrency converts m into currency to, skipping the exchange-rate lookup
+// when it is already in that currency or is zero.
+func toCurrency(rates helper.RateProvider, m money.Money, to string) (money.Money, error) {
+	to = strings.ToUpper(to)
+	if m.Currency == to {
+		return m, nil
+	}
+	if m.IsZero() {
+		return money.New(0, to), nil
+	}
+
+	if rates == nil {
+		return money.Money{}, fmt.Errorf("no exchange-rate provider configured for %s/%s", m.Currency, to)
+	}
+	rate, err := rates.Rate(m.Currency, to)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return m.Convert(rate, to), nil
+}
+
+// unitPricing builds the OCTO pricing object for a single booking unit.
+func unitPricing(price money.Money) model.Pricing {
+	return model.Pricing{
+		Original:          price.Amount,
+		Retail:            price.Amount,
+		Net:               price.Amount,
+		Currency:          price.Currency,
+		CurrencyPrecision: price.Precision(),
+		IncludedTaxes:     []model.Tax{},
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"octo-api/helper"
+	"octo-api/model"
+	"octo-api/money"
+	"strings"
+	"testing"
+)
+
+func TestPriceUnits(t *testing.T) {
+	availability := &model.Availability{Price: 1000, Currency: "usd"}
+	adult := model.Unit{ID: "adult_id", Price: 5000, Currency: "USD"}
+	child := model.Unit{ID: "child_id", Price: 2499, Currency: "USD"}
+
+	prices, total, err := priceUnits(nil, availability, []model.Unit{adult, adult, child})
+	if err != nil {
+		t.Fatalf("error was not expected while pricing units: %s", err)
+	}
+
+	want := []int64{6000, 6000, 3499}
+	var sum int64
+	for i, p := range prices {
+		if p.Amount != want[i] || p.Currency != "USD" {
+			t.Errorf("unit %d priced %v, want %d USD", i, p, want[i])
+		}
+		sum += p.Amount
+	}
+	if total.Amount != 15499 || total.Amount != sum || total.Currency != "USD" {
+		t.Errorf("total = %v, want 154.99 USD matching the unit sum %d", total, sum)
+	}
+}
+
+func TestUnitPricing(t *testing.T) {
+	pricing := unitPricing(money.New(1512, "JPY"))
+	if pricing.Retail != 1512 || pricing.Original != 1512 || pricing.Net != 1512 {
+		t.Errorf("unexpected amounts %+v", pricing)
+	}
+	if pricing.Currency != "JPY" || pricing.CurrencyPrecision != 0 || pricing.IncludedTaxes == nil {
+		t.Errorf("unexpected pricing %+v", pricing)
+	}
+}
+
+func TestPriceUnitsMixedCurrency(t *testing.T) {
+	rates, err := helper.ParseRatesJSON(strings.NewReader(`{"base": "USD", "rates": {"EUR": 0.5}}`))
+	if err != nil {
+		t.Fatalf("error was not expected while parsing rates: %s", err)
+	}
+	availability := &model.Availability{Price: 1000, Currency: "USD"}
+	adult := model.Unit{ID: "adult_id", Price: 2000, Currency: "EUR"}
+
+	prices, total, err := priceUnits(rates, availability, []model.Unit{adult, adult})
+	if err != nil {
+		t.Fatalf("error was not expected while pricing units: %s", err)
+	}
+	if prices[0].Amount != 5000 || total.Amount != 10000 || total.Currency != "USD" {
+		t.Errorf("prices = %v, total = %v, want 50.00 USD each and 100.00 USD in total", prices, total)
+	}
+
+	// Without a provider mixed currencies cannot be priced
+	if _, _, err := priceUnits(nil, availability, []model.Unit{adult}); err == nil {
+		t.Error("expected an error without an exchange-rate provider")
+	}
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"octo-api/model"
-	"octo-api/store"
+	"octo-api/octoerr"
 	"strings"
 
 	"github.com/google/uuid"
@@ -14,114 +16,77 @@ import (
 
 // GetProducts godoc
 // @Summary Get all products
-// @Description Retrieves all products, with the option to filter by pricing mode
+// @Description Retrieves all products, with prices when the octo/pricing capability is requested
 // @Tags product
 // @Accept  json
 // @Produce  json
-// @Param   Capability header string false "Capability to filter by pricing mode"
-// @Success 200 {array} model.ProductPayload_Rs_NonPricing "Success - Return all products in non-pricing mode"
-// @Success 200 {array} model.Product "Success - Return all products in pricing mode"
-// @Failure 500 {string} string "Internal Server Error"
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Success 200 {array} model.ProductPayload_Rs_Pricing "Success"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
 // @Router /products [get]
-func GetProducts(w http.ResponseWriter, r *http.Request) {
-	capHeader := r.Header.Get("Capability")
-	// Check if pricing mode
-	isExt := (strings.ToLower(capHeader) == "pricing")
-
-	database := store.ConnectToDB()
-	defer database.Close()
+func (s *Server) GetProducts(w http.ResponseWriter, r *http.Request) {
 
 	// Get the Whole Product Data from DB
-	products, err := store.GetProductsFromDB(database)
+	products, err := s.repo.GetProducts()
 	if err != nil {
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		octoerr.Write(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	// Prepare Output data according to mode
-	if isExt { // pricing mode
-		var productsOutputs []model.ProductPayload_Rs_Pricing
-		for _, product := range products {
-			productsOutputs = append(productsOutputs, model.ProductPayload_Rs_Pricing{
-				Id:       product.ID,
-				Name:     product.Name,
-				Capacity: product.Capacity,
-				Price:    product.Price,
-				Currency: product.Currency,
-			})
-		}
-		json.NewEncoder(w).Encode(productsOutputs)
-	} else { // Non-Pricing mode
-		var productsOutputs []model.ProductPayload_Rs_NonPricing
-		for _, product := range products {
-			productsOutputs = append(productsOutputs, model.ProductPayload_Rs_NonPricing{
-				Id:       product.ID,
-				Name:     product.Name,
-				Capacity: product.Capacity,
-			})
-		}
-		json.NewEncoder(w).Encode(productsOutputs)
+	productsOutputs := []model.ProductPayload_Rs_Pricing{}
+	for _, product := range products {
+		productsOutputs = append(productsOutputs, productPayload(product))
 	}
+	writeOcto(w, r, http.StatusOK, productsOutputs)
 }
 
 // GetProduct godoc
 // @Summary Get a product by ID
-// @Description Fetches a product by its ID, with the option to filter by pricing mode
+// @Description Fetches a product by its ID, with prices when the octo/pricing capability is requested
 // @Tags product
 // @Accept  json
 // @Produce  json
 // @Param   id path string true "Product ID"
-// @Param   Capability header string false "Capability to filter by pricing mode"
-// @Success 200 {object} model.ProductPayload_Rs_NonPricing "Success - Return product in non-pricing mode"
-// @Success 200 {object} model.Product "Success - Return product in pricing mode"
-// @Failure 404 {string} string "Product not found"
-// @Failure 500 {string} string "Internal Server Error"
+// @Param   Octo-Capabilities header string false "Comma-separated OCTO capabilities, e.g. octo/pricing"
+// @Success 200 {object} model.ProductPayload_Rs_Pricing "Success"
+// @Failure 404 {object} octoerr.Error "Product not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
 // @Router /products/{id} [get]
-func GetProduct(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetProduct(w http.ResponseWriter, r *http.Request) {
 
 	// Get ProductID
 	vars := mux.Vars(r)
 	productId := vars["id"]
 
-	capHeader := r.Header.Get("Capability")
-	// Check if pricing mode
-	isExt := (strings.ToLower(capHeader) == "pricing")
-
-	database := store.ConnectToDB()
-	defer database.Close()
-
 	// Get Product with certain ID
-	product, err := store.GetProductFromDB(database, productId)
+	product, err := s.repo.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.InvalidProductID(productId).WithStatus(http.StatusNotFound))
+		return
+	}
 	if err != nil {
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusNotFound)
+		octoerr.Write(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	// Prepare Output data according to mode
-	if isExt { // Pricing mode
-		outputProduct := model.ProductPayload_Rs_Pricing{
-			Id:       product.ID,
-			Name:     product.Name,
-			Capacity: product.Capacity,
-			Price:    product.Price,
-			Currency: product.Currency,
-		}
-		json.NewEncoder(w).Encode(outputProduct)
-	} else { // Non-Pricing mode
-		outputProduct := model.ProductPayload_Rs_NonPricing{
-			Id:       product.ID,
-			Name:     product.Name,
-			Capacity: product.Capacity,
-		}
-		json.NewEncoder(w).Encode(outputProduct)
+	writeOcto(w, r, http.StatusOK, productPayload(*product))
+}
+
+// productPayload returns the response shape of a product.
+func productPayload(product model.Product) model.ProductPayload_Rs_Pricing {
+	return model.ProductPayload_Rs_Pricing{
+		Id:                       product.ID,
+		SupplierId:               product.SupplierId,
+		Name:                     product.Name,
+		Capacity:                 product.Capacity,
+		Price:                    product.Price,
+		Currency:                 product.Currency,
+		CancellationCutoffAmount: product.CancellationCutoffAmount,
+		CancellationCutoffUnit:   product.CancellationCutoffUnit,
+		AvailabilityType:         product.AvailabilityType,
+		DeliveryMethods:          product.DeliveryMethods,
+		DeliveryFormats:          product.DeliveryFormats,
+		Options:                  optionsPricing(product.Options),
 	}
 }
 
@@ -133,37 +98,96 @@ func GetProduct(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param   ProductPayload_Rq body model.ProductPayload_Rq true "Request Payload for Adding a Product"
 // @Success 201 {string} string "successfully created"
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal Server Error"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
 // @Router /products/add [post]
-func AddProduct(w http.ResponseWriter, r *http.Request) {
+func (s *Server) AddProduct(w http.ResponseWriter, r *http.Request) {
 	// Decode Product Data from request
 	var product_schema model.ProductPayload_Rq
 	if err := json.NewDecoder(r.Body).Decode(&product_schema); err != nil {
 		fmt.Println(err.Error())
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
 		return
 	}
 
-	database := store.ConnectToDB()
-	defer database.Close()
-
 	if len(product_schema.Currency) == 0 {
 		// Set the default currency type as USD if it is not mentioned in payload
 		product_schema.Currency = "USD"
 	}
 
+	// Cancellation cutoffs are counted in hours unless stated otherwise
+	if len(product_schema.CancellationCutoffUnit) == 0 {
+		product_schema.CancellationCutoffUnit = model.CutoffUnitHour
+	}
+	product_schema.CancellationCutoffUnit = strings.ToLower(product_schema.CancellationCutoffUnit)
+	if _, err := cutoffDuration(product_schema.CancellationCutoffAmount, product_schema.CancellationCutoffUnit); err != nil {
+		octoerr.Write(w, octoerr.BadRequest(err.Error()))
+		return
+	}
+
+	// Products are open all day unless they declare start times
+	product_schema.AvailabilityType = strings.ToUpper(product_schema.AvailabilityType)
+	switch product_schema.AvailabilityType {
+	case "":
+		product_schema.AvailabilityType = model.AvailabilityTypeOpeningHours
+	case model.AvailabilityTypeStartTime, model.AvailabilityTypeOpeningHours:
+	default:
+		octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("invalid availabilityType %q", product_schema.AvailabilityType)))
+		return
+	}
+
+	// Products deliver every voucher and ticket format unless they choose fewer
+	deliveryMethods, err := deliveryValues("deliveryMethods", product_schema.DeliveryMethods, model.DeliveryMethods)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	deliveryFormats, err := deliveryValues("deliveryFormats", product_schema.DeliveryFormats, model.DeliveryFormats)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	// Check the owning supplier, falling back to the default supplier
+	var supplier *model.Supplier
+	if len(product_schema.SupplierId) == 0 {
+		supplier, err = s.defaultSupplier(r)
+	} else {
+		supplier, err = s.repo.GetSupplier(product_schema.SupplierId)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.BadRequest("Invalid supplierId"))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	// Build options and units, defaulting to a single adult unit at the product price
+	options, err := buildOptions(product_schema)
+	if err != nil {
+		octoerr.Write(w, octoerr.BadRequest(err.Error()))
+		return
+	}
+
 	// Add Product to DB
-	err := store.InsertProductIntoDB(database, model.Product{
-		ID:       uuid.NewString(),
-		Name:     product_schema.Name,
-		Capacity: product_schema.Capacity,
-		Price:    product_schema.Price,
-		Currency: product_schema.Currency,
+	err = s.repo.InsertProduct(model.Product{
+		ID:                       uuid.NewString(),
+		SupplierId:               supplier.ID,
+		Name:                     product_schema.Name,
+		Capacity:                 product_schema.Capacity,
+		Price:                    product_schema.Price,
+		Currency:                 product_schema.Currency,
+		CancellationCutoffAmount: product_schema.CancellationCutoffAmount,
+		CancellationCutoffUnit:   product_schema.CancellationCutoffUnit,
+		AvailabilityType:         product_schema.AvailabilityType,
+		DeliveryMethods:          deliveryMethods,
+		DeliveryFormats:          deliveryFormats,
+		Options:                  options,
 	})
 	if err != nil {
-		fmt.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		octoerr.Write(w, err)
 		return
 	}
 
@@ -171,3 +195,141 @@ func AddProduct(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode("successfully created")
 }
+
+// deliveryValues upper-cases the requested delivery methods or formats and
+// checks each is one of allowed, which is also the default when none are sent.
+func deliveryValues(field string, values, allowed []string) ([]string, error) {
+	if len(values) == 0 {
+		return append([]string{}, allowed...), nil
+	}
+	var out []string
+	for _, v := range values {
+		v = strings.ToUpper(v)
+		if !contains(allowed, v) {
+			return nil, octoerr.BadRequest(fmt.Sprintf("invalid %s %q", field, v))
+		}
+		if !contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// unitTypes lists the unit types accepted by OCTO.
+var unitTypes = map[string]bool{
+	model.UnitTypeAdult:    true,
+	model.UnitTypeYouth:    true,
+	model.UnitTypeChild:    true,
+	model.UnitTypeInfant:   true,
+	model.UnitTypeFamily:   true,
+	model.UnitTypeSenior:   true,
+	model.UnitTypeStudent:  true,
+	model.UnitTypeMilitary: true,
+	model.UnitTypeOther:    true,
+}
+
+// buildOptions turns the requested options into model options with fresh IDs.
+// A product without options gets a default option holding one adult unit at
+// the product price.
+func buildOptions(req model.ProductPayload_Rq) ([]model.Option, error) {
+	if len(req.Options) == 0 {
+		req.Options = []model.OptionPayload_Rq{{
+			InternalName: "DEFAULT",
+			Default:      true,
+			Units: []model.UnitPayload_Rq{{
+				InternalName: "Adult",
+				Type:         model.UnitTypeAdult,
+				Restrictions: model.UnitRestrictions{MinAge: 18, MaxAge: 99, PaxCount: 1},
+				Price:        req.Price,
+				Currency:     req.Currency,
+			}},
+		}}
+	}
+
+	hasDefault := false
+	for _, o := range req.Options {
+		hasDefault = hasDefault || o.Default
+	}
+
+	var options []model.Option
+	for i, o := range req.Options {
+		if len(o.Units) == 0 {
+			return nil, fmt.Errorf("option %q must have at least one unit", o.InternalName)
+		}
+
+		option := model.Option{
+			ID:           uuid.NewString(),
+			Default:      o.Default || (!hasDefault && i == 0),
+			InternalName: o.InternalName,
+			Reference:    o.Reference,
+		}
+
+		// Assign IDs first so accompaniedBy unit types can be resolved to sibling units
+		idsByType := map[string][]string{}
+		for _, u := range o.Units {
+			u.Type = strings.ToUpper(u.Type)
+			if !unitTypes[u.Type] {
+				return nil, fmt.Errorf("invalid unit type %q", u.Type)
+			}
+			if len(u.Currency) == 0 {
+				u.Currency = req.Currency
+			}
+			if u.Restrictions.PaxCount == 0 {
+				u.Restrictions.PaxCount = 1
+			}
+			unit := model.Unit{
+				ID:           uuid.NewString(),
+				OptionId:     option.ID,
+				InternalName: u.InternalName,
+				Reference:    u.Reference,
+				Type:         u.Type,
+				Restrictions: u.Restrictions,
+				Price:        u.Price,
+				Currency:     u.Currency,
+			}
+			idsByType[unit.Type] = append(idsByType[unit.Type], unit.ID)
+			option.Units = append(option.Units, unit)
+		}
+		for j := range option.Units {
+			restrictions := &option.Units[j].Restrictions
+			var accompaniedBy []string
+			for _, unitType := range restrictions.AccompaniedBy {
+				ids, ok := idsByType[strings.ToUpper(unitType)]
+				if !ok {
+					return nil, fmt.Errorf("accompaniedBy unit type %q is not offered by option %q", unitType, o.InternalName)
+				}
+				accompaniedBy = append(accompaniedBy, ids...)
+			}
+			restrictions.AccompaniedBy = accompaniedBy
+		}
+
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+func optionsPricing(options []model.Option) []model.OptionPayload_Rs_Pricing {
+	outputs := []model.OptionPayload_Rs_Pricing{}
+	for _, o := range options {
+		units := []model.UnitPayload_Rs_Pricing{}
+		for _, u := range o.Units {
+			units = append(units, model.UnitPayload_Rs_Pricing{
+				Id:           u.ID,
+				InternalName: u.InternalName,
+				Reference:    u.Reference,
+				Type:         u.Type,
+				Restrictions: u.Restrictions,
+				Price:        u.Price,
+				Currency:     u.Currency,
+			})
+		}
+		outputs = append(outputs, model.OptionPayload_Rs_Pricing{
+			Id:           o.ID,
+			Default:      o.Default,
+			InternalName: o.InternalName,
+			Reference:    o.Reference,
+			Units:        units,
+		})
+	}
+	return outputs
+}
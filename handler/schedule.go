@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"octo-api/store"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GetScheduleRules godoc
+// @Summary Get schedule rules
+// @Description Get the recurring schedule rules availabilities of a product are generated from
+// @Tags schedule
+// @Produce  json
+// @Param   id path string true "Product ID"
+// @Success 200 {object} []model.ScheduleRule "Success"
+// @Failure 404 {object} octoerr.Error "Product not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /products/{id}/schedules [get]
+func (s *Server) GetScheduleRules(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	productID := vars["id"]
+
+	if _, err := s.repo.GetProduct(productID); err != nil {
+		writeScheduleError(w, err, octoerr.InvalidProductID(productID))
+		return
+	}
+
+	rules, err := s.repo.GetScheduleRules(productID)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rules)
+}
+
+// AddScheduleRule godoc
+// @Summary Add a schedule rule
+// @Description Adds a recurring schedule rule to a product and generates its availabilities up to the schedule horizon
+// @Tags schedule
+// @Accept  json
+// @Produce  json
+// @Param   id path string true "Product ID"
+// @Param   ScheduleRulePayload_Rq body model.ScheduleRulePayload_Rq true "Request Payload for Adding a Schedule Rule"
+// @Success 201 {object} model.ScheduleRule "Schedule rule successfully created"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 404 {object} octoerr.Error "Product not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /products/{id}/schedules [post]
+func (s *Server) AddScheduleRule(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	rule, err := decodeScheduleRule(r)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	rule.ID = uuid.NewString()
+	rule.ProductId = vars["id"]
+
+	if err := s.repo.InsertScheduleRule(rule); err != nil {
+		writeScheduleError(w, err, octoerr.InvalidProductID(rule.ProductId))
+		return
+	}
+
+	s.writeScheduleRule(w, rule.ID, http.StatusCreated)
+}
+
+// UpdateScheduleRule godoc
+// @Summary Update a schedule rule
+// @Description Replaces a schedule rule and regenerates its availabilities. Availabilities that already have bookings are left unchanged.
+// @Tags schedule
+// @Accept  json
+// @Produce  json
+// @Param   id path string true "Schedule Rule ID"
+// @Param   ScheduleRulePayload_Rq body model.ScheduleRulePayload_Rq true "Request Payload for Updating a Schedule Rule"
+// @Success 200 {object} model.ScheduleRule "Schedule rule successfully updated"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 404 {object} octoerr.Error "Schedule rule not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /schedules/{id} [put]
+func (s *Server) UpdateScheduleRule(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	rule, err := decodeScheduleRule(r)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	rule.ID = vars["id"]
+
+	if err := s.repo.UpdateScheduleRule(rule); err != nil {
+		writeScheduleError(w, err, octoerr.BadRequest("Schedule rule not found"))
+		return
+	}
+
+	s.writeScheduleRule(w, rule.ID, http.StatusOK)
+}
+
+// DeleteScheduleRule godoc
+// @Summary Delete a schedule rule
+// @Description Deletes a schedule rule and the upcoming availabilities it generated that have no bookings
+// @Tags schedule
+// @Produce  json
+// @Param   id path string true "Schedule Rule ID"
+// @Success 200 {string} string "successfully deleted"
+// @Failure 404 {object} octoerr.Error "Schedule rule not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /schedules/{id} [delete]
+func (s *Server) DeleteScheduleRule(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	rule, err := s.repo.GetScheduleRule(vars["id"])
+	if err != nil {
+		writeScheduleError(w, err, octoerr.BadRequest("Schedule rule not found"))
+		return
+	}
+	from, _, err := store.ProductScheduleWindow(s.repo, rule.ProductId, s.now(), s.scheduleHorizonDays)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if err := s.repo.DeleteScheduleRule(rule.ID, from); err != nil {
+		writeScheduleError(w, err, octoerr.BadRequest("Schedule rule not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode("successfully deleted")
+}
+
+// decodeScheduleRule reads a schedule rule from the request body.
+func decodeScheduleRule(r *http.Request) (model.ScheduleRule, error) {
+	var req model.ScheduleRulePayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return model.ScheduleRule{}, octoerr.BadRequest("Invalid request body")
+	}
+
+	rule := model.ScheduleRule{
+		Weekdays:        req.Weekdays,
+		StartTimes:      req.StartTimes,
+		DurationMinutes: req.DurationMinutes,
+		Capacity:        req.Capacity,
+		Price:           req.Price,
+		Currency:        strings.ToUpper(req.Currency),
+	}
+	if req.DurationMinutes < 0 {
+		return rule, octoerr.BadRequest("durationMinutes must not be negative")
+	}
+
+	var err error
+	if rule.ValidFrom, err = time.Parse("2006-01-02", req.ValidFrom); err != nil {
+		return rule, octoerr.BadRequest("Invalid validFrom format. Please use YYYY-MM-DD.")
+	}
+	if req.ValidUntil != "" {
+		until, err := time.Parse("2006-01-02", req.ValidUntil)
+		if err != nil {
+			return rule, octoerr.BadRequest("Invalid validUntil format. Please use YYYY-MM-DD.")
+		}
+		rule.ValidUntil = &until
+	}
+	for _, d := range req.BlackoutDates {
+		blackout, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return rule, octoerr.BadRequest(fmt.Sprintf("Invalid blackout date %q. Please use YYYY-MM-DD.", d))
+		}
+		rule.BlackoutDates = append(rule.BlackoutDates, blackout)
+	}
+	return rule, nil
+}
+
+// writeScheduleRule regenerates the availabilities of a stored rule's product
+// and responds with the rule.
+func (s *Server) writeScheduleRule(w http.ResponseWriter, ruleID string, status int) {
+	rule, err := s.repo.GetScheduleRule(ruleID)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	from, until, err := store.ProductScheduleWindow(s.repo, rule.ProductId, s.now(), s.scheduleHorizonDays)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if _, err := s.repo.MaterializeSchedule(rule.ProductId, from, until); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// writeScheduleError responds to a failed schedule rule change, sending
+// notFound with 404 when the product or rule does not exist.
+func writeScheduleError(w http.ResponseWriter, err error, notFound *octoerr.Error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		octoerr.Write(w, notFound.WithStatus(http.StatusNotFound))
+	case errors.Is(err, store.ErrInvalidScheduleRule):
+		octoerr.Write(w, octoerr.BadRequest(err.Error()))
+	default:
+		octoerr.Write(w, err)
+	}
+}
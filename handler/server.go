@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/helper"
+	"octo-api/store"
+	"octo-api/voucher"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Server holds the dependencies shared by every HTTP handler.
+type Server struct {
+	repo store.Repository
+
+	// supplierID is the supplier served by GET /supplier and assigned to new
+	// products that do not name one. Empty means the first supplier on record.
+	supplierID string
+
+	// rates converts prices when a booking mixes currencies.
+	rates helper.RateProvider
+
+	// now is the clock used for cutoffs; tests pin it with WithClock.
+	now func() time.Time
+
+	// reservationExpiry is how long a reservation holds its vacancies when the
+	// request does not set expirationMinutes.
+	reservationExpiry time.Duration
+
+	// scheduleHorizonDays is how far ahead schedule rules are materialized
+	// when they change.
+	scheduleHorizonDays int
+
+	// maxDateRangeDays caps the number of days availability lookups may span.
+	maxDateRangeDays int
+
+	// vouchers lays out the PDF vouchers served by GET /bookings/{id}/voucher.
+	vouchers *voucher.Template
+
+	// adminKeyHash is the hash of an admin API key accepted without being on
+	// record, so the first keys can be issued. Empty disables it.
+	adminKeyHash string
+}
+
+// DefaultReservationExpiry is the hold time of reservations that do not ask
+// for one.
+const DefaultReservationExpiry = store.DefaultReservationExpiry
+
+// DefaultMaxDateRangeDays is the longest date range availability lookups
+// accept by default.
+const DefaultMaxDateRangeDays = 366
+
+// MaxDateRangeFromEnv reads MAX_DATE_RANGE_DAYS, falling back to
+// DefaultMaxDateRangeDays.
+func MaxDateRangeFromEnv() (int, error) {
+	days, err := helper.EnvInt("MAX_DATE_RANGE_DAYS", DefaultMaxDateRangeDays)
+	if err != nil {
+		return 0, err
+	}
+	if days <= 0 {
+		return 0, fmt.Errorf("MAX_DATE_RANGE_DAYS must be positive")
+	}
+	return days, nil
+}
+
+// Option customises a Server.
+type Option func(*Server)
+
+// WithSupplierID sets the supplier this API instance acts for.
+func WithSupplierID(supplierID string) Option {
+	return func(s *Server) {
+		s.supplierID = supplierID
+	}
+}
+
+// WithRateProvider sets the exchange-rate provider used to price bookings
+// that mix currencies.
+func WithRateProvider(rates helper.RateProvider) Option {
+	return func(s *Server) {
+		s.rates = rates
+	}
+}
+
+// WithClock replaces the wall clock used for time-dependent rules such as
+// cancellation cutoffs.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithReservationExpiry sets the default hold time of new reservations.
+func WithReservationExpiry(expiry time.Duration) Option {
+	return func(s *Server) {
+		s.reservationExpiry = expiry
+	}
+}
+
+// WithScheduleHorizon sets how many days ahead availabilities are generated
+// when a schedule rule changes.
+func WithScheduleHorizon(days int) Option {
+	return func(s *Server) {
+		s.scheduleHorizonDays = days
+	}
+}
+
+// WithMaxDateRange sets the longest date range, in days, availability lookups
+// accept.
+func WithMaxDateRange(days int) Option {
+	return func(s *Server) {
+		s.maxDateRangeDays = days
+	}
+}
+
+// WithVoucherTemplate replaces the built-in layout of PDF vouchers.
+func WithVoucherTemplate(tmpl *voucher.Template) Option {
+	return func(s *Server) {
+		s.vouchers = tmpl
+	}
+}
+
+// WithAdminAPIKey accepts key as an admin API key in addition to the keys on
+// record. An empty key is ignored.
+func WithAdminAPIKey(key string) Option {
+	return func(s *Server) {
+		if key != "" {
+			s.adminKeyHash = hashAPIKey(key)
+		}
+	}
+}
+
+// NewServer returns a Server backed by the shared Postgres connection pool.
+func NewServer(db *sql.DB, opts ...Option) *Server {
+	return NewServerWithRepository(store.NewPostgresStore(db), opts...)
+}
+
+// NewServerWithRepository returns a Server backed by an arbitrary repository,
+// e.g. store.NewMemoryStore in tests.
+func NewServerWithRepository(repo store.Repository, opts ...Option) *Server {
+	s := &Server{repo: repo, now: time.Now, reservationExpiry: DefaultReservationExpiry, scheduleHorizonDays: store.DefaultScheduleHorizonDays, maxDateRangeDays: DefaultMaxDateRangeDays, vouchers: voucher.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Routes registers every API route on a new router.
+func (s *Server) Routes() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(s.authenticate)
+	r.Use(s.idempotent)
+
+	// Supplier routes
+	r.HandleFunc("/supplier", s.GetSupplier).Methods("GET")
+
+	// Product routes
+	r.HandleFunc("/products", s.GetProducts).Methods("GET")
+	r.HandleFunc("/products/new", s.adminOnly(s.AddProduct)).Methods("POST")
+	r.HandleFunc("/products/{id}", s.GetProduct).Methods("GET")
+
+	// Schedule routes
+	r.HandleFunc("/products/{id}/schedules", s.GetScheduleRules).Methods("GET")
+	r.HandleFunc("/products/{id}/schedules", s.adminOnly(s.AddScheduleRule)).Methods("POST")
+	r.HandleFunc("/schedules/{id}", s.adminOnly(s.UpdateScheduleRule)).Methods("PUT")
+	r.HandleFunc("/schedules/{id}", s.adminOnly(s.DeleteScheduleRule)).Methods("DELETE")
+
+	// Availability routes
+	r.HandleFunc("/availability", s.GetAvailabilities).Methods("GET")
+	r.HandleFunc("/availability", s.CheckAvailability).Methods("POST")
+	r.HandleFunc("/availability/add", s.adminOnly(s.AddAvailabilities)).Methods("POST")
+	r.HandleFunc("/availability/calendar", s.GetAvailabilityCalendar).Methods("POST")
+	r.HandleFunc("/availability/{id}", s.adminOnly(s.UpdateAvailability)).Methods("PATCH")
+
+	// Booking routes
+	r.HandleFunc("/bookings", s.PostBooking).Methods("POST")
+	r.HandleFunc("/bookings/all", s.GetAllBookings).Methods("GET")
+	r.HandleFunc("/bookings/{id}", s.GetBooking).Methods("GET")
+	r.HandleFunc("/bookings/{id}", s.UpdateBooking).Methods("PATCH")
+	r.HandleFunc("/bookings/{id}", s.CancelBooking).Methods("DELETE")
+	r.HandleFunc("/bookings/{id}/confirm", s.ConfirmBooking).Methods("POST")
+	r.HandleFunc("/bookings/{id}/cancel", s.CancelBooking).Methods("POST")
+	r.HandleFunc("/bookings/{id}/extend", s.ExtendBooking).Methods("PATCH")
+	r.HandleFunc("/bookings/{id}/voucher", s.GetVoucher).Methods("GET")
+
+	// Ticket routes
+	r.HandleFunc("/tickets/redeem", s.adminOnly(s.RedeemTicket)).Methods("POST")
+
+	// API key routes
+	r.HandleFunc("/api-keys", s.adminOnly(s.PostAPIKey)).Methods("POST")
+
+	// Webhook routes
+	r.HandleFunc("/webhooks", s.adminOnly(s.GetWebhooks)).Methods("GET")
+	r.HandleFunc("/webhooks", s.adminOnly(s.PostWebhook)).Methods("POST")
+	r.HandleFunc("/webhooks/dead-letters", s.adminOnly(s.GetDeadLetters)).Methods("GET")
+	r.HandleFunc("/webhooks/dead-letters/{id}/retry", s.adminOnly(s.RetryDeadLetter)).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", s.adminOnly(s.DeleteWebhook)).Methods("DELETE")
+
+	return r
+}
@@ -0,0 +1,1509 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"octo-api/model"
+	"octo-api/money"
+	"octo-api/octoerr"
+	"octo-api/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNow is the clock of test servers, a day before the seeded availability.
+var testNow = time.Date(2024, 2, 28, 12, 0, 0, 0, time.UTC)
+
+// testAdminKey is the admin API key doRequest sends unless told otherwise.
+const testAdminKey = "test-admin-key"
+
+// newTestServer returns a router backed by an in-memory store seeded with one
+// supplier, one product with adult and child units and three days of availability starting on 2024-03-01.
+// The server clock is fixed at testNow unless opts override it, and it accepts testAdminKey.
+func newTestServer(t *testing.T, opts ...Option) (http.Handler, *store.MemoryStore) {
+	t.Helper()
+
+	repo := store.NewMemoryStore()
+	if err := repo.InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Endpoint: "http://localhost:8080", Timezone: "Europe/London"}); err != nil {
+		t.Fatalf("error was not expected while seeding supplier: %s", err)
+	}
+	maxChildren := 2
+	product := model.Product{
+		ID:                       "product_id",
+		SupplierId:               "supplier_id",
+		Name:                     "Product Name",
+		Capacity:                 10,
+		Price:                    5000,
+		Currency:                 "USD",
+		CancellationCutoffAmount: 24,
+		CancellationCutoffUnit:   model.CutoffUnitHour,
+		Options: []model.Option{{
+			ID:           "option_id",
+			Default:      true,
+			InternalName: "DEFAULT",
+			Units: []model.Unit{
+				{ID: "adult_id", InternalName: "Adult", Type: model.UnitTypeAdult, Restrictions: model.UnitRestrictions{MinAge: 18, MaxAge: 99, PaxCount: 1}, Price: 5000, Currency: "USD"},
+				{ID: "child_id", InternalName: "Child", Type: model.UnitTypeChild, Restrictions: model.UnitRestrictions{MinAge: 4, MaxAge: 17, PaxCount: 1, MaxQuantity: &maxChildren, AccompaniedBy: []string{"adult_id"}}, Price: 2500, Currency: "USD"},
+			},
+		}},
+	}
+	if err := repo.InsertProduct(product); err != nil {
+		t.Fatalf("error was not expected while seeding product: %s", err)
+	}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.AddAvailability("product_id", start, start.AddDate(0, 0, 2), store.Timeslots{}, money.New(1000, "USD")); err != nil {
+		t.Fatalf("error was not expected while seeding availability: %s", err)
+	}
+
+	opts = append([]Option{WithClock(func() time.Time { return testNow }), WithAdminAPIKey(testAdminKey)}, opts...)
+	return NewServerWithRepository(repo, opts...).Routes(), repo
+}
+
+// doRequest performs a request against h, JSON-encoding body when it is not nil.
+// It authenticates with testAdminKey unless headers set Authorization.
+func doRequest(t *testing.T, h http.Handler, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("error was not expected while encoding body: %s", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// expectError checks that rec is an OCTO error response with the given status and code.
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, code octoerr.Code) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Errorf("expected status %d, got %d: %s", status, rec.Code, rec.Body)
+		return
+	}
+	var e octoerr.Error
+	decodeBody(t, rec, &e)
+	if e.Code != code || e.Message == "" {
+		t.Errorf("expected error %s with a message, got %+v", code, e)
+	}
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("error was not expected while decoding response: %s", err)
+	}
+}
+
+func TestServerProducts(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{Name: "Boat Tour", Capacity: 20, Price: 3000}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "GET", "/products", nil, map[string]string{OctoCapabilitiesHeader: "octo/pricing"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var products []model.ProductPayload_Rs_Pricing
+	decodeBody(t, rec, &products)
+	if len(products) != 2 || products[1].Name != "Boat Tour" || products[1].Currency != "USD" || products[1].SupplierId != "supplier_id" {
+		t.Fatalf("unexpected products %+v", products)
+	}
+	if options := products[1].Options; len(options) != 1 || !options[0].Default || len(options[0].Units) != 1 ||
+		options[0].Units[0].Type != model.UnitTypeAdult || options[0].Units[0].Price != 3000 {
+		t.Errorf("expected a default option with one adult unit, got %+v", products[1].Options)
+	}
+
+	rec = doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{SupplierId: "missing", Name: "Bus Tour", Capacity: 20}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doRequest(t, h, "GET", "/products/product_id", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "GET", "/products/missing", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestServerAvailabilities(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doRequest(t, h, "POST", "/availability/add", model.AvailabilityNewPayload_Rq{ProductId: "product_id", LocalDate: "2024-03-10", Price: 500}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "GET", "/availability?localDateStart=2024-03-01&localDateEnd=2024-03-31", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var availabilities []model.AvailabilityPayload_Rs_Pricing
+	decodeBody(t, rec, &availabilities)
+	if len(availabilities) != 4 {
+		t.Errorf("expected 4 availabilities, got %d", len(availabilities))
+	}
+
+	rec = doRequest(t, h, "GET", "/availability?localDateStart=2024-03-01&localDateEnd=2024-03-31&productId=product_id", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	decodeBody(t, rec, &availabilities)
+	if len(availabilities) != 4 || availabilities[0].ProductName != "Product Name" {
+		t.Errorf("expected 4 availabilities of the product, got %+v", availabilities)
+	}
+
+	rec = doRequest(t, h, "GET", "/availability?localDate=2024-03-01&productId=missing", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	for _, query := range []string{
+		"localDate=03/01/2024",
+		"localDateStart=2024-03-31&localDateEnd=2024-03-01",
+		"localDateStart=2024-01-01&localDateEnd=2025-01-01",
+	} {
+		rec = doRequest(t, h, "GET", "/availability?"+query, nil, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, query, rec.Code)
+		}
+	}
+}
+
+func TestMaxDateRangeFromEnv(t *testing.T) {
+	if days, err := MaxDateRangeFromEnv(); err != nil || days != DefaultMaxDateRangeDays {
+		t.Errorf("expected the default of %d days, got %d, %v", DefaultMaxDateRangeDays, days, err)
+	}
+	t.Setenv("MAX_DATE_RANGE_DAYS", "7")
+	days, err := MaxDateRangeFromEnv()
+	if err != nil || days != 7 {
+		t.Fatalf("expected 7 days, got %d, %v", days, err)
+	}
+	for _, v := range []string{"week", "0"} {
+		t.Setenv("MAX_DATE_RANGE_DAYS", v)
+		if _, err := MaxDateRangeFromEnv(); err == nil {
+			t.Errorf("expected an error for MAX_DATE_RANGE_DAYS=%s", v)
+		}
+	}
+
+	h, _ := newTestServer(t, WithMaxDateRange(days))
+	rec := doRequest(t, h, "GET", "/availability?localDateStart=2024-03-01&localDateEnd=2024-03-07", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d for 7 days, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "GET", "/availability?localDateStart=2024-03-01&localDateEnd=2024-03-08", nil, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+}
+
+func TestServerStartTimeAvailabilities(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	rec := doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{Name: "Walking Tour", Capacity: 12, Price: 2000, AvailabilityType: "start_time"}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	products, _ := repo.GetProducts()
+	productID := products[1].ID
+	if products[1].AvailabilityType != model.AvailabilityTypeStartTime {
+		t.Fatalf("expected a START_TIME product, got %q", products[1].AvailabilityType)
+	}
+
+	rec = doRequest(t, h, "POST", "/availability/add", model.AvailabilityNewPayload_Rq{ProductId: productID, LocalDate: "2024-03-10"}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d without start times, got %d", http.StatusBadRequest, rec.Code)
+	}
+	rec = doRequest(t, h, "POST", "/availability/add", model.AvailabilityNewPayload_Rq{ProductId: productID, LocalDate: "2024-03-10", StartTimes: []string{"9:00 am"}}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a malformed start time, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doRequest(t, h, "POST", "/availability/add", model.AvailabilityNewPayload_Rq{
+		ProductId:       productID,
+		LocalDate:       "2024-03-10",
+		StartTimes:      []string{"13:00", "09:00", "17:00"},
+		DurationMinutes: 120,
+		Price:           2000,
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "GET", "/availability?localDate=2024-03-10", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var slots []struct {
+		LocalDateTimeStart string `json:"localDateTimeStart"`
+		LocalDateTimeEnd   string `json:"localDateTimeEnd"`
+	}
+	decodeBody(t, rec, &slots)
+	want := [][2]string{
+		{"2024-03-10T09:00:00Z", "2024-03-10T11:00:00Z"},
+		{"2024-03-10T13:00:00Z", "2024-03-10T15:00:00Z"},
+		{"2024-03-10T17:00:00Z", "2024-03-10T19:00:00Z"},
+	}
+	if len(slots) != len(want) {
+		t.Fatalf("expected %d slots, got %+v", len(want), slots)
+	}
+	for i, slot := range slots {
+		if slot.LocalDateTimeStart != want[i][0] || slot.LocalDateTimeEnd != want[i][1] {
+			t.Errorf("slot %d: expected %v, got %+v", i, want[i], slot)
+		}
+	}
+}
+
+func TestServerScheduleRules(t *testing.T) {
+	h, repo := newTestServer(t, WithScheduleHorizon(13))
+
+	// Every Friday from today, 2024-02-28, except 2024-03-08
+	rec := doRequest(t, h, "POST", "/products/product_id/schedules", model.ScheduleRulePayload_Rq{
+		Weekdays:      []string{"FRIDAY"},
+		ValidFrom:     "2024-02-28",
+		BlackoutDates: []string{"2024-03-08"},
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var rule model.ScheduleRule
+	decodeBody(t, rec, &rule)
+	if rule.ID == "" || rule.ProductId != "product_id" || len(rule.Weekdays) != 1 || rule.Weekdays[0] != "FRIDAY" {
+		t.Errorf("unexpected schedule rule %+v", rule)
+	}
+
+	// 2024-03-01 was seeded by hand; only 2024-02-28 + 13 days = 2024-03-11 is in the horizon
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	if availabilities, _ := repo.GetAvailabilities(day(1), day(31)); len(availabilities) != 3 {
+		t.Errorf("expected the 3 seeded availabilities and no generated ones, got %d", len(availabilities))
+	}
+
+	rec = doRequest(t, h, "PUT", "/schedules/"+rule.ID, model.ScheduleRulePayload_Rq{Weekdays: []string{"FRIDAY", "SATURDAY"}, ValidFrom: "2024-02-28"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	// Saturday 2nd is seeded by hand; Friday 8th and Saturday 9th are generated
+	if availabilities, _ := repo.GetAvailabilities(day(8), day(9)); len(availabilities) != 2 {
+		t.Errorf("expected 2 generated availabilities, got %d", len(availabilities))
+	}
+
+	rec = doRequest(t, h, "GET", "/products/product_id/schedules", nil, nil)
+	var rules []model.ScheduleRule
+	decodeBody(t, rec, &rules)
+	if len(rules) != 1 || len(rules[0].Weekdays) != 2 {
+		t.Errorf("unexpected schedule rules %+v", rules)
+	}
+
+	rec = doRequest(t, h, "POST", "/products/product_id/schedules", model.ScheduleRulePayload_Rq{Weekdays: []string{"FUNDAY"}, ValidFrom: "2024-02-28"}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	rec = doRequest(t, h, "POST", "/products/missing/schedules", model.ScheduleRulePayload_Rq{ValidFrom: "2024-02-28"}, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = doRequest(t, h, "DELETE", "/schedules/"+rule.ID, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if availabilities, _ := repo.GetAvailabilities(day(8), day(9)); len(availabilities) != 0 {
+		t.Errorf("expected generated availabilities to be removed, got %d", len(availabilities))
+	}
+	rec = doRequest(t, h, "DELETE", "/schedules/"+rule.ID, nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestServerBookingLifecycle(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	availabilityID := availabilities[0].ID
+
+	pricing := map[string]string{OctoCapabilitiesHeader: CapabilityPricing}
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilityID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}, {UnitId: "child_id"}},
+	}, pricing)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	if booking.Status != "RESERVED" || booking.OptionId != "option_id" || booking.Price != 15500 || booking.Currency != "USD" {
+		t.Errorf("unexpected booking %+v", booking)
+	}
+	if len(booking.UnitItems) != 3 || booking.UnitItems[2].UnitId != "child_id" || booking.UnitItems[2].Pricing.Retail != 3500 {
+		t.Errorf("unexpected unit items %+v", booking.UnitItems)
+	}
+
+	availability, _ := repo.GetAvailabilityByID(availabilityID)
+	if availability.Vacancies != 7 {
+		t.Errorf("expected 7 vacancies left, got %d", availability.Vacancies)
+	}
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID, nil, map[string]string{OctoCapabilitiesHeader: "octo/pricing"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+	if confirmed.Status != "CONFIRMED" || len(confirmed.Units) != 3 || confirmed.Units[0].Ticket == nil {
+		t.Errorf("unexpected confirmed booking %+v", confirmed)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/all", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var bookings []model.BookingPayload_Rs
+	decodeBody(t, rec, &bookings)
+	if len(bookings) != 1 || len(bookings[0].Units) != 3 {
+		t.Errorf("unexpected bookings %+v", bookings)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/missing", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestServerCancelBooking(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	availabilityID := availabilities[0].ID
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+			AvailabilityId: availabilityID,
+			UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}},
+		}, nil)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		}
+		var booking model.Booking
+		decodeBody(t, rec, &booking)
+		ids = append(ids, booking.ID)
+	}
+
+	// POST /bookings/{id}/cancel with a reason
+	rec := doRequest(t, h, "POST", "/bookings/"+ids[0]+"/cancel", model.BookingCancellationPayload_Rq{Reason: "Customer request"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var cancelled model.BookingPayload_Rs
+	decodeBody(t, rec, &cancelled)
+	if cancelled.Status != "CANCELLED" || cancelled.Cancellation == nil || cancelled.Cancellation.Reason != "Customer request" {
+		t.Errorf("unexpected cancelled booking %+v", cancelled)
+	}
+
+	// DELETE /bookings/{id} without a body
+	rec = doRequest(t, h, "DELETE", "/bookings/"+ids[1], nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	availability, _ := repo.GetAvailabilityByID(availabilityID)
+	if availability.Vacancies != 10 {
+		t.Errorf("expected every vacancy back, got %d", availability.Vacancies)
+	}
+
+	// Cancelling again returns the booking without restoring vacancies twice
+	rec = doRequest(t, h, "DELETE", "/bookings/"+ids[1], nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if availability, _ := repo.GetAvailabilityByID(availabilityID); availability.Vacancies != 10 {
+		t.Errorf("expected 10 vacancies, got %d", availability.Vacancies)
+	}
+
+	rec = doRequest(t, h, "POST", "/bookings/"+ids[0]+"/confirm", nil, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d confirming a cancelled booking, got %d", http.StatusConflict, rec.Code)
+	}
+
+	rec = doRequest(t, h, "DELETE", "/bookings/missing", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestServerConfirmBookingContact(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "child_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	childItem := booking.UnitItems[1].ID
+
+	// Invalid contacts are refused before anything changes
+	tests := []struct {
+		name    string
+		payload model.BookingConfirmationPayload_Rq
+	}{
+		{"invalid email", model.BookingConfirmationPayload_Rq{Contact: &model.Contact{EmailAddress: "not an email"}}},
+		{"invalid country", model.BookingConfirmationPayload_Rq{Contact: &model.Contact{Country: "GBR"}}},
+		{"unknown unit item", model.BookingConfirmationPayload_Rq{UnitItems: []model.BookingUnitContactPayload_Rq{{ID: "missing"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", tt.payload, nil)
+			expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+		})
+	}
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", model.BookingConfirmationPayload_Rq{
+		Contact:   &model.Contact{FullName: "Ada Lovelace", EmailAddress: "ada@example.com", PhoneNumber: "+44 20 7946 0000", Locales: []string{"en-GB"}, Country: "GB", Notes: "Arriving by boat"},
+		UnitItems: []model.BookingUnitContactPayload_Rq{{ID: childItem, Contact: model.Contact{FullName: "Byron Lovelace"}}},
+	}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID, nil, nil)
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+	if c := confirmed.Contact; c.FullName != "Ada Lovelace" || c.EmailAddress != "ada@example.com" || c.Country != "GB" || len(c.Locales) != 1 {
+		t.Errorf("unexpected contact %+v", c)
+	}
+	for _, unit := range confirmed.Units {
+		if unit.ID == childItem && (unit.Contact == nil || unit.Contact.FullName != "Byron Lovelace") {
+			t.Errorf("unexpected unit contact %+v", unit.Contact)
+		}
+		if unit.ID != childItem && unit.Contact != nil {
+			t.Errorf("expected no contact on unit %s, got %+v", unit.ID, unit.Contact)
+		}
+	}
+
+	// The lead contact can be corrected later
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Contact: &model.Contact{FullName: "Ada King", EmailAddress: "ada@example.com"}}, nil)
+	var updated model.BookingPayload_Rs
+	decodeBody(t, rec, &updated)
+	if updated.Contact.FullName != "Ada King" || updated.Units[0].Contact == nil && updated.Units[1].Contact == nil {
+		t.Errorf("unexpected updated booking %+v", updated)
+	}
+}
+
+func TestServerVouchers(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "child_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+
+	// Reservations have no voucher yet
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID+"/voucher", nil, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", model.BookingConfirmationPayload_Rq{Contact: &model.Contact{FullName: "Ada Lovelace"}}, nil)
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+	pdfURL := "http://localhost:8080/bookings/" + booking.ID + "/voucher"
+	v := confirmed.Voucher
+	if v == nil || v.RedemptionMethod != model.RedemptionMethodDigital || len(v.DeliveryOptions) != 3 {
+		t.Fatalf("unexpected voucher %+v", v)
+	}
+	want := []model.DeliveryOption{
+		{DeliveryFormat: "QRCODE", DeliveryValue: v.Reference},
+		{DeliveryFormat: "CODE128", DeliveryValue: v.Reference},
+		{DeliveryFormat: "PDF_URL", DeliveryValue: pdfURL},
+	}
+	for i, option := range v.DeliveryOptions {
+		if option != want[i] {
+			t.Errorf("expected delivery option %+v, got %+v", want[i], option)
+		}
+	}
+	for _, unit := range confirmed.Units {
+		ticket := unit.Ticket
+		if ticket == nil || ticket.Reference == v.Reference || len(ticket.DeliveryOptions) != 3 || ticket.DeliveryOptions[2].DeliveryValue != pdfURL+"?unitItemId="+unit.ID {
+			t.Errorf("unexpected ticket %+v", ticket)
+		}
+	}
+
+	// Amending a confirmed booking keeps the references of units it keeps
+	notes := "Window seat"
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Notes: &notes}, nil)
+	var amended model.BookingPayload_Rs
+	decodeBody(t, rec, &amended)
+	if amended.Voucher == nil || amended.Voucher.Reference != v.Reference || amended.Units[0].Ticket == nil || amended.Units[0].Ticket.Reference != confirmed.Units[0].Ticket.Reference {
+		t.Errorf("expected the references to be kept, got %+v", amended)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID+"/voucher", nil, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("expected a PDF, got status %d and %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if pdf := rec.Body.String(); !strings.HasPrefix(pdf, "%PDF-") || !strings.Contains(pdf, "(Voucher "+v.Reference+")") || !strings.Contains(pdf, "(Lead traveller: Ada Lovelace)") {
+		t.Errorf("unexpected voucher PDF %q", pdf)
+	}
+
+	ticket := confirmed.Units[1]
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID+"/voucher?unitItemId="+ticket.ID, nil, nil)
+	if pdf := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(pdf, "(Ticket "+ticket.Ticket.Reference+")") || strings.Contains(pdf, confirmed.Units[0].Ticket.Reference) {
+		t.Errorf("unexpected ticket PDF %q", pdf)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID+"/voucher?unitItemId=missing", nil, nil)
+	expectError(t, rec, http.StatusNotFound, octoerr.BadRequestCode)
+	rec = doRequest(t, h, "GET", "/bookings/missing/voucher", nil, nil)
+	expectError(t, rec, http.StatusNotFound, octoerr.InvalidBookingUUIDCode)
+}
+
+func TestServerVoucherDeliveryMethods(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	// Invalid delivery settings are refused
+	rec := doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{Name: "Ferry", Capacity: 5, DeliveryFormats: []string{"SMS"}}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+
+	rec = doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{Name: "Ferry", Capacity: 5, Price: 1500, DeliveryMethods: []string{"ticket"}, DeliveryFormats: []string{"qrcode"}}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	products, _ := repo.GetProducts()
+	ferry := products[1]
+	if len(ferry.DeliveryMethods) != 1 || ferry.DeliveryMethods[0] != "TICKET" || len(ferry.DeliveryFormats) != 1 || ferry.DeliveryFormats[0] != "QRCODE" {
+		t.Fatalf("unexpected delivery settings %v %v", ferry.DeliveryMethods, ferry.DeliveryFormats)
+	}
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.AddAvailability(ferry.ID, day, day, store.Timeslots{}, money.New(1500, "USD")); err != nil {
+		t.Fatalf("error was not expected while adding availability: %s", err)
+	}
+	availabilities, _ := repo.GetProductAvailabilities(ferry.ID, day, day)
+	rec = doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: ferry.Options[0].Units[0].ID}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+
+	// Only tickets are delivered, and only as QR codes
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+	if confirmed.Voucher != nil {
+		t.Errorf("expected no booking voucher, got %+v", confirmed.Voucher)
+	}
+	if ticket := confirmed.Units[0].Ticket; ticket == nil || len(ticket.DeliveryOptions) != 1 || ticket.DeliveryOptions[0] != (model.DeliveryOption{DeliveryFormat: "QRCODE", DeliveryValue: ticket.Reference}) {
+		t.Errorf("unexpected ticket %+v", ticket)
+	}
+
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID+"/voucher?unitItemId="+confirmed.Units[0].ID, nil, nil)
+	expectError(t, rec, http.StatusNotFound, octoerr.BadRequestCode)
+}
+
+func TestServerRedeemTicket(t *testing.T) {
+	now := testNow
+	h, repo := newTestServer(t, WithClock(func() time.Time { return now }))
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}, {UnitId: "child_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: "UNKNOWN", Device: "gate-1"}, nil)
+	expectError(t, rec, http.StatusNotFound, octoerr.BadRequestCode)
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: "UNKNOWN"}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+	ticket := confirmed.Units[0].Ticket.Reference
+
+	// Tickets are only valid on the day of the availability
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: ticket, Device: "gate-1"}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	now = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: strings.ToLower(ticket), Device: "gate-1"}, nil)
+	var redeemed model.BookingPayload_Rs
+	decodeBody(t, rec, &redeemed)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if unit := redeemed.Units[0].Ticket; unit.UtcRedeemedAt == nil || !unit.UtcRedeemedAt.Equal(now) || unit.RedeemedBy == nil || *unit.RedeemedBy != "gate-1" {
+		t.Errorf("expected the ticket to be redeemed by gate-1, got %+v", unit)
+	}
+	if redeemed.Units[1].Ticket.UtcRedeemedAt != nil || redeemed.Voucher.UtcRedeemedAt != nil {
+		t.Errorf("expected the other tickets and the voucher to stay unredeemed, got %+v", redeemed)
+	}
+
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: ticket, Device: "gate-2"}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	// The voucher admits the rest of the party
+	now = now.Add(time.Minute)
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: confirmed.Voucher.Reference, Device: "gate-2"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID, nil, nil)
+	decodeBody(t, rec, &redeemed)
+	if *redeemed.Units[0].Ticket.RedeemedBy != "gate-1" || *redeemed.Units[1].Ticket.RedeemedBy != "gate-2" || *redeemed.Units[2].Ticket.RedeemedBy != "gate-2" {
+		t.Errorf("unexpected unit redemptions %+v", redeemed.Units)
+	}
+	if v := redeemed.Voucher; v.UtcRedeemedAt == nil || !v.UtcRedeemedAt.Equal(now) {
+		t.Errorf("expected the voucher to be redeemed at %s, got %+v", now, v)
+	}
+
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: confirmed.Voucher.Reference, Device: "gate-2"}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	// Cancelled bookings no longer admit anyone
+	rec = doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	decodeBody(t, rec, &booking)
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	decodeBody(t, rec, &confirmed)
+	now = testNow
+	doRequest(t, h, "DELETE", "/bookings/"+booking.ID, nil, nil)
+	now = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: confirmed.Voucher.Reference, Device: "gate-1"}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+}
+
+func TestServerUpdateBooking(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	first, second := availabilities[0].ID, availabilities[1].ID
+
+	pricing := map[string]string{OctoCapabilitiesHeader: CapabilityPricing}
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: first,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}},
+	}, pricing)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+
+	// Move to the next day with a child added
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{
+		AvailabilityId: second,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}, {UnitId: "child_id"}},
+	}, pricing)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var moved model.BookingPayload_Rs
+	decodeBody(t, rec, &moved)
+	if moved.AvailabilityId != second || len(moved.Units) != 3 || moved.Price <= booking.Price || moved.Status != "RESERVED" {
+		t.Errorf("unexpected updated booking %+v", moved)
+	}
+	if a, _ := repo.GetAvailabilityByID(first); a.Vacancies != 10 {
+		t.Errorf("expected the old slot to get its vacancies back, got %d", a.Vacancies)
+	}
+	if a, _ := repo.GetAvailabilityByID(second); a.Vacancies != 7 {
+		t.Errorf("expected 7 vacancies left on the new slot, got %d", a.Vacancies)
+	}
+
+	// Notes alone keep the units and price
+	notes := "Vegetarian lunch"
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Notes: &notes}, pricing)
+	var noted model.BookingPayload_Rs
+	decodeBody(t, rec, &noted)
+	if noted.Notes == nil || *noted.Notes != notes || noted.Price != moved.Price || noted.Units[0].ID != moved.Units[0].ID {
+		t.Errorf("unexpected booking after setting notes %+v", noted)
+	}
+
+	// Failed updates leave the booking and vacancies untouched
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{AvailabilityId: "missing"}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.InvalidAvailabilityIDCode)
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{UnitItems: []model.UnitItemPayload_Rq{{UnitId: "child_id"}}}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.UnprocessableEntityCode)
+	if err := repo.UpdateAvailability(first, model.AvailabilityStatusClosed, nil); err != nil {
+		t.Fatalf("error was not expected while closing availability: %s", err)
+	}
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{AvailabilityId: first}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+	if a, _ := repo.GetAvailabilityByID(second); a.Vacancies != 7 {
+		t.Errorf("expected 7 vacancies left on the booked slot, got %d", a.Vacancies)
+	}
+
+	// Confirmed bookings get tickets for their new units
+	doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}, nil)
+	var amended model.BookingPayload_Rs
+	decodeBody(t, rec, &amended)
+	if amended.Status != "CONFIRMED" || len(amended.Units) != 1 || amended.Units[0].Ticket == nil {
+		t.Errorf("unexpected amended booking %+v", amended)
+	}
+
+	doRequest(t, h, "POST", "/bookings/"+booking.ID+"/cancel", nil, nil)
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Notes: &notes}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	rec = doRequest(t, h, "PATCH", "/bookings/missing", model.BookingUpdatePayload_Rq{Notes: &notes}, nil)
+	expectError(t, rec, http.StatusNotFound, octoerr.InvalidBookingUUIDCode)
+}
+
+func TestServerAmendBookingOnClosedSlot(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	slot := availabilities[0].ID
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: slot,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	if err := repo.UpdateAvailability(slot, model.AvailabilityStatusClosed, nil); err != nil {
+		t.Fatalf("error was not expected while closing availability: %s", err)
+	}
+
+	// Notes leave the slot alone, so closing it does not block them
+	notes := "Vegetarian lunch"
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Notes: &notes}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if a, _ := repo.GetAvailabilityByID(slot); a.Status != model.AvailabilityStatusClosed || a.Vacancies != 8 {
+		t.Errorf("expected the slot to stay CLOSED with 8 vacancies, got %s with %d", a.Status, a.Vacancies)
+	}
+
+	// Adding a guest still needs an open slot
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}, {UnitId: "adult_id"}}}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+}
+
+func TestServerAmendRedeemedBooking(t *testing.T) {
+	now := testNow
+	h, repo := newTestServer(t, WithClock(func() time.Time { return now }))
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+
+	// Check one adult in, then amend before the cutoff as the clock allows
+	checkedIn := confirmed.Units[1]
+	now = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	rec = doRequest(t, h, "POST", "/tickets/redeem", model.TicketRedemptionPayload_Rq{Reference: checkedIn.Ticket.Reference, Device: "gate-1"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	now = testNow
+
+	// The checked-in guest cannot be moved to another slot
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{
+		AvailabilityId: availabilities[1].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}},
+	}, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+	if a, _ := repo.GetAvailabilityByID(availabilities[1].ID); a.Vacancies != 10 {
+		t.Errorf("expected the other slot to keep its vacancies, got %d", a.Vacancies)
+	}
+
+	// Dropping an adult drops the one not checked in
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{
+		UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var amended model.BookingPayload_Rs
+	decodeBody(t, rec, &amended)
+	if len(amended.Units) != 1 || amended.Units[0].ID != checkedIn.ID {
+		t.Fatalf("expected the checked-in adult to remain, got %+v", amended.Units)
+	}
+	if ticket := amended.Units[0].Ticket; ticket.Reference != checkedIn.Ticket.Reference || ticket.UtcRedeemedAt == nil || ticket.RedeemedBy == nil || *ticket.RedeemedBy != "gate-1" {
+		t.Errorf("expected the redeemed ticket to be kept, got %+v", ticket)
+	}
+}
+
+func TestServerAmendConfirmedBookingKeepsUnits(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "child_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	adult, child := booking.UnitItems[0].ID, booking.UnitItems[1].ID
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", model.BookingConfirmationPayload_Rq{
+		UnitItems: []model.BookingUnitContactPayload_Rq{{ID: child, Contact: model.Contact{FullName: "Byron Lovelace"}}},
+	}, nil)
+	var confirmed model.BookingPayload_Rs
+	decodeBody(t, rec, &confirmed)
+	tickets := map[string]string{}
+	for _, unit := range confirmed.Units {
+		tickets[unit.ID] = unit.Ticket.Reference
+	}
+
+	// Adding an adult keeps the units already booked as they were
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{
+		UnitItems: []model.UnitItemPayload_Rq{{UnitId: "child_id"}, {UnitId: "adult_id"}, {UnitId: "adult_id"}},
+	}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var amended model.BookingPayload_Rs
+	decodeBody(t, rec, &amended)
+	if len(amended.Units) != 3 || amended.Voucher == nil || amended.Voucher.Reference != confirmed.Voucher.Reference {
+		t.Fatalf("unexpected amended booking %+v", amended)
+	}
+	for _, unit := range amended.Units {
+		switch unit.ID {
+		case adult:
+		case child:
+			if unit.Contact == nil || unit.Contact.FullName != "Byron Lovelace" {
+				t.Errorf("expected the child to keep their contact, got %+v", unit.Contact)
+			}
+		default:
+			if unit.UnitId != "adult_id" || unit.Ticket == nil || unit.Ticket.Reference == tickets[adult] || unit.Ticket.Reference == tickets[child] {
+				t.Errorf("expected a new adult with a new ticket, got %+v", unit)
+			}
+			continue
+		}
+		if unit.Ticket == nil || unit.Ticket.Reference != tickets[unit.ID] {
+			t.Errorf("expected unit %s to keep ticket %s, got %+v", unit.ID, tickets[unit.ID], unit.Ticket)
+		}
+	}
+
+	// Dropping the child and an adult keeps the other adult
+	for _, unit := range amended.Units {
+		tickets[unit.ID] = unit.Ticket.Reference
+	}
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{
+		UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	decodeBody(t, rec, &amended)
+	if len(amended.Units) != 1 || amended.Units[0].ID == child || amended.Units[0].Ticket.Reference != tickets[amended.Units[0].ID] {
+		t.Errorf("expected one of the adults to remain with their ticket, got %+v", amended.Units)
+	}
+}
+
+func TestServerUpdateBookingAfterCutoff(t *testing.T) {
+	// The product's 24 hour cutoff before 2024-03-01 00:00 Europe/London has passed
+	h, repo := newTestServer(t, WithClock(func() time.Time { return time.Date(2024, 2, 29, 6, 0, 0, 0, time.UTC) }))
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+
+	// Reservations are not bound by the cutoff
+	notes := "Late arrival"
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Notes: &notes}, nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID, model.BookingUpdatePayload_Rq{Notes: &notes}, nil)
+	expectError(t, rec, http.StatusBadRequest, octoerr.UnprocessableEntityCode)
+}
+
+func TestServerCancelBookingAfterCutoff(t *testing.T) {
+	// The product's 24 hour cutoff before 2024-03-01 00:00 Europe/London has passed
+	h, repo := newTestServer(t, WithClock(func() time.Time { return time.Date(2024, 2, 29, 6, 0, 0, 0, time.UTC) }))
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/cancel", nil, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body)
+	}
+	if b, _ := repo.GetBookingByID(booking.ID); b.Status != "RESERVED" {
+		t.Errorf("expected the booking to stay RESERVED, got %s", b.Status)
+	}
+}
+
+func TestServerReservationExpiry(t *testing.T) {
+	now := testNow
+	h, repo := newTestServer(t, WithClock(func() time.Time { return now }))
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec := doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId:    availabilities[0].ID,
+		UnitItems:         []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+		ExpirationMinutes: 10,
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	if booking.UtcExpiresAt == nil || !booking.UtcExpiresAt.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("expected the reservation to expire 10 minutes from now, got %v", booking.UtcExpiresAt)
+	}
+
+	// Extending without a body uses the default hold time
+	now = now.Add(5 * time.Minute)
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID+"/extend", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var extended model.BookingPayload_Rs
+	decodeBody(t, rec, &extended)
+	if extended.UtcExpiresAt == nil || !extended.UtcExpiresAt.Equal(now.Add(DefaultReservationExpiry)) {
+		t.Errorf("expected the reservation to expire %s from now, got %v", DefaultReservationExpiry, extended.UtcExpiresAt)
+	}
+
+	// Once the hold has passed the booking can no longer be confirmed or extended
+	now = now.Add(DefaultReservationExpiry)
+	rec = doRequest(t, h, "POST", "/bookings/"+booking.ID+"/confirm", nil, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d confirming an expired reservation, got %d", http.StatusConflict, rec.Code)
+	}
+	rec = doRequest(t, h, "PATCH", "/bookings/"+booking.ID+"/extend", model.BookingExtendPayload_Rq{ExpirationMinutes: 15}, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d extending an expired reservation, got %d", http.StatusConflict, rec.Code)
+	}
+
+	rec = doRequest(t, h, "PATCH", "/bookings/missing/extend", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	rec = doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId:    availabilities[0].ID,
+		UnitItems:         []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+		ExpirationMinutes: -1,
+	}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a negative expirationMinutes, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestServerBookingUUID(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	availabilities, _ := repo.GetAvailabilities(day, day)
+	availabilityID := availabilities[0].ID
+
+	payload := model.BookingPayload_Rq{
+		UUID:           "5b5e1f3c-3f0e-4c1e-9a55-6f0d6a2b7c11",
+		AvailabilityId: availabilityID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "adult_id"}},
+	}
+	rec := doRequest(t, h, "POST", "/bookings", payload, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var booking model.Booking
+	decodeBody(t, rec, &booking)
+	if booking.UUID != payload.UUID || booking.ID == payload.UUID {
+		t.Errorf("expected the reseller uuid next to a generated ID, got %+v", booking)
+	}
+
+	// Retrying returns the original booking without booking again
+	rec = doRequest(t, h, "POST", "/bookings", payload, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var replayed model.BookingPayload_Rs
+	decodeBody(t, rec, &replayed)
+	if replayed.ID != booking.ID || replayed.UUID != payload.UUID || len(replayed.Units) != 2 {
+		t.Errorf("expected the original booking, got %+v", replayed)
+	}
+	availability, _ := repo.GetAvailabilityByID(availabilityID)
+	if availability.Vacancies != 8 {
+		t.Errorf("expected 8 vacancies left, got %d", availability.Vacancies)
+	}
+
+	// Reusing the uuid for another request is a conflict
+	payload.UnitItems = payload.UnitItems[:1]
+	rec = doRequest(t, h, "POST", "/bookings", payload, nil)
+	expectError(t, rec, http.StatusConflict, octoerr.InvalidBookingUUIDCode)
+
+	// Without a uuid the booking ID is used
+	rec = doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilityID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	decodeBody(t, rec, &booking)
+	rec = doRequest(t, h, "GET", "/bookings/"+booking.ID, nil, nil)
+	var shown model.BookingPayload_Rs
+	decodeBody(t, rec, &shown)
+	if shown.UUID != booking.ID {
+		t.Errorf("expected uuid %q, got %q", booking.ID, shown.UUID)
+	}
+}
+
+func TestServerIdempotencyKey(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	key := map[string]string{IdempotencyKeyHeader: "create-boat-tour"}
+	payload := model.ProductPayload_Rq{Name: "Boat Tour", Capacity: 20, Price: 3000}
+
+	first := doRequest(t, h, "POST", "/products/new", payload, key)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, first.Code, first.Body)
+	}
+	second := doRequest(t, h, "POST", "/products/new", payload, key)
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("expected the first response to be replayed, got %d: %s", second.Code, second.Body)
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expected the replay to be marked")
+	}
+	products, _ := repo.GetProducts()
+	if len(products) != 2 {
+		t.Errorf("expected one product to be added, got %d products", len(products))
+	}
+
+	// The same key with another body is a conflict
+	payload.Name = "Bus Tour"
+	rec := doRequest(t, h, "POST", "/products/new", payload, key)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	// Keys are scoped to the route, and reads ignore them
+	rec = doRequest(t, h, "POST", "/availability/add", model.AvailabilityNewPayload_Rq{ProductId: "product_id", LocalDate: "2024-03-10", Price: 500}, key)
+	if rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("expected a fresh response, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "GET", "/products", nil, key)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	// Replays carry the recorded headers, such as the applied capabilities
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	booking := model.BookingPayload_Rq{AvailabilityId: availabilities[0].ID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}
+	headers := map[string]string{IdempotencyKeyHeader: "book-boat-tour", OctoCapabilitiesHeader: CapabilityPricing}
+	first = doRequest(t, h, "POST", "/bookings", booking, headers)
+	second = doRequest(t, h, "POST", "/bookings", booking, headers)
+	if second.Header().Get(IdempotentReplayedHeader) != "true" || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected the booking to be replayed, got %d: %s", second.Code, second.Body)
+	}
+	if got := second.Header().Get(OctoCapabilitiesHeader); got != CapabilityPricing {
+		t.Errorf("expected the replay to keep %s %q, got %q", OctoCapabilitiesHeader, CapabilityPricing, got)
+	}
+}
+
+func TestIdempotentReleasesUnfinishedClaims(t *testing.T) {
+	repo := store.NewMemoryStore()
+	now := testNow
+	s := NewServerWithRepository(repo, WithClock(func() time.Time { return now }))
+
+	calls := 0
+	h := s.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/products/new", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A panic still reaches the server, but frees the key for the retry
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be passed on")
+			}
+		}()
+		send()
+	}()
+	if rec := send(); rec.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected the retry to be handled, got %d after %d calls", rec.Code, calls)
+	}
+
+	// A claim left behind by a request that never finished blocks retries
+	// until it goes stale
+	if _, err := repo.ClaimIdempotencyKey("POST /products/new crashed", "hash", now, now); err != nil {
+		t.Fatalf("error was not expected while claiming key: %s", err)
+	}
+	req := httptest.NewRequest("POST", "/products/new", strings.NewReader(""))
+	req.Header.Set(IdempotencyKeyHeader, "crashed")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+
+	now = now.Add(IdempotencyClaimTimeout + time.Second)
+	req = httptest.NewRequest("POST", "/products/new", strings.NewReader(""))
+	req.Header.Set(IdempotencyKeyHeader, "crashed")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected the stale claim to be taken over, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServerPostBookingInvalid(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	availabilities, _ := repo.GetAvailabilities(day, day)
+	availabilityID := availabilities[0].ID
+
+	tests := []struct {
+		name    string
+		payload model.BookingPayload_Rq
+	}{
+		{"invalid uuid", model.BookingPayload_Rq{UUID: "not-a-uuid", AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}},
+		{"unknown availability", model.BookingPayload_Rq{AvailabilityId: "missing", UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}},
+		{"mismatched product", model.BookingPayload_Rq{ProductId: "other", AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}},
+		{"unknown option", model.BookingPayload_Rq{OptionId: "missing", AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}},
+		{"no unit items", model.BookingPayload_Rq{AvailabilityId: availabilityID}},
+		{"unknown unit", model.BookingPayload_Rq{AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "missing"}}}},
+		{"unaccompanied child", model.BookingPayload_Rq{AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "child_id"}}}},
+		{"too many children", model.BookingPayload_Rq{AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}, {UnitId: "child_id"}, {UnitId: "child_id"}, {UnitId: "child_id"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, h, "POST", "/bookings", tt.payload, nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestServerUpdateAvailability(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	availabilities, _ := repo.GetAvailabilities(day, day)
+	availabilityID := availabilities[0].ID
+	booking := model.BookingPayload_Rq{AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}}
+
+	rec := doRequest(t, h, "PATCH", "/availability/"+availabilityID, model.AvailabilityUpdatePayload_Rq{Status: "closed"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var availability model.Availability
+	decodeBody(t, rec, &availability)
+	if availability.Status != model.AvailabilityStatusClosed || availability.Available {
+		t.Errorf("expected a closed availability, got %+v", availability)
+	}
+
+	rec = doRequest(t, h, "POST", "/bookings", booking, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d booking a closed availability, got %d: %s", http.StatusConflict, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "PATCH", "/availability/"+availabilityID, model.AvailabilityUpdatePayload_Rq{Status: model.AvailabilityStatusAvailable}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "POST", "/bookings", booking, nil)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d after reopening, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(t, h, "PATCH", "/availability/"+availabilityID, model.AvailabilityUpdatePayload_Rq{Status: model.AvailabilityStatusSoldOut}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doRequest(t, h, "PATCH", "/availability/missing", model.AvailabilityUpdatePayload_Rq{Status: model.AvailabilityStatusClosed}, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestServerCheckAvailability(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	one := 1
+	rec := doRequest(t, h, "PATCH", "/availability/"+availabilities[0].ID, model.AvailabilityUpdatePayload_Rq{Vacancies: &one}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	check := model.AvailabilityCheckPayload_Rq{
+		ProductId:      "product_id",
+		OptionId:       "option_id",
+		LocalDateStart: "2024-03-01",
+		LocalDateEnd:   "2024-03-03",
+		Units:          []model.UnitQuantityPayload_Rq{{Id: "adult_id", Quantity: 2}},
+	}
+	rec = doRequest(t, h, "POST", "/availability", check, map[string]string{OctoCapabilitiesHeader: "octo/pricing"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var slots []model.AvailabilityCheckPayload_Rs_Pricing
+	decodeBody(t, rec, &slots)
+	if len(slots) != 2 || slots[0].Id == availabilities[0].ID || slots[1].Id == availabilities[0].ID {
+		t.Fatalf("expected the two slots with room for 2 adults, got %+v", slots)
+	}
+	if !slots[0].AllDay || slots[0].Capacity != 10 || len(slots[0].OpeningHours) != 1 {
+		t.Errorf("unexpected slot %+v", slots[0])
+	}
+	if slots[0].Pricing == nil || slots[0].Pricing.Retail != 12000 || len(slots[0].UnitPricing) != 2 {
+		t.Errorf("expected pricing of 12000 with 2 unit prices, got %+v %+v", slots[0].Pricing, slots[0].UnitPricing)
+	}
+
+	check = model.AvailabilityCheckPayload_Rq{ProductId: "product_id", OptionId: "option_id", AvailabilityIds: []string{availabilities[0].ID}}
+	rec = doRequest(t, h, "POST", "/availability", check, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var plain []model.AvailabilityCheckPayload_Rs_Pricing
+	decodeBody(t, rec, &plain)
+	if len(plain) != 1 || plain[0].Id != availabilities[0].ID || plain[0].Vacancies != 1 {
+		t.Errorf("expected the requested slot, got %+v", plain)
+	}
+
+	for _, invalid := range []model.AvailabilityCheckPayload_Rq{
+		{ProductId: "product_id", LocalDate: "2024-03-01"},
+		{ProductId: "product_id", OptionId: "missing", LocalDate: "2024-03-01"},
+		{ProductId: "product_id", OptionId: "option_id", AvailabilityIds: []string{"missing"}},
+		{ProductId: "product_id", OptionId: "option_id", LocalDate: "2024-03-01", Units: []model.UnitQuantityPayload_Rq{{Id: "adult_id", Quantity: -1}}},
+	} {
+		rec = doRequest(t, h, "POST", "/availability", invalid, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %+v, got %d", http.StatusBadRequest, invalid, rec.Code)
+		}
+	}
+}
+
+func TestServerAvailabilityCalendar(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC))
+	one := 1
+	rec := doRequest(t, h, "PATCH", "/availability/"+availabilities[0].ID, model.AvailabilityUpdatePayload_Rq{Status: model.AvailabilityStatusClosed}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "PATCH", "/availability/"+availabilities[1].ID, model.AvailabilityUpdatePayload_Rq{Vacancies: &one}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	calendar := model.AvailabilityCalendarPayload_Rq{
+		ProductId:      "product_id",
+		OptionId:       "option_id",
+		LocalDateStart: "2024-03-01",
+		LocalDateEnd:   "2024-03-04",
+		Units:          []model.UnitQuantityPayload_Rq{{Id: "adult_id", Quantity: 2}},
+	}
+	rec = doRequest(t, h, "POST", "/availability/calendar", calendar, map[string]string{OctoCapabilitiesHeader: "octo/pricing"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var days []model.AvailabilityCalendarPayload_Rs_Pricing
+	decodeBody(t, rec, &days)
+	if len(days) != 4 {
+		t.Fatalf("expected 4 days, got %d", len(days))
+	}
+
+	first := days[0]
+	if !first.Available || first.Status != model.AvailabilityStatusAvailable || first.Vacancies != 10 || first.Capacity != 10 {
+		t.Errorf("unexpected first day %+v", first)
+	}
+	if len(first.OpeningHours) != 1 || first.OpeningHours[0] != (model.OpeningHours{From: "00:00", To: "24:00"}) {
+		t.Errorf("unexpected opening hours %+v", first.OpeningHours)
+	}
+	if len(first.UnitPricingFrom) != 2 || first.UnitPricingFrom[0].Retail != 6000 || first.UnitPricingFrom[1].Retail != 3500 {
+		t.Errorf("unexpected unit pricing %+v", first.UnitPricingFrom)
+	}
+	if first.PricingFrom == nil || first.PricingFrom.Retail != 12000 {
+		t.Errorf("expected pricing from 12000, got %+v", first.PricingFrom)
+	}
+
+	for i, want := range []string{model.AvailabilityStatusClosed, model.AvailabilityStatusSoldOut, model.AvailabilityStatusClosed} {
+		day := days[i+1]
+		if day.Available || day.Status != want || day.PricingFrom != nil {
+			t.Errorf("expected %s on %s, got %+v", want, day.LocalDate, day)
+		}
+	}
+	if days[2].Vacancies != 1 {
+		t.Errorf("expected 1 vacancy on %s, got %d", days[2].LocalDate, days[2].Vacancies)
+	}
+
+	calendar.LocalDateEnd = "2024-02-01"
+	rec = doRequest(t, h, "POST", "/availability/calendar", calendar, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	calendar.LocalDateEnd = "2024-03-04"
+	calendar.Units = []model.UnitQuantityPayload_Rq{{Id: "child_id", Quantity: 1}}
+	rec = doRequest(t, h, "POST", "/availability/calendar", calendar, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unaccompanied child, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestServerAddProductWithOptions(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	rec := doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{
+		Name:     "Zoo",
+		Capacity: 50,
+		Currency: "EUR",
+		Options: []model.OptionPayload_Rq{{
+			InternalName: "Morning",
+			Units: []model.UnitPayload_Rq{
+				{InternalName: "Adult", Type: "adult", Price: 2000},
+				{InternalName: "Infant", Type: "INFANT", Price: 0, Restrictions: model.UnitRestrictions{MaxAge: 2, AccompaniedBy: []string{"ADULT"}}},
+			},
+		}},
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	products, _ := repo.GetProducts()
+	options := products[1].Options
+	if len(options) != 1 || !options[0].Default || len(options[0].Units) != 2 {
+		t.Fatalf("unexpected options %+v", options)
+	}
+	var adult, infant model.Unit
+	for _, u := range options[0].Units {
+		if u.Type == model.UnitTypeAdult {
+			adult = u
+		} else {
+			infant = u
+		}
+	}
+	if adult.Currency != "EUR" || infant.Type != model.UnitTypeInfant {
+		t.Errorf("unexpected units %+v %+v", adult, infant)
+	}
+	if len(infant.Restrictions.AccompaniedBy) != 1 || infant.Restrictions.AccompaniedBy[0] != adult.ID {
+		t.Errorf("expected infant to be accompanied by %s, got %v", adult.ID, infant.Restrictions.AccompaniedBy)
+	}
+
+	rec = doRequest(t, h, "POST", "/products/new", model.ProductPayload_Rq{
+		Name:    "Zoo",
+		Options: []model.OptionPayload_Rq{{InternalName: "Morning", Units: []model.UnitPayload_Rq{{Type: "PET"}}}},
+	}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	h, repo := newTestServer(t)
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	availabilities, _ := repo.GetAvailabilities(day, day)
+	availabilityID := availabilities[0].ID
+	adult := []model.UnitItemPayload_Rq{{UnitId: "adult_id"}}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+		code   octoerr.Code
+	}{
+		{"unknown product", "GET", "/products/missing", nil, http.StatusNotFound, octoerr.InvalidProductIDCode},
+		{"product with bad cutoff", "POST", "/products/new", model.ProductPayload_Rq{Name: "Tour", CancellationCutoffUnit: "week"}, http.StatusBadRequest, octoerr.BadRequestCode},
+		{"product of unknown supplier", "POST", "/products/new", model.ProductPayload_Rq{SupplierId: "missing", Name: "Tour"}, http.StatusBadRequest, octoerr.BadRequestCode},
+		{"availability of unknown product", "GET", "/availability?localDate=2024-03-01&productId=missing", nil, http.StatusNotFound, octoerr.InvalidProductIDCode},
+		{"availability with bad dates", "GET", "/availability?localDate=tomorrow", nil, http.StatusBadRequest, octoerr.BadRequestCode},
+		{"check without option", "POST", "/availability", model.AvailabilityCheckPayload_Rq{ProductId: "product_id", LocalDate: "2024-03-01"}, http.StatusBadRequest, octoerr.InvalidOptionIDCode},
+		{"check of unknown product", "POST", "/availability", model.AvailabilityCheckPayload_Rq{ProductId: "missing", OptionId: "option_id", LocalDate: "2024-03-01"}, http.StatusBadRequest, octoerr.InvalidProductIDCode},
+		{"check of unknown availability", "POST", "/availability", model.AvailabilityCheckPayload_Rq{ProductId: "product_id", OptionId: "option_id", AvailabilityIds: []string{"missing"}}, http.StatusBadRequest, octoerr.InvalidAvailabilityIDCode},
+		{"calendar of unknown unit", "POST", "/availability/calendar", model.AvailabilityCalendarPayload_Rq{ProductId: "product_id", LocalDateStart: "2024-03-01", LocalDateEnd: "2024-03-02", Units: []model.UnitQuantityPayload_Rq{{Id: "missing", Quantity: 1}}}, http.StatusBadRequest, octoerr.InvalidUnitIDCode},
+		{"add availability to unknown product", "POST", "/availability/add", model.AvailabilityNewPayload_Rq{ProductId: "missing", LocalDate: "2024-03-10"}, http.StatusBadRequest, octoerr.InvalidProductIDCode},
+		{"update unknown availability", "PATCH", "/availability/missing", model.AvailabilityUpdatePayload_Rq{Status: model.AvailabilityStatusClosed}, http.StatusNotFound, octoerr.InvalidAvailabilityIDCode},
+		{"book unknown availability", "POST", "/bookings", model.BookingPayload_Rq{AvailabilityId: "missing", UnitItems: adult}, http.StatusBadRequest, octoerr.InvalidAvailabilityIDCode},
+		{"book unknown option", "POST", "/bookings", model.BookingPayload_Rq{AvailabilityId: availabilityID, OptionId: "missing", UnitItems: adult}, http.StatusBadRequest, octoerr.InvalidOptionIDCode},
+		{"book unaccompanied child", "POST", "/bookings", model.BookingPayload_Rq{AvailabilityId: availabilityID, UnitItems: []model.UnitItemPayload_Rq{{UnitId: "child_id"}}}, http.StatusBadRequest, octoerr.UnprocessableEntityCode},
+		{"get unknown booking", "GET", "/bookings/missing", nil, http.StatusNotFound, octoerr.InvalidBookingUUIDCode},
+		{"confirm unknown booking", "POST", "/bookings/missing/confirm", nil, http.StatusNotFound, octoerr.InvalidBookingUUIDCode},
+		{"cancel unknown booking", "DELETE", "/bookings/missing", nil, http.StatusNotFound, octoerr.InvalidBookingUUIDCode},
+		{"extend unknown booking", "PATCH", "/bookings/missing/extend", nil, http.StatusNotFound, octoerr.InvalidBookingUUIDCode},
+		{"schedules of unknown product", "GET", "/products/missing/schedules", nil, http.StatusNotFound, octoerr.InvalidProductIDCode},
+		{"invalid schedule rule", "POST", "/products/product_id/schedules", model.ScheduleRulePayload_Rq{ValidFrom: "2024-03-01", Weekdays: []string{"FUNDAY"}}, http.StatusBadRequest, octoerr.BadRequestCode},
+		{"delete unknown schedule rule", "DELETE", "/schedules/missing", nil, http.StatusNotFound, octoerr.BadRequestCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, doRequest(t, h, tt.method, tt.path, tt.body, nil), tt.status, tt.code)
+		})
+	}
+
+	t.Run("booking conflict", func(t *testing.T) {
+		rec := doRequest(t, h, "PATCH", "/availability/"+availabilityID, model.AvailabilityUpdatePayload_Rq{Status: model.AvailabilityStatusClosed}, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		rec = doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{AvailabilityId: availabilityID, UnitItems: adult}, nil)
+		expectError(t, rec, http.StatusConflict, octoerr.UnprocessableEntityCode)
+	})
+}
+
+// brokenRepository fails every product lookup like an unreachable database.
+type brokenRepository struct {
+	store.Repository
+}
+
+func (brokenRepository) GetProduct(productID string) (*model.Product, error) {
+	return nil, errors.New("pq: connection refused")
+}
+
+func TestServerInternalError(t *testing.T) {
+	_, repo := newTestServer(t)
+	h := NewServerWithRepository(brokenRepository{repo}, WithAdminAPIKey(testAdminKey)).Routes()
+
+	rec := doRequest(t, h, "GET", "/products/product_id", nil, nil)
+	if body := rec.Body.String(); strings.Contains(body, "pq:") {
+		t.Errorf("expected the database error not to leak, got %s", body)
+	}
+	expectError(t, rec, http.StatusInternalServerError, octoerr.InternalServerErrorCode)
+}
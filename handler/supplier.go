@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+)
+
+// GetSupplier godoc
+// @Summary Get the supplier
+// @Description Returns the supplier (operator) this API acts for, per OCTO: the API key's supplier, or the configured one
+// @Tags supplier
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} model.Supplier "Success"
+// @Failure 404 {object} octoerr.Error "Supplier not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /supplier [get]
+func (s *Server) GetSupplier(w http.ResponseWriter, r *http.Request) {
+
+	supplier, err := s.defaultSupplier(r)
+	if err == sql.ErrNoRows {
+		octoerr.Write(w, octoerr.BadRequest("Supplier not found").WithStatus(http.StatusNotFound))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(supplier)
+}
+
+// defaultSupplier returns the supplier of the caller's API key, else the
+// configured supplier, or the first supplier on record when none is
+// configured. It returns sql.ErrNoRows if there is none.
+func (s *Server) defaultSupplier(r *http.Request) (*model.Supplier, error) {
+	if key := caller(r); key != nil && key.SupplierId != "" {
+		return s.repo.GetSupplier(key.SupplierId)
+	}
+	if s.supplierID != "" {
+		return s.repo.GetSupplier(s.supplierID)
+	}
+
+	suppliers, err := s.repo.GetSuppliers()
+	if err != nil {
+		return nil, err
+	}
+	if len(suppliers) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &suppliers[0], nil
+}
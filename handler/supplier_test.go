@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"octo-api/model"
+	"octo-api/store"
+	"testing"
+)
+
+func TestGetSupplier(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doRequest(t, h, "GET", "/supplier", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var supplier model.Supplier
+	decodeBody(t, rec, &supplier)
+	if supplier.ID != "supplier_id" || supplier.Timezone != "Europe/London" {
+		t.Errorf("unexpected supplier %+v", supplier)
+	}
+}
+
+func TestGetSupplierConfigured(t *testing.T) {
+	repo := store.NewMemoryStore()
+	repo.InsertSupplier(model.Supplier{ID: "a", Name: "First", Timezone: "UTC"})
+	repo.InsertSupplier(model.Supplier{ID: "b", Name: "Second", Timezone: "UTC"})
+
+	h := NewServerWithRepository(repo, WithSupplierID("b"), WithAdminAPIKey(testAdminKey)).Routes()
+	rec := doRequest(t, h, "GET", "/supplier", nil, nil)
+	var supplier model.Supplier
+	decodeBody(t, rec, &supplier)
+	if supplier.ID != "b" {
+		t.Errorf("expected the configured supplier, got %+v", supplier)
+	}
+
+	h = NewServerWithRepository(store.NewMemoryStore(), WithAdminAPIKey(testAdminKey)).Routes()
+	rec = doRequest(t, h, "GET", "/supplier", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"octo-api/store"
+	"strings"
+	"time"
+)
+
+// RedeemTicket godoc
+// @Summary Redeem a ticket
+// @Description Checks guests in at the gate. Scanning a ticket reference admits its unit; scanning a booking's voucher reference admits every unit not yet admitted. The booking must be CONFIRMED and for today's availability in the supplier's timezone, and each ticket can be redeemed once.
+// @Tags ticket
+// @Accept  json
+// @Produce  json
+// @Param   TicketRedemptionPayload_Rq body model.TicketRedemptionPayload_Rq true "Scanned reference and scanning device"
+// @Success 200 {object} model.BookingPayload_Rs "Tickets redeemed"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 404 {object} octoerr.Error "Unknown voucher or ticket reference"
+// @Failure 409 {object} octoerr.Error "Booking is not confirmed or not for today, or the ticket was already redeemed"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /tickets/redeem [post]
+func (s *Server) RedeemTicket(w http.ResponseWriter, r *http.Request) {
+
+	var redemption model.TicketRedemptionPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&redemption); err != nil {
+		fmt.Println(err.Error())
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+	// References are often typed in by hand
+	reference := strings.ToUpper(strings.TrimSpace(redemption.Reference))
+	device := strings.TrimSpace(redemption.Device)
+	if reference == "" || device == "" {
+		octoerr.Write(w, octoerr.BadRequest("reference and device are required"))
+		return
+	}
+
+	booking, err := s.repo.GetBookingByReference(reference)
+	if errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("Unknown voucher or ticket reference %q", reference)).WithStatus(http.StatusNotFound))
+		return
+	}
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if booking.Status != model.BookingStatusConfirmed {
+		writeBookingError(w, booking.ID, store.ErrBookingNotConfirmed)
+		return
+	}
+
+	// Tickets are only valid on the day of their availability
+	availability, _, supplier, err := s.bookedSlot(booking.AvailabilityId)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	loc := supplierLocation(supplier)
+	day := availability.LocalDateTimeStart.In(loc).Format("2006-01-02")
+	if today := s.now().In(loc).Format("2006-01-02"); day != today {
+		octoerr.Write(w, octoerr.UnprocessableEntity(fmt.Sprintf("Ticket is valid on %s, not today (%s)", day, today)).WithStatus(http.StatusConflict))
+		return
+	}
+
+	// A voucher admits every unit still waiting, a ticket only its own
+	var unitIDs []string
+	for _, unit := range booking.Units {
+		ticket := unit.Ticket
+		if ticket == nil {
+			continue
+		}
+		if ticket.Reference == reference && ticket.UtcRedeemedAt != nil {
+			octoerr.Write(w, octoerr.UnprocessableEntity(fmt.Sprintf("Ticket %s was already redeemed at %s by %s",
+				reference, ticket.UtcRedeemedAt.Format(time.RFC3339), *ticket.RedeemedBy)).WithStatus(http.StatusConflict))
+			return
+		}
+		if ticket.UtcRedeemedAt == nil && (ticket.Reference == reference || booking.Voucher != nil && booking.Voucher.Reference == reference) {
+			unitIDs = append(unitIDs, unit.ID)
+		}
+	}
+	if len(unitIDs) == 0 {
+		writeBookingError(w, booking.ID, store.ErrTicketRedeemed)
+		return
+	}
+
+	if err := s.repo.RedeemTickets(booking.ID, unitIDs, device, s.now()); err != nil {
+		writeBookingError(w, booking.ID, err)
+		return
+	}
+
+	if booking, err = s.repo.GetBookingByID(booking.ID); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+	if err := s.deliver(booking); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	writeOcto(w, r, http.StatusOK, booking)
+}
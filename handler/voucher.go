@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"octo-api/voucher"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetVoucher godoc
+// @Summary Get a voucher as PDF
+// @Description Renders the voucher of a confirmed booking, or with unitItemId the ticket of one of its units, as a printable PDF. This is the document PDF_URL delivery options link to.
+// @Tags booking
+// @Produce  application/pdf
+// @Param   id path string true "Booking ID"
+// @Param   unitItemId query string false "Booking unit ID, to render that unit's ticket"
+// @Success 200 {file} file "PDF voucher"
+// @Failure 404 {object} octoerr.Error "Booking or unit item not found, or the product does not deliver this voucher as PDF"
+// @Failure 409 {object} octoerr.Error "Booking is not confirmed"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /bookings/{id}/voucher [get]
+func (s *Server) GetVoucher(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+	unitItemID := r.URL.Query().Get("unitItemId")
+
+	booking, err := s.repo.GetBookingByID(bookingID)
+	if err != nil {
+		writeBookingError(w, bookingID, err)
+		return
+	}
+	if booking.Status != model.BookingStatusConfirmed || booking.Voucher == nil {
+		octoerr.Write(w, octoerr.UnprocessableEntity("Booking is not confirmed").WithStatus(http.StatusConflict))
+		return
+	}
+
+	availability, product, supplier, err := s.bookedSlot(booking.AvailabilityId)
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	method := model.DeliveryMethodVoucher
+	if unitItemID != "" {
+		method = model.DeliveryMethodTicket
+	}
+	if !contains(product.DeliveryMethods, method) || !contains(product.DeliveryFormats, model.DeliveryFormatPDFURL) {
+		octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("Product %s does not deliver a PDF %s", product.ID, strings.ToLower(method))).WithStatus(http.StatusNotFound))
+		return
+	}
+
+	start := availability.LocalDateTimeStart.In(supplierLocation(supplier))
+	data := voucher.Data{
+		Supplier:      supplier.Name,
+		Product:       product.Name,
+		Start:         start.Format("Mon 2 Jan 2006 15:04"),
+		BookingID:     booking.ID,
+		Reference:     booking.Voucher.Reference,
+		LeadTraveller: booking.Contact.FullName,
+	}
+	unitNames := map[string]string{}
+	for _, o := range product.Options {
+		if o.ID == booking.OptionId {
+			data.Option = o.InternalName
+			for _, u := range o.Units {
+				unitNames[u.ID] = u.InternalName
+			}
+		}
+	}
+	for _, unit := range booking.Units {
+		if unit.Ticket == nil || (unitItemID != "" && unit.ID != unitItemID) {
+			continue
+		}
+		ticket := voucher.Ticket{Unit: unitNames[unit.UnitId], Reference: unit.Ticket.Reference}
+		if unit.Contact != nil {
+			ticket.Guest = unit.Contact.FullName
+		}
+		data.Tickets = append(data.Tickets, ticket)
+	}
+	if unitItemID != "" {
+		if len(data.Tickets) == 0 {
+			octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("Unit item %s is not part of the booking", unitItemID)).WithStatus(http.StatusNotFound))
+			return
+		}
+		data.Ticket = true
+		data.Reference = data.Tickets[0].Reference
+	}
+
+	// Render first so a template error can still be reported as JSON
+	var pdf bytes.Buffer
+	if err := s.vouchers.Render(&pdf, data); err != nil {
+		fmt.Println(err.Error())
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", data.Reference+".pdf"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf.Bytes())
+}
+
+// deliver fills in the delivery options of a booking's voucher and tickets
+// from the product's deliveryMethods and deliveryFormats, dropping whichever
+// of the two the product does not deliver.
+func (s *Server) deliver(booking *model.BookingPayload_Rs) error {
+	issued := booking.Voucher != nil
+	for _, unit := range booking.Units {
+		issued = issued || unit.Ticket != nil
+	}
+	if !issued {
+		return nil
+	}
+
+	_, product, supplier, err := s.bookedSlot(booking.AvailabilityId)
+	if err != nil {
+		return err
+	}
+
+	pdfURL := strings.TrimRight(supplier.Endpoint, "/") + "/bookings/" + url.PathEscape(booking.ID) + "/voucher"
+	if !contains(product.DeliveryMethods, model.DeliveryMethodVoucher) {
+		booking.Voucher = nil
+	}
+	if booking.Voucher != nil {
+		booking.Voucher.DeliveryOptions = deliveryOptions(product.DeliveryFormats, booking.Voucher.Reference, pdfURL)
+	}
+	for i := range booking.Units {
+		unit := &booking.Units[i]
+		if !contains(product.DeliveryMethods, model.DeliveryMethodTicket) {
+			unit.Ticket = nil
+		}
+		if unit.Ticket != nil {
+			unit.Ticket.DeliveryOptions = deliveryOptions(product.DeliveryFormats, unit.Ticket.Reference, pdfURL+"?unitItemId="+url.QueryEscape(unit.ID))
+		}
+	}
+	return nil
+}
+
+// bookedSlot returns an availability together with its product and the
+// product's supplier.
+func (s *Server) bookedSlot(availabilityID string) (*model.Availability, *model.Product, *model.Supplier, error) {
+	availability, err := s.repo.GetAvailabilityByID(availabilityID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	product, err := s.repo.GetProduct(availability.ProductId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	supplier, err := s.repo.GetSupplier(product.SupplierId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return availability, product, supplier, nil
+}
+
+// supplierLocation returns the supplier's timezone, or UTC when it is unset
+// or unknown.
+func supplierLocation(supplier *model.Supplier) *time.Location {
+	if loc, err := time.LoadLocation(supplier.Timezone); err == nil && supplier.Timezone != "" {
+		return loc
+	}
+	return time.UTC
+}
+
+// deliveryOptions lists a voucher in each of the given formats: barcodes
+// encode its reference and PDF_URL links to pdfURL.
+func deliveryOptions(formats []string, reference, pdfURL string) []model.DeliveryOption {
+	options := []model.DeliveryOption{}
+	for _, format := range formats {
+		value := reference
+		if format == model.DeliveryFormatPDFURL {
+			value = pdfURL
+		}
+		options = append(options, model.DeliveryOption{DeliveryFormat: format, DeliveryValue: value})
+	}
+	return options
+}
+
+// ticketReference returns the reference of an issued ticket, or nil.
+func ticketReference(ticket *model.Voucher) *string {
+	if ticket == nil {
+		return nil
+	}
+	reference := ticket.Reference
+	return &reference
+}
+
+// contains reports whether values holds value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
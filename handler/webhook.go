@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PostWebhook godoc
+// @Summary Subscribe to webhook events
+// @Description Subscribes an endpoint to booking and availability events. Every delivery is signed with the subscription's secret, which is generated unless one is sent and is only returned by this request. Needs an admin API key.
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Param   WebhookSubscriptionPayload_Rq body model.WebhookSubscriptionPayload_Rq true "Endpoint and events"
+// @Success 201 {object} model.WebhookSubscriptionPayload_Rs "Subscription created"
+// @Failure 400 {object} octoerr.Error "Invalid request body"
+// @Failure 401 {object} octoerr.Error "Missing or invalid API key"
+// @Failure 403 {object} octoerr.Error "Not an admin API key"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /webhooks [post]
+func (s *Server) PostWebhook(w http.ResponseWriter, r *http.Request) {
+
+	var req model.WebhookSubscriptionPayload_Rq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println(err.Error())
+		octoerr.Write(w, octoerr.BadRequest("Invalid request body"))
+		return
+	}
+
+	sub := model.WebhookSubscription{
+		ID:        uuid.NewString(),
+		URL:       strings.TrimSpace(req.URL),
+		Secret:    req.Secret,
+		CreatedAt: s.now().UTC(),
+	}
+	if u, err := url.Parse(sub.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		octoerr.Write(w, octoerr.BadRequest("url must be an absolute http or https URL"))
+		return
+	}
+	if len(req.Events) == 0 {
+		octoerr.Write(w, octoerr.BadRequest("events must list at least one event"))
+		return
+	}
+	for _, event := range req.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if !contains(model.WebhookEvents, event) {
+			octoerr.Write(w, octoerr.BadRequest(fmt.Sprintf("Unknown event %q; events are %s", event, strings.Join(model.WebhookEvents, ", "))))
+			return
+		}
+		if !contains(sub.Events, event) {
+			sub.Events = append(sub.Events, event)
+		}
+	}
+	if sub.Secret == "" {
+		secret, err := newSecret("whsec_")
+		if err != nil {
+			octoerr.Write(w, err)
+			return
+		}
+		sub.Secret = secret
+	}
+
+	if err := s.repo.InsertWebhookSubscription(sub); err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(model.WebhookSubscriptionPayload_Rs{WebhookSubscription: sub, Secret: sub.Secret})
+}
+
+// GetWebhooks godoc
+// @Summary List webhook subscriptions
+// @Description Lists every webhook subscription, without their secrets. Needs an admin API key.
+// @Tags webhooks
+// @Produce  json
+// @Success 200 {array} model.WebhookSubscription "Subscriptions"
+// @Failure 401 {object} octoerr.Error "Missing or invalid API key"
+// @Failure 403 {object} octoerr.Error "Not an admin API key"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /webhooks [get]
+func (s *Server) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	subscriptions, err := s.repo.GetWebhookSubscriptions()
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// DeleteWebhook godoc
+// @Summary Unsubscribe a webhook
+// @Description Deletes a webhook subscription together with its pending and dead deliveries. Needs an admin API key.
+// @Tags webhooks
+// @Produce  json
+// @Param   id path string true "Subscription ID"
+// @Success 200 {string} string "successfully deleted"
+// @Failure 401 {object} octoerr.Error "Missing or invalid API key"
+// @Failure 403 {object} octoerr.Error "Not an admin API key"
+// @Failure 404 {object} octoerr.Error "Subscription not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /webhooks/{id} [delete]
+func (s *Server) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	if err := s.repo.DeleteWebhookSubscription(vars["id"]); errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, octoerr.BadRequest("Webhook subscription not found").WithStatus(http.StatusNotFound))
+		return
+	} else if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode("successfully deleted")
+}
+
+// GetDeadLetters godoc
+// @Summary List dead webhook deliveries
+// @Description Lists the webhook deliveries that ran out of attempts, with the event, endpoint, payload and last response of each. Needs an admin API key.
+// @Tags webhooks
+// @Produce  json
+// @Success 200 {array} model.WebhookDelivery "Dead deliveries"
+// @Failure 401 {object} octoerr.Error "Missing or invalid API key"
+// @Failure 403 {object} octoerr.Error "Not an admin API key"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /webhooks/dead-letters [get]
+func (s *Server) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+
+	deliveries, err := s.repo.GetDeadWebhookDeliveries()
+	if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// RetryDeadLetter godoc
+// @Summary Retry a dead webhook delivery
+// @Description Queues a dead delivery again with fresh attempts; it is sent on the next dispatch. Needs an admin API key.
+// @Tags webhooks
+// @Produce  json
+// @Param   id path int true "Delivery ID"
+// @Success 202 {string} string "queued"
+// @Failure 401 {object} octoerr.Error "Missing or invalid API key"
+// @Failure 403 {object} octoerr.Error "Not an admin API key"
+// @Failure 404 {object} octoerr.Error "Dead delivery not found"
+// @Failure 500 {object} octoerr.Error "Internal Server Error"
+// @Router /webhooks/dead-letters/{id}/retry [post]
+func (s *Server) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	notFound := octoerr.BadRequest("Dead webhook delivery not found").WithStatus(http.StatusNotFound)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		octoerr.Write(w, notFound)
+		return
+	}
+	if err := s.repo.RetryWebhookDelivery(id, s.now().UTC()); errors.Is(err, sql.ErrNoRows) {
+		octoerr.Write(w, notFound)
+		return
+	} else if err != nil {
+		octoerr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode("queued")
+}
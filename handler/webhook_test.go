@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"octo-api/model"
+	"octo-api/octoerr"
+	"octo-api/webhook"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerWebhookValidation(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	for _, req := range []model.WebhookSubscriptionPayload_Rq{
+		{URL: "", Events: []string{model.WebhookEventBookingCreated}},
+		{URL: "ftp://example.com/hook", Events: []string{model.WebhookEventBookingCreated}},
+		{URL: "/hook", Events: []string{model.WebhookEventBookingCreated}},
+		{URL: "https://example.com/hook"},
+		{URL: "https://example.com/hook", Events: []string{"booking.deleted"}},
+	} {
+		rec := doRequest(t, h, "POST", "/webhooks", req, nil)
+		expectError(t, rec, http.StatusBadRequest, octoerr.BadRequestCode)
+	}
+
+	reseller := issueKey(t, h, model.APIKeyPayload_Rq{Name: "Reseller", Role: model.APIKeyRoleReseller, ResellerId: "reseller_id"})
+	rec := doRequest(t, h, "GET", "/webhooks", nil, reseller)
+	expectError(t, rec, http.StatusForbidden, octoerr.ForbiddenCode)
+	rec = doRequest(t, h, "POST", "/webhooks", model.WebhookSubscriptionPayload_Rq{URL: "https://example.com/hook", Events: []string{model.WebhookEventBookingCreated}}, reseller)
+	expectError(t, rec, http.StatusForbidden, octoerr.ForbiddenCode)
+}
+
+func TestServerWebhooks(t *testing.T) {
+	var signature, timestamp string
+	var body []byte
+	received := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature, timestamp = r.Header.Get(webhook.SignatureHeader), r.Header.Get(webhook.TimestampHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer received.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	h, repo := newTestServer(t)
+
+	rec := doRequest(t, h, "POST", "/webhooks", model.WebhookSubscriptionPayload_Rq{
+		URL:    received.URL,
+		Events: []string{"Booking.Created", model.WebhookEventBookingCreated},
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var sub model.WebhookSubscriptionPayload_Rs
+	decodeBody(t, rec, &sub)
+	if !strings.HasPrefix(sub.Secret, "whsec_") || len(sub.Events) != 1 || sub.Events[0] != model.WebhookEventBookingCreated {
+		t.Errorf("unexpected subscription %+v", sub)
+	}
+	rec = doRequest(t, h, "POST", "/webhooks", model.WebhookSubscriptionPayload_Rq{
+		URL:    failing.URL,
+		Events: []string{model.WebhookEventBookingCreated},
+		Secret: "whsec_failing",
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var failingSub model.WebhookSubscriptionPayload_Rs
+	decodeBody(t, rec, &failingSub)
+
+	// Listing never shows secrets
+	rec = doRequest(t, h, "GET", "/webhooks", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "whsec_") {
+		t.Errorf("expected secrets to be hidden, got %s", rec.Body)
+	}
+
+	availabilities, _ := repo.GetAvailabilities(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec = doRequest(t, h, "POST", "/bookings", model.BookingPayload_Rq{
+		AvailabilityId: availabilities[0].ID,
+		UnitItems:      []model.UnitItemPayload_Rq{{UnitId: "adult_id"}},
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	d := webhook.NewDispatcher(repo, webhook.Config{Timeout: time.Second, MaxAttempts: 1, Backoff: time.Minute})
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatalf("error was not expected while dispatching: %s", err)
+	}
+	ts, _ := strconv.ParseInt(timestamp, 10, 64)
+	if signature == "" || signature != webhook.Sign(sub.Secret, ts, body) {
+		t.Errorf("expected a delivery signed with the subscription secret, got %q", signature)
+	}
+
+	rec = doRequest(t, h, "GET", "/webhooks/dead-letters", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var dead []model.WebhookDelivery
+	decodeBody(t, rec, &dead)
+	if len(dead) != 1 || dead[0].SubscriptionId != failingSub.ID || dead[0].URL != failing.URL || dead[0].LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the failing delivery to be dead, got %+v", dead)
+	}
+
+	deadID := strconv.FormatInt(dead[0].ID, 10)
+	rec = doRequest(t, h, "POST", "/webhooks/dead-letters/"+deadID+"/retry", nil, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "POST", "/webhooks/dead-letters/"+deadID+"/retry", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	rec = doRequest(t, h, "POST", "/webhooks/dead-letters/unknown/retry", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = doRequest(t, h, "DELETE", "/webhooks/"+failingSub.ID, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec = doRequest(t, h, "DELETE", "/webhooks/"+failingSub.ID, nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	// Deleting a subscription drops its deliveries
+	rec = doRequest(t, h, "GET", "/webhooks/dead-letters", nil, nil)
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("expected no dead letters, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,63 @@
+package helper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ECBRateProvider serves euro reference rates parsed from an ECB
+// eurofxref-daily.xml style feed.
+type ECBRateProvider struct {
+	// Date is the reference date of the feed, e.g. "2024-03-01".
+	Date  string
+	rates rateTable
+}
+
+// Rate returns the base/target rate, crossing through EUR when neither side is EUR.
+func (p *ECBRateProvider) Rate(base, target string) (float64, error) {
+	return p.rates.Rate(base, target)
+}
+
+type ecbEnvelope struct {
+	Cube struct {
+		Cube struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// LoadECBFile reads an ECB XML feed from a local file.
+func LoadECBFile(path string) (*ECBRateProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseECB(f)
+}
+
+// ParseECB parses an ECB eurofxref XML feed.
+func ParseECB(r io.Reader) (*ECBRateProvider, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid ECB feed: %w", err)
+	}
+
+	day := envelope.Cube.Cube
+	if len(day.Rates) == 0 {
+		return nil, fmt.Errorf("invalid ECB feed: no rates")
+	}
+
+	rates := rateTable{}
+	for _, r := range day.Rates {
+		rates.set("EUR", r.Currency, r.Rate)
+	}
+	return &ECBRateProvider{Date: day.Time, rates: rates}, nil
+}
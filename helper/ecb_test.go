@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoadECBFile(t *testing.T) {
+	provider, err := LoadECBFile("testdata/eurofxref-daily.xml")
+	if err != nil {
+		t.Fatalf("error was not expected while loading the ECB feed: %s", err)
+	}
+	if provider.Date != "2024-03-01" {
+		t.Errorf("Date = %q, want 2024-03-01", provider.Date)
+	}
+
+	tests := []struct {
+		base   string
+		target string
+		want   float64
+	}{
+		{"EUR", "USD", 1.08},
+		{"USD", "EUR", 1 / 1.08},
+		{"USD", "JPY", 162.00 / 1.08},
+		{"GBP", "USD", 1.08 / 0.855},
+	}
+	for _, tt := range tests {
+		got, err := provider.Rate(tt.base, tt.target)
+		if err != nil {
+			t.Errorf("Rate(%s, %s): error was not expected: %s", tt.base, tt.target, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Rate(%s, %s) = %v, want %v", tt.base, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestParseECBEmpty(t *testing.T) {
+	if _, err := ParseECB(strings.NewReader(`<Envelope><Cube><Cube time="2024-03-01"></Cube></Cube></Envelope>`)); err == nil {
+		t.Error("expected an error for a feed without rates")
+	}
+}
@@ -0,0 +1,34 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvInt(t *testing.T) {
+	if n, err := EnvInt("TEST_ENV_INT", 7); err != nil || n != 7 {
+		t.Errorf("expected the fallback 7, got %d, %v", n, err)
+	}
+	t.Setenv("TEST_ENV_INT", "42")
+	if n, err := EnvInt("TEST_ENV_INT", 7); err != nil || n != 42 {
+		t.Errorf("expected 42, got %d, %v", n, err)
+	}
+	t.Setenv("TEST_ENV_INT", "many")
+	if _, err := EnvInt("TEST_ENV_INT", 7); err == nil {
+		t.Error("expected an error for a non-numeric value")
+	}
+}
+
+func TestEnvDuration(t *testing.T) {
+	if d, err := EnvDuration("TEST_ENV_DURATION", time.Minute); err != nil || d != time.Minute {
+		t.Errorf("expected the fallback 1m, got %s, %v", d, err)
+	}
+	t.Setenv("TEST_ENV_DURATION", "90s")
+	if d, err := EnvDuration("TEST_ENV_DURATION", time.Minute); err != nil || d != 90*time.Second {
+		t.Errorf("expected 90s, got %s, %v", d, err)
+	}
+	t.Setenv("TEST_ENV_DURATION", "soon")
+	if _, err := EnvDuration("TEST_ENV_DURATION", time.Minute); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
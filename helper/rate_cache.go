@@ -0,0 +1,55 @@
+package helper
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedRateProvider remembers rates from another provider for a fixed TTL.
+// Failed lookups are not cached.
+type CachedRateProvider struct {
+	provider RateProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedRate
+}
+
+type cachedRate struct {
+	rate    float64
+	expires time.Time
+}
+
+// NewCachedRateProvider wraps provider with a TTL cache.
+func NewCachedRateProvider(provider RateProvider, ttl time.Duration) *CachedRateProvider {
+	return &CachedRateProvider{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]cachedRate{},
+	}
+}
+
+// Rate returns a cached rate while it is fresh and asks the wrapped provider otherwise.
+func (c *CachedRateProvider) Rate(base, target string) (float64, error) {
+	key := strings.ToUpper(base) + "/" + strings.ToUpper(target)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.rate, nil
+	}
+
+	rate, err := c.provider.Rate(base, target)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cachedRate{rate: rate, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return rate, nil
+}
@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type countingProvider struct {
+	calls int
+	rate  float64
+	err   error
+}
+
+func (p *countingProvider) Rate(base, target string) (float64, error) {
+	p.calls++
+	return p.rate, p.err
+}
+
+func TestCachedRateProvider(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	provider := &countingProvider{rate: 0.92}
+	cache := NewCachedRateProvider(provider, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if rate, err := cache.Rate("USD", "EUR"); err != nil || rate != 0.92 {
+			t.Fatalf("Rate() = %v, %v", rate, err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times within the TTL, want 1", provider.calls)
+	}
+
+	// Pairs are cached independently
+	cache.Rate("EUR", "USD")
+	if provider.calls != 2 {
+		t.Errorf("provider called %d times, want 2", provider.calls)
+	}
+
+	// Entries are refreshed after the TTL
+	now = now.Add(2 * time.Minute)
+	provider.rate = 0.93
+	if rate, _ := cache.Rate("usd", "eur"); rate != 0.93 {
+		t.Errorf("Rate() after TTL = %v, want 0.93", rate)
+	}
+}
+
+func TestCachedRateProvider_ErrorsNotCached(t *testing.T) {
+	provider := &countingProvider{err: errors.New("provider down")}
+	cache := NewCachedRateProvider(provider, time.Hour)
+
+	if _, err := cache.Rate("USD", "EUR"); err == nil {
+		t.Fatal("expected the provider error")
+	}
+
+	provider.err = nil
+	provider.rate = 0.92
+	if rate, err := cache.Rate("USD", "EUR"); err != nil || rate != 0.92 {
+		t.Errorf("Rate() after recovery = %v, %v", rate, err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider called %d times, want 2", provider.calls)
+	}
+}
@@ -2,11 +2,11 @@ package helper
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 var API_KEY string
@@ -25,78 +25,56 @@ type Currency struct {
 	Value float64 `json:"value"`
 }
 
-func Rate_Convert(baseCurrency, targetCurrency string, base_amount float64) (float64, error) {
-
-	if base_amount == 0 {
-		return 0, nil
-	}
-
-	if !(len(API_KEY) > 0) {
-		return 0, errors.New("CURRENCY_EXCHANGE_API_KEY missing")
-	}
-
-	url := fmt.Sprintf("https://api.currencyapi.com/v3/latest?apikey=%s&base_currency=%s&currencies=%s", API_KEY, baseCurrency, targetCurrency)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatalf("Failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Failed to read response body: %v", err)
-	}
-
-	// Decode JSON into the ApiResponse struct
-	var apiResponse ApiResponse
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		log.Fatalf("Failed to decode JSON response: %v", err)
-	}
-
-	var updated_amount float64
+// CurrencyAPIProvider fetches live rates from api.currencyapi.com.
+type CurrencyAPIProvider struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
 
-	fmt.Printf("Last Updated: %s\n", apiResponse.Meta.LastUpdatedAt)
-	for code, currency := range apiResponse.Data {
-		fmt.Printf("Currency: %s, Value: %f\n", code, currency.Value)
-		updated_amount = currency.Value * base_amount
+// NewCurrencyAPIProvider returns a provider for the public currencyapi.com API.
+func NewCurrencyAPIProvider(apiKey string) *CurrencyAPIProvider {
+	return &CurrencyAPIProvider{
+		APIKey:  apiKey,
+		BaseURL: "https://api.currencyapi.com",
+		Client:  &http.Client{Timeout: 10 * time.Second},
 	}
-
-	return updated_amount, nil
 }
 
-func Rate_Convert_URL(serverURL string, base_amount float64) (float64, error) {
-
-	if base_amount == 0 {
-		return 0, nil
+// Rate asks currencyapi for the latest base/target rate.
+func (p *CurrencyAPIProvider) Rate(base, target string) (float64, error) {
+	base, target = strings.ToUpper(base), strings.ToUpper(target)
+	if base == target {
+		return 1, nil
+	}
+	if len(p.APIKey) == 0 {
+		return 0, fmt.Errorf("CURRENCY_EXCHANGE_API_KEY missing")
 	}
 
-	url := serverURL
+	query := url.Values{}
+	query.Set("apikey", p.APIKey)
+	query.Set("base_currency", base)
+	query.Set("currencies", target)
 
-	resp, err := http.Get(url)
+	resp, err := p.Client.Get(strings.TrimSuffix(p.BaseURL, "/") + "/v3/latest?" + query.Encode())
 	if err != nil {
-		log.Fatalf("Failed to make request: %v", err)
+		return 0, fmt.Errorf("currencyapi request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Failed to read response body: %v", err)
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("currencyapi returned %s", resp.Status)
 	}
 
 	// Decode JSON into the ApiResponse struct
 	var apiResponse ApiResponse
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		log.Fatalf("Failed to decode JSON response: %v", err)
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return 0, fmt.Errorf("failed to decode currencyapi response: %w", err)
 	}
 
-	var updated_amount float64
-
-	fmt.Printf("Last Updated: %s\n", apiResponse.Meta.LastUpdatedAt)
-	for code, currency := range apiResponse.Data {
-		fmt.Printf("Currency: %s, Value: %f\n", code, currency.Value)
-		updated_amount = currency.Value * base_amount
+	currency, ok := apiResponse.Data[target]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s/%s", ErrRateNotFound, base, target)
 	}
-
-	return updated_amount, nil
+	return currency.Value, nil
 }
@@ -7,9 +7,13 @@ import (
 	"testing"
 )
 
-func TestRate_Convert(t *testing.T) {
+func TestCurrencyAPIProvider_Rate(t *testing.T) {
 	// Setup mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apikey") != "test_key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 		fmt.Fprintln(w, `{
             "meta": {
                 "last_updated_at": "2022-01-01T00:00:00Z"
@@ -24,14 +28,10 @@ func TestRate_Convert(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Temporarily replace the API_KEY with the mock server's URL for testing
-	originalAPIKey := API_KEY
-	API_KEY = server.URL // This is just for illustrative purposes; adjust as needed for your actual code structure
-	defer func() { API_KEY = originalAPIKey }()
-
 	// Test cases
 	tests := []struct {
 		name           string
+		apiKey         string
 		baseCurrency   string
 		targetCurrency string
 		baseAmount     float64
@@ -40,24 +40,56 @@ func TestRate_Convert(t *testing.T) {
 	}{
 		{
 			name:           "Successful conversion from USD to EUR",
+			apiKey:         "test_key",
 			baseCurrency:   "USD",
 			targetCurrency: "EUR",
 			baseAmount:     100,
 			want:           120, // Expected result based on the mock API response
 			expectErr:      false,
 		},
+		{
+			name:           "Currency missing from the response",
+			apiKey:         "test_key",
+			baseCurrency:   "USD",
+			targetCurrency: "GBP",
+			baseAmount:     100,
+			expectErr:      true,
+		},
+		{
+			name:           "Provider rejects the request",
+			apiKey:         "wrong_key",
+			baseCurrency:   "USD",
+			targetCurrency: "EUR",
+			baseAmount:     100,
+			expectErr:      true,
+		},
 	}
 
 	// Execute test cases
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := Rate_Convert_URL(server.URL, tt.baseAmount)
+			provider := NewCurrencyAPIProvider(tt.apiKey)
+			provider.BaseURL = server.URL
+
+			got, err := Convert(provider, tt.baseCurrency, tt.targetCurrency, tt.baseAmount)
 			if (err != nil) != tt.expectErr {
-				t.Errorf("Rate_Convert() error = %v, wantErr %v", err, tt.expectErr)
+				t.Errorf("Convert() error = %v, wantErr %v", err, tt.expectErr)
 			}
 			if got != tt.want {
-				t.Errorf("Rate_Convert() got = %v, want %v", got, tt.want)
+				t.Errorf("Convert() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestCurrencyAPIProvider_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	provider := NewCurrencyAPIProvider("test_key")
+	provider.BaseURL = server.URL
+
+	if _, err := provider.Rate("USD", "EUR"); err == nil {
+		t.Error("expected an error for an unreachable provider")
+	}
+}
@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrRateNotFound is returned when a provider has no rate for a currency pair.
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// RateProvider looks up exchange rates. Rate returns how many units of target
+// one unit of base is worth.
+type RateProvider interface {
+	Rate(base, target string) (float64, error)
+}
+
+// Convert converts amount from base to target using provider.
+func Convert(provider RateProvider, base, target string, amount float64) (float64, error) {
+	if amount == 0 {
+		return 0, nil
+	}
+	rate, err := provider.Rate(base, target)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// RateProviderFromEnv builds the provider selected by RATES_PROVIDER:
+//
+//   - "currencyapi" (default) queries api.currencyapi.com with
+//     CURRENCY_EXCHANGE_API_KEY, falling back to API_KEY.
+//   - "file" reads a static JSON or CSV rates file from RATES_FILE.
+//   - "ecb" reads an ECB eurofxref XML feed from RATES_FILE.
+//
+// The provider is wrapped in a cache whose TTL is RATES_CACHE_TTL (default 1h).
+func RateProviderFromEnv() (RateProvider, error) {
+	var provider RateProvider
+	var err error
+
+	switch kind := strings.ToLower(os.Getenv("RATES_PROVIDER")); kind {
+	case "", "currencyapi":
+		apiKey := os.Getenv("CURRENCY_EXCHANGE_API_KEY")
+		if apiKey == "" {
+			apiKey = API_KEY
+		}
+		provider = NewCurrencyAPIProvider(apiKey)
+	case "file":
+		provider, err = LoadRatesFile(os.Getenv("RATES_FILE"))
+	case "ecb":
+		provider, err = LoadECBFile(os.Getenv("RATES_FILE"))
+	default:
+		err = fmt.Errorf("unknown RATES_PROVIDER %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := EnvDuration("RATES_CACHE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return NewCachedRateProvider(provider, ttl), nil
+}
+
+// rateTable holds rates quoted against one or more base currencies and
+// derives inverse and cross rates from them.
+type rateTable map[string]map[string]float64
+
+func (t rateTable) set(base, target string, rate float64) {
+	base, target = strings.ToUpper(base), strings.ToUpper(target)
+	if t[base] == nil {
+		t[base] = map[string]float64{}
+	}
+	t[base][target] = rate
+}
+
+// Rate returns the direct rate if known, otherwise the inverse rate, otherwise
+// a cross rate through a shared base currency. Shared bases are tried in
+// alphabetical order so the same table always yields the same cross rate.
+func (t rateTable) Rate(base, target string) (float64, error) {
+	base, target = strings.ToUpper(base), strings.ToUpper(target)
+	if base == target {
+		return 1, nil
+	}
+	if rate, ok := t[base][target]; ok {
+		return rate, nil
+	}
+	if rate, ok := t[target][base]; ok && rate != 0 {
+		return 1 / rate, nil
+	}
+	bases := make([]string, 0, len(t))
+	for b := range t {
+		bases = append(bases, b)
+	}
+	sort.Strings(bases)
+	for _, b := range bases {
+		quotes := t[b]
+		from, okFrom := quotes[base]
+		to, okTo := quotes[target]
+		if okFrom && okTo && from != 0 {
+			return to / from, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s/%s", ErrRateNotFound, base, target)
+}
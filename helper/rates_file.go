@@ -0,0 +1,86 @@
+package helper
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// StaticRateProvider serves rates loaded once from a local file.
+type StaticRateProvider struct {
+	rates rateTable
+}
+
+// Rate returns the base/target rate from the loaded table.
+func (p *StaticRateProvider) Rate(base, target string) (float64, error) {
+	return p.rates.Rate(base, target)
+}
+
+type ratesFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// LoadRatesFile reads a static rates file. Files ending in .csv hold
+// "base,target,rate" rows with an optional header; anything else is parsed as
+// JSON of the form {"base": "EUR", "rates": {"USD": 1.08}}.
+func LoadRatesFile(path string) (*StaticRateProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseRatesCSV(f)
+	}
+	return ParseRatesJSON(f)
+}
+
+// ParseRatesJSON parses {"base": "EUR", "rates": {"USD": 1.08, ...}}.
+func ParseRatesJSON(r io.Reader) (*StaticRateProvider, error) {
+	var file ratesFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid rates JSON: %w", err)
+	}
+	if len(file.Base) == 0 {
+		return nil, fmt.Errorf("invalid rates JSON: missing base currency")
+	}
+
+	rates := rateTable{}
+	for target, rate := range file.Rates {
+		rates.set(file.Base, target, rate)
+	}
+	return &StaticRateProvider{rates: rates}, nil
+}
+
+// ParseRatesCSV parses "base,target,rate" rows. A first row whose rate column
+// is not a number is treated as a header.
+func ParseRatesCSV(r io.Reader) (*StaticRateProvider, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid rates CSV: %w", err)
+	}
+
+	rates := rateTable{}
+	for i, record := range records {
+		rate, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("invalid rates CSV line %d: %w", i+1, err)
+		}
+		rates.set(record[0], record[1], rate)
+	}
+	return &StaticRateProvider{rates: rates}, nil
+}
@@ -0,0 +1,81 @@
+package helper
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoadRatesFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		base   string
+		target string
+		want   float64
+	}{
+		{"JSON direct rate", "testdata/rates.json", "USD", "EUR", 0.92},
+		{"JSON inverse rate", "testdata/rates.json", "EUR", "USD", 1 / 0.92},
+		{"JSON cross rate", "testdata/rates.json", "EUR", "GBP", 0.79 / 0.92},
+		{"JSON lower case", "testdata/rates.json", "usd", "jpy", 150.1},
+		{"same currency", "testdata/rates.json", "CHF", "CHF", 1},
+		{"CSV direct rate", "testdata/rates.csv", "EUR", "CHF", 0.96},
+		{"CSV inverse rate", "testdata/rates.csv", "GBP", "USD", 1 / 0.79},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := LoadRatesFile(tt.path)
+			if err != nil {
+				t.Fatalf("error was not expected while loading %s: %s", tt.path, err)
+			}
+			got, err := provider.Rate(tt.base, tt.target)
+			if err != nil {
+				t.Fatalf("error was not expected: %s", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Rate(%s, %s) = %v, want %v", tt.base, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateTableCrossRateIsDeterministic(t *testing.T) {
+	rates, err := ParseRatesCSV(strings.NewReader("USD,CHF,0.9\nUSD,GBP,0.8\nEUR,CHF,1\nEUR,GBP,0.5\n"))
+	if err != nil {
+		t.Fatalf("error was not expected while parsing rates: %s", err)
+	}
+	// Both EUR and USD quote CHF and GBP; EUR sorts first and always wins
+	for i := 0; i < 20; i++ {
+		got, err := rates.Rate("CHF", "GBP")
+		if err != nil {
+			t.Fatalf("error was not expected: %s", err)
+		}
+		if got != 0.5 {
+			t.Fatalf("Rate(CHF, GBP) = %v, want 0.5", got)
+		}
+	}
+}
+
+func TestStaticRateProvider_Unknown(t *testing.T) {
+	provider, err := LoadRatesFile("testdata/rates.json")
+	if err != nil {
+		t.Fatalf("error was not expected while loading rates: %s", err)
+	}
+	if _, err := provider.Rate("USD", "CHF"); !errors.Is(err, ErrRateNotFound) {
+		t.Errorf("expected ErrRateNotFound, got %v", err)
+	}
+}
+
+func TestParseRatesInvalid(t *testing.T) {
+	if _, err := ParseRatesJSON(strings.NewReader(`{"rates": {"EUR": 0.9}}`)); err == nil {
+		t.Error("expected an error for JSON without a base currency")
+	}
+	if _, err := ParseRatesCSV(strings.NewReader("USD,EUR,0.9\nUSD,GBP,abc\n")); err == nil {
+		t.Error("expected an error for a non-numeric CSV rate")
+	}
+	if _, err := LoadRatesFile("testdata/missing.json"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
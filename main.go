@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"octo-api/handler"
 	"octo-api/helper"
+	"octo-api/store"
+	"octo-api/voucher"
+	"octo-api/webhook"
 	"os"
 
-	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 
 	_ "octo-api/docs"
@@ -27,25 +31,76 @@ func init() {
 }
 
 func main() {
-	r := mux.NewRouter()
+	cfg, err := store.DBConfigFromEnv()
+	if err != nil {
+		log.Fatalf("invalid database configuration: %v", err)
+	}
+
+	// One pool for the whole process; fail fast if Postgres is unreachable.
+	db, err := store.OpenDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	expiry, err := store.ExpiryConfigFromEnv()
+	if err != nil {
+		log.Fatalf("invalid reservation configuration: %v", err)
+	}
+
+	policy, err := store.StatusPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("invalid availability configuration: %v", err)
+	}
+
+	schedule, err := store.ScheduleConfigFromEnv()
+	if err != nil {
+		log.Fatalf("invalid schedule configuration: %v", err)
+	}
+
+	rates, err := helper.RateProviderFromEnv()
+	if err != nil {
+		log.Fatalf("invalid exchange-rate configuration: %v", err)
+	}
+
+	vouchers, err := voucher.TemplateFromEnv()
+	if err != nil {
+		log.Fatalf("invalid voucher template: %v", err)
+	}
+
+	maxDateRange, err := handler.MaxDateRangeFromEnv()
+	if err != nil {
+		log.Fatalf("invalid availability configuration: %v", err)
+	}
+
+	webhooks, err := webhook.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("invalid webhook configuration: %v", err)
+	}
+
+	repo := store.NewPostgresStore(db, store.WithStatusPolicy(policy))
+	server := handler.NewServerWithRepository(repo,
+		handler.WithSupplierID(os.Getenv("SUPPLIER_ID")),
+		handler.WithAdminAPIKey(os.Getenv("ADMIN_API_KEY")),
+		handler.WithRateProvider(rates),
+		handler.WithReservationExpiry(expiry.ReservationExpiry),
+		handler.WithScheduleHorizon(schedule.HorizonDays),
+		handler.WithMaxDateRange(maxDateRange),
+		handler.WithVoucherTemplate(vouchers),
+	)
+	r := server.Routes()
 
-	// Product routes
-	r.HandleFunc("/products", handler.GetProducts).Methods("GET")
-	r.HandleFunc("/products/new", handler.AddProduct).Methods("POST")
-	r.HandleFunc("/products/{id}", handler.GetProduct).Methods("GET")
+	// Release the vacancies of reservations that were never confirmed
+	go store.RunExpirySweeper(context.Background(), repo, expiry.SweepInterval)
 
-	// Availability routes
-	r.HandleFunc("/availability", handler.GetAvailabilities).Methods("GET")
-	r.HandleFunc("/availability/add", handler.AddAvailabilities).Methods("POST")
+	// Keep availabilities generated from schedule rules for a rolling horizon
+	go store.RunScheduleGenerator(context.Background(), repo, schedule)
 
-	// Booking routes
-	r.HandleFunc("/bookings", handler.PostBooking).Methods("POST")
-	r.HandleFunc("/bookings/all", handler.GetAllBookings).Methods("GET")
-	r.HandleFunc("/bookings/{id}", handler.GetBooking).Methods("GET")
-	r.HandleFunc("/bookings/{id}/confirm", handler.ConfirmBooking).Methods("POST")
+	// Send booking and availability events to webhook subscribers
+	go webhook.NewDispatcher(repo, webhooks).Run(context.Background())
 
 	// Swagger
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-	http.ListenAndServe(":8080", r)
+	log.Fatal(http.ListenAndServe(":8080", r))
 }
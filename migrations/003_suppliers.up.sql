@@ -0,0 +1,19 @@
+CREATE TABLE "suppliers" (
+    "id" VARCHAR(255) PRIMARY KEY,
+    "name" VARCHAR(255) NOT NULL,
+    "endpoint" VARCHAR(255) NOT NULL,
+    "contact_website" VARCHAR(255),
+    "contact_email" VARCHAR(255),
+    "contact_telephone" VARCHAR(50),
+    "contact_address" VARCHAR(255),
+    "timezone" VARCHAR(50) NOT NULL DEFAULT 'UTC'
+);
+
+-- Every existing product is owned by a default supplier until reassigned
+INSERT INTO "suppliers" ("id", "name", "endpoint", "timezone")
+VALUES ('00000000-0000-0000-0000-000000000001', 'Default Supplier', 'http://localhost:8080', 'UTC');
+
+ALTER TABLE "products" ADD COLUMN "supplier_id" VARCHAR(255);
+UPDATE "products" SET "supplier_id" = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE "products" ALTER COLUMN "supplier_id" SET NOT NULL;
+ALTER TABLE "products" ADD FOREIGN KEY ("supplier_id") REFERENCES "suppliers" ("id");
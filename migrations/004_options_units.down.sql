@@ -0,0 +1,5 @@
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "ticket";
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "unit_id";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "option_id";
+DROP TABLE IF EXISTS "units";
+DROP TABLE IF EXISTS "options";
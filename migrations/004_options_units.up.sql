@@ -0,0 +1,40 @@
+CREATE TABLE "options" (
+    "id" VARCHAR(255) PRIMARY KEY,
+    "product_id" VARCHAR(255) NOT NULL,
+    "internal_name" VARCHAR(255) NOT NULL,
+    "reference" VARCHAR(255),
+    "is_default" BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY ("product_id") REFERENCES "products" ("id")
+);
+
+CREATE TABLE "units" (
+    "id" VARCHAR(255) PRIMARY KEY,
+    "option_id" VARCHAR(255) NOT NULL,
+    "internal_name" VARCHAR(255) NOT NULL,
+    "reference" VARCHAR(255),
+    "type" VARCHAR(50) NOT NULL,
+    "min_age" INT NOT NULL DEFAULT 0,
+    "max_age" INT NOT NULL DEFAULT 99,
+    "id_required" BOOLEAN NOT NULL DEFAULT FALSE,
+    "min_quantity" INT,
+    "max_quantity" INT,
+    "pax_count" INT NOT NULL DEFAULT 1,
+    "accompanied_by" TEXT[] NOT NULL DEFAULT '{}',
+    "price" REAL NOT NULL,
+    "currency" VARCHAR(50) NOT NULL,
+    FOREIGN KEY ("option_id") REFERENCES "options" ("id")
+);
+
+-- Give every existing product a default option with a single adult unit at the product price
+INSERT INTO "options" ("id", "product_id", "internal_name", "is_default")
+SELECT gen_random_uuid()::text, "id", 'DEFAULT', TRUE FROM "products";
+
+INSERT INTO "units" ("id", "option_id", "internal_name", "type", "min_age", "max_age", "price", "currency")
+SELECT gen_random_uuid()::text, o."id", 'Adult', 'ADULT', 18, 99, p."price", p."currency"
+FROM "options" o INNER JOIN "products" p ON o."product_id" = p."id";
+
+ALTER TABLE "bookings" ADD COLUMN "option_id" VARCHAR(255) REFERENCES "options" ("id");
+
+ALTER TABLE "booking_units" ADD COLUMN "unit_id" VARCHAR(255) REFERENCES "units" ("id");
+ALTER TABLE "booking_units" ADD COLUMN "ticket" VARCHAR(255);
+UPDATE "booking_units" SET "ticket" = "id";
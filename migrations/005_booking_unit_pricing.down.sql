@@ -0,0 +1,4 @@
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "included_taxes";
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "currency_precision";
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "net";
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "original";
@@ -0,0 +1,10 @@
+-- "price" keeps holding the retail amount of each booking unit
+ALTER TABLE "booking_units" ADD COLUMN "original" REAL;
+ALTER TABLE "booking_units" ADD COLUMN "net" REAL;
+ALTER TABLE "booking_units" ADD COLUMN "currency_precision" INT NOT NULL DEFAULT 2;
+ALTER TABLE "booking_units" ADD COLUMN "included_taxes" JSONB NOT NULL DEFAULT '[]';
+
+UPDATE "booking_units" SET "original" = "price", "net" = "price";
+
+ALTER TABLE "booking_units" ALTER COLUMN "original" SET NOT NULL;
+ALTER TABLE "booking_units" ALTER COLUMN "net" SET NOT NULL;
@@ -0,0 +1,20 @@
+CREATE FUNCTION pg_temp.to_major(amount BIGINT, places INT) RETURNS REAL AS $$
+    SELECT (amount::NUMERIC / POWER(10, places))::REAL
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE FUNCTION pg_temp.currency_precision(currency VARCHAR) RETURNS INT AS $$
+    SELECT CASE
+        WHEN UPPER(currency) IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN UPPER(currency) IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        WHEN UPPER(currency) IN ('CLF', 'UYW') THEN 4
+        ELSE 2
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE "products" ALTER COLUMN "price" TYPE REAL USING pg_temp.to_major("price", pg_temp.currency_precision("currency"));
+ALTER TABLE "units" ALTER COLUMN "price" TYPE REAL USING pg_temp.to_major("price", pg_temp.currency_precision("currency"));
+ALTER TABLE "availabilities" ALTER COLUMN "price" TYPE REAL USING pg_temp.to_major("price", pg_temp.currency_precision("currency"));
+ALTER TABLE "bookings" ALTER COLUMN "price" TYPE REAL USING pg_temp.to_major("price", pg_temp.currency_precision("currency"));
+ALTER TABLE "booking_units" ALTER COLUMN "original" TYPE REAL USING pg_temp.to_major("original", "currency_precision");
+ALTER TABLE "booking_units" ALTER COLUMN "price" TYPE REAL USING pg_temp.to_major("price", "currency_precision");
+ALTER TABLE "booking_units" ALTER COLUMN "net" TYPE REAL USING pg_temp.to_major("net", "currency_precision");
@@ -0,0 +1,23 @@
+-- Store every price as an integer amount in the currency's minor unit (ISO 4217)
+CREATE FUNCTION pg_temp.currency_precision(currency VARCHAR) RETURNS INT AS $$
+    SELECT CASE
+        WHEN UPPER(currency) IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN UPPER(currency) IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        WHEN UPPER(currency) IN ('CLF', 'UYW') THEN 4
+        ELSE 2
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE FUNCTION pg_temp.to_minor(amount REAL, currency VARCHAR) RETURNS BIGINT AS $$
+    SELECT ROUND(amount::NUMERIC * POWER(10, pg_temp.currency_precision(currency)))::BIGINT
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE "products" ALTER COLUMN "price" TYPE BIGINT USING pg_temp.to_minor("price", "currency");
+ALTER TABLE "units" ALTER COLUMN "price" TYPE BIGINT USING pg_temp.to_minor("price", "currency");
+ALTER TABLE "availabilities" ALTER COLUMN "price" TYPE BIGINT USING pg_temp.to_minor("price", "currency");
+ALTER TABLE "bookings" ALTER COLUMN "price" TYPE BIGINT USING pg_temp.to_minor("price", "currency");
+ALTER TABLE "booking_units" ALTER COLUMN "original" TYPE BIGINT USING pg_temp.to_minor("original", "currency");
+ALTER TABLE "booking_units" ALTER COLUMN "price" TYPE BIGINT USING pg_temp.to_minor("price", "currency");
+ALTER TABLE "booking_units" ALTER COLUMN "net" TYPE BIGINT USING pg_temp.to_minor("net", "currency");
+
+UPDATE "booking_units" SET "currency_precision" = pg_temp.currency_precision("currency");
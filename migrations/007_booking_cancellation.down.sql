@@ -0,0 +1,4 @@
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "cancelled_at";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "cancellation_reason";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "cancellation_cutoff_unit";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "cancellation_cutoff_amount";
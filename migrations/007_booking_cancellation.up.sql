@@ -0,0 +1,7 @@
+-- Per-product cancellation cutoff, following OCTO's cancellationCutoffAmount/cancellationCutoffUnit
+ALTER TABLE "products" ADD COLUMN "cancellation_cutoff_amount" INT NOT NULL DEFAULT 0;
+ALTER TABLE "products" ADD COLUMN "cancellation_cutoff_unit" VARCHAR(10) NOT NULL DEFAULT 'hour';
+
+-- Cancellation details, set when a booking moves to CANCELLED
+ALTER TABLE "bookings" ADD COLUMN "cancellation_reason" TEXT;
+ALTER TABLE "bookings" ADD COLUMN "cancelled_at" TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "bookings_reserved_expires_at_idx";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "expires_at";
@@ -0,0 +1,4 @@
+-- Unconfirmed reservations hold vacancies only until they expire
+ALTER TABLE "bookings" ADD COLUMN "expires_at" TIMESTAMP;
+
+CREATE INDEX "bookings_reserved_expires_at_idx" ON "bookings" ("expires_at") WHERE "status" = 'RESERVED';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "availabilities_product_start_idx";
+ALTER TABLE "availabilities" DROP COLUMN IF EXISTS "local_date_time_end";
+ALTER TABLE "availabilities" DROP COLUMN IF EXISTS "local_date_time_start";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "availability_type";
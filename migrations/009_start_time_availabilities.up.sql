@@ -0,0 +1,19 @@
+-- Products run either at fixed start times or during opening hours
+ALTER TABLE "products" ADD COLUMN "availability_type" VARCHAR(20) NOT NULL DEFAULT 'OPENING_HOURS';
+
+-- Availabilities become timeslots within a local date, stored as instants
+ALTER TABLE "availabilities" ADD COLUMN "local_date_time_start" TIMESTAMPTZ;
+ALTER TABLE "availabilities" ADD COLUMN "local_date_time_end" TIMESTAMPTZ;
+
+-- Existing availabilities cover their whole local date in the supplier's timezone
+UPDATE "availabilities" a
+SET "local_date_time_start" = a."local_date"::TIMESTAMP AT TIME ZONE s."timezone",
+    "local_date_time_end" = (a."local_date" + 1)::TIMESTAMP AT TIME ZONE s."timezone"
+FROM "products" p
+INNER JOIN "suppliers" s ON p."supplier_id" = s."id"
+WHERE a."product_id" = p."id";
+
+ALTER TABLE "availabilities" ALTER COLUMN "local_date_time_start" SET NOT NULL;
+ALTER TABLE "availabilities" ALTER COLUMN "local_date_time_end" SET NOT NULL;
+
+CREATE INDEX "availabilities_product_start_idx" ON "availabilities" ("product_id", "local_date_time_start");
@@ -0,0 +1,2 @@
+ALTER TABLE "availabilities" DROP COLUMN IF EXISTS "schedule_rule_id";
+DROP TABLE IF EXISTS "schedule_rules";
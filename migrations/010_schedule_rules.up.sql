@@ -0,0 +1,22 @@
+-- Recurring rules that availabilities are generated from
+CREATE TABLE "schedule_rules" (
+    "id" VARCHAR(255) PRIMARY KEY,
+    "product_id" VARCHAR(255) NOT NULL,
+    -- Bit n is set when the rule applies on weekday n, Sunday being 0
+    "weekday_mask" INT NOT NULL DEFAULT 127,
+    "start_times" TEXT[] NOT NULL DEFAULT '{}',
+    "duration_minutes" INT NOT NULL DEFAULT 0,
+    "valid_from" DATE NOT NULL,
+    "valid_until" DATE,
+    "blackout_dates" DATE[] NOT NULL DEFAULT '{}',
+    "capacity" INT,
+    "price" BIGINT,
+    "currency" VARCHAR(50),
+    FOREIGN KEY ("product_id") REFERENCES "products" ("id")
+);
+
+CREATE INDEX "schedule_rules_product_id_idx" ON "schedule_rules" ("product_id");
+
+-- Generated availabilities remember their rule; NULL marks one-off availabilities
+ALTER TABLE "availabilities" ADD COLUMN "schedule_rule_id" VARCHAR(255);
+ALTER TABLE "availabilities" ADD FOREIGN KEY ("schedule_rule_id") REFERENCES "schedule_rules" ("id") ON DELETE SET NULL;
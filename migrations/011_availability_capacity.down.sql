@@ -0,0 +1 @@
+ALTER TABLE "availabilities" DROP COLUMN IF EXISTS "capacity";
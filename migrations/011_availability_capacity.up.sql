@@ -0,0 +1,9 @@
+-- Availabilities remember their own capacity, which schedule rules may override
+ALTER TABLE "availabilities" ADD COLUMN "capacity" INT;
+
+UPDATE "availabilities" a
+SET "capacity" = GREATEST(p."capacity", a."vacancies")
+FROM "products" p
+WHERE a."product_id" = p."id";
+
+ALTER TABLE "availabilities" ALTER COLUMN "capacity" SET NOT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "idempotency_keys";
+
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "request_hash";
+ALTER TABLE "bookings" DROP CONSTRAINT IF EXISTS "bookings_uuid_key";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "uuid";
@@ -0,0 +1,17 @@
+-- Resellers may send their own booking uuid; a retry with the same uuid must not book twice
+ALTER TABLE "bookings" ADD COLUMN "uuid" VARCHAR(255);
+UPDATE "bookings" SET "uuid" = "id";
+ALTER TABLE "bookings" ALTER COLUMN "uuid" SET NOT NULL;
+ALTER TABLE "bookings" ADD CONSTRAINT "bookings_uuid_key" UNIQUE ("uuid");
+
+-- Fingerprint of the request that created the booking, to tell a retry from a conflicting reuse of its uuid
+ALTER TABLE "bookings" ADD COLUMN "request_hash" VARCHAR(64);
+
+-- Responses recorded for requests sent with an Idempotency-Key header
+CREATE TABLE "idempotency_keys" (
+    "key" VARCHAR(512) PRIMARY KEY,
+    "request_hash" VARCHAR(64) NOT NULL,
+    "status" INT,
+    "body" BYTEA,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+ALTER TABLE "availabilities" DROP CONSTRAINT IF EXISTS "availabilities_vacancies_check";
@@ -0,0 +1,2 @@
+-- Vacancies can never be oversold, whatever the application does
+ALTER TABLE "availabilities" ADD CONSTRAINT "availabilities_vacancies_check" CHECK ("vacancies" >= 0);
@@ -0,0 +1,2 @@
+-- Free-text notes from the reseller, editable until the booking is final
+ALTER TABLE "bookings" ADD COLUMN "notes" TEXT;
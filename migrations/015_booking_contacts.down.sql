@@ -0,0 +1,8 @@
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "contact";
+
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "contact_notes";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "contact_country";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "contact_locales";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "contact_phone_number";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "contact_email_address";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "contact_full_name";
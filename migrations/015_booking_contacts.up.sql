@@ -0,0 +1,10 @@
+-- The lead traveller of each booking
+ALTER TABLE "bookings" ADD COLUMN "contact_full_name" VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE "bookings" ADD COLUMN "contact_email_address" VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE "bookings" ADD COLUMN "contact_phone_number" VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE "bookings" ADD COLUMN "contact_locales" TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE "bookings" ADD COLUMN "contact_country" VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE "bookings" ADD COLUMN "contact_notes" TEXT NOT NULL DEFAULT '';
+
+-- Optional details of the guest holding each unit
+ALTER TABLE "booking_units" ADD COLUMN "contact" JSONB;
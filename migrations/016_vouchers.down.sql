@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "booking_units_ticket_key";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "voucher_reference";
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "delivery_formats";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "delivery_methods";
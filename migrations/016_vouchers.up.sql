@@ -0,0 +1,8 @@
+-- How each product delivers its vouchers and tickets
+ALTER TABLE "products" ADD COLUMN "delivery_methods" TEXT[] NOT NULL DEFAULT '{VOUCHER,TICKET}';
+ALTER TABLE "products" ADD COLUMN "delivery_formats" TEXT[] NOT NULL DEFAULT '{QRCODE,CODE128,PDF_URL}';
+
+-- Confirmed bookings get a voucher reference, and each of their units a ticket
+-- reference; both are printed on vouchers and must be unique
+ALTER TABLE "bookings" ADD COLUMN "voucher_reference" VARCHAR(16) UNIQUE;
+CREATE UNIQUE INDEX "booking_units_ticket_key" ON "booking_units" ("ticket");
@@ -0,0 +1,2 @@
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "redeemed_by";
+ALTER TABLE "booking_units" DROP COLUMN IF EXISTS "redeemed_at";
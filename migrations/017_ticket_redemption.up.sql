@@ -0,0 +1,3 @@
+-- When and by which gate device each unit's ticket was scanned
+ALTER TABLE "booking_units" ADD COLUMN "redeemed_at" TIMESTAMPTZ;
+ALTER TABLE "booking_units" ADD COLUMN "redeemed_by" VARCHAR(255);
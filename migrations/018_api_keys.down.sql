@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "bookings_reseller_id_idx";
+ALTER TABLE "bookings" DROP COLUMN IF EXISTS "reseller_id";
+
+DROP TABLE IF EXISTS "api_keys";
@@ -0,0 +1,14 @@
+-- Bearer tokens resellers and admins authenticate with; only a SHA-256 hash of each key is kept
+CREATE TABLE "api_keys" (
+    "key_hash" VARCHAR(64) PRIMARY KEY,
+    "name" VARCHAR(255) NOT NULL,
+    "role" VARCHAR(16) NOT NULL CHECK ("role" IN ('RESELLER', 'ADMIN')),
+    "reseller_id" VARCHAR(255),
+    "supplier_id" VARCHAR(255) REFERENCES "suppliers" ("id"),
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ("role" = 'ADMIN' OR "reseller_id" IS NOT NULL)
+);
+
+-- The reseller whose key created each booking; NULL for bookings made by admins or before keys existed
+ALTER TABLE "bookings" ADD COLUMN "reseller_id" VARCHAR(255);
+CREATE INDEX "bookings_reseller_id_idx" ON "bookings" ("reseller_id");
@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS "webhook_dead_letters";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "outbox_events";
+DROP TABLE IF EXISTS "webhook_subscriptions";
@@ -0,0 +1,46 @@
+-- Endpoints that receive booking and availability events; the secret signs each delivery
+CREATE TABLE "webhook_subscriptions" (
+    "id" VARCHAR(255) PRIMARY KEY,
+    "url" TEXT NOT NULL,
+    "events" TEXT[] NOT NULL,
+    "secret" VARCHAR(255) NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Events written in the same transaction as the change they describe, with the booking or availability as the
+-- change left it, until they are fanned out to subscriptions
+CREATE TABLE "outbox_events" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "event" VARCHAR(64) NOT NULL,
+    "resource_id" VARCHAR(255) NOT NULL,
+    "data" JSONB NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "dispatched_at" TIMESTAMPTZ
+);
+CREATE INDEX "outbox_events_pending_idx" ON "outbox_events" ("id") WHERE "dispatched_at" IS NULL;
+
+-- One event sent to one subscription; payload is the exact body that is signed and retried
+CREATE TABLE "webhook_deliveries" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "event_id" BIGINT NOT NULL REFERENCES "outbox_events" ("id"),
+    "subscription_id" VARCHAR(255) NOT NULL REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE,
+    "payload" BYTEA NOT NULL,
+    "status" VARCHAR(16) NOT NULL DEFAULT 'PENDING' CHECK ("status" IN ('PENDING', 'DELIVERED', 'DEAD')),
+    "attempts" INT NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_status_code" INT,
+    "last_error" TEXT,
+    "delivered_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE ("event_id", "subscription_id")
+);
+CREATE INDEX "webhook_deliveries_due_idx" ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'PENDING';
+
+-- Deliveries that ran out of attempts, with the event and endpoint they were for
+CREATE VIEW "webhook_dead_letters" AS
+SELECT d."id", d."event_id", e."event", d."subscription_id", s."url", d."payload", d."status", d."attempts",
+       d."next_attempt_at", d."last_status_code", d."last_error", d."delivered_at", d."created_at"
+FROM "webhook_deliveries" d
+INNER JOIN "outbox_events" e ON d."event_id" = e."id"
+INNER JOIN "webhook_subscriptions" s ON d."subscription_id" = s."id"
+WHERE d."status" = 'DEAD';
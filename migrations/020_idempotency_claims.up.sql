@@ -0,0 +1,5 @@
+-- When the request holding a key started, so a claim left by a crashed request can be taken over
+ALTER TABLE "idempotency_keys" ADD COLUMN "claimed_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- Response headers replayed with the body, e.g. Octo-Capabilities
+ALTER TABLE "idempotency_keys" ADD COLUMN "headers" JSONB;
@@ -1,49 +1,374 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+type Supplier struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Endpoint string          `json:"endpoint"`
+	Contact  SupplierContact `json:"contact"`
+	Timezone string          `json:"timezone"`
+}
+
+type SupplierContact struct {
+	Website   *string `json:"website"`
+	Email     *string `json:"email"`
+	Telephone *string `json:"telephone"`
+	Address   *string `json:"address"`
+}
 
 type Product struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Capacity int     `json:"capacity"`
-	Price    float64 `json:"price,omitempty"`
-	Currency string  `json:"currency,omitempty"`
+	ID                       string   `json:"id"`
+	SupplierId               string   `json:"supplierId"`
+	Name                     string   `json:"name"`
+	Capacity                 int      `json:"capacity"`
+	Price                    int64    `json:"price,omitempty"`
+	Currency                 string   `json:"currency,omitempty"`
+	CancellationCutoffAmount int      `json:"cancellationCutoffAmount"`
+	CancellationCutoffUnit   string   `json:"cancellationCutoffUnit"`
+	AvailabilityType         string   `json:"availabilityType"`
+	DeliveryMethods          []string `json:"deliveryMethods"`
+	DeliveryFormats          []string `json:"deliveryFormats"`
+	Options                  []Option `json:"options"`
 }
 
+// Availability types defined by OCTO. START_TIME products run at fixed times
+// of day; OPENING_HOURS products can be visited any time the venue is open.
+const (
+	AvailabilityTypeStartTime    = "START_TIME"
+	AvailabilityTypeOpeningHours = "OPENING_HOURS"
+)
+
+// Delivery methods defined by OCTO: a VOUCHER covers the whole booking and a
+// TICKET a single unit.
+const (
+	DeliveryMethodVoucher = "VOUCHER"
+	DeliveryMethodTicket  = "TICKET"
+)
+
+// Delivery formats defined by OCTO. QRCODE and CODE128 values are the payload
+// to encode in the barcode; PDF_URL values link to a printable voucher.
+const (
+	DeliveryFormatQRCode  = "QRCODE"
+	DeliveryFormatCode128 = "CODE128"
+	DeliveryFormatPDFURL  = "PDF_URL"
+)
+
+// DeliveryMethods and DeliveryFormats list every value OCTO defines. Products
+// offer all of them unless they choose fewer.
+var (
+	DeliveryMethods = []string{DeliveryMethodVoucher, DeliveryMethodTicket}
+	DeliveryFormats = []string{DeliveryFormatQRCode, DeliveryFormatCode128, DeliveryFormatPDFURL}
+)
+
+// RedemptionMethodDigital means a voucher is scanned or shown on a phone.
+const RedemptionMethodDigital = "DIGITAL"
+
+// Cancellation cutoff units defined by OCTO.
+const (
+	CutoffUnitMinute = "minute"
+	CutoffUnitHour   = "hour"
+	CutoffUnitDay    = "day"
+)
+
+type Option struct {
+	ID           string  `json:"id"`
+	ProductId    string  `json:"productId"`
+	Default      bool    `json:"default"`
+	InternalName string  `json:"internalName"`
+	Reference    *string `json:"reference"`
+	Units        []Unit  `json:"units"`
+}
+
+// Unit types defined by OCTO.
+const (
+	UnitTypeAdult    = "ADULT"
+	UnitTypeYouth    = "YOUTH"
+	UnitTypeChild    = "CHILD"
+	UnitTypeInfant   = "INFANT"
+	UnitTypeFamily   = "FAMILY"
+	UnitTypeSenior   = "SENIOR"
+	UnitTypeStudent  = "STUDENT"
+	UnitTypeMilitary = "MILITARY"
+	UnitTypeOther    = "OTHER"
+)
+
+type Unit struct {
+	ID           string           `json:"id"`
+	OptionId     string           `json:"optionId"`
+	InternalName string           `json:"internalName"`
+	Reference    *string          `json:"reference"`
+	Type         string           `json:"type"`
+	Restrictions UnitRestrictions `json:"restrictions"`
+	Price        int64            `json:"price"`
+	Currency     string           `json:"currency"`
+}
+
+type UnitRestrictions struct {
+	MinAge        int      `json:"minAge"`
+	MaxAge        int      `json:"maxAge"`
+	IdRequired    bool     `json:"idRequired"`
+	MinQuantity   *int     `json:"minQuantity"`
+	MaxQuantity   *int     `json:"maxQuantity"`
+	PaxCount      int      `json:"paxCount"`
+	AccompaniedBy []string `json:"accompaniedBy"`
+}
+
+// Availability statuses defined by OCTO. AVAILABLE, LIMITED and SOLD_OUT follow
+// the remaining vacancies; CLOSED and FREESALE are set by hand.
+const (
+	AvailabilityStatusAvailable = "AVAILABLE"
+	AvailabilityStatusLimited   = "LIMITED"
+	AvailabilityStatusSoldOut   = "SOLD_OUT"
+	AvailabilityStatusClosed    = "CLOSED"
+	AvailabilityStatusFreesale  = "FREESALE"
+)
+
 type Availability struct {
-	ID        string    `json:"id"`
-	LocalDate time.Time `json:"localDate"`
-	Status    string    `json:"status"`
-	ProductId string    `json:"productId"`
-	Vacancies int       `json:"vacancies"`
-	Available bool      `json:"available"`
-	Price     float64   `json:"price"`
-	Currency  string    `json:"currency"`
+	ID                 string    `json:"id"`
+	LocalDate          time.Time `json:"localDate"`
+	LocalDateTimeStart time.Time `json:"localDateTimeStart"`
+	LocalDateTimeEnd   time.Time `json:"localDateTimeEnd"`
+	Status             string    `json:"status"`
+	ProductId          string    `json:"productId"`
+	Capacity           int       `json:"capacity"`
+	Vacancies          int       `json:"vacancies"`
+	Available          bool      `json:"available"`
+	Price              int64     `json:"price"`
+	Currency           string    `json:"currency"`
 }
 
 type AvailabilityShow struct {
-	ID          string    `json:"id"`
-	LocalDate   time.Time `json:"localDate"`
-	Status      string    `json:"status"`
-	ProductName string    `json:"productName"`
-	Vacancies   int       `json:"vacancies"`
-	Available   bool      `json:"available"`
-	Price       float64   `json:"price"`
-	Currency    string    `json:"currency"`
+	ID                 string    `json:"id"`
+	LocalDate          time.Time `json:"localDate"`
+	LocalDateTimeStart time.Time `json:"localDateTimeStart"`
+	LocalDateTimeEnd   time.Time `json:"localDateTimeEnd"`
+	Status             string    `json:"status"`
+	ProductName        string    `json:"productName"`
+	Vacancies          int       `json:"vacancies"`
+	Available          bool      `json:"available"`
+	Price              int64     `json:"price"`
+	Currency           string    `json:"currency"`
 }
 
+// ScheduleRule generates availabilities for a product on every matching day
+// between ValidFrom and ValidUntil. Weekdays holds day names such as MONDAY;
+// empty means every day. Capacity, Price and Currency override the product's
+// when set.
+type ScheduleRule struct {
+	ID              string      `json:"id"`
+	ProductId       string      `json:"productId"`
+	Weekdays        []string    `json:"weekdays"`
+	StartTimes      []string    `json:"startTimes"`
+	DurationMinutes int         `json:"durationMinutes"`
+	ValidFrom       time.Time   `json:"validFrom"`
+	ValidUntil      *time.Time  `json:"validUntil"`
+	BlackoutDates   []time.Time `json:"blackoutDates"`
+	Capacity        *int        `json:"capacity"`
+	Price           *int64      `json:"price"`
+	Currency        string      `json:"currency,omitempty"`
+}
+
+// Booking statuses defined by OCTO.
+const (
+	BookingStatusReserved  = "RESERVED"
+	BookingStatusConfirmed = "CONFIRMED"
+	BookingStatusCancelled = "CANCELLED"
+	BookingStatusExpired   = "EXPIRED"
+)
+
+// Booking is a reservation of units on an availability. UUID is the
+// reseller's reference, the ID unless they sent their own, and RequestHash
+// fingerprints the request that created it. VoucherReference is issued when
+// the booking is confirmed. ResellerId is the reseller whose API key created
+// it, empty for bookings made by admins. AmendableUntil is the last moment a
+// CONFIRMED booking may be amended, set only on amendments.
 type Booking struct {
-	ID             string  `json:"id"`
-	Status         string  `json:"status"`
-	AvailabilityId string  `json:"availabilityId"`
-	Units          int     `json:"units"`
-	Price          float64 `json:"price"`
-	Currency       string  `json:"currency"`
+	ID               string        `json:"id"`
+	UUID             string        `json:"uuid"`
+	Status           string        `json:"status"`
+	AvailabilityId   string        `json:"availabilityId"`
+	OptionId         string        `json:"optionId"`
+	Units            int           `json:"units"`
+	UnitItems        []BookingUnit `json:"unitItems"`
+	Price            int64         `json:"price"`
+	Currency         string        `json:"currency"`
+	UtcExpiresAt     *time.Time    `json:"utcExpiresAt"`
+	Cancellation     *Cancellation `json:"cancellation"`
+	Notes            *string       `json:"notes"`
+	Contact          Contact       `json:"contact"`
+	VoucherReference *string       `json:"-"`
+	ResellerId       string        `json:"-"`
+	RequestHash      string        `json:"-"`
+	AmendableUntil   *time.Time    `json:"-"`
+}
+
+// API key roles. Resellers sell and book products; admins also manage the
+// catalogue, availability and API keys, and see every reseller's bookings.
+const (
+	APIKeyRoleReseller = "RESELLER"
+	APIKeyRoleAdmin    = "ADMIN"
+)
+
+// APIKey is a bearer token callers authenticate with. Only the SHA-256 hash
+// of the key is stored. Reseller keys name the reseller their bookings are
+// stamped with; SupplierId, when set, is the supplier the key acts for.
+type APIKey struct {
+	KeyHash    string    `json:"-"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	ResellerId string    `json:"resellerId,omitempty"`
+	SupplierId string    `json:"supplierId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Webhook events. Booking events follow a booking through its lifecycle;
+// booking.updated also covers amendments, extensions, redemptions and expiry.
+// availability.updated is sent when an availability's vacancies or status
+// change.
+const (
+	WebhookEventBookingCreated      = "booking.created"
+	WebhookEventBookingUpdated      = "booking.updated"
+	WebhookEventBookingConfirmed    = "booking.confirmed"
+	WebhookEventBookingCancelled    = "booking.cancelled"
+	WebhookEventAvailabilityUpdated = "availability.updated"
+)
+
+// WebhookEvents lists every event a subscription can ask for.
+var WebhookEvents = []string{
+	WebhookEventBookingCreated,
+	WebhookEventBookingUpdated,
+	WebhookEventBookingConfirmed,
+	WebhookEventBookingCancelled,
+	WebhookEventAvailabilityUpdated,
+}
+
+// WebhookSubscription is an endpoint that receives the listed events. Secret
+// signs every delivery and is only shown when the subscription is created.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OutboxEvent is a change to the booking or availability ResourceId, recorded
+// in the same transaction as the change and waiting to be fanned out to
+// webhook subscriptions. Data is the resource as the change left it.
+type OutboxEvent struct {
+	ID         int64
+	Event      string
+	ResourceId string
+	Data       json.RawMessage
+	CreatedAt  time.Time
+}
+
+// Webhook delivery statuses. Deliveries are retried while PENDING and become
+// DEAD once they run out of attempts.
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryDead      = "DEAD"
+)
+
+// WebhookDelivery is one event sent to one subscription. Payload is the body
+// that is signed and sent on every attempt; Event, URL and Secret come from the
+// event and subscription it belongs to.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EventId        int64           `json:"eventId"`
+	Event          string          `json:"event"`
+	SubscriptionId string          `json:"subscriptionId"`
+	URL            string          `json:"url"`
+	Secret         string          `json:"-"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// IdempotentResponse is the response recorded for a request sent with an
+// Idempotency-Key. Status is zero while the request is still being handled,
+// which started at ClaimedAt.
+type IdempotentResponse struct {
+	Key         string
+	RequestHash string
+	ClaimedAt   time.Time
+	Status      int
+	Header      map[string][]string
+	Body        []byte
+}
+
+// Cancellation is the OCTO cancellation object, set once a booking is CANCELLED.
+type Cancellation struct {
+	Refund         string    `json:"refund"`
+	Reason         string    `json:"reason"`
+	UtcCancelledAt time.Time `json:"utcCancelledAt"`
+}
+
+// Contact is the OCTO contact object: the lead traveller of a booking, or the
+// guest holding one of its units. Country is an ISO 3166-1 alpha-2 code.
+type Contact struct {
+	FullName     string   `json:"fullName"`
+	EmailAddress string   `json:"emailAddress"`
+	PhoneNumber  string   `json:"phoneNumber"`
+	Locales      []string `json:"locales"`
+	Country      string   `json:"country"`
+	Notes        string   `json:"notes"`
+}
+
+// Voucher is the OCTO voucher object, used both for the voucher of a booking
+// and the ticket of each unit. Reference is the short code printed on it and
+// encoded in its barcodes. A ticket is redeemed when it is scanned at the gate,
+// recording the scanning device in RedeemedBy; a booking's voucher counts as
+// redeemed once all of its tickets are.
+type Voucher struct {
+	Reference        string           `json:"reference"`
+	RedemptionMethod string           `json:"redemptionMethod"`
+	UtcRedeemedAt    *time.Time       `json:"utcRedeemedAt"`
+	RedeemedBy       *string          `json:"redeemedBy,omitempty"`
+	DeliveryOptions  []DeliveryOption `json:"deliveryOptions"`
+}
+
+type DeliveryOption struct {
+	DeliveryFormat string `json:"deliveryFormat"`
+	DeliveryValue  string `json:"deliveryValue"`
 }
 
 type BookingUnit struct {
-	ID        string  `json:"id"`
-	BookingId string  `json:"bookingId"`
-	Price     float64 `json:"price"`
-	Currency  string  `json:"currency"`
+	ID            string     `json:"id"`
+	BookingId     string     `json:"bookingId"`
+	UnitId        string     `json:"unitId"`
+	Ticket        *string    `json:"ticket"`
+	Pricing       Pricing    `json:"pricing"`
+	Contact       *Contact   `json:"contact"`
+	UtcRedeemedAt *time.Time `json:"utcRedeemedAt"`
+	RedeemedBy    *string    `json:"redeemedBy"`
+}
+
+// Pricing is the OCTO pricing object. Retail is what the customer pays, Net
+// is what the supplier receives and Original is the retail price before any
+// discount. Like every price in this package, amounts are integers in the
+// minor unit of Currency (see package money).
+type Pricing struct {
+	Original          int64  `json:"original"`
+	Retail            int64  `json:"retail"`
+	Net               int64  `json:"net"`
+	Currency          string `json:"currency"`
+	CurrencyPrecision int    `json:"currencyPrecision"`
+	IncludedTaxes     []Tax  `json:"includedTaxes"`
+}
+
+type Tax struct {
+	Name   string `json:"name"`
+	Retail int64  `json:"retail"`
+	Net    int64  `json:"net"`
 }
@@ -3,91 +3,295 @@ package model
 import "time"
 
 type ProductPayload_Rq struct {
-	Name     string  `json:"name"`
-	Capacity int     `json:"capacity"`
-	Price    float64 `json:"price,omitempty"`
-	Currency string  `json:"currency,omitempty"`
+	SupplierId               string             `json:"supplierId,omitempty"`
+	Name                     string             `json:"name"`
+	Capacity                 int                `json:"capacity"`
+	Price                    int64              `json:"price,omitempty"`
+	Currency                 string             `json:"currency,omitempty"`
+	CancellationCutoffAmount int                `json:"cancellationCutoffAmount,omitempty"`
+	CancellationCutoffUnit   string             `json:"cancellationCutoffUnit,omitempty"`
+	AvailabilityType         string             `json:"availabilityType,omitempty"`
+	DeliveryMethods          []string           `json:"deliveryMethods,omitempty"`
+	DeliveryFormats          []string           `json:"deliveryFormats,omitempty"`
+	Options                  []OptionPayload_Rq `json:"options,omitempty"`
 }
 
-type ProductPayload_Rs_NonPricing struct {
-	Id       string `json:"id"`
-	Name     string `json:"name"`
-	Capacity int    `json:"capacity"`
+type OptionPayload_Rq struct {
+	InternalName string           `json:"internalName"`
+	Reference    *string          `json:"reference,omitempty"`
+	Default      bool             `json:"default,omitempty"`
+	Units        []UnitPayload_Rq `json:"units"`
+}
+
+// UnitPayload_Rq describes a unit to create. Restrictions.AccompaniedBy lists
+// unit types (e.g. ADULT) of sibling units in the same option; they are
+// resolved to unit IDs when the product is stored.
+type UnitPayload_Rq struct {
+	InternalName string           `json:"internalName"`
+	Reference    *string          `json:"reference,omitempty"`
+	Type         string           `json:"type"`
+	Restrictions UnitRestrictions `json:"restrictions"`
+	Price        int64            `json:"price"`
+	Currency     string           `json:"currency,omitempty"`
 }
 
 type ProductPayload_Rs_Pricing struct {
-	Id       string  `json:"id"`
-	Name     string  `json:"name"`
-	Capacity int     `json:"capacity"`
-	Price    float64 `json:"price"`
-	Currency string  `json:"currency"`
+	Id                       string                     `json:"id"`
+	SupplierId               string                     `json:"supplierId"`
+	Name                     string                     `json:"name"`
+	Capacity                 int                        `json:"capacity"`
+	Price                    int64                      `json:"price"`
+	Currency                 string                     `json:"currency"`
+	CancellationCutoffAmount int                        `json:"cancellationCutoffAmount"`
+	CancellationCutoffUnit   string                     `json:"cancellationCutoffUnit"`
+	AvailabilityType         string                     `json:"availabilityType"`
+	DeliveryMethods          []string                   `json:"deliveryMethods"`
+	DeliveryFormats          []string                   `json:"deliveryFormats"`
+	Options                  []OptionPayload_Rs_Pricing `json:"options"`
 }
 
-type AvailabilityPayload_Rq struct {
-	LocalDate      string `json:"localDate,omitempty"`
-	LocalDateStart string `json:"localDateStart,omitempty"`
-	LocalDateEnd   string `json:"localDateEnd,omitempty"`
+type OptionPayload_Rs_Pricing struct {
+	Id           string                   `json:"id"`
+	Default      bool                     `json:"default"`
+	InternalName string                   `json:"internalName"`
+	Reference    *string                  `json:"reference"`
+	Units        []UnitPayload_Rs_Pricing `json:"units"`
 }
 
-type AvailabilityNewPayload_Rq struct {
-	ProductId      string  `json:"productId"`
-	LocalDate      string  `json:"localDate,omitempty"`
-	LocalDateStart string  `json:"localDateStart,omitempty"`
-	LocalDateEnd   string  `json:"localDateEnd,omitempty"`
-	Price          float64 `json:"price,omitempty"`
-	Currency       string  `json:"currency,omitempty"`
+type UnitPayload_Rs_Pricing struct {
+	Id           string           `json:"id"`
+	InternalName string           `json:"internalName"`
+	Reference    *string          `json:"reference"`
+	Type         string           `json:"type"`
+	Restrictions UnitRestrictions `json:"restrictions"`
+	Price        int64            `json:"price"`
+	Currency     string           `json:"currency"`
 }
 
-type AvailabilityPayload_Rs_NonPricing struct {
-	Id          string    `json:"id"`
-	LocalDate   time.Time `json:"localDate"`
-	Status      string    `json:"status"`
-	ProductName string    `json:"productName"`
-	Vacancies   int       `json:"vacancies"`
-	Available   bool      `json:"available"`
+// AvailabilityNewPayload_Rq adds availabilities for each day in a range.
+// StartTimes are local "HH:MM" times in the supplier's timezone: one slot per
+// start time for START_TIME products, or the opening time of OPENING_HOURS
+// products. Slots last DurationMinutes, or until the end of the day when zero.
+type AvailabilityNewPayload_Rq struct {
+	ProductId       string   `json:"productId"`
+	LocalDate       string   `json:"localDate,omitempty"`
+	LocalDateStart  string   `json:"localDateStart,omitempty"`
+	LocalDateEnd    string   `json:"localDateEnd,omitempty"`
+	StartTimes      []string `json:"startTimes,omitempty"`
+	DurationMinutes int      `json:"durationMinutes,omitempty"`
+	Price           int64    `json:"price,omitempty"`
+	Currency        string   `json:"currency,omitempty"`
 }
 
 type AvailabilityPayload_Rs_Pricing struct {
-	Id          string    `json:"id"`
-	LocalDate   time.Time `json:"localDate"`
-	Status      string    `json:"status"`
-	ProductName string    `json:"productName"`
-	Vacancies   int       `json:"vacancies"`
-	Available   bool      `json:"available"`
-	Price       float64   `json:"price"`
-	Currency    string    `json:"currency"`
+	Id                 string    `json:"id"`
+	LocalDate          time.Time `json:"localDate"`
+	LocalDateTimeStart time.Time `json:"localDateTimeStart"`
+	LocalDateTimeEnd   time.Time `json:"localDateTimeEnd"`
+	Status             string    `json:"status"`
+	ProductName        string    `json:"productName"`
+	Vacancies          int       `json:"vacancies"`
+	Available          bool      `json:"available"`
+	Price              int64     `json:"price"`
+	Currency           string    `json:"currency"`
+}
+
+// UnitQuantityPayload_Rq asks for a number of one unit, e.g. 2 adults.
+type UnitQuantityPayload_Rq struct {
+	Id       string `json:"id"`
+	Quantity int    `json:"quantity"`
+}
+
+// AvailabilityCalendarPayload_Rq asks for per-day availability of a product
+// option between two local dates, optionally for a mix of units.
+type AvailabilityCalendarPayload_Rq struct {
+	ProductId      string                   `json:"productId"`
+	OptionId       string                   `json:"optionId,omitempty"`
+	LocalDateStart string                   `json:"localDateStart"`
+	LocalDateEnd   string                   `json:"localDateEnd"`
+	Units          []UnitQuantityPayload_Rq `json:"units,omitempty"`
+}
+
+// OpeningHours is a local "HH:MM" time range within a day.
+type OpeningHours struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// UnitPricing is the pricing of a single unit.
+type UnitPricing struct {
+	UnitId string `json:"unitId"`
+	Pricing
+}
+
+type AvailabilityCalendarPayload_Rs_Pricing struct {
+	LocalDate       string         `json:"localDate"`
+	Available       bool           `json:"available"`
+	Status          string         `json:"status"`
+	Vacancies       int            `json:"vacancies"`
+	Capacity        int            `json:"capacity"`
+	OpeningHours    []OpeningHours `json:"openingHours"`
+	UnitPricingFrom []UnitPricing  `json:"unitPricingFrom"`
+	PricingFrom     *Pricing       `json:"pricingFrom,omitempty"`
+}
+
+// AvailabilityCheckPayload_Rq asks which timeslots of a product option can
+// take a mix of units, either by availabilityIds or within a date range.
+type AvailabilityCheckPayload_Rq struct {
+	ProductId       string                   `json:"productId"`
+	OptionId        string                   `json:"optionId"`
+	LocalDate       string                   `json:"localDate,omitempty"`
+	LocalDateStart  string                   `json:"localDateStart,omitempty"`
+	LocalDateEnd    string                   `json:"localDateEnd,omitempty"`
+	AvailabilityIds []string                 `json:"availabilityIds,omitempty"`
+	Units           []UnitQuantityPayload_Rq `json:"units,omitempty"`
+}
+
+type AvailabilityCheckPayload_Rs_Pricing struct {
+	Id                 string         `json:"id"`
+	LocalDateTimeStart time.Time      `json:"localDateTimeStart"`
+	LocalDateTimeEnd   time.Time      `json:"localDateTimeEnd"`
+	AllDay             bool           `json:"allDay"`
+	Available          bool           `json:"available"`
+	Status             string         `json:"status"`
+	Vacancies          int            `json:"vacancies"`
+	Capacity           int            `json:"capacity"`
+	OpeningHours       []OpeningHours `json:"openingHours"`
+	UnitPricing        []UnitPricing  `json:"unitPricing"`
+	Pricing            *Pricing       `json:"pricing,omitempty"`
+}
+
+// AvailabilityUpdatePayload_Rq edits an availability by hand. Status may be
+// CLOSED, FREESALE or AVAILABLE (reopen); Vacancies replaces the remaining
+// vacancies.
+type AvailabilityUpdatePayload_Rq struct {
+	Status    string `json:"status,omitempty"`
+	Vacancies *int   `json:"vacancies,omitempty"`
+}
+
+// ScheduleRulePayload_Rq creates or replaces a schedule rule. Dates are
+// YYYY-MM-DD; ValidUntil may be left out for an open-ended rule.
+type ScheduleRulePayload_Rq struct {
+	Weekdays        []string `json:"weekdays,omitempty"`
+	StartTimes      []string `json:"startTimes,omitempty"`
+	DurationMinutes int      `json:"durationMinutes,omitempty"`
+	ValidFrom       string   `json:"validFrom"`
+	ValidUntil      string   `json:"validUntil,omitempty"`
+	BlackoutDates   []string `json:"blackoutDates,omitempty"`
+	Capacity        *int     `json:"capacity,omitempty"`
+	Price           *int64   `json:"price,omitempty"`
+	Currency        string   `json:"currency,omitempty"`
 }
 
 type BookingPayload_Rq struct {
-	ProductId      string `json:"productId,omitempty"`
-	AvailabilityId string `json:"availabilityId"`
-	Units          int    `json:"units"`
+	// UUID is the reseller's own reference. Repeating a request with the same
+	// UUID returns the original booking instead of booking again.
+	UUID           string               `json:"uuid,omitempty"`
+	ProductId      string               `json:"productId,omitempty"`
+	OptionId       string               `json:"optionId,omitempty"`
+	AvailabilityId string               `json:"availabilityId"`
+	UnitItems      []UnitItemPayload_Rq `json:"unitItems"`
+	// ExpirationMinutes is how long the reservation holds its vacancies before
+	// it expires. Zero means the server default.
+	ExpirationMinutes int     `json:"expirationMinutes,omitempty"`
+	Notes             *string `json:"notes,omitempty"`
+}
+
+// BookingUpdatePayload_Rq amends a booking. Omitted fields keep their value;
+// unitItems, when sent, replace every unit of the booking.
+type BookingUpdatePayload_Rq struct {
+	AvailabilityId string               `json:"availabilityId,omitempty"`
+	OptionId       string               `json:"optionId,omitempty"`
+	UnitItems      []UnitItemPayload_Rq `json:"unitItems,omitempty"`
+	Notes          *string              `json:"notes,omitempty"`
+	Contact        *Contact             `json:"contact,omitempty"`
+}
+
+// BookingConfirmationPayload_Rq carries the contacts captured when a booking
+// is confirmed. Unit items are matched to the booking's units by ID.
+type BookingConfirmationPayload_Rq struct {
+	Contact   *Contact                       `json:"contact,omitempty"`
+	UnitItems []BookingUnitContactPayload_Rq `json:"unitItems,omitempty"`
+}
+
+type BookingUnitContactPayload_Rq struct {
+	ID      string  `json:"id"`
+	Contact Contact `json:"contact"`
+}
+
+// TicketRedemptionPayload_Rq scans a voucher or ticket reference at the gate.
+// A ticket admits its unit; a booking voucher admits every unit not yet
+// admitted. Device identifies the scanner.
+type TicketRedemptionPayload_Rq struct {
+	Reference string `json:"reference"`
+	Device    string `json:"device"`
+}
+
+// APIKeyPayload_Rq issues an API key. Reseller keys must name their
+// resellerId.
+type APIKeyPayload_Rq struct {
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	ResellerId string `json:"resellerId"`
+	SupplierId string `json:"supplierId"`
+}
+
+// APIKeyPayload_Rs is a newly issued API key. Key is only ever shown here.
+type APIKeyPayload_Rs struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// WebhookSubscriptionPayload_Rq subscribes url to events. A secret is
+// generated when none is sent.
+type WebhookSubscriptionPayload_Rq struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// WebhookSubscriptionPayload_Rs is a new subscription. Secret is only ever
+// shown here.
+type WebhookSubscriptionPayload_Rs struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type BookingExtendPayload_Rq struct {
+	ExpirationMinutes int `json:"expirationMinutes"`
+}
+
+type UnitItemPayload_Rq struct {
+	UnitId string `json:"unitId"`
+}
+
+type BookingCancellationPayload_Rq struct {
+	Reason string `json:"reason"`
 }
 
 type BookingPayload_Rs struct {
 	ID             string                  `json:"id"`
+	UUID           string                  `json:"uuid"`
 	Status         string                  `json:"status"`
 	AvailabilityId string                  `json:"availabilityId"`
+	OptionId       string                  `json:"optionId"`
 	Units          []BookingUnitPayload_Rs `json:"units"`
-	Price          float64                 `json:"price"`
+	Price          int64                   `json:"price"`
 	Currency       string                  `json:"currency"`
+	UtcExpiresAt   *time.Time              `json:"utcExpiresAt"`
+	Cancellation   *Cancellation           `json:"cancellation"`
+	Notes          *string                 `json:"notes"`
+	Contact        Contact                 `json:"contact"`
+	Voucher        *Voucher                `json:"voucher"`
+	ResellerId     string                  `json:"-"`
+	RequestHash    string                  `json:"-"`
 }
 
 type BookingUnitPayload_Rs struct {
-	ID        string  `json:"id"`
-	BookingId string  `json:"bookingId"`
-	Price     float64 `json:"price"`
-	Currency  string  `json:"currency"`
-}
-
-type BookingPayload_Rs_NonPricing struct {
-	ID             string                             `json:"id"`
-	Status         string                             `json:"status"`
-	AvailabilityId string                             `json:"availabilityId"`
-	Units          []BookingUnitPayload_Rs_NonPricing `json:"units"`
-}
-
-type BookingUnitPayload_Rs_NonPricing struct {
-	ID        string `json:"id"`
-	BookingId string `json:"bookingId"`
+	ID        string   `json:"id"`
+	BookingId string   `json:"bookingId"`
+	UnitId    string   `json:"unitId"`
+	Ticket    *Voucher `json:"ticket"`
+	Pricing   Pricing  `json:"pricing"`
+	Contact   *Contact `json:"contact"`
 }
@@ -0,0 +1,21 @@
+package money
+
+import "strings"
+
+// precisions lists the ISO 4217 currencies whose minor unit is not two
+// decimal places.
+var precisions = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// Precision returns the number of decimal places used by currency. Unknown
+// currencies default to two.
+func Precision(currency string) int {
+	if p, ok := precisions[strings.ToUpper(currency)]; ok {
+		return p
+	}
+	return 2
+}
@@ -0,0 +1,88 @@
+// Package money represents monetary amounts as integer minor units (cents,
+// pence, yen, ...) together with their ISO 4217 currency, so prices can be
+// added and multiplied without floating point drift.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+// Money is an amount in the minor unit of Currency, e.g. {1050, "EUR"} is €10.50.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New returns amount minor units of currency.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// FromMajor converts a decimal amount such as 10.50 into minor units,
+// rounding half away from zero.
+func FromMajor(amount float64, currency string) Money {
+	return New(int64(math.Round(amount*scale(currency))), currency)
+}
+
+// Major returns the amount as a decimal number of major units. It is meant
+// for display and for exchange-rate APIs, not for arithmetic.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / scale(m.Currency)
+}
+
+// Precision returns the number of decimal places used by m's currency.
+func (m Money) Precision() int {
+	return Precision(m.Currency)
+}
+
+// Add returns m + o. Both amounts must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Mul returns m multiplied by n.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Convert returns m expressed in currency to, using rate units of to per
+// major unit of m's currency. The result is rounded half away from zero.
+func (m Money) Convert(rate float64, to string) Money {
+	return FromMajor(m.Major()*rate, to)
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// String formats m as e.g. "10.50 EUR".
+func (m Money) String() string {
+	return fmt.Sprintf("%.*f %s", m.Precision(), m.Major(), m.Currency)
+}
+
+// Sum adds up amounts that share currency. It returns zero of currency when
+// amounts is empty.
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := New(0, currency)
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func scale(currency string) float64 {
+	return math.Pow10(Precision(currency))
+}
@@ -0,0 +1,72 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{10.50, "EUR", 1050},
+		{0.1 + 0.2, "USD", 30},
+		{1000.4, "JPY", 1000},
+		{1.2345, "KWD", 1235},
+		{-2.005, "USD", -201},
+	}
+	for _, tt := range tests {
+		if got := FromMajor(tt.amount, tt.currency); got.Amount != tt.want {
+			t.Errorf("FromMajor(%v, %s) = %d, want %d", tt.amount, tt.currency, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	price := New(1999, "usd")
+	if price.Currency != "USD" {
+		t.Errorf("expected currency to be normalised, got %s", price.Currency)
+	}
+
+	total, err := price.Mul(3).Add(New(3, "USD"))
+	if err != nil || total.Amount != 6000 {
+		t.Errorf("expected 6000 USD, got %v (%v)", total, err)
+	}
+	if total.String() != "60.00 USD" {
+		t.Errorf("unexpected string %q", total.String())
+	}
+
+	if _, err := price.Add(New(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected ErrCurrencyMismatch, got %v", err)
+	}
+
+	sum, err := Sum("EUR", New(10, "EUR"), New(20, "EUR"))
+	if err != nil || sum.Amount != 30 {
+		t.Errorf("expected 30 EUR, got %v (%v)", sum, err)
+	}
+	if sum, _ := Sum("EUR"); !sum.IsZero() || sum.Currency != "EUR" {
+		t.Errorf("expected zero EUR, got %v", sum)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// 12.34 EUR at 1.1 USD per EUR is 13.574 USD, rounded to 13.57
+	if got := New(1234, "EUR").Convert(1.1, "USD"); got.Amount != 1357 || got.Currency != "USD" {
+		t.Errorf("unexpected conversion %v", got)
+	}
+	// 10.00 USD at 151.237 JPY per USD is 1512 JPY
+	if got := New(1000, "USD").Convert(151.237, "JPY"); got.Amount != 1512 {
+		t.Errorf("unexpected conversion %v", got)
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	tests := map[string]int{"USD": 2, "eur": 2, "JPY": 0, "KWD": 3, "CLF": 4, "XYZ": 2}
+	for currency, want := range tests {
+		if got := Precision(currency); got != want {
+			t.Errorf("Precision(%q) = %d, want %d", currency, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package octoerr implements the OCTO error responses: a JSON body with an
+// error code, a human readable errorMessage and the offending ID, e.g.
+//
+//	{"error": "INVALID_PRODUCT_ID", "errorMessage": "The productId was missing or invalid", "productId": "123"}
+package octoerr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is an OCTO error code.
+type Code string
+
+const (
+	InvalidProductIDCode      Code = "INVALID_PRODUCT_ID"
+	InvalidOptionIDCode       Code = "INVALID_OPTION_ID"
+	InvalidUnitIDCode         Code = "INVALID_UNIT_ID"
+	InvalidAvailabilityIDCode Code = "INVALID_AVAILABILITY_ID"
+	InvalidBookingUUIDCode    Code = "INVALID_BOOKING_UUID"
+	UnprocessableEntityCode   Code = "UNPROCESSABLE_ENTITY"
+	BadRequestCode            Code = "BAD_REQUEST"
+	UnauthorizedCode          Code = "UNAUTHORIZED"
+	ForbiddenCode             Code = "FORBIDDEN"
+	InternalServerErrorCode   Code = "INTERNAL_SERVER_ERROR"
+)
+
+// Error is an OCTO error response. Status is the HTTP status it is sent with;
+// OCTO answers every error but UNAUTHORIZED, FORBIDDEN and
+// INTERNAL_SERVER_ERROR with 400.
+type Error struct {
+	Status         int    `json:"-"`
+	Code           Code   `json:"error"`
+	Message        string `json:"errorMessage"`
+	ProductId      string `json:"productId,omitempty"`
+	OptionId       string `json:"optionId,omitempty"`
+	UnitId         string `json:"unitId,omitempty"`
+	AvailabilityId string `json:"availabilityId,omitempty"`
+	UUID           string `json:"uuid,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// WithStatus returns a copy of e sent with another HTTP status, e.g. 404 for
+// an unknown ID in the request path.
+func (e *Error) WithStatus(status int) *Error {
+	c := *e
+	c.Status = status
+	return &c
+}
+
+// InvalidProductID reports a missing or unknown productId.
+func InvalidProductID(productID string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: InvalidProductIDCode, Message: "The productId was missing or invalid", ProductId: productID}
+}
+
+// InvalidOptionID reports a missing or unknown optionId.
+func InvalidOptionID(optionID string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: InvalidOptionIDCode, Message: "The optionId was missing or invalid", OptionId: optionID}
+}
+
+// InvalidUnitID reports an unknown unit ID.
+func InvalidUnitID(unitID string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: InvalidUnitIDCode, Message: "The unitId was missing or invalid", UnitId: unitID}
+}
+
+// InvalidAvailabilityID reports a missing or unknown availabilityId.
+func InvalidAvailabilityID(availabilityID string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: InvalidAvailabilityIDCode, Message: "The availabilityId was missing or invalid", AvailabilityId: availabilityID}
+}
+
+// InvalidBookingUUID reports an unknown booking.
+func InvalidBookingUUID(uuid string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: InvalidBookingUUIDCode, Message: "The uuid was already used, missing or invalid", UUID: uuid}
+}
+
+// UnprocessableEntity reports a well-formed request that breaks a business
+// rule, e.g. too few vacancies.
+func UnprocessableEntity(message string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: UnprocessableEntityCode, Message: message}
+}
+
+// BadRequest reports a malformed request.
+func BadRequest(message string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: BadRequestCode, Message: message}
+}
+
+// Unauthorized reports a missing or unknown API key.
+func Unauthorized(message string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: UnauthorizedCode, Message: message}
+}
+
+// Forbidden reports an API key that may not make the request.
+func Forbidden(message string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: ForbiddenCode, Message: message}
+}
+
+// Internal reports a server failure without exposing its cause.
+func Internal() *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: InternalServerErrorCode, Message: "Internal server error"}
+}
+
+// Write sends err as an OCTO error response. Errors that are not an *Error are
+// logged and sent as INTERNAL_SERVER_ERROR so their details do not leak.
+func Write(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		fmt.Println(err.Error())
+		e = Internal()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
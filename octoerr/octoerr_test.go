@@ -0,0 +1,58 @@
+package octoerr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    Code
+		message string
+	}{
+		{"typed", InvalidProductID("missing"), http.StatusBadRequest, InvalidProductIDCode, "The productId was missing or invalid"},
+		{"wrapped", fmt.Errorf("lookup: %w", InvalidBookingUUID("missing").WithStatus(http.StatusNotFound)), http.StatusNotFound, InvalidBookingUUIDCode, "The uuid was already used, missing or invalid"},
+		{"unauthorized", Unauthorized("Missing API key"), http.StatusUnauthorized, UnauthorizedCode, "Missing API key"},
+		{"untyped", errors.New("pq: connection refused"), http.StatusInternalServerError, InternalServerErrorCode, "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Write(rec, tt.err)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected a JSON response, got %q", ct)
+			}
+			var body Error
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("error was not expected while decoding response: %s", err)
+			}
+			if body.Code != tt.code || body.Message != tt.message {
+				t.Errorf("expected %s %q, got %s %q", tt.code, tt.message, body.Code, body.Message)
+			}
+		})
+	}
+}
+
+func TestWithStatus(t *testing.T) {
+	e := InvalidProductID("missing")
+	notFound := e.WithStatus(http.StatusNotFound)
+
+	if e.Status != http.StatusBadRequest || notFound.Status != http.StatusNotFound {
+		t.Errorf("expected statuses 400 and 404, got %d and %d", e.Status, notFound.Status)
+	}
+	if notFound.ProductId != "missing" {
+		t.Errorf("expected the product ID to be kept, got %q", notFound.ProductId)
+	}
+}
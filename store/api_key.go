@@ -0,0 +1,43 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+)
+
+// GetAPIKey returns the API key with the given SHA-256 hash.
+func (s *PostgresStore) GetAPIKey(keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	var resellerID, supplierID sql.NullString
+	err := s.db.QueryRow(
+		"SELECT key_hash, name, role, reseller_id, supplier_id, created_at FROM api_keys WHERE key_hash = $1",
+		keyHash,
+	).Scan(&key.KeyHash, &key.Name, &key.Role, &resellerID, &supplierID, &key.CreatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Println(err.Error())
+		}
+		return nil, err
+	}
+	key.ResellerId, key.SupplierId = resellerID.String, supplierID.String
+	key.CreatedAt = key.CreatedAt.UTC()
+	return &key, nil
+}
+
+// InsertAPIKey stores a new API key.
+func (s *PostgresStore) InsertAPIKey(key model.APIKey) error {
+	_, err := s.db.Exec(
+		"INSERT INTO api_keys (key_hash, name, role, reseller_id, supplier_id, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		key.KeyHash, key.Name, key.Role, nullString(key.ResellerId), nullString(key.SupplierId), key.CreatedAt,
+	)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	return err
+}
+
+// nullString stores an empty string as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"database/sql"
+	"octo-api/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var apiKeyColumns = []string{"key_hash", "name", "role", "reseller_id", "supplier_id", "created_at"}
+
+func TestGetAPIKey(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	createdAt := time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE key_hash = \\$1").WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow("hash", "Reseller A", "RESELLER", "reseller_a", nil, createdAt))
+	mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE key_hash = \\$1").WithArgs("unknown").
+		WillReturnError(sql.ErrNoRows)
+
+	s := NewPostgresStore(db)
+	key, err := s.GetAPIKey("hash")
+	if err != nil {
+		t.Fatalf("error was not expected while fetching API key: %s", err)
+	}
+	if key.Role != model.APIKeyRoleReseller || key.ResellerId != "reseller_a" || key.SupplierId != "" || !key.CreatedAt.Equal(createdAt) {
+		t.Errorf("unexpected API key %+v", key)
+	}
+	if _, err := s.GetAPIKey("unknown"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for an unknown key, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestInsertAPIKey(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	createdAt := time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectExec("INSERT INTO api_keys").
+		WithArgs("hash", "Back office", "ADMIN", nil, "supplier_id", createdAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := NewPostgresStore(db).InsertAPIKey(model.APIKey{KeyHash: "hash", Name: "Back office", Role: model.APIKeyRoleAdmin, SupplierId: "supplier_id", CreatedAt: createdAt})
+	if err != nil {
+		t.Errorf("error was not expected while inserting API key: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
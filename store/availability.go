@@ -4,25 +4,27 @@ import (
 	"database/sql"
 	"fmt"
 	"octo-api/model"
+	"octo-api/money"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// GetAvailabilitiesFromDB queries all availabilities from the database.
-func GetAvailabilitiesFromDB(db *sql.DB, startDate, endDate time.Time) ([]model.AvailabilityShow, error) {
+// GetAvailabilities queries all availabilities between startDate and endDate,
+// one row per timeslot ordered by start time.
+func (s *PostgresStore) GetAvailabilities(startDate, endDate time.Time) ([]model.AvailabilityShow, error) {
 	var query string
 	var rows *sql.Rows
 	var err error
 
 	if startDate.Equal(endDate) {
 		// Single date query
-		query = "SELECT a.id, a.local_date, a.status, p.name AS product_name, a.vacancies, a.available, a.price AS availability_price, a.currency AS availability_currency FROM availabilities a INNER JOIN products p ON a.product_id = p.id WHERE a.local_date = $1"
-		rows, err = db.Query(query, startDate)
+		query = "SELECT a.id, a.local_date, a.local_date_time_start, a.local_date_time_end, a.status, p.name AS product_name, a.vacancies, a.available, a.price AS availability_price, a.currency AS availability_currency, s.timezone FROM availabilities a INNER JOIN products p ON a.product_id = p.id INNER JOIN suppliers s ON p.supplier_id = s.id WHERE a.local_date = $1 ORDER BY a.local_date_time_start, p.name"
+		rows, err = s.db.Query(query, startDate)
 	} else {
 		// Date range query
-		query = "SELECT a.id, a.local_date, a.status, p.name AS product_name, a.vacancies, a.available, a.price AS availability_price, a.currency AS availability_currency FROM availabilities a INNER JOIN products p ON a.product_id = p.id WHERE a.local_date BETWEEN $1 AND $2"
-		rows, err = db.Query(query, startDate, endDate)
+		query = "SELECT a.id, a.local_date, a.local_date_time_start, a.local_date_time_end, a.status, p.name AS product_name, a.vacancies, a.available, a.price AS availability_price, a.currency AS availability_currency, s.timezone FROM availabilities a INNER JOIN products p ON a.product_id = p.id INNER JOIN suppliers s ON p.supplier_id = s.id WHERE a.local_date BETWEEN $1 AND $2 ORDER BY a.local_date_time_start, p.name"
+		rows, err = s.db.Query(query, startDate, endDate)
 	}
 
 	if err != nil {
@@ -35,40 +37,64 @@ func GetAvailabilitiesFromDB(db *sql.DB, startDate, endDate time.Time) ([]model.
 	var availabilities []model.AvailabilityShow
 	for rows.Next() {
 		var cur model.AvailabilityShow
+		var timezone string
 		if err := rows.Scan(
 			&cur.ID,
 			&cur.LocalDate,
+			&cur.LocalDateTimeStart,
+			&cur.LocalDateTimeEnd,
 			&cur.Status,
 			&cur.ProductName,
 			&cur.Vacancies,
 			&cur.Available,
 			&cur.Price,
 			&cur.Currency,
+			&timezone,
 		); err != nil {
 			// log.Fatal(err)
 			fmt.Println(err.Error())
 			return nil, err
 		}
+		loc := loadLocation(timezone)
+		cur.LocalDateTimeStart, cur.LocalDateTimeEnd = cur.LocalDateTimeStart.In(loc), cur.LocalDateTimeEnd.In(loc)
 		availabilities = append(availabilities, cur)
 	}
 	return availabilities, nil
 }
 
-func GetAvailabilityByIdFromDB(db *sql.DB, id string) (*model.Availability, error) {
+// availabilitySelect selects availabilities for scanAvailability.
+const availabilitySelect = "SELECT a.id, a.local_date, a.local_date_time_start, a.local_date_time_end, a.status, a.product_id, a.capacity, a.vacancies, a.available, a.price, a.currency, s.timezone FROM availabilities a INNER JOIN products p ON a.product_id = p.id INNER JOIN suppliers s ON p.supplier_id = s.id"
+
+// scanAvailability reads a row selected with availabilitySelect. Its start
+// and end are in the supplier's timezone.
+func scanAvailability(row scanner) (model.Availability, error) {
 	var a model.Availability
-	err := db.QueryRow(
-		"SELECT id, local_date, status, product_id, vacancies, available, price, currency FROM availabilities WHERE id = $1",
-		id,
-	).Scan(
+	var timezone string
+	err := row.Scan(
 		&a.ID,
 		&a.LocalDate,
+		&a.LocalDateTimeStart,
+		&a.LocalDateTimeEnd,
 		&a.Status,
 		&a.ProductId,
+		&a.Capacity,
 		&a.Vacancies,
 		&a.Available,
 		&a.Price,
 		&a.Currency,
+		&timezone,
 	)
+	if err != nil {
+		return a, err
+	}
+	loc := loadLocation(timezone)
+	a.LocalDateTimeStart, a.LocalDateTimeEnd = a.LocalDateTimeStart.In(loc), a.LocalDateTimeEnd.In(loc)
+	return a, nil
+}
+
+// GetAvailabilityByID returns the availability with the given ID.
+func (s *PostgresStore) GetAvailabilityByID(id string) (*model.Availability, error) {
+	a, err := scanAvailability(s.db.QueryRow(availabilitySelect+" WHERE a.id = $1", id))
 	if err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
@@ -77,46 +103,119 @@ func GetAvailabilityByIdFromDB(db *sql.DB, id string) (*model.Availability, erro
 	return &a, nil
 }
 
-func AddAvailabilityIntoDB(db *sql.DB, productID string, startDate, endDate time.Time, price float64, currency string) error {
+// GetProductAvailabilities returns the availabilities of a product whose local
+// date falls between startDate and endDate, inclusive, ordered by start time.
+func (s *PostgresStore) GetProductAvailabilities(productID string, startDate, endDate time.Time) ([]model.Availability, error) {
+	rows, err := s.db.Query(availabilitySelect+" WHERE a.product_id = $1 AND a.local_date BETWEEN $2 AND $3 ORDER BY a.local_date_time_start", productID, startDate, endDate)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	availabilities := []model.Availability{}
+	for rows.Next() {
+		a, err := scanAvailability(rows)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		availabilities = append(availabilities, a)
+	}
+	return availabilities, rows.Err()
+}
+
+// AddAvailability creates availabilities for every day between startDate and
+// endDate: one per start time for START_TIME products, or a single opening
+// window for OPENING_HOURS products. Times are local to the supplier.
+func (s *PostgresStore) AddAvailability(productID string, startDate, endDate time.Time, timeslots Timeslots, price money.Money) error {
 
-	tx, err := db.Begin()
+	curProduct, err := s.GetProduct(productID)
 	if err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
 		return err
 	}
+	supplier, err := s.GetSupplier(curProduct.SupplierId)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	loc := loadLocation(supplier.Timezone)
 
-	indDate := startDate
+	// New availabilities start with the product's full capacity
+	status := s.policy.Status(model.AvailabilityStatusAvailable, curProduct.Capacity)
 
-	curProduct, err := GetProductFromDB(db, productID)
+	tx, err := s.db.Begin()
 	if err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
 		return err
 	}
 
-	for !indDate.After(endDate) {
-
-		insertAvaStmt := "INSERT INTO availabilities (id, local_date, status, product_id, vacancies, available, price, currency) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
-		_, err = tx.Exec(
-			insertAvaStmt,
-			uuid.NewString(),
-			indDate,
-			"AVAILABLE",
-			productID,
-			curProduct.Capacity,
-			true,
-			price,
-			currency,
-		)
+	insertAvaStmt := "INSERT INTO availabilities (id, local_date, local_date_time_start, local_date_time_end, status, product_id, capacity, vacancies, available, price, currency) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"
+	for indDate := startDate; !indDate.After(endDate); indDate = indDate.AddDate(0, 0, 1) {
+		slots, err := timeslots.slots(indDate, loc, curProduct.AvailabilityType)
 		if err != nil {
 			tx.Rollback()
-			// log.Fatal(err)
-			fmt.Println(err.Error())
-			continue
+			return err
 		}
 
-		indDate = indDate.AddDate(0, 0, 1)
+		for _, sl := range slots {
+			_, err = tx.Exec(
+				insertAvaStmt,
+				uuid.NewString(),
+				indDate,
+				sl.start,
+				sl.end,
+				status,
+				productID,
+				curProduct.Capacity,
+				curProduct.Capacity,
+				IsAvailable(status),
+				price.Amount,
+				price.Currency,
+			)
+			if err != nil {
+				tx.Rollback()
+				// log.Fatal(err)
+				fmt.Println(err.Error())
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateAvailability applies a manual edit to an availability. status may be
+// CLOSED, FREESALE, AVAILABLE to reopen it, or empty to keep it; vacancies, when
+// set, replaces the remaining vacancies. See StatusPolicy.Edit.
+func (s *PostgresStore) UpdateAvailability(availabilityID, status string, vacancies *int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var current string
+	var curVacancies int
+	err = tx.QueryRow("SELECT status, vacancies FROM availabilities WHERE id = $1 FOR UPDATE", availabilityID).Scan(&current, &curVacancies)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if vacancies != nil {
+		curVacancies = *vacancies
+	}
+
+	status, err = s.policy.Edit(current, status, curVacancies)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := setAvailability(tx, availabilityID, curVacancies, status); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	return tx.Commit()
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"octo-api/helper"
+	"octo-api/model"
+)
+
+// ErrAvailabilityClosed is returned when booking an availability that has
+// been closed by hand.
+var ErrAvailabilityClosed = errors.New("availability is closed")
+
+// ErrInvalidAvailabilityUpdate is returned for manual edits the state machine
+// does not allow.
+var ErrInvalidAvailabilityUpdate = errors.New("invalid availability update")
+
+// StatusPolicy is the availability status state machine. Open availabilities
+// are AVAILABLE, LIMITED once their vacancies drop to LimitedThreshold and
+// SOLD_OUT at zero. CLOSED and FREESALE are only entered and left through
+// Edit; a FREESALE availability has unlimited capacity and its vacancies are
+// not tracked.
+type StatusPolicy struct {
+	// LimitedThreshold is the number of remaining vacancies at or below which
+	// an open availability is LIMITED. Zero disables LIMITED.
+	LimitedThreshold int
+}
+
+// StatusPolicyFromEnv reads AVAILABILITY_LIMITED_THRESHOLD.
+func StatusPolicyFromEnv() (StatusPolicy, error) {
+	threshold, err := helper.EnvInt("AVAILABILITY_LIMITED_THRESHOLD", 0)
+	if err != nil {
+		return StatusPolicy{}, err
+	}
+	if threshold < 0 {
+		return StatusPolicy{}, fmt.Errorf("AVAILABILITY_LIMITED_THRESHOLD must not be negative")
+	}
+	return StatusPolicy{LimitedThreshold: threshold}, nil
+}
+
+// Status returns the status of an availability in status current once it has
+// the given vacancies.
+func (p StatusPolicy) Status(current string, vacancies int) string {
+	switch current {
+	case model.AvailabilityStatusClosed, model.AvailabilityStatusFreesale:
+		return current
+	}
+	switch {
+	case vacancies <= 0:
+		return model.AvailabilityStatusSoldOut
+	case vacancies <= p.LimitedThreshold:
+		return model.AvailabilityStatusLimited
+	}
+	return model.AvailabilityStatusAvailable
+}
+
+// Book takes units off an availability and returns its new vacancies and status.
+func (p StatusPolicy) Book(status string, vacancies, units int) (int, string, error) {
+	switch status {
+	case model.AvailabilityStatusClosed:
+		return vacancies, status, ErrAvailabilityClosed
+	case model.AvailabilityStatusFreesale:
+		return vacancies, status, nil
+	}
+	if vacancies < units {
+		return vacancies, status, ErrInsufficientVacancies
+	}
+	vacancies -= units
+	return vacancies, p.Status(status, vacancies), nil
+}
+
+// Release gives units back to an availability, e.g. on cancellation or
+// expiry, and returns its new vacancies and status.
+func (p StatusPolicy) Release(status string, vacancies, units int) (int, string) {
+	if status == model.AvailabilityStatusFreesale {
+		return vacancies, status
+	}
+	vacancies += units
+	return vacancies, p.Status(status, vacancies)
+}
+
+// Edit applies a manual change. requested may be CLOSED or FREESALE, AVAILABLE
+// to reopen the availability with its status following the vacancies again, or
+// empty to keep the current status. LIMITED and SOLD_OUT follow from the
+// vacancies and cannot be requested.
+func (p StatusPolicy) Edit(current, requested string, vacancies int) (string, error) {
+	if vacancies < 0 {
+		return current, fmt.Errorf("%w: vacancies must not be negative", ErrInvalidAvailabilityUpdate)
+	}
+	switch requested {
+	case "":
+		return p.Status(current, vacancies), nil
+	case model.AvailabilityStatusClosed, model.AvailabilityStatusFreesale:
+		return requested, nil
+	case model.AvailabilityStatusAvailable:
+		return p.Status(requested, vacancies), nil
+	}
+	return current, fmt.Errorf("%w: status cannot be set to %q", ErrInvalidAvailabilityUpdate, requested)
+}
+
+// IsAvailable reports whether an availability in the given status can be booked.
+func IsAvailable(status string) bool {
+	switch status {
+	case model.AvailabilityStatusAvailable, model.AvailabilityStatusLimited, model.AvailabilityStatusFreesale:
+		return true
+	}
+	return false
+}
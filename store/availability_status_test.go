@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStatusPolicyBook(t *testing.T) {
+	policy := StatusPolicy{LimitedThreshold: 3}
+
+	tests := []struct {
+		name          string
+		status        string
+		vacancies     int
+		units         int
+		wantVacancies int
+		wantStatus    string
+		wantErr       error
+	}{
+		{"stays available", "AVAILABLE", 10, 2, 8, "AVAILABLE", nil},
+		{"becomes limited at the threshold", "AVAILABLE", 5, 2, 3, "LIMITED", nil},
+		{"limited stays limited", "LIMITED", 3, 1, 2, "LIMITED", nil},
+		{"sells out at zero", "LIMITED", 2, 2, 0, "SOLD_OUT", nil},
+		{"not enough vacancies", "AVAILABLE", 1, 2, 1, "AVAILABLE", ErrInsufficientVacancies},
+		{"sold out", "SOLD_OUT", 0, 1, 0, "SOLD_OUT", ErrInsufficientVacancies},
+		{"closed", "CLOSED", 10, 1, 10, "CLOSED", ErrAvailabilityClosed},
+		{"freesale ignores vacancies", "FREESALE", 0, 50, 0, "FREESALE", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vacancies, status, err := policy.Book(tt.status, tt.vacancies, tt.units)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if vacancies != tt.wantVacancies || status != tt.wantStatus {
+				t.Errorf("Book() = %d %s, want %d %s", vacancies, status, tt.wantVacancies, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStatusPolicyRelease(t *testing.T) {
+	policy := StatusPolicy{LimitedThreshold: 3}
+
+	tests := []struct {
+		name          string
+		status        string
+		vacancies     int
+		units         int
+		wantVacancies int
+		wantStatus    string
+	}{
+		{"sold out becomes limited", "SOLD_OUT", 0, 2, 2, "LIMITED"},
+		{"sold out becomes available", "SOLD_OUT", 0, 5, 5, "AVAILABLE"},
+		{"limited becomes available", "LIMITED", 3, 1, 4, "AVAILABLE"},
+		{"closed stays closed", "CLOSED", 0, 2, 2, "CLOSED"},
+		{"freesale is not tracked", "FREESALE", 0, 2, 0, "FREESALE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vacancies, status := policy.Release(tt.status, tt.vacancies, tt.units)
+			if vacancies != tt.wantVacancies || status != tt.wantStatus {
+				t.Errorf("Release() = %d %s, want %d %s", vacancies, status, tt.wantVacancies, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStatusPolicyEdit(t *testing.T) {
+	policy := StatusPolicy{LimitedThreshold: 3}
+
+	tests := []struct {
+		name       string
+		current    string
+		requested  string
+		vacancies  int
+		wantStatus string
+		wantErr    bool
+	}{
+		{"close", "AVAILABLE", "CLOSED", 10, "CLOSED", false},
+		{"freesale", "SOLD_OUT", "FREESALE", 0, "FREESALE", false},
+		{"reopen with vacancies", "CLOSED", "AVAILABLE", 10, "AVAILABLE", false},
+		{"reopen with few vacancies", "CLOSED", "AVAILABLE", 2, "LIMITED", false},
+		{"reopen without vacancies", "FREESALE", "AVAILABLE", 0, "SOLD_OUT", false},
+		{"change vacancies only", "AVAILABLE", "", 0, "SOLD_OUT", false},
+		{"change vacancies of a closed availability", "CLOSED", "", 5, "CLOSED", false},
+		{"derived status", "AVAILABLE", "SOLD_OUT", 0, "AVAILABLE", true},
+		{"unknown status", "AVAILABLE", "OPEN", 10, "AVAILABLE", true},
+		{"negative vacancies", "AVAILABLE", "", -1, "AVAILABLE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := policy.Edit(tt.current, tt.requested, tt.vacancies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Edit() = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStatusPolicyLimitedDisabled(t *testing.T) {
+	if status := (StatusPolicy{}).Status("AVAILABLE", 1); status != "AVAILABLE" {
+		t.Errorf("expected AVAILABLE without a limited threshold, got %s", status)
+	}
+}
@@ -1,27 +1,34 @@
 package store
 
 import (
+	"octo-api/money"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
-func TestGetAvailabilitiesFromDB(t *testing.T) {
+var availabilityColumns = []string{"id", "local_date", "local_date_time_start", "local_date_time_end", "status", "product_id", "capacity", "vacancies", "available", "price", "currency", "timezone"}
+
+func TestGetAvailabilities(t *testing.T) {
 	db, mock := NewMock()
 	defer db.Close()
 
 	// Mock rows data
-	rows := sqlmock.NewRows([]string{"id", "local_date", "status", "product_name", "vacancies", "available", "availability_price", "availability_currency"}).
-		AddRow("id1", time.Now(), "AVAILABLE", "Product 1", 10, true, 100.0, "USD")
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "local_date", "local_date_time_start", "local_date_time_end", "status", "product_name", "vacancies", "available", "availability_price", "availability_currency", "timezone"}).
+		AddRow("id1", start, start, start.Add(2*time.Hour), "AVAILABLE", "Product 1", 10, true, 10000, "USD", "Europe/Paris")
 
 	// Expectations
-	mock.ExpectQuery("^SELECT (.+) FROM availabilities a INNER JOIN products p").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT (.+) FROM availabilities a INNER JOIN products p (.+) INNER JOIN suppliers s (.+) ORDER BY a.local_date_time_start").WillReturnRows(rows)
 
-	_, err := GetAvailabilitiesFromDB(db, time.Now(), time.Now())
+	availabilities, err := NewPostgresStore(db).GetAvailabilities(time.Now(), time.Now())
 	if err != nil {
 		t.Errorf("Error was not expected, got %v", err)
 	}
+	if len(availabilities) != 1 || availabilities[0].LocalDateTimeStart.Format(time.RFC3339) != "2024-03-01T09:00:00+01:00" {
+		t.Errorf("expected a slot starting at 09:00 Paris time, got %+v", availabilities)
+	}
 
 	// Assert all expectations are met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -29,15 +36,38 @@ func TestGetAvailabilitiesFromDB(t *testing.T) {
 	}
 }
 
-func TestGetAvailabilityByIdFromDB(t *testing.T) {
+func TestGetProductAvailabilities(t *testing.T) {
 	db, mock := NewMock()
 	defer db.Close()
 
-	query := "SELECT id, local_date, status, product_id, vacancies, available, price, currency FROM availabilities WHERE id = \\$1"
-	mock.ExpectQuery(query).WithArgs("test_id").WillReturnRows(sqlmock.NewRows([]string{"id", "local_date", "status", "product_id", "vacancies", "available", "price", "currency"}).
-		AddRow("test_id", time.Now(), "AVAILABLE", "product_id", 5, true, 100.0, "USD"))
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT (.+) FROM availabilities a (.+) WHERE a.product_id = \\$1 AND a.local_date BETWEEN \\$2 AND \\$3 ORDER BY a.local_date_time_start").
+		WithArgs("product_id", day, day.AddDate(0, 0, 6)).
+		WillReturnRows(sqlmock.NewRows(availabilityColumns).
+			AddRow("id1", day, day.Add(9*time.Hour), day.Add(11*time.Hour), "AVAILABLE", "product_id", 10, 4, true, 10000, "USD", "America/New_York"))
 
-	_, err := GetAvailabilityByIdFromDB(db, "test_id")
+	availabilities, err := NewPostgresStore(db).GetProductAvailabilities("product_id", day, day.AddDate(0, 0, 6))
+	if err != nil {
+		t.Fatalf("error was not expected while fetching availabilities: %s", err)
+	}
+	if len(availabilities) != 1 || availabilities[0].Capacity != 10 || availabilities[0].LocalDateTimeStart.Format(time.RFC3339) != "2024-03-01T04:00:00-05:00" {
+		t.Errorf("unexpected availabilities %+v", availabilities)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetAvailabilityByID(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	query := "SELECT a.id, a.local_date, a.local_date_time_start, a.local_date_time_end, a.status, a.product_id, a.capacity, a.vacancies, a.available, a.price, a.currency, s.timezone FROM availabilities a (.+) WHERE a.id = \\$1"
+	mock.ExpectQuery(query).WithArgs("test_id").WillReturnRows(sqlmock.NewRows(availabilityColumns).
+		AddRow("test_id", time.Now(), time.Now(), time.Now(), "AVAILABLE", "product_id", 10, 5, true, 10000, "USD", "UTC"))
+
+	_, err := NewPostgresStore(db).GetAvailabilityByID("test_id")
 	if err != nil {
 		t.Fatalf("error was not expected while fetching data: %s", err)
 	}
@@ -47,27 +77,40 @@ func TestGetAvailabilityByIdFromDB(t *testing.T) {
 	}
 }
 
-func TestAddAvailabilityIntoDB(t *testing.T) {
+func TestAddAvailability(t *testing.T) {
 	db, mock := NewMock()
 	defer db.Close()
 
+	// Expect the product and supplier select queries
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").
+		WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(productColumns).
+			AddRow("product_id", "supplier_id", "Product Name", 100, 5000, "USD", 0, "hour", "START_TIME", "{VOUCHER,TICKET}", "{QRCODE,CODE128,PDF_URL}"))
+
+	mock.ExpectQuery("SELECT (.+) FROM options WHERE product_id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(optionColumns))
+
+	mock.ExpectQuery("SELECT (.+) FROM suppliers WHERE id = \\$1").WithArgs("supplier_id").
+		WillReturnRows(sqlmock.NewRows(supplierColumns).
+			AddRow("supplier_id", "Supplier", "http://localhost:8080", nil, nil, nil, nil, "Europe/London"))
+
 	// Begin transaction
 	mock.ExpectBegin()
 
-	// Expect the product select query
-	mock.ExpectQuery("SELECT id, name, capacity, price, currency FROM products WHERE id = \\$1").
-		WithArgs("product_id").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "capacity", "price", "currency"}).
-			AddRow("product_id", "Product Name", 100, 50.0, "USD"))
-
-	// Expect the insert into availabilities
-	mock.ExpectExec("INSERT INTO availabilities").
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Expect one insert into availabilities per start time, in local time
+	day := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	london, _ := time.LoadLocation("Europe/London")
+	for _, hour := range []int{9, 13} {
+		start := time.Date(2024, 7, 1, hour, 0, 0, 0, london)
+		mock.ExpectExec("INSERT INTO availabilities").
+			WithArgs(sqlmock.AnyArg(), day, start, start.Add(90*time.Minute), "AVAILABLE", "product_id", 100, 100, true, int64(10000), "USD").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
 
 	// Commit transaction
 	mock.ExpectCommit()
 
-	err := AddAvailabilityIntoDB(db, "product_id", time.Now(), time.Now(), 100.0, "USD")
+	err := NewPostgresStore(db).AddAvailability("product_id", day, day, Timeslots{StartTimes: []string{"13:00", "09:00"}, Duration: 90 * time.Minute}, money.New(10000, "USD"))
 	if err != nil {
 		t.Errorf("error was not expected while inserting data: %s", err)
 	}
@@ -76,3 +119,20 @@ func TestAddAvailabilityIntoDB(t *testing.T) {
 		t.Errorf("there were unmet expectations: %s", err)
 	}
 }
+
+func TestUpdateAvailability(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectAvailabilityUpdate(mock, "availability_id", "CLOSED", 4, 4, "AVAILABLE", true)
+	mock.ExpectCommit()
+
+	if err := NewPostgresStore(db).UpdateAvailability("availability_id", "AVAILABLE", nil); err != nil {
+		t.Fatalf("error was not expected while updating availability: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
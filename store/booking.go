@@ -2,127 +2,691 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"octo-api/model"
+	"octo-api/voucher"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
 )
 
-// CreateBooking inserts a new booking into the database and updates availability, with a check for sufficient vacancies.
-func CreateBooking(db *sql.DB, booking model.Booking) error {
-	tx, err := db.Begin()
+// ErrInsufficientVacancies is returned when a booking asks for more units than
+// the availability has left.
+var ErrInsufficientVacancies = errors.New("insufficient vacancies for the requested booking")
+
+// ErrBookingCancelled is returned when confirming a booking that has been cancelled.
+var ErrBookingCancelled = errors.New("booking has been cancelled")
+
+// ErrBookingExpired is returned when acting on a reservation that has expired.
+var ErrBookingExpired = errors.New("booking reservation has expired")
+
+// ErrBookingNotReserved is returned when extending a booking that is no
+// longer RESERVED.
+var ErrBookingNotReserved = errors.New("booking is not reserved")
+
+// ErrBookingNotConfirmed is returned when redeeming the tickets of a booking
+// that is not CONFIRMED.
+var ErrBookingNotConfirmed = errors.New("booking is not confirmed")
+
+// ErrTicketRedeemed is returned when redeeming a ticket that has already been
+// redeemed, or when an amendment would move or drop a redeemed unit.
+var ErrTicketRedeemed = errors.New("ticket has already been redeemed")
+
+// ErrAmendmentCutoff is returned when amending a CONFIRMED booking after its
+// amendment deadline.
+var ErrAmendmentCutoff = errors.New("amendment cutoff has passed")
+
+// ErrDuplicateBookingUUID is returned when creating a booking with a UUID that
+// another booking already has.
+var ErrDuplicateBookingUUID = errors.New("booking uuid already used")
+
+// isConstraintViolation reports whether err is Postgres rejecting a write
+// that breaks the named constraint.
+func isConstraintViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Class() == "23" && pqErr.Constraint == constraint
+}
+
+// CreateBooking inserts a new booking and takes its units off the availability.
+func (s *PostgresStore) CreateBooking(booking model.Booking) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	// Check if there are enough vacancies for the booking
+	// Lock the availability and check it can take the booking
+	var status string
 	var vacancies int
-	checkStmt := "SELECT vacancies FROM availabilities WHERE id = $1"
-	err = tx.QueryRow(checkStmt, booking.AvailabilityId).Scan(&vacancies)
+	checkStmt := "SELECT status, vacancies FROM availabilities WHERE id = $1 FOR UPDATE"
+	err = tx.QueryRow(checkStmt, booking.AvailabilityId).Scan(&status, &vacancies)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	if vacancies < booking.Units {
+	vacancies, status, err = s.policy.Book(status, vacancies, booking.Units)
+	if err != nil {
 		tx.Rollback()
-		return errors.New("insufficient vacancies for the requested booking")
+		return err
 	}
 
 	// Insert the booking
-	bookingStmt := "INSERT INTO bookings (id, status, availability_id, units, price, currency) VALUES ($1, $2, $3, $4, $5, $6)"
-	_, err = tx.Exec(bookingStmt, booking.ID, booking.Status, booking.AvailabilityId, booking.Units, booking.Price, booking.Currency)
+	bookingStmt := "INSERT INTO bookings (id, uuid, status, availability_id, option_id, units, price, currency, expires_at, notes, request_hash, reseller_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)"
+	_, err = tx.Exec(bookingStmt, booking.ID, bookingUUID(booking), booking.Status, booking.AvailabilityId, booking.OptionId, booking.Units, booking.Price, booking.Currency, booking.UtcExpiresAt, booking.Notes, booking.RequestHash, nullString(booking.ResellerId))
 	if err != nil {
 		tx.Rollback()
+		if isConstraintViolation(err, "bookings_uuid_key") {
+			return ErrDuplicateBookingUUID
+		}
 		return err
 	}
 
-	// check if the vacancies will be 0
-	emptyFlg := (vacancies == booking.Units)
+	// Insert the units reserved with the booking
+	if err := insertBookingUnits(tx, booking.ID, booking.UnitItems); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordBookingEvent(tx, model.WebhookEventBookingCreated, booking.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	// Update the availability
-	var updateStmt string
-	var result sql.Result
-	if emptyFlg {
-		// No need to update availability.status and availability.available
-		updateStmt = "UPDATE availabilities SET vacancies = vacancies - $1 WHERE id = $2"
-		result, err = tx.Exec(updateStmt, booking.Units, booking.AvailabilityId)
+	if err := setAvailability(tx, booking.AvailabilityId, vacancies, status); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertBookingUnits stores the units of a booking inside tx. Units keep the
+// ticket and redemption they carry, so amending a booking does not reissue or
+// reset them.
+func insertBookingUnits(tx *sql.Tx, bookingID string, units []model.BookingUnit) error {
+	unitStmt := "INSERT INTO booking_units (id, booking_id, unit_id, ticket, original, price, net, currency, currency_precision, included_taxes, contact, redeemed_at, redeemed_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)"
+	for _, unit := range units {
+		taxes, err := json.Marshal(taxesOrEmpty(unit.Pricing.IncludedTaxes))
+		if err != nil {
+			return err
+		}
+		contact, err := contactJSON(unit.Contact)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			unitStmt,
+			unit.ID,
+			bookingID,
+			unit.UnitId,
+			unit.Ticket,
+			unit.Pricing.Original,
+			unit.Pricing.Retail,
+			unit.Pricing.Net,
+			unit.Pricing.Currency,
+			unit.Pricing.CurrencyPrecision,
+			string(taxes),
+			contact,
+			unit.UtcRedeemedAt,
+			unit.RedeemedBy,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redeemedUnitIDs returns the IDs of the units of a booking whose ticket has
+// been redeemed.
+func redeemedUnitIDs(tx *sql.Tx, bookingID string) ([]string, error) {
+	rows, err := tx.Query("SELECT id FROM booking_units WHERE booking_id = $1 AND redeemed_at IS NOT NULL ORDER BY id", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// keepsUnits reports whether booking still has every unit in unitIDs.
+func keepsUnits(booking model.Booking, unitIDs []string) bool {
+	kept := map[string]bool{}
+	for _, unit := range booking.UnitItems {
+		kept[unit.ID] = true
+	}
+	for _, id := range unitIDs {
+		if !kept[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// saveBookingUnits sets the units of an amended booking inside tx: units it
+// already has are re-priced and keep their ticket and redemption, units not in
+// units are deleted and the others are inserted.
+func saveBookingUnits(tx *sql.Tx, bookingID string, units []model.BookingUnit) error {
+	ids := make([]string, 0, len(units))
+	for _, unit := range units {
+		ids = append(ids, unit.ID)
+	}
+	if _, err := tx.Exec("DELETE FROM booking_units WHERE booking_id = $1 AND NOT (id = ANY($2))", bookingID, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	updateStmt := "UPDATE booking_units SET unit_id = $1, original = $2, price = $3, net = $4, currency = $5, currency_precision = $6, included_taxes = $7, contact = $8 WHERE id = $9 AND booking_id = $10"
+	for _, unit := range units {
+		taxes, err := json.Marshal(taxesOrEmpty(unit.Pricing.IncludedTaxes))
+		if err != nil {
+			return err
+		}
+		contact, err := contactJSON(unit.Contact)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(
+			updateStmt,
+			unit.UnitId,
+			unit.Pricing.Original,
+			unit.Pricing.Retail,
+			unit.Pricing.Net,
+			unit.Pricing.Currency,
+			unit.Pricing.CurrencyPrecision,
+			string(taxes),
+			contact,
+			unit.ID,
+			bookingID,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 1 {
+			continue
+		}
+		if err := insertBookingUnits(tx, bookingID, []model.BookingUnit{unit}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contactJSON encodes the contact of a unit for its JSONB column, or nil when
+// the unit has none.
+func contactJSON(contact *model.Contact) (interface{}, error) {
+	if contact == nil {
+		return nil, nil
+	}
+	c := *contact
+	c.Locales = localesOrEmpty(c.Locales)
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// UpdateBooking amends a RESERVED or CONFIRMED booking: it moves the booking
+// to booking.AvailabilityId, sets its units, price and notes. Units whose ID
+// the booking already has keep their ticket and redemption, the others are
+// dropped or added. Vacancies move from the old availability to the new one
+// in the same transaction, and a confirmed booking gets tickets for its new
+// units. A CONFIRMED booking is refused with ErrAmendmentCutoff when at is
+// after booking.AmendableUntil.
+func (s *PostgresStore) UpdateBooking(booking model.Booking, at time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Lock the booking against concurrent changes
+	var status, oldAvailabilityID, oldOptionID string
+	var oldUnits int
+	err = tx.QueryRow("SELECT status, availability_id, COALESCE(option_id, ''), units FROM bookings WHERE id = $1 FOR UPDATE", booking.ID).Scan(&status, &oldAvailabilityID, &oldOptionID, &oldUnits)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	switch status {
+	case model.BookingStatusCancelled:
+		tx.Rollback()
+		return ErrBookingCancelled
+	case model.BookingStatusExpired:
+		tx.Rollback()
+		return ErrBookingExpired
+	case model.BookingStatusConfirmed:
+		if booking.AmendableUntil != nil && at.After(*booking.AmendableUntil) {
+			tx.Rollback()
+			return ErrAmendmentCutoff
+		}
+	}
+
+	// Guests already checked in stay on the slot and option they were
+	// admitted to
+	redeemed, err := redeemedUnitIDs(tx, booking.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(redeemed) > 0 && (booking.AvailabilityId != oldAvailabilityID || booking.OptionId != oldOptionID || !keepsUnits(booking, redeemed)) {
+		tx.Rollback()
+		return ErrTicketRedeemed
+	}
+
+	// Vacancies only move with the slot or the head count, so amending notes
+	// or contacts still works once the slot is closed
+	if booking.AvailabilityId != oldAvailabilityID || booking.Units != oldUnits {
+		if err := s.moveVacancies(tx, oldAvailabilityID, oldUnits, booking.AvailabilityId, booking.Units); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	updateStmt := "UPDATE bookings SET availability_id = $1, option_id = $2, units = $3, price = $4, currency = $5, notes = $6 WHERE id = $7"
+	_, err = tx.Exec(updateStmt, booking.AvailabilityId, booking.OptionId, booking.Units, booking.Price, booking.Currency, booking.Notes, booking.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := setContact(tx, booking.ID, booking.Contact); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := saveBookingUnits(tx, booking.ID, booking.UnitItems); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status == model.BookingStatusConfirmed {
+		if err := issueTickets(tx, booking.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := recordBookingEvent(tx, model.WebhookEventBookingUpdated, booking.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// vacancyState is the locked status and vacancies of an availability.
+type vacancyState struct {
+	status    string
+	vacancies int
+}
+
+// moveVacancies gives oldUnits back to the availability oldID and takes
+// newUnits from newID inside tx. Both may be the same availability.
+func (s *PostgresStore) moveVacancies(tx *sql.Tx, oldID string, oldUnits int, newID string, newUnits int) error {
+	// Lock both availabilities in ID order so concurrent moves cannot deadlock
+	ids := []string{oldID}
+	if newID != oldID {
+		ids = append(ids, newID)
+		sort.Strings(ids)
+	}
+	slots := map[string]*vacancyState{}
+	for _, id := range ids {
+		slot := &vacancyState{}
+		err := tx.QueryRow("SELECT status, vacancies FROM availabilities WHERE id = $1 FOR UPDATE", id).Scan(&slot.status, &slot.vacancies)
 		if err != nil {
+			return err
+		}
+		slots[id] = slot
+	}
+
+	// Give the old units back, then take the new ones
+	var err error
+	old, next := slots[oldID], slots[newID]
+	old.vacancies, old.status = s.policy.Release(old.status, old.vacancies, oldUnits)
+	if next.vacancies, next.status, err = s.policy.Book(next.status, next.vacancies, newUnits); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := setAvailability(tx, id, slots[id].vacancies, slots[id].status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseVacancies gives units back to an availability inside tx.
+func (s *PostgresStore) releaseVacancies(tx *sql.Tx, availabilityID string, units int) error {
+	var status string
+	var vacancies int
+	err := tx.QueryRow("SELECT status, vacancies FROM availabilities WHERE id = $1 FOR UPDATE", availabilityID).Scan(&status, &vacancies)
+	if err != nil {
+		return err
+	}
+	vacancies, status = s.policy.Release(status, vacancies, units)
+	return setAvailability(tx, availabilityID, vacancies, status)
+}
+
+// setAvailability stores the vacancies and status of an availability inside
+// tx and records the change in the outbox. Callers lock the row with SELECT ...
+// FOR UPDATE first, so concurrent bookings check and take vacancies one after
+// another; the vacancies check constraint backs that up.
+func setAvailability(tx *sql.Tx, availabilityID string, vacancies int, status string) error {
+	updateStmt := "UPDATE availabilities SET vacancies = $1, status = $2, available = $3 WHERE id = $4"
+	result, err := tx.Exec(updateStmt, vacancies, status, IsAvailable(status), availabilityID)
+	if isConstraintViolation(err, "availabilities_vacancies_check") {
+		return ErrInsufficientVacancies
+	}
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("failed to update availability")
+	}
+	return recordAvailabilityEvent(tx, availabilityID)
+}
+
+// ConfirmBooking updates the booking's status to CONFIRMED and generates
+// tickets. A non-nil contact replaces the lead traveller, and unitContacts,
+// keyed by booking unit ID, set the guest holding each unit.
+func (s *PostgresStore) ConfirmBooking(bookingID string, contact *model.Contact, unitContacts map[string]model.Contact) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM bookings WHERE id = $1 FOR UPDATE", bookingID).Scan(&status)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status == model.BookingStatusCancelled {
+		tx.Rollback()
+		return ErrBookingCancelled
+	}
+	if status == model.BookingStatusExpired {
+		tx.Rollback()
+		return ErrBookingExpired
+	}
+
+	// Confirmed bookings no longer expire
+	updateStmt := "UPDATE bookings SET status = 'CONFIRMED', expires_at = NULL WHERE id = $1 RETURNING id"
+	err = tx.QueryRow(updateStmt, bookingID).Scan(&bookingID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if contact != nil {
+		if err := setContact(tx, bookingID, *contact); err != nil {
 			tx.Rollback()
 			return err
 		}
-	} else {
-		// Update availability.status to SOLD_OUT and availability.available to false
-		updateStmt = "UPDATE availabilities SET vacancies = vacancies - $1, status = $2, available = $3 WHERE id = $4"
-		result, err = tx.Exec(updateStmt, booking.Units, "SOLD_OUT", false, booking.AvailabilityId)
+	}
+	unitIDs := make([]string, 0, len(unitContacts))
+	for unitID := range unitContacts {
+		unitIDs = append(unitIDs, unitID)
+	}
+	sort.Strings(unitIDs)
+	for _, unitID := range unitIDs {
+		unitContact := unitContacts[unitID]
+		encoded, err := contactJSON(&unitContact)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
+		if _, err := tx.Exec("UPDATE booking_units SET contact = $1 WHERE id = $2 AND booking_id = $3", encoded, unitID, bookingID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := issueTickets(tx, bookingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordBookingEvent(tx, model.WebhookEventBookingConfirmed, bookingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setContact stores the lead traveller of a booking inside tx.
+func setContact(tx *sql.Tx, bookingID string, contact model.Contact) error {
+	contactStmt := "UPDATE bookings SET contact_full_name = $1, contact_email_address = $2, contact_phone_number = $3, contact_locales = $4, contact_country = $5, contact_notes = $6 WHERE id = $7"
+	_, err := tx.Exec(contactStmt, contact.FullName, contact.EmailAddress, contact.PhoneNumber, pq.Array(localesOrEmpty(contact.Locales)), contact.Country, contact.Notes, bookingID)
+	return err
+}
+
+// issueTickets gives a booking its voucher reference and each of its units a
+// ticket reference inside tx. References already issued are kept.
+func issueTickets(tx *sql.Tx, bookingID string) error {
+	_, err := tx.Exec("UPDATE bookings SET voucher_reference = $1 WHERE id = $2 AND voucher_reference IS NULL", voucher.NewReference(), bookingID)
+	if err != nil {
+		return err
+	}
+
+	// Collect the units without a ticket
+	rows, err := tx.Query("SELECT id FROM booking_units WHERE booking_id = $1 AND ticket IS NULL ORDER BY id", bookingID)
+	if err != nil {
+		return err
+	}
+	var unitIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		unitIDs = append(unitIDs, id)
+	}
+	rows.Close()
+
+	for _, unitID := range unitIDs {
+		_, err := tx.Exec("UPDATE booking_units SET ticket = $1 WHERE id = $2", voucher.NewReference(), unitID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedeemTickets marks units of a CONFIRMED booking as redeemed at the given
+// time by device. If any of them already is, nothing changes and it returns
+// ErrTicketRedeemed; the booking is locked, so a ticket scanned at two gates
+// at once is only admitted once.
+func (s *PostgresStore) RedeemTickets(bookingID string, unitIDs []string, device string, at time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
 	}
 
+	var status string
+	err = tx.QueryRow("SELECT status FROM bookings WHERE id = $1 FOR UPDATE", bookingID).Scan(&status)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status != model.BookingStatusConfirmed {
+		tx.Rollback()
+		return ErrBookingNotConfirmed
+	}
+
+	redeemStmt := "UPDATE booking_units SET redeemed_at = $1, redeemed_by = $2 WHERE booking_id = $3 AND id = ANY($4) AND redeemed_at IS NULL"
+	result, err := tx.Exec(redeemStmt, at.UTC(), device, bookingID, pq.Array(unitIDs))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	affected, err := result.RowsAffected()
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	if affected == 0 {
+	if affected != int64(len(unitIDs)) {
+		tx.Rollback()
+		return ErrTicketRedeemed
+	}
+	if err := recordBookingEvent(tx, model.WebhookEventBookingUpdated, bookingID); err != nil {
 		tx.Rollback()
-		return errors.New("failed to update availability, possibly due to insufficient vacancies")
+		return err
 	}
 
 	return tx.Commit()
 }
 
-// ConfirmBooking updates the booking's status to CONFIRMED and generates tickets.
-func ConfirmBooking(db *sql.DB, bookingID string) error {
-	tx, err := db.Begin()
+// CancelBooking moves a booking to CANCELLED and gives its units back to the
+// availability. Cancelling an already cancelled booking is a no-op.
+func (s *PostgresStore) CancelBooking(bookingID, reason string) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	// Generate tickets and update booking status
-	// This is a simplified approach. Adjust according to your schema and requirements.
-	updateStmt := "UPDATE bookings SET status = 'CONFIRMED' WHERE id = $1 RETURNING availability_id, units"
-	var availabilityID string
+	// Lock the booking so concurrent cancellations restore vacancies only once
+	var status, availabilityID string
 	var units int
-	err = tx.QueryRow(updateStmt, bookingID).Scan(&availabilityID, &units)
+	err = tx.QueryRow("SELECT status, availability_id, units FROM bookings WHERE id = $1 FOR UPDATE", bookingID).Scan(&status, &availabilityID, &units)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
+	if status == model.BookingStatusCancelled {
+		tx.Rollback()
+		return nil
+	}
+	if status == model.BookingStatusExpired {
+		tx.Rollback()
+		return ErrBookingExpired
+	}
 
-	ava, err := GetAvailabilityByIdFromDB(db, availabilityID)
+	cancelStmt := "UPDATE bookings SET status = $1, cancellation_reason = $2, cancelled_at = $3 WHERE id = $4"
+	_, err = tx.Exec(cancelStmt, model.BookingStatusCancelled, reason, time.Now().UTC(), bookingID)
 	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordBookingEvent(tx, model.WebhookEventBookingCancelled, bookingID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Give the units back
+	if err := s.releaseVacancies(tx, availabilityID, units); err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	prod, err := GetProductFromDB(db, ava.ProductId)
+	return tx.Commit()
+}
+
+// ExtendBooking moves the expiry of a RESERVED booking to expiresAt.
+func (s *PostgresStore) ExtendBooking(bookingID string, expiresAt time.Time) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	// Generate tickets for each unit. This could be more complex in a real scenario.
-	for i := 0; i < units; i++ {
-		ticketID := fmt.Sprintf("TICKET-%d-%s", i, bookingID)
-		insertTicketStmt := "INSERT INTO booking_units (id, booking_id, price, currency) VALUES ($1, $2, $3, $4)"
-		_, err := tx.Exec(insertTicketStmt, ticketID, bookingID, prod.Price, prod.Currency)
-		if err != nil {
+	var status string
+	err = tx.QueryRow("UPDATE bookings SET expires_at = CASE WHEN status = 'RESERVED' THEN $1 ELSE expires_at END WHERE id = $2 RETURNING status", expiresAt, bookingID).Scan(&status)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status == model.BookingStatusReserved {
+		if err := recordBookingEvent(tx, model.WebhookEventBookingUpdated, bookingID); err != nil {
 			tx.Rollback()
 			return err
 		}
+		return tx.Commit()
 	}
 
-	return tx.Commit()
+	tx.Rollback()
+	switch status {
+	case model.BookingStatusExpired:
+		return ErrBookingExpired
+	case model.BookingStatusCancelled:
+		return ErrBookingCancelled
+	}
+	return ErrBookingNotReserved
 }
 
-// GetAllBookings get all lists of booking information
-func GetAllBookings(db *sql.DB) ([]model.BookingPayload_Rs, error) {
-	var query string
-	var rows *sql.Rows
-	var err error
-	fmt.Println("------------------ Insides")
+// ExpireBookings moves every RESERVED booking whose hold ended at or before now
+// to EXPIRED and gives its units back. It returns how many bookings expired.
+func (s *PostgresStore) ExpireBookings(now time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// Skip rows another transaction is confirming or cancelling; the next sweep picks them up
+	rows, err := tx.Query("SELECT id, availability_id, units FROM bookings WHERE status = 'RESERVED' AND expires_at <= $1 FOR UPDATE SKIP LOCKED", now)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	type hold struct {
+		bookingID      string
+		availabilityID string
+		units          int
+	}
+	var holds []hold
+	for rows.Next() {
+		var h hold
+		if err := rows.Scan(&h.bookingID, &h.availabilityID, &h.units); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return 0, err
+		}
+		holds = append(holds, h)
+	}
+	rows.Close()
+
+	for _, h := range holds {
+		_, err = tx.Exec("UPDATE bookings SET status = $1 WHERE id = $2", model.BookingStatusExpired, h.bookingID)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if err := recordBookingEvent(tx, model.WebhookEventBookingUpdated, h.bookingID); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if err := s.releaseVacancies(tx, h.availabilityID, h.units); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(holds), nil
+}
 
-	query = "SELECT id, status, availability_id, price, currency FROM bookings"
-	rows, err = db.Query(query)
+// GetAllBookings get all lists of booking information. A resellerID limits
+// them to that reseller's bookings; empty returns every booking.
+func (s *PostgresStore) GetAllBookings(resellerID string) ([]model.BookingPayload_Rs, error) {
+	query, args := bookingSelect, []interface{}{}
+	if resellerID != "" {
+		query, args = bookingSelect+" WHERE reseller_id = $1", append(args, resellerID)
+	}
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		fmt.Println(err.Error())
 		return nil, err
@@ -131,72 +695,238 @@ func GetAllBookings(db *sql.DB) ([]model.BookingPayload_Rs, error) {
 
 	var bookings []model.BookingPayload_Rs
 	for rows.Next() {
-		var curBooking model.BookingPayload_Rs
-		if err := rows.Scan(
-			&curBooking.ID,
-			&curBooking.Status,
-			&curBooking.AvailabilityId,
-			&curBooking.Price,
-			&curBooking.Currency,
-		); err != nil {
-			fmt.Println(err.Error())
-			return nil, err
-		}
-
-		// Retrieve booking units
-		unitsQuery := "SELECT id, booking_id, price, currency FROM booking_units WHERE booking_id = $1"
-		unitsRows, err := db.Query(unitsQuery, curBooking.ID)
+		curBooking, err := scanBooking(rows)
 		if err != nil {
 			fmt.Println(err.Error())
-			continue
+			return nil, err
 		}
-		defer unitsRows.Close()
+		bookings = append(bookings, curBooking)
+	}
+	rows.Close()
 
-		curBooking.Units = []model.BookingUnitPayload_Rs{}
-		for unitsRows.Next() {
-			var unit model.BookingUnitPayload_Rs
-			if err := unitsRows.Scan(&unit.ID, &unit.BookingId, &unit.Price, &unit.Currency); err != nil {
-				fmt.Println(err.Error())
-				return nil, err
-			}
-			curBooking.Units = append(curBooking.Units, unit)
+	// Retrieve booking units
+	for i := range bookings {
+		if bookings[i].Units, err = getBookingUnits(s.db, bookings[i].ID); err != nil {
+			return nil, err
 		}
-		bookings = append(bookings, curBooking)
+		setVoucherRedeemed(&bookings[i])
 	}
 
 	return bookings, nil
 }
 
 // GetBookingByID retrieves a booking and its units by ID.
-func GetBookingByID(db *sql.DB, bookingID string) (*model.BookingPayload_Rs, error) {
-	booking := &model.BookingPayload_Rs{}
+func (s *PostgresStore) GetBookingByID(bookingID string) (*model.BookingPayload_Rs, error) {
+	return getBooking(s.db, "id = $1", bookingID)
+}
+
+// GetBookingByUUID retrieves a booking and its units by the reseller's UUID.
+func (s *PostgresStore) GetBookingByUUID(uuid string) (*model.BookingPayload_Rs, error) {
+	return getBooking(s.db, "uuid = $1", uuid)
+}
+
+// GetBookingByReference retrieves the booking whose voucher, or one of whose
+// tickets, has the given reference.
+func (s *PostgresStore) GetBookingByReference(reference string) (*model.BookingPayload_Rs, error) {
+	return getBooking(s.db, "voucher_reference = $1 OR id = (SELECT booking_id FROM booking_units WHERE ticket = $1)", reference)
+}
 
+// querier runs queries on a *sql.DB or inside a *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getBooking retrieves the booking matching condition, which takes value as
+// $1, with its units using q.
+func getBooking(q querier, condition, value string) (*model.BookingPayload_Rs, error) {
 	// Retrieve the booking
-	bookingQuery := "SELECT id, status, availability_id, price, currency FROM bookings WHERE id = $1"
-	err := db.QueryRow(bookingQuery, bookingID).Scan(&booking.ID, &booking.Status, &booking.AvailabilityId, &booking.Price, &booking.Currency)
+	booking, err := scanBooking(q.QueryRow(bookingSelect+" WHERE "+condition, value))
 	if err != nil {
 		fmt.Println(err.Error())
 		return nil, err
 	}
 
 	// Retrieve booking units
-	unitsQuery := "SELECT id, booking_id, price, currency FROM booking_units WHERE booking_id = $1"
-	rows, err := db.Query(unitsQuery, bookingID)
+	if booking.Units, err = getBookingUnits(q, booking.ID); err != nil {
+		return nil, err
+	}
+	setVoucherRedeemed(&booking)
+
+	return &booking, nil
+}
+
+// bookingSelect selects the columns scanBooking reads.
+const bookingSelect = "SELECT id, uuid, status, availability_id, COALESCE(option_id, ''), price, currency, expires_at, cancellation_reason, cancelled_at, notes, contact_full_name, contact_email_address, contact_phone_number, contact_locales, contact_country, contact_notes, voucher_reference, COALESCE(reseller_id, ''), COALESCE(request_hash, '') FROM bookings"
+
+// scanBooking reads a booking, without its units, selected by bookingSelect.
+func scanBooking(row scanner) (model.BookingPayload_Rs, error) {
+	var booking model.BookingPayload_Rs
+	var expiresAt, cancelledAt sql.NullTime
+	var reason sql.NullString
+	var voucherReference *string
+	err := row.Scan(
+		&booking.ID,
+		&booking.UUID,
+		&booking.Status,
+		&booking.AvailabilityId,
+		&booking.OptionId,
+		&booking.Price,
+		&booking.Currency,
+		&expiresAt,
+		&reason,
+		&cancelledAt,
+		&booking.Notes,
+		&booking.Contact.FullName,
+		&booking.Contact.EmailAddress,
+		&booking.Contact.PhoneNumber,
+		pq.Array(&booking.Contact.Locales),
+		&booking.Contact.Country,
+		&booking.Contact.Notes,
+		&voucherReference,
+		&booking.ResellerId,
+		&booking.RequestHash,
+	)
+	if err != nil {
+		return booking, err
+	}
+	booking.UtcExpiresAt = utcTime(expiresAt)
+	booking.Cancellation = cancellation(reason, cancelledAt)
+	booking.Contact.Locales = localesOrEmpty(booking.Contact.Locales)
+	booking.Voucher = issuedVoucher(voucherReference)
+	return booking, nil
+}
+
+// issuedVoucher returns the voucher with the given reference, or nil when none
+// has been issued. Delivery options depend on the product and are left empty.
+func issuedVoucher(reference *string) *model.Voucher {
+	if reference == nil {
+		return nil
+	}
+	return &model.Voucher{
+		Reference:        *reference,
+		RedemptionMethod: model.RedemptionMethodDigital,
+		DeliveryOptions:  []model.DeliveryOption{},
+	}
+}
+
+// setVoucherRedeemed marks a booking's voucher redeemed at the time its last
+// ticket was, once every ticket has been.
+func setVoucherRedeemed(booking *model.BookingPayload_Rs) {
+	if booking.Voucher == nil || len(booking.Units) == 0 {
+		return
+	}
+	var last *time.Time
+	for _, unit := range booking.Units {
+		if unit.Ticket == nil || unit.Ticket.UtcRedeemedAt == nil {
+			return
+		}
+		if last == nil || unit.Ticket.UtcRedeemedAt.After(*last) {
+			last = unit.Ticket.UtcRedeemedAt
+		}
+	}
+	redeemedAt := *last
+	booking.Voucher.UtcRedeemedAt = &redeemedAt
+}
+
+// bookingUUID returns the UUID a booking is stored under, its ID when the
+// reseller did not send one.
+func bookingUUID(booking model.Booking) string {
+	if booking.UUID == "" {
+		return booking.ID
+	}
+	return booking.UUID
+}
+
+// getBookingUnits loads the units of a booking with q.
+func getBookingUnits(q querier, bookingID string) ([]model.BookingUnitPayload_Rs, error) {
+	unitsQuery := "SELECT id, booking_id, COALESCE(unit_id, ''), ticket, original, price, net, currency, currency_precision, included_taxes, contact, redeemed_at, redeemed_by FROM booking_units WHERE booking_id = $1 ORDER BY id"
+	rows, err := q.Query(unitsQuery, bookingID)
 	if err != nil {
 		fmt.Println(err.Error())
 		return nil, err
 	}
 	defer rows.Close()
 
-	booking.Units = []model.BookingUnitPayload_Rs{}
+	units := []model.BookingUnitPayload_Rs{}
 	for rows.Next() {
 		var unit model.BookingUnitPayload_Rs
-		if err := rows.Scan(&unit.ID, &unit.BookingId, &unit.Price, &unit.Currency); err != nil {
+		var ticket, redeemedBy *string
+		var redeemedAt sql.NullTime
+		var taxes, contact []byte
+		if err := rows.Scan(
+			&unit.ID,
+			&unit.BookingId,
+			&unit.UnitId,
+			&ticket,
+			&unit.Pricing.Original,
+			&unit.Pricing.Retail,
+			&unit.Pricing.Net,
+			&unit.Pricing.Currency,
+			&unit.Pricing.CurrencyPrecision,
+			&taxes,
+			&contact,
+			&redeemedAt,
+			&redeemedBy,
+		); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		if err := json.Unmarshal(taxes, &unit.Pricing.IncludedTaxes); err != nil {
 			fmt.Println(err.Error())
 			return nil, err
 		}
-		booking.Units = append(booking.Units, unit)
+		if contact != nil {
+			if err := json.Unmarshal(contact, &unit.Contact); err != nil {
+				fmt.Println(err.Error())
+				return nil, err
+			}
+		}
+		unit.Ticket = issuedVoucher(ticket)
+		if unit.Ticket != nil {
+			unit.Ticket.UtcRedeemedAt = utcTime(redeemedAt)
+			unit.Ticket.RedeemedBy = redeemedBy
+		}
+		units = append(units, unit)
 	}
+	return units, nil
+}
 
-	return booking, nil
+// cancellation builds the OCTO cancellation object of a cancelled booking, or
+// nil when the booking has not been cancelled.
+func cancellation(reason sql.NullString, cancelledAt sql.NullTime) *model.Cancellation {
+	if !cancelledAt.Valid {
+		return nil
+	}
+	return &model.Cancellation{
+		Refund:         "FULL",
+		Reason:         reason.String,
+		UtcCancelledAt: cancelledAt.Time.UTC(),
+	}
+}
+
+// utcTime returns a nullable timestamp as a UTC time, or nil.
+func utcTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// taxesOrEmpty keeps included_taxes a JSON array rather than null.
+func taxesOrEmpty(taxes []model.Tax) []model.Tax {
+	if taxes == nil {
+		return []model.Tax{}
+	}
+	return taxes
+}
+
+// localesOrEmpty returns locales, or an empty list so contacts always encode
+// their locales as an array.
+func localesOrEmpty(locales []string) []string {
+	if locales == nil {
+		return []string{}
+	}
+	return locales
 }
@@ -0,0 +1,108 @@
+//go:build integration
+
+package store
+
+import (
+	"errors"
+	"octo-api/model"
+	"octo-api/money"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestCreateBookingConcurrent fires parallel bookings at one slot of a real
+// Postgres and checks it is never oversold. Run it against the docker-compose
+// database with `make test-integration`.
+func TestCreateBookingConcurrent(t *testing.T) {
+	cfg, err := DBConfigFromEnv()
+	if err != nil {
+		t.Fatalf("error was not expected while reading config: %s", err)
+	}
+	cfg.MaxOpenConns = 50
+	db, err := OpenDB(cfg)
+	if err != nil {
+		t.Fatalf("error was not expected while connecting: %s", err)
+	}
+	defer db.Close()
+
+	const capacity, attempts = 50, 300
+	s := NewPostgresStore(db)
+	suffix := uuid.NewString()
+	supplierID, productID, optionID := "supplier_"+suffix, "product_"+suffix, "option_"+suffix
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM bookings WHERE option_id = $1", optionID)
+		db.Exec("DELETE FROM availabilities WHERE product_id = $1", productID)
+		db.Exec("DELETE FROM options WHERE id = $1", optionID)
+		db.Exec("DELETE FROM products WHERE id = $1", productID)
+		db.Exec("DELETE FROM suppliers WHERE id = $1", supplierID)
+	})
+
+	if err := s.InsertSupplier(model.Supplier{ID: supplierID, Name: "Concurrency Tours", Timezone: "UTC"}); err != nil {
+		t.Fatalf("error was not expected while inserting supplier: %s", err)
+	}
+	err = s.InsertProduct(model.Product{
+		ID:               productID,
+		SupplierId:       supplierID,
+		Name:             "Busy Slot",
+		Capacity:         capacity,
+		Currency:         "USD",
+		AvailabilityType: model.AvailabilityTypeOpeningHours,
+		Options:          []model.Option{{ID: optionID, InternalName: "Default", Default: true}},
+	})
+	if err != nil {
+		t.Fatalf("error was not expected while inserting product: %s", err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.AddAvailability(productID, day, day, Timeslots{}, money.Money{Amount: 1000, Currency: "USD"}); err != nil {
+		t.Fatalf("error was not expected while adding availability: %s", err)
+	}
+	availabilities, err := s.GetProductAvailabilities(productID, day, day)
+	if err != nil || len(availabilities) != 1 {
+		t.Fatalf("expected one availability, got %d: %v", len(availabilities), err)
+	}
+	availabilityID := availabilities[0].ID
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	booked, rejected := 0, 0
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.CreateBooking(model.Booking{
+				ID:             uuid.NewString(),
+				Status:         model.BookingStatusReserved,
+				AvailabilityId: availabilityID,
+				OptionId:       optionID,
+				Units:          1,
+				Currency:       "USD",
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				booked++
+			case errors.Is(err, ErrInsufficientVacancies):
+				rejected++
+			default:
+				t.Errorf("unexpected error while booking: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if booked != capacity || rejected != attempts-capacity {
+		t.Errorf("expected %d bookings and %d rejections, got %d and %d", capacity, attempts-capacity, booked, rejected)
+	}
+	availability, err := s.GetAvailabilityByID(availabilityID)
+	if err != nil {
+		t.Fatalf("error was not expected while fetching availability: %s", err)
+	}
+	if availability.Vacancies != 0 || availability.Status != model.AvailabilityStatusSoldOut {
+		t.Errorf("expected the slot to be sold out, got %d vacancies and status %s", availability.Vacancies, availability.Status)
+	}
+}
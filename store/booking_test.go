@@ -1,49 +1,242 @@
 package store
 
 import (
+	"database/sql/driver"
+	"errors"
+	"octo-api/model"
+	"octo-api/voucher"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 )
 
-// func TestCreateBooking(t *testing.T) {
-// 	db, mock := NewMock()
-// 	defer db.Close()
+var bookingColumns = []string{"id", "uuid", "status", "availability_id", "option_id", "price", "currency", "expires_at", "cancellation_reason", "cancelled_at", "notes",
+	"contact_full_name", "contact_email_address", "contact_phone_number", "contact_locales", "contact_country", "contact_notes", "voucher_reference", "reseller_id", "request_hash"}
 
-// 	booking := model.Booking{
-// 		ID:             "booking_id",
-// 		Status:         "NEW",
-// 		AvailabilityId: "availability_id",
-// 		Units:          2,
-// 		Price:          200.0,
-// 		Currency:       "USD",
-// 	}
+var bookingUnitColumns = []string{"id", "booking_id", "unit_id", "ticket", "original", "price", "net", "currency", "currency_precision", "included_taxes", "contact", "redeemed_at", "redeemed_by"}
 
-// 	mock.ExpectBegin()
+// referenceArg matches a newly issued voucher or ticket reference.
+type referenceArg struct{}
 
-// 	mock.ExpectQuery("SELECT vacancies FROM availabilities WHERE id = \\$1").
-// 		WithArgs(booking.AvailabilityId).
-// 		WillReturnRows(sqlmock.NewRows([]string{"vacancies"}).AddRow(10))
+func (referenceArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && len(s) == voucher.ReferenceLength
+}
 
-// 	mock.ExpectExec("INSERT INTO bookings \\(id, status, availability_id, units, price, currency\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)").
-// 		WithArgs(booking.ID, booking.Status, booking.AvailabilityId, booking.Units, booking.Price, booking.Currency).
-// 		WillReturnResult(sqlmock.NewResult(1, 1))
+// expectEvent expects the booking or availability resourceID to be read back
+// and an event about it written to the outbox.
+func expectEvent(mock sqlmock.Sqlmock, event, resourceID string) {
+	if event == model.WebhookEventAvailabilityUpdated {
+		day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("SELECT (.+) FROM availabilities a (.+) WHERE a.id = \\$1").
+			WithArgs(resourceID).
+			WillReturnRows(sqlmock.NewRows(availabilityColumns).
+				AddRow(resourceID, day, day.Add(9*time.Hour), day.Add(11*time.Hour), "AVAILABLE", "product_id", 10, 5, true, 10000, "USD", "UTC"))
+	} else {
+		mock.ExpectQuery("SELECT (.+) FROM bookings WHERE id = \\$1").
+			WithArgs(resourceID).
+			WillReturnRows(sqlmock.NewRows(bookingColumns).
+				AddRow(resourceID, resourceID, "RESERVED", "availability_id", "option_id", 10000, "USD", nil, nil, nil, nil,
+					"", "", "", "{}", "", "", nil, "", ""))
+		mock.ExpectQuery("SELECT (.+) FROM booking_units WHERE booking_id = \\$1").
+			WithArgs(resourceID).
+			WillReturnRows(sqlmock.NewRows(bookingUnitColumns))
+	}
+	mock.ExpectExec("INSERT INTO outbox_events \\(event, resource_id, data\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		WithArgs(event, resourceID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
 
-// 	mock.ExpectExec("UPDATE availabilities SET vacancies = vacancies - \\$1 WHERE id = \\$2").
-// 		WithArgs(booking.Units, booking.AvailabilityId).
-// 		WillReturnResult(sqlmock.NewResult(1, 1))
+// expectAvailabilityUpdate expects an availability to be locked with the given
+// status and vacancies and then updated to the wanted ones.
+func expectAvailabilityUpdate(mock sqlmock.Sqlmock, availabilityID, status string, vacancies, wantVacancies int, wantStatus string, wantAvailable bool) {
+	mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+		WithArgs(availabilityID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow(status, vacancies))
+	mock.ExpectExec("UPDATE availabilities SET vacancies = \\$1, status = \\$2, available = \\$3 WHERE id = \\$4").
+		WithArgs(wantVacancies, wantStatus, wantAvailable, availabilityID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.WebhookEventAvailabilityUpdated, availabilityID)
+}
 
-// 	mock.ExpectCommit()
+func TestCreateBooking(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		vacancies     int
+		wantVacancies int
+		wantStatus    string
+		wantAvailable bool
+	}{
+		{"vacancies left", "AVAILABLE", 10, 8, "AVAILABLE", true},
+		{"down to the limited threshold", "AVAILABLE", 5, 3, "LIMITED", true},
+		{"last vacancies", "LIMITED", 2, 0, "SOLD_OUT", false},
+		{"freesale is not tracked", "FREESALE", 0, 0, "FREESALE", true},
+	}
 
-// 	err := CreateBooking(db, booking)
-// 	if err != nil {
-// 		t.Fatalf("error was not expected while creating booking: %s", err)
-// 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			defer db.Close()
 
-// 	if err := mock.ExpectationsWereMet(); err != nil {
-// 		t.Fatalf("there were unmet expectations: %s", err)
-// 	}
-// }
+			booking := model.Booking{
+				ID:             "booking_id",
+				Status:         "RESERVED",
+				AvailabilityId: "availability_id",
+				OptionId:       "option_id",
+				Units:          2,
+				Price:          20000,
+				Currency:       "USD",
+				ResellerId:     "reseller_a",
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+				WithArgs(booking.AvailabilityId).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow(tt.status, tt.vacancies))
+			mock.ExpectExec("INSERT INTO bookings \\(id, uuid, status, availability_id, option_id, units, price, currency, expires_at, notes, request_hash, reseller_id\\)").
+				WithArgs(booking.ID, booking.ID, booking.Status, booking.AvailabilityId, booking.OptionId, booking.Units, booking.Price, booking.Currency, nil, nil, "", "reseller_a").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectEvent(mock, model.WebhookEventBookingCreated, booking.ID)
+			mock.ExpectExec("UPDATE availabilities SET vacancies = \\$1, status = \\$2, available = \\$3 WHERE id = \\$4").
+				WithArgs(tt.wantVacancies, tt.wantStatus, tt.wantAvailable, booking.AvailabilityId).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectEvent(mock, model.WebhookEventAvailabilityUpdated, booking.AvailabilityId)
+			mock.ExpectCommit()
+
+			s := NewPostgresStore(db, WithStatusPolicy(StatusPolicy{LimitedThreshold: 3}))
+			if err := s.CreateBooking(booking); err != nil {
+				t.Fatalf("error was not expected while creating booking: %s", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unmet expectations: %s", err)
+			}
+		})
+	}
+}
+
+// TestCreateBookingSoldOut books the last vacancies through both stores,
+// which take the new status from StatusPolicy.Book alike.
+func TestCreateBookingSoldOut(t *testing.T) {
+	bookLast := func(t *testing.T, repo Repository, availabilityID string) {
+		t.Helper()
+		booking := model.Booking{ID: "booking_id", Status: "RESERVED", AvailabilityId: availabilityID, OptionId: "option_id", Units: 2, Price: 20000, Currency: "USD"}
+		if err := repo.CreateBooking(booking); err != nil {
+			t.Fatalf("error was not expected while creating booking: %s", err)
+		}
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+			WithArgs("availability_id").
+			WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow("AVAILABLE", 2))
+		mock.ExpectExec("INSERT INTO bookings").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectEvent(mock, model.WebhookEventBookingCreated, "booking_id")
+		mock.ExpectExec("UPDATE availabilities SET vacancies = \\$1, status = \\$2, available = \\$3 WHERE id = \\$4").
+			WithArgs(0, model.AvailabilityStatusSoldOut, false, "availability_id").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectEvent(mock, model.WebhookEventAvailabilityUpdated, "availability_id")
+		mock.ExpectCommit()
+
+		bookLast(t, NewPostgresStore(db), "availability_id")
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %s", err)
+		}
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		s, availability := newSeededMemoryStore(t, 2)
+
+		bookLast(t, s, availability.ID)
+		a, _ := s.GetAvailabilityByID(availability.ID)
+		if a.Vacancies != 0 || a.Status != model.AvailabilityStatusSoldOut || a.Available {
+			t.Errorf("expected availability to be SOLD_OUT, got %+v", a)
+		}
+	})
+}
+
+func TestCreateBookingRejected(t *testing.T) {
+	tests := []struct {
+		status    string
+		vacancies int
+		want      error
+	}{
+		{"LIMITED", 1, ErrInsufficientVacancies},
+		{"CLOSED", 10, ErrAvailabilityClosed},
+	}
+
+	for _, tt := range tests {
+		db, mock := NewMock()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+			WithArgs("availability_id").
+			WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow(tt.status, tt.vacancies))
+		mock.ExpectRollback()
+
+		err := NewPostgresStore(db).CreateBooking(model.Booking{ID: "booking_id", AvailabilityId: "availability_id", Units: 2})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.status, tt.want, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unmet expectations: %s", tt.status, err)
+		}
+		db.Close()
+	}
+}
+
+func TestCreateBookingVacanciesCheck(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+		WithArgs("availability_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow("AVAILABLE", 10))
+	mock.ExpectExec("INSERT INTO bookings").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, model.WebhookEventBookingCreated, "booking_id")
+	mock.ExpectExec("UPDATE availabilities SET vacancies").
+		WillReturnError(&pq.Error{Code: "23514", Constraint: "availabilities_vacancies_check"})
+	mock.ExpectRollback()
+
+	err := NewPostgresStore(db).CreateBooking(model.Booking{ID: "booking_id", AvailabilityId: "availability_id", Units: 2})
+	if !errors.Is(err, ErrInsufficientVacancies) {
+		t.Errorf("expected %v, got %v", ErrInsufficientVacancies, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestCreateBookingDuplicateUUID(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+		WithArgs("availability_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow("AVAILABLE", 10))
+	mock.ExpectExec("INSERT INTO bookings").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "bookings_uuid_key"})
+	mock.ExpectRollback()
+
+	err := NewPostgresStore(db).CreateBooking(model.Booking{ID: "booking_id", UUID: "reseller_uuid", AvailabilityId: "availability_id", Units: 2})
+	if !errors.Is(err, ErrDuplicateBookingUUID) {
+		t.Errorf("expected %v, got %v", ErrDuplicateBookingUUID, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
 
 // func TestGetAllBookings(t *testing.T) {
 // 	db, mock := NewMock()
@@ -58,7 +251,7 @@ import (
 // 		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "price", "currency"}).
 // 			AddRow("unit_id", "booking_id", 100.0, "USD"))
 
-// 	_, err := GetAllBookings(db)
+// 	_, err := NewPostgresStore(db).GetAllBookings()
 // 	if err != nil {
 // 		t.Fatalf("error was not expected while fetching all bookings: %s", err)
 // 	}
@@ -73,20 +266,487 @@ func TestGetBookingByID(t *testing.T) {
 	defer db.Close()
 
 	bookingID := "booking_id"
-	mock.ExpectQuery("SELECT id, status, availability_id, price, currency FROM bookings WHERE id = \\$1").
+	mock.ExpectQuery("SELECT id, uuid, status, availability_id, (.+), price, currency, expires_at, cancellation_reason, cancelled_at, (.+) FROM bookings WHERE id = \\$1").
+		WithArgs(bookingID).
+		WillReturnRows(sqlmock.NewRows(bookingColumns).
+			AddRow(bookingID, bookingID, "CONFIRMED", "availability_id", "option_id", 10000, "USD", nil, nil, nil, nil,
+				"Ada Lovelace", "ada@example.com", "+44 20 7946 0000", "{en-GB,fr}", "GB", "", "7K3QX9MZ2D", "reseller_a", ""))
+
+	redeemedAt := time.Date(2024, 3, 1, 9, 5, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, booking_id, (.+), ticket, original, price, net, currency, currency_precision, included_taxes, contact, redeemed_at, redeemed_by FROM booking_units WHERE booking_id = \\$1").
+		WithArgs(bookingID).
+		WillReturnRows(sqlmock.NewRows(bookingUnitColumns).
+			AddRow("booking_unit_id", bookingID, "unit_id", "A1B2C3D4E5", 10000, 10000, 8000, "USD", 2, []byte(`[{"name":"VAT","retail":20,"net":16}]`), []byte(`{"fullName":"Byron Lovelace"}`), redeemedAt, "gate-1"))
+
+	booking, err := NewPostgresStore(db).GetBookingByID(bookingID)
+	if err != nil {
+		t.Fatalf("error was not expected while fetching booking by ID: %s", err)
+	}
+
+	if booking.Cancellation != nil {
+		t.Errorf("expected no cancellation, got %+v", booking.Cancellation)
+	}
+	if booking.ResellerId != "reseller_a" {
+		t.Errorf("expected the booking of reseller_a, got %q", booking.ResellerId)
+	}
+	if c := booking.Contact; c.FullName != "Ada Lovelace" || c.Country != "GB" || len(c.Locales) != 2 || c.Locales[1] != "fr" {
+		t.Errorf("unexpected contact %+v", c)
+	}
+	if booking.Voucher == nil || booking.Voucher.Reference != "7K3QX9MZ2D" || booking.Voucher.RedemptionMethod != model.RedemptionMethodDigital {
+		t.Errorf("unexpected voucher %+v", booking.Voucher)
+	}
+	if ticket := booking.Units[0].Ticket; ticket == nil || ticket.Reference != "A1B2C3D4E5" || ticket.UtcRedeemedAt == nil || !ticket.UtcRedeemedAt.Equal(redeemedAt) || *ticket.RedeemedBy != "gate-1" {
+		t.Errorf("unexpected ticket %+v", ticket)
+	}
+	// Its only ticket is redeemed, so the booking's voucher is too
+	if booking.Voucher.UtcRedeemedAt == nil || !booking.Voucher.UtcRedeemedAt.Equal(redeemedAt) {
+		t.Errorf("expected the voucher to be redeemed, got %+v", booking.Voucher)
+	}
+	if c := booking.Units[0].Contact; c == nil || c.FullName != "Byron Lovelace" {
+		t.Errorf("unexpected unit contact %+v", c)
+	}
+	pricing := booking.Units[0].Pricing
+	if pricing.Retail != 10000 || pricing.Net != 8000 || pricing.CurrencyPrecision != 2 || len(pricing.IncludedTaxes) != 1 || pricing.IncludedTaxes[0].Name != "VAT" {
+		t.Errorf("unexpected unit pricing %+v", pricing)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestConfirmBooking(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	bookingID := "booking_id"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM bookings WHERE id = \\$1 FOR UPDATE").
+		WithArgs(bookingID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("RESERVED"))
+	mock.ExpectQuery("UPDATE bookings SET status = 'CONFIRMED', expires_at = NULL WHERE id = \\$1 RETURNING id").
 		WithArgs(bookingID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "availability_id", "price", "currency"}).
-			AddRow(bookingID, "CONFIRMED", "availability_id", 100.0, "USD"))
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bookingID))
+	mock.ExpectExec("UPDATE bookings SET contact_full_name = \\$1, contact_email_address = \\$2, contact_phone_number = \\$3, contact_locales = \\$4, contact_country = \\$5, contact_notes = \\$6 WHERE id = \\$7").
+		WithArgs("Ada Lovelace", "ada@example.com", "", sqlmock.AnyArg(), "GB", "", bookingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE booking_units SET contact = \\$1 WHERE id = \\$2 AND booking_id = \\$3").
+		WithArgs(`{"fullName":"Byron Lovelace","emailAddress":"","phoneNumber":"","locales":[],"country":"","notes":""}`, "unit_b", bookingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE bookings SET voucher_reference = \\$1 WHERE id = \\$2 AND voucher_reference IS NULL").
+		WithArgs(referenceArg{}, bookingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM booking_units WHERE booking_id = \\$1 AND ticket IS NULL").
+		WithArgs(bookingID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("unit_a").AddRow("unit_b"))
+	mock.ExpectExec("UPDATE booking_units SET ticket = \\$1 WHERE id = \\$2").
+		WithArgs(referenceArg{}, "unit_a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE booking_units SET ticket = \\$1 WHERE id = \\$2").
+		WithArgs(referenceArg{}, "unit_b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.WebhookEventBookingConfirmed, bookingID)
+	mock.ExpectCommit()
+
+	contact := &model.Contact{FullName: "Ada Lovelace", EmailAddress: "ada@example.com", Country: "GB"}
+	unitContacts := map[string]model.Contact{"unit_b": {FullName: "Byron Lovelace"}}
+	if err := NewPostgresStore(db).ConfirmBooking(bookingID, contact, unitContacts); err != nil {
+		t.Fatalf("error was not expected while confirming booking: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestConfirmCancelledBooking(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM bookings WHERE id = \\$1 FOR UPDATE").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("CANCELLED"))
+	mock.ExpectRollback()
+
+	if err := NewPostgresStore(db).ConfirmBooking("booking_id", nil, nil); !errors.Is(err, ErrBookingCancelled) {
+		t.Errorf("expected ErrBookingCancelled, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestRedeemTickets(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		status   string
+		affected int64
+		want     error
+	}{
+		{"redeemed", "CONFIRMED", 2, nil},
+		{"scanned twice", "CONFIRMED", 1, ErrTicketRedeemed},
+		{"not confirmed", "RESERVED", 0, ErrBookingNotConfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			defer db.Close()
 
-	mock.ExpectQuery("SELECT id, booking_id, price, currency FROM booking_units WHERE booking_id = \\$1").
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT status FROM bookings WHERE id = \\$1 FOR UPDATE").
+				WithArgs("booking_id").
+				WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(tt.status))
+			if tt.status == "CONFIRMED" {
+				mock.ExpectExec("UPDATE booking_units SET redeemed_at = \\$1, redeemed_by = \\$2 WHERE booking_id = \\$3 AND id = ANY\\(\\$4\\) AND redeemed_at IS NULL").
+					WithArgs(at, "gate-1", "booking_id", `{"unit_a","unit_b"}`).
+					WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			}
+			if tt.want == nil {
+				expectEvent(mock, model.WebhookEventBookingUpdated, "booking_id")
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			err := NewPostgresStore(db).RedeemTickets("booking_id", []string{"unit_a", "unit_b"}, "gate-1", at)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unmet expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestCancelBooking(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	bookingID := "booking_id"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, availability_id, units FROM bookings WHERE id = \\$1 FOR UPDATE").
 		WithArgs(bookingID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "price", "currency"}).
-			AddRow("unit_id", bookingID, 100.0, "USD"))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "availability_id", "units"}).AddRow("CONFIRMED", "availability_id", 3))
+	mock.ExpectExec("UPDATE bookings SET status = \\$1, cancellation_reason = \\$2, cancelled_at = \\$3 WHERE id = \\$4").
+		WithArgs("CANCELLED", "Customer request", sqlmock.AnyArg(), bookingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.WebhookEventBookingCancelled, bookingID)
+	expectAvailabilityUpdate(mock, "availability_id", "SOLD_OUT", 0, 3, "AVAILABLE", true)
+	mock.ExpectCommit()
+
+	if err := NewPostgresStore(db).CancelBooking(bookingID, "Customer request"); err != nil {
+		t.Fatalf("error was not expected while cancelling booking: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestCancelBookingTwice(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, availability_id, units FROM bookings WHERE id = \\$1 FOR UPDATE").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "availability_id", "units"}).AddRow("CANCELLED", "availability_id", 3))
+	mock.ExpectRollback()
+
+	if err := NewPostgresStore(db).CancelBooking("booking_id", ""); err != nil {
+		t.Fatalf("error was not expected while cancelling a cancelled booking: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestUpdateBooking(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	notes := "Moved to the afternoon"
+	booking := model.Booking{
+		ID:             "booking_id",
+		AvailabilityId: "availability_b",
+		OptionId:       "option_id",
+		Units:          3,
+		UnitItems:      []model.BookingUnit{{ID: "unit_a", UnitId: "adult_id"}, {ID: "unit_b", UnitId: "adult_id"}, {ID: "unit_c", UnitId: "child_id"}},
+		Price:          12500,
+		Currency:       "USD",
+		Notes:          &notes,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, availability_id, COALESCE\\(option_id, ''\\), units FROM bookings WHERE id = \\$1 FOR UPDATE").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "availability_id", "option_id", "units"}).AddRow("CONFIRMED", "availability_a", "option_id", 2))
+	mock.ExpectQuery("SELECT id FROM booking_units WHERE booking_id = \\$1 AND redeemed_at IS NOT NULL ORDER BY id").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	// Both availabilities are locked in ID order
+	mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+		WithArgs("availability_a").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow("SOLD_OUT", 0))
+	mock.ExpectQuery("SELECT status, vacancies FROM availabilities WHERE id = \\$1 FOR UPDATE").
+		WithArgs("availability_b").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "vacancies"}).AddRow("AVAILABLE", 10))
+	mock.ExpectExec("UPDATE availabilities SET vacancies = \\$1, status = \\$2, available = \\$3 WHERE id = \\$4").
+		WithArgs(2, "AVAILABLE", true, "availability_a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.WebhookEventAvailabilityUpdated, "availability_a")
+	mock.ExpectExec("UPDATE availabilities SET vacancies = \\$1, status = \\$2, available = \\$3 WHERE id = \\$4").
+		WithArgs(7, "AVAILABLE", true, "availability_b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.WebhookEventAvailabilityUpdated, "availability_b")
+	mock.ExpectExec("UPDATE bookings SET availability_id = \\$1, option_id = \\$2, units = \\$3, price = \\$4, currency = \\$5, notes = \\$6 WHERE id = \\$7").
+		WithArgs("availability_b", "option_id", 3, int64(12500), "USD", &notes, "booking_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE bookings SET contact_full_name = \\$1, (.+) WHERE id = \\$7").
+		WithArgs("", "", "", sqlmock.AnyArg(), "", "", "booking_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// unit_a is kept with its ticket, a dropped unit is deleted and the
+	// others are added
+	mock.ExpectExec("DELETE FROM booking_units WHERE booking_id = \\$1 AND NOT \\(id = ANY\\(\\$2\\)\\)").
+		WithArgs("booking_id", pq.Array([]string{"unit_a", "unit_b", "unit_c"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for i, unit := range booking.UnitItems {
+		kept := int64(0)
+		if i == 0 {
+			kept = 1
+		}
+		mock.ExpectExec("UPDATE booking_units SET unit_id = \\$1, (.+) WHERE id = \\$9 AND booking_id = \\$10").
+			WithArgs(unit.UnitId, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, unit.ID, "booking_id").
+			WillReturnResult(sqlmock.NewResult(0, kept))
+		if i == 0 {
+			continue
+		}
+		mock.ExpectExec("INSERT INTO booking_units").
+			WithArgs(unit.ID, "booking_id", unit.UnitId, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	// Confirmed bookings keep their references and get tickets for new units
+	mock.ExpectExec("UPDATE bookings SET voucher_reference = \\$1 WHERE id = \\$2 AND voucher_reference IS NULL").
+		WithArgs(referenceArg{}, "booking_id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id FROM booking_units WHERE booking_id = \\$1 AND ticket IS NULL ORDER BY id").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("unit_b").AddRow("unit_c"))
+	for _, id := range []string{"unit_b", "unit_c"} {
+		mock.ExpectExec("UPDATE booking_units SET ticket = \\$1 WHERE id = \\$2").
+			WithArgs(referenceArg{}, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectEvent(mock, model.WebhookEventBookingUpdated, "booking_id")
+	mock.ExpectCommit()
+
+	if err := NewPostgresStore(db).UpdateBooking(booking, time.Now()); err != nil {
+		t.Fatalf("error was not expected while updating booking: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestUpdateBookingKeepsVacancies(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	notes := "Vegetarian lunch"
+	booking := model.Booking{
+		ID:             "booking_id",
+		AvailabilityId: "availability_a",
+		OptionId:       "option_id",
+		Units:          1,
+		UnitItems:      []model.BookingUnit{{ID: "unit_a", UnitId: "adult_id"}},
+		Price:          6000,
+		Currency:       "USD",
+		Notes:          &notes,
+	}
+
+	// Same slot and head count: the availability is neither locked nor updated
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, availability_id, COALESCE\\(option_id, ''\\), units FROM bookings WHERE id = \\$1 FOR UPDATE").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "availability_id", "option_id", "units"}).AddRow("RESERVED", "availability_a", "option_id", 1))
+	mock.ExpectQuery("SELECT id FROM booking_units WHERE booking_id = \\$1 AND redeemed_at IS NOT NULL ORDER BY id").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE bookings SET availability_id = \\$1, option_id = \\$2, units = \\$3, price = \\$4, currency = \\$5, notes = \\$6 WHERE id = \\$7").
+		WithArgs("availability_a", "option_id", 1, int64(6000), "USD", &notes, "booking_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE bookings SET contact_full_name = \\$1, (.+) WHERE id = \\$7").
+		WithArgs("", "", "", sqlmock.AnyArg(), "", "", "booking_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM booking_units WHERE booking_id = \\$1 AND NOT \\(id = ANY\\(\\$2\\)\\)").
+		WithArgs("booking_id", pq.Array([]string{"unit_a"})).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE booking_units SET unit_id = \\$1, (.+) WHERE id = \\$9 AND booking_id = \\$10").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.WebhookEventBookingUpdated, "booking_id")
+	mock.ExpectCommit()
+
+	if err := NewPostgresStore(db).UpdateBooking(booking, time.Now()); err != nil {
+		t.Fatalf("error was not expected while updating booking: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestUpdateBookingAfterCutoff(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	// The booking was confirmed after the caller read it; the locked status
+	// decides
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, availability_id, (.+) FROM bookings WHERE id = \\$1 FOR UPDATE").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "availability_id", "option_id", "units"}).AddRow("CONFIRMED", "availability_a", "option_id", 1))
+	mock.ExpectRollback()
+
+	deadline := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	booking := model.Booking{ID: "booking_id", AvailabilityId: "availability_a", OptionId: "option_id", Units: 1, AmendableUntil: &deadline}
+	if err := NewPostgresStore(db).UpdateBooking(booking, deadline.Add(time.Minute)); !errors.Is(err, ErrAmendmentCutoff) {
+		t.Errorf("expected ErrAmendmentCutoff, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestUpdateBookingKeepsRedeemedUnits(t *testing.T) {
+	tests := []struct {
+		name    string
+		booking model.Booking
+		want    error
+	}{
+		{"moved", model.Booking{AvailabilityId: "availability_b", OptionId: "option_id", UnitItems: []model.BookingUnit{{ID: "unit_a"}}}, ErrTicketRedeemed},
+		{"other option", model.Booking{AvailabilityId: "availability_a", OptionId: "other_option", UnitItems: []model.BookingUnit{{ID: "unit_a"}}}, ErrTicketRedeemed},
+		{"redeemed unit dropped", model.Booking{AvailabilityId: "availability_a", OptionId: "option_id", UnitItems: []model.BookingUnit{{ID: "unit_b"}}}, ErrTicketRedeemed},
+	}
 
-	_, err := GetBookingByID(db, bookingID)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT status, availability_id, (.+) FROM bookings WHERE id = \\$1 FOR UPDATE").
+				WithArgs("booking_id").
+				WillReturnRows(sqlmock.NewRows([]string{"status", "availability_id", "option_id", "units"}).AddRow("CONFIRMED", "availability_a", "option_id", 2))
+			mock.ExpectQuery("SELECT id FROM booking_units WHERE booking_id = \\$1 AND redeemed_at IS NOT NULL").
+				WithArgs("booking_id").
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("unit_a"))
+			mock.ExpectRollback()
+
+			tt.booking.ID, tt.booking.Units = "booking_id", len(tt.booking.UnitItems)
+			if err := NewPostgresStore(db).UpdateBooking(tt.booking, time.Now()); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestGetCancelledBookingByID(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	cancelledAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT (.+) FROM bookings WHERE id = \\$1").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows(bookingColumns).
+			AddRow("booking_id", "booking_id", "CANCELLED", "availability_id", "option_id", 10000, "USD", nil, "Weather", cancelledAt, "Window seat",
+				"", "", "", "{}", "", "", nil, "", ""))
+	mock.ExpectQuery("SELECT (.+) FROM booking_units WHERE booking_id = \\$1").
+		WithArgs("booking_id").
+		WillReturnRows(sqlmock.NewRows(bookingUnitColumns))
+
+	booking, err := NewPostgresStore(db).GetBookingByID("booking_id")
 	if err != nil {
 		t.Fatalf("error was not expected while fetching booking by ID: %s", err)
 	}
+	if booking.Cancellation == nil || booking.Cancellation.Reason != "Weather" || !booking.Cancellation.UtcCancelledAt.Equal(cancelledAt) {
+		t.Errorf("unexpected cancellation %+v", booking.Cancellation)
+	}
+	if booking.Notes == nil || *booking.Notes != "Window seat" {
+		t.Errorf("unexpected notes %v", booking.Notes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestExpireBookings(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, availability_id, units FROM bookings WHERE status = 'RESERVED' AND expires_at <= \\$1 FOR UPDATE SKIP LOCKED").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "availability_id", "units"}).
+			AddRow("booking_1", "availability_id", 2).
+			AddRow("booking_2", "availability_id", 1))
+	for _, b := range []struct {
+		id    string
+		units int
+	}{{"booking_1", 2}, {"booking_2", 1}} {
+		mock.ExpectExec("UPDATE bookings SET status = \\$1 WHERE id = \\$2").
+			WithArgs("EXPIRED", b.id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectEvent(mock, model.WebhookEventBookingUpdated, b.id)
+		expectAvailabilityUpdate(mock, "availability_id", "AVAILABLE", 5, 5+b.units, "AVAILABLE", true)
+	}
+	mock.ExpectCommit()
+
+	expired, err := NewPostgresStore(db).ExpireBookings(now)
+	if err != nil {
+		t.Fatalf("error was not expected while expiring bookings: %s", err)
+	}
+	if expired != 2 {
+		t.Errorf("expected 2 expired bookings, got %d", expired)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestExtendBooking(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE bookings SET expires_at = (.+) WHERE id = \\$2 RETURNING status").
+		WithArgs(expiresAt, "booking_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("RESERVED"))
+	expectEvent(mock, model.WebhookEventBookingUpdated, "booking_id")
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE bookings SET expires_at = (.+) WHERE id = \\$2 RETURNING status").
+		WithArgs(expiresAt, "confirmed_id").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("CONFIRMED"))
+	mock.ExpectRollback()
+
+	s := NewPostgresStore(db)
+	if err := s.ExtendBooking("booking_id", expiresAt); err != nil {
+		t.Fatalf("error was not expected while extending booking: %s", err)
+	}
+	if err := s.ExtendBooking("confirmed_id", expiresAt); !errors.Is(err, ErrBookingNotReserved) {
+		t.Errorf("expected ErrBookingNotReserved, got %v", err)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("there were unmet expectations: %s", err)
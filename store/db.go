@@ -1,40 +1,89 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"octo-api/helper"
 	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
-func ConnectToDB() *sql.DB {
-	var (
-		// host     = "localhost"
-		host = os.Getenv("DB_HOST")
-		port = 5432
-		// user     = "postgres"
-		user = os.Getenv("DB_USER")
-		// password = "postgres"
-		password = os.Getenv("DB_PASSWORD")
-		// dbname   = "ventrata_octo"
-		dbname = os.Getenv("DB_NAME")
-	)
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
-	fmt.Println(psqlInfo)
+// DBConfig describes how to reach Postgres and how to size the connection pool.
+type DBConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DBConfigFromEnv reads the database configuration from the environment,
+// falling back to sensible pool defaults for anything that is not set.
+func DBConfigFromEnv() (DBConfig, error) {
+	cfg := DBConfig{
+		Host:     os.Getenv("DB_HOST"),
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		Name:     os.Getenv("DB_NAME"),
+		SSLMode:  envString("DB_SSLMODE", "disable"),
+	}
+
+	var err error
+	if cfg.Port, err = helper.EnvInt("DB_PORT", 5432); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxOpenConns, err = helper.EnvInt("DB_MAX_OPEN_CONNS", 25); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIdleConns, err = helper.EnvInt("DB_MAX_IDLE_CONNS", 25); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxLifetime, err = helper.EnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxIdleTime, err = helper.EnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// OpenDB opens the shared connection pool and verifies that Postgres is
+// reachable. The returned pool is meant to live for the whole process.
+func OpenDB(cfg DBConfig) (*sql.DB, error) {
+	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode)
 
 	db, err := sql.Open("postgres", psqlInfo)
 	if err != nil {
-		// log.Fatal(err)
-		fmt.Println(err.Error())
+		return nil, err
 	}
-	err = db.Ping()
-	if err != nil {
-		// log.Fatal(err)
-		fmt.Println(err.Error())
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping %s:%d/%s: %w", cfg.Host, cfg.Port, cfg.Name, err)
+	}
+	return db, nil
+}
+
+func envString(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
-	fmt.Println("Successfully connected!")
-	return db
+	return fallback
 }
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDBConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_NAME", "ventrata_octo")
+	t.Setenv("DB_MAX_OPEN_CONNS", "10")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "30m")
+
+	cfg, err := DBConfigFromEnv()
+	if err != nil {
+		t.Fatalf("error was not expected while reading config: %s", err)
+	}
+
+	if cfg.Host != "db" || cfg.Port != 5432 || cfg.SSLMode != "disable" {
+		t.Errorf("unexpected connection settings %+v", cfg)
+	}
+	if cfg.MaxOpenConns != 10 || cfg.MaxIdleConns != 25 {
+		t.Errorf("unexpected pool sizes %+v", cfg)
+	}
+	if cfg.ConnMaxLifetime != 30*time.Minute || cfg.ConnMaxIdleTime != 5*time.Minute {
+		t.Errorf("unexpected pool lifetimes %+v", cfg)
+	}
+}
+
+func TestDBConfigFromEnvInvalid(t *testing.T) {
+	t.Setenv("DB_MAX_IDLE_CONNS", "many")
+
+	if _, err := DBConfigFromEnv(); err == nil {
+		t.Errorf("expected an error for an invalid DB_MAX_IDLE_CONNS")
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"octo-api/helper"
+	"time"
+)
+
+// DefaultReservationExpiry is how long a reservation holds its vacancies when
+// neither the request nor the configuration says otherwise.
+const DefaultReservationExpiry = 30 * time.Minute
+
+// ExpiryConfig controls how long reservations hold vacancies and how often
+// stale ones are released.
+type ExpiryConfig struct {
+	ReservationExpiry time.Duration
+	SweepInterval     time.Duration
+}
+
+// ExpiryConfigFromEnv reads RESERVATION_EXPIRY and RESERVATION_SWEEP_INTERVAL.
+func ExpiryConfigFromEnv() (ExpiryConfig, error) {
+	var cfg ExpiryConfig
+	var err error
+
+	if cfg.ReservationExpiry, err = helper.EnvDuration("RESERVATION_EXPIRY", DefaultReservationExpiry); err != nil {
+		return cfg, err
+	}
+	if cfg.SweepInterval, err = helper.EnvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.ReservationExpiry <= 0 || cfg.SweepInterval <= 0 {
+		return cfg, fmt.Errorf("RESERVATION_EXPIRY and RESERVATION_SWEEP_INTERVAL must be positive")
+	}
+	return cfg, nil
+}
+
+// RunExpirySweeper expires stale reservations every interval until ctx is
+// done, so abandoned RESERVED bookings give their vacancies back. It blocks;
+// start it in its own goroutine.
+func RunExpirySweeper(ctx context.Context, repo BookingRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := repo.ExpireBookings(time.Now().UTC())
+			if err != nil {
+				log.Printf("failed to expire reservations: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("expired %d reservations", expired)
+			}
+		}
+	}
+}
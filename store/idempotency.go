@@ -0,0 +1,69 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"octo-api/model"
+	"time"
+)
+
+// ClaimIdempotencyKey records that a request with the given key and body hash
+// is being handled from at. It returns nil when the key is new, or was claimed
+// before staleBefore by a request that never finished, and the caller should
+// handle the request; otherwise it returns what is recorded under the key.
+func (s *PostgresStore) ClaimIdempotencyKey(key, requestHash string, at, staleBefore time.Time) (*model.IdempotentResponse, error) {
+	claimStmt := "INSERT INTO idempotency_keys (key, request_hash, claimed_at) VALUES ($1, $2, $3) " +
+		"ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, claimed_at = EXCLUDED.claimed_at " +
+		"WHERE idempotency_keys.status IS NULL AND idempotency_keys.claimed_at < $4"
+	res, err := s.db.Exec(claimStmt, key, requestHash, at, staleBefore)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	prior := &model.IdempotentResponse{}
+	var status sql.NullInt64
+	var header []byte
+	err = s.db.QueryRow("SELECT key, request_hash, claimed_at, status, headers, body FROM idempotency_keys WHERE key = $1", key).
+		Scan(&prior.Key, &prior.RequestHash, &prior.ClaimedAt, &status, &header, &prior.Body)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	prior.ClaimedAt = prior.ClaimedAt.UTC()
+	prior.Status = int(status.Int64)
+	if header != nil {
+		if err := json.Unmarshal(header, &prior.Header); err != nil {
+			return nil, err
+		}
+	}
+	return prior, nil
+}
+
+// SaveIdempotentResponse stores the response a claimed key is replayed with.
+func (s *PostgresStore) SaveIdempotentResponse(key string, status int, header map[string][]string, body []byte) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE idempotency_keys SET status = $2, headers = $3, body = $4 WHERE key = $1", key, status, string(encoded), body)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a claimed key so the request can be retried.
+func (s *PostgresStore) ReleaseIdempotencyKey(key string) error {
+	_, err := s.db.Exec("DELETE FROM idempotency_keys WHERE key = $1", key)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	staleBefore := now.Add(-5 * time.Minute)
+	claimStmt := "INSERT INTO idempotency_keys \\(key, request_hash, claimed_at\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT \\(key\\) DO UPDATE (.+) WHERE idempotency_keys.status IS NULL AND idempotency_keys.claimed_at < \\$4"
+	mock.ExpectExec(claimStmt).
+		WithArgs("POST /products/new key", "hash", now, staleBefore).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(claimStmt).
+		WithArgs("POST /products/new key", "hash", now, staleBefore).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT key, request_hash, claimed_at, status, headers, body FROM idempotency_keys WHERE key = \\$1").
+		WithArgs("POST /products/new key").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "request_hash", "claimed_at", "status", "headers", "body"}).
+			AddRow("POST /products/new key", "hash", now, 201, []byte(`{"Octo-Capabilities":["octo/pricing"]}`), []byte(`{"id":"product_id"}`)))
+
+	s := NewPostgresStore(db)
+	prior, err := s.ClaimIdempotencyKey("POST /products/new key", "hash", now, staleBefore)
+	if err != nil || prior != nil {
+		t.Fatalf("expected a new key to be claimed, got %+v, %v", prior, err)
+	}
+	prior, err = s.ClaimIdempotencyKey("POST /products/new key", "hash", now, staleBefore)
+	if err != nil {
+		t.Fatalf("error was not expected while claiming a used key: %s", err)
+	}
+	if prior == nil || prior.Status != 201 || string(prior.Body) != `{"id":"product_id"}` || prior.Header["Octo-Capabilities"][0] != "octo/pricing" {
+		t.Errorf("unexpected recorded response %+v", prior)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestMemoryStoreClaimIdempotencyKey(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	if prior, _ := s.ClaimIdempotencyKey("key", "hash", now, now.Add(-time.Minute)); prior != nil {
+		t.Fatalf("expected a new key to be claimed, got %+v", prior)
+	}
+	if prior, _ := s.ClaimIdempotencyKey("key", "hash", now.Add(time.Minute), now); prior == nil || prior.Status != 0 {
+		t.Fatalf("expected the claim to still be in progress, got %+v", prior)
+	}
+
+	// A claim that never finished is taken over once it is stale
+	later := now.Add(time.Hour)
+	if prior, _ := s.ClaimIdempotencyKey("key", "hash", later, later.Add(-time.Minute)); prior != nil {
+		t.Fatalf("expected the stale claim to be taken over, got %+v", prior)
+	}
+
+	// A recorded response is replayed however old it is
+	if err := s.SaveIdempotentResponse("key", 201, map[string][]string{"Content-Type": {"application/json"}}, []byte(`{}`)); err != nil {
+		t.Fatalf("error was not expected while saving response: %s", err)
+	}
+	prior, _ := s.ClaimIdempotencyKey("key", "hash", later.Add(24*time.Hour), later.Add(23*time.Hour))
+	if prior == nil || prior.Status != 201 || prior.Header["Content-Type"][0] != "application/json" {
+		t.Errorf("expected the recorded response, got %+v", prior)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"octo-api/model"
+	"sync"
+)
+
+// MemoryStore implements Repository in process memory. It mirrors the
+// behaviour of PostgresStore and is meant for tests and local development.
+type MemoryStore struct {
+	mu     sync.Mutex
+	policy StatusPolicy
+
+	suppliers      []model.Supplier
+	products       []model.Product
+	availabilities []model.Availability
+	scheduleRules  []model.ScheduleRule
+	bookings       []model.Booking
+	bookingUnits   []model.BookingUnit
+
+	// availabilityRules maps generated availabilities to their schedule rule.
+	availabilityRules map[string]string
+	// idempotencyKeys holds the responses recorded per Idempotency-Key.
+	idempotencyKeys map[string]model.IdempotentResponse
+	// apiKeys holds the API keys by hash.
+	apiKeys map[string]model.APIKey
+
+	// events is the outbox; an event's ID is its position plus one.
+	events []model.OutboxEvent
+	// dispatchedEvents marks the events that have been fanned out.
+	dispatchedEvents     map[int64]bool
+	webhookSubscriptions []model.WebhookSubscription
+	webhookDeliveries    []model.WebhookDelivery
+	// lastDeliveryID is the ID of the latest webhook delivery.
+	lastDeliveryID int64
+}
+
+// NewMemoryStore returns an empty in-memory Repository.
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	o := newOptions(opts)
+	return &MemoryStore{
+		policy:            o.policy,
+		availabilityRules: map[string]string{},
+		idempotencyKeys:   map[string]model.IdempotentResponse{},
+		apiKeys:           map[string]model.APIKey{},
+		dispatchedEvents:  map[int64]bool{},
+	}
+}
+
+var _ Repository = (*MemoryStore)(nil)
+
+// supplier returns a pointer to the stored supplier with the given ID. The
+// caller must hold s.mu.
+func (s *MemoryStore) supplier(supplierID string) *model.Supplier {
+	for i := range s.suppliers {
+		if s.suppliers[i].ID == supplierID {
+			return &s.suppliers[i]
+		}
+	}
+	return nil
+}
+
+// product returns a pointer to the stored product with the given ID. The
+// caller must hold s.mu.
+func (s *MemoryStore) product(productID string) *model.Product {
+	for i := range s.products {
+		if s.products[i].ID == productID {
+			return &s.products[i]
+		}
+	}
+	return nil
+}
+
+// availability returns a pointer to the stored availability with the given
+// ID. The caller must hold s.mu.
+func (s *MemoryStore) availability(availabilityID string) *model.Availability {
+	for i := range s.availabilities {
+		if s.availabilities[i].ID == availabilityID {
+			return &s.availabilities[i]
+		}
+	}
+	return nil
+}
+
+// booking returns a pointer to the stored booking with the given ID. The
+// caller must hold s.mu.
+func (s *MemoryStore) booking(bookingID string) *model.Booking {
+	for i := range s.bookings {
+		if s.bookings[i].ID == bookingID {
+			return &s.bookings[i]
+		}
+	}
+	return nil
+}
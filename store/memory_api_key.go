@@ -0,0 +1,31 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+)
+
+// GetAPIKey returns the API key with the given SHA-256 hash.
+func (s *MemoryStore) GetAPIKey(keyHash string) (*model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[keyHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &key, nil
+}
+
+// InsertAPIKey stores a new API key.
+func (s *MemoryStore) InsertAPIKey(key model.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apiKeys[key.KeyHash]; ok {
+		return fmt.Errorf("API key %s already exists", key.Name)
+	}
+	s.apiKeys[key.KeyHash] = key
+	return nil
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"database/sql"
+	"octo-api/model"
+	"octo-api/money"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetAvailabilities returns the availabilities whose local date falls between
+// startDate and endDate, inclusive, ordered by start time.
+func (s *MemoryStore) GetAvailabilities(startDate, endDate time.Time) ([]model.AvailabilityShow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	startDate, endDate = truncateDate(startDate), truncateDate(endDate)
+
+	var availabilities []model.AvailabilityShow
+	for _, a := range s.availabilities {
+		localDate := truncateDate(a.LocalDate)
+		if localDate.Before(startDate) || localDate.After(endDate) {
+			continue
+		}
+		p := s.product(a.ProductId)
+		if p == nil {
+			continue
+		}
+		availabilities = append(availabilities, model.AvailabilityShow{
+			ID:                 a.ID,
+			LocalDate:          a.LocalDate,
+			LocalDateTimeStart: a.LocalDateTimeStart,
+			LocalDateTimeEnd:   a.LocalDateTimeEnd,
+			Status:             a.Status,
+			ProductName:        p.Name,
+			Vacancies:          a.Vacancies,
+			Available:          a.Available,
+			Price:              a.Price,
+			Currency:           a.Currency,
+		})
+	}
+	sort.SliceStable(availabilities, func(i, j int) bool {
+		return availabilities[i].LocalDateTimeStart.Before(availabilities[j].LocalDateTimeStart)
+	})
+	return availabilities, nil
+}
+
+// GetAvailabilityByID returns the availability with the given ID.
+func (s *MemoryStore) GetAvailabilityByID(availabilityID string) (*model.Availability, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.availability(availabilityID)
+	if a == nil {
+		return nil, sql.ErrNoRows
+	}
+	availability := *a
+	return &availability, nil
+}
+
+// GetProductAvailabilities returns the availabilities of a product whose local
+// date falls between startDate and endDate, inclusive, ordered by start time.
+func (s *MemoryStore) GetProductAvailabilities(productID string, startDate, endDate time.Time) ([]model.Availability, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	startDate, endDate = truncateDate(startDate), truncateDate(endDate)
+
+	availabilities := []model.Availability{}
+	for _, a := range s.availabilities {
+		if a.ProductId != productID || a.LocalDate.Before(startDate) || a.LocalDate.After(endDate) {
+			continue
+		}
+		availabilities = append(availabilities, a)
+	}
+	sort.SliceStable(availabilities, func(i, j int) bool {
+		return availabilities[i].LocalDateTimeStart.Before(availabilities[j].LocalDateTimeStart)
+	})
+	return availabilities, nil
+}
+
+// AddAvailability creates the timeslots of every day between startDate and
+// endDate. Nothing is added if any day is invalid.
+func (s *MemoryStore) AddAvailability(productID string, startDate, endDate time.Time, timeslots Timeslots, price money.Money) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.product(productID)
+	if p == nil {
+		return sql.ErrNoRows
+	}
+	loc := time.UTC
+	if sp := s.supplier(p.SupplierId); sp != nil {
+		loc = loadLocation(sp.Timezone)
+	}
+
+	// New availabilities start with the product's full capacity
+	status := s.policy.Status(model.AvailabilityStatusAvailable, p.Capacity)
+
+	var added []model.Availability
+	for indDate := startDate; !indDate.After(endDate); indDate = indDate.AddDate(0, 0, 1) {
+		slots, err := timeslots.slots(indDate, loc, p.AvailabilityType)
+		if err != nil {
+			return err
+		}
+		for _, sl := range slots {
+			added = append(added, model.Availability{
+				ID:                 uuid.NewString(),
+				LocalDate:          truncateDate(indDate),
+				LocalDateTimeStart: sl.start,
+				LocalDateTimeEnd:   sl.end,
+				Status:             status,
+				ProductId:          productID,
+				Capacity:           p.Capacity,
+				Vacancies:          p.Capacity,
+				Available:          IsAvailable(status),
+				Price:              price.Amount,
+				Currency:           price.Currency,
+			})
+		}
+	}
+	s.availabilities = append(s.availabilities, added...)
+	return nil
+}
+
+// UpdateAvailability applies a manual edit to an availability. See
+// StatusPolicy.Edit.
+func (s *MemoryStore) UpdateAvailability(availabilityID, status string, vacancies *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.availability(availabilityID)
+	if a == nil {
+		return sql.ErrNoRows
+	}
+	newVacancies := a.Vacancies
+	if vacancies != nil {
+		newVacancies = *vacancies
+	}
+
+	status, err := s.policy.Edit(a.Status, status, newVacancies)
+	if err != nil {
+		return err
+	}
+	a.Vacancies, a.Status, a.Available = newVacancies, status, IsAvailable(status)
+	s.recordEvent(model.WebhookEventAvailabilityUpdated, availabilityID)
+	return nil
+}
+
+// truncateDate drops the time of day, matching the DATE column in Postgres.
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,470 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+	"octo-api/voucher"
+	"sort"
+	"time"
+)
+
+// CreateBooking stores a new booking and takes its units off the availability.
+func (s *MemoryStore) CreateBooking(booking model.Booking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.availability(booking.AvailabilityId)
+	if a == nil {
+		return sql.ErrNoRows
+	}
+	if s.booking(booking.ID) != nil {
+		return fmt.Errorf("booking %s already exists", booking.ID)
+	}
+	booking.UUID = bookingUUID(booking)
+	if s.bookingByUUID(booking.UUID) != nil {
+		return ErrDuplicateBookingUUID
+	}
+	vacancies, status, err := s.policy.Book(a.Status, a.Vacancies, booking.Units)
+	if err != nil {
+		return err
+	}
+
+	s.insertUnits(booking.ID, booking.UnitItems)
+	booking.UnitItems = nil
+	booking.Contact = copyContact(booking.Contact)
+	if booking.UtcExpiresAt != nil {
+		expiresAt := booking.UtcExpiresAt.UTC()
+		booking.UtcExpiresAt = &expiresAt
+	}
+	s.bookings = append(s.bookings, booking)
+
+	a.Vacancies, a.Status, a.Available = vacancies, status, IsAvailable(status)
+	s.recordEvent(model.WebhookEventBookingCreated, booking.ID)
+	s.recordEvent(model.WebhookEventAvailabilityUpdated, a.ID)
+	return nil
+}
+
+// ConfirmBooking updates the booking's status to CONFIRMED and generates
+// tickets. A non-nil contact replaces the lead traveller, and unitContacts,
+// keyed by booking unit ID, set the guest holding each unit.
+func (s *MemoryStore) ConfirmBooking(bookingID string, contact *model.Contact, unitContacts map[string]model.Contact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.booking(bookingID)
+	if b == nil {
+		return sql.ErrNoRows
+	}
+	if b.Status == model.BookingStatusCancelled {
+		return ErrBookingCancelled
+	}
+	if b.Status == model.BookingStatusExpired {
+		return ErrBookingExpired
+	}
+
+	// Confirmed bookings no longer expire
+	b.Status = model.BookingStatusConfirmed
+	b.UtcExpiresAt = nil
+	if contact != nil {
+		b.Contact = copyContact(*contact)
+	}
+	for _, unit := range s.units(bookingID) {
+		if c, ok := unitContacts[unit.ID]; ok {
+			c = copyContact(c)
+			unit.Contact = &c
+		}
+	}
+	s.issueTickets(bookingID)
+	s.recordEvent(model.WebhookEventBookingConfirmed, bookingID)
+	return nil
+}
+
+// UpdateBooking amends a RESERVED or CONFIRMED booking: it moves the booking
+// to booking.AvailabilityId and sets its units, price and notes. Units it
+// already has keep their ticket and redemption. A CONFIRMED booking is refused
+// with ErrAmendmentCutoff when at is after booking.AmendableUntil.
+func (s *MemoryStore) UpdateBooking(booking model.Booking, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.booking(booking.ID)
+	if b == nil {
+		return sql.ErrNoRows
+	}
+	switch b.Status {
+	case model.BookingStatusCancelled:
+		return ErrBookingCancelled
+	case model.BookingStatusExpired:
+		return ErrBookingExpired
+	case model.BookingStatusConfirmed:
+		if booking.AmendableUntil != nil && at.After(*booking.AmendableUntil) {
+			return ErrAmendmentCutoff
+		}
+	}
+	old, next := s.availability(b.AvailabilityId), s.availability(booking.AvailabilityId)
+	if old == nil || next == nil {
+		return sql.ErrNoRows
+	}
+
+	// Guests already checked in stay on the slot and option they were
+	// admitted to
+	var redeemed []string
+	for _, u := range s.bookingUnits {
+		if u.BookingId == booking.ID && u.UtcRedeemedAt != nil {
+			redeemed = append(redeemed, u.ID)
+		}
+	}
+	if len(redeemed) > 0 && (next != old || booking.OptionId != b.OptionId || !keepsUnits(booking, redeemed)) {
+		return ErrTicketRedeemed
+	}
+
+	// Vacancies only move with the slot or the head count; give the old
+	// units back, then take the new ones
+	if next != old || booking.Units != b.Units {
+		oldVacancies, oldStatus := s.policy.Release(old.Status, old.Vacancies, b.Units)
+		nextVacancies, nextStatus := oldVacancies, oldStatus
+		if next != old {
+			nextVacancies, nextStatus = next.Vacancies, next.Status
+		}
+		nextVacancies, nextStatus, err := s.policy.Book(nextStatus, nextVacancies, booking.Units)
+		if err != nil {
+			return err
+		}
+		old.Vacancies, old.Status, old.Available = oldVacancies, oldStatus, IsAvailable(oldStatus)
+		next.Vacancies, next.Status, next.Available = nextVacancies, nextStatus, IsAvailable(nextStatus)
+		s.recordEvent(model.WebhookEventAvailabilityUpdated, old.ID)
+		if next != old {
+			s.recordEvent(model.WebhookEventAvailabilityUpdated, next.ID)
+		}
+	}
+
+	b.AvailabilityId = booking.AvailabilityId
+	b.OptionId = booking.OptionId
+	b.Units = booking.Units
+	b.Price, b.Currency = booking.Price, booking.Currency
+	b.Notes = booking.Notes
+	b.Contact = copyContact(booking.Contact)
+
+	// Re-price the units the booking keeps, drop the others and add the new
+	// ones
+	wanted := map[string]model.BookingUnit{}
+	for _, unit := range booking.UnitItems {
+		wanted[unit.ID] = unit
+	}
+	kept := s.bookingUnits[:0]
+	for _, u := range s.bookingUnits {
+		if u.BookingId == booking.ID {
+			unit, ok := wanted[u.ID]
+			if !ok {
+				continue
+			}
+			delete(wanted, u.ID)
+			u.UnitId, u.Pricing, u.Contact = unit.UnitId, unit.Pricing, nil
+			u.Pricing.IncludedTaxes = taxesOrEmpty(u.Pricing.IncludedTaxes)
+			if unit.Contact != nil {
+				c := copyContact(*unit.Contact)
+				u.Contact = &c
+			}
+		}
+		kept = append(kept, u)
+	}
+	s.bookingUnits = kept
+	for _, unit := range booking.UnitItems {
+		if _, added := wanted[unit.ID]; added {
+			s.insertUnits(booking.ID, []model.BookingUnit{unit})
+		}
+	}
+	if b.Status == model.BookingStatusConfirmed {
+		s.issueTickets(booking.ID)
+	}
+	s.recordEvent(model.WebhookEventBookingUpdated, booking.ID)
+	return nil
+}
+
+// insertUnits stores the units of a booking, keeping the ticket they carry.
+// The caller must hold s.mu.
+func (s *MemoryStore) insertUnits(bookingID string, units []model.BookingUnit) {
+	for _, unit := range units {
+		unit.BookingId = bookingID
+		if unit.Ticket != nil {
+			ticket := *unit.Ticket
+			unit.Ticket = &ticket
+		}
+		if unit.UtcRedeemedAt != nil {
+			redeemedAt := unit.UtcRedeemedAt.UTC()
+			unit.UtcRedeemedAt = &redeemedAt
+		}
+		unit.Pricing.IncludedTaxes = taxesOrEmpty(unit.Pricing.IncludedTaxes)
+		if unit.Contact != nil {
+			c := copyContact(*unit.Contact)
+			unit.Contact = &c
+		}
+		s.bookingUnits = append(s.bookingUnits, unit)
+	}
+}
+
+// copyContact returns a contact that shares no memory with c.
+func copyContact(c model.Contact) model.Contact {
+	c.Locales = append([]string{}, c.Locales...)
+	return c
+}
+
+// issueTickets gives a booking its voucher reference and each of its units a
+// ticket reference, keeping references already issued. The caller must hold
+// s.mu.
+func (s *MemoryStore) issueTickets(bookingID string) {
+	if b := s.booking(bookingID); b != nil && b.VoucherReference == nil {
+		reference := voucher.NewReference()
+		b.VoucherReference = &reference
+	}
+	for _, unit := range s.units(bookingID) {
+		if unit.Ticket == nil {
+			ticket := voucher.NewReference()
+			unit.Ticket = &ticket
+		}
+	}
+}
+
+// RedeemTickets marks units of a CONFIRMED booking as redeemed at the given
+// time by device. If any of them already is, nothing changes and it returns
+// ErrTicketRedeemed.
+func (s *MemoryStore) RedeemTickets(bookingID string, unitIDs []string, device string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.booking(bookingID)
+	if b == nil {
+		return sql.ErrNoRows
+	}
+	if b.Status != model.BookingStatusConfirmed {
+		return ErrBookingNotConfirmed
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range unitIDs {
+		wanted[id] = true
+	}
+	var units []*model.BookingUnit
+	for _, unit := range s.units(bookingID) {
+		if wanted[unit.ID] && unit.UtcRedeemedAt == nil {
+			units = append(units, unit)
+		}
+	}
+	if len(units) != len(unitIDs) {
+		return ErrTicketRedeemed
+	}
+	for _, unit := range units {
+		redeemedAt, redeemedBy := at.UTC(), device
+		unit.UtcRedeemedAt, unit.RedeemedBy = &redeemedAt, &redeemedBy
+	}
+	s.recordEvent(model.WebhookEventBookingUpdated, bookingID)
+	return nil
+}
+
+// CancelBooking moves a booking to CANCELLED and gives its units back to the
+// availability. Cancelling an already cancelled booking is a no-op.
+func (s *MemoryStore) CancelBooking(bookingID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.booking(bookingID)
+	if b == nil {
+		return sql.ErrNoRows
+	}
+	if b.Status == model.BookingStatusCancelled {
+		return nil
+	}
+	if b.Status == model.BookingStatusExpired {
+		return ErrBookingExpired
+	}
+
+	b.Status = model.BookingStatusCancelled
+	b.Cancellation = &model.Cancellation{
+		Refund:         "FULL",
+		Reason:         reason,
+		UtcCancelledAt: time.Now().UTC(),
+	}
+	s.recordEvent(model.WebhookEventBookingCancelled, bookingID)
+
+	s.releaseVacancies(b.AvailabilityId, b.Units)
+	return nil
+}
+
+// ExtendBooking moves the expiry of a RESERVED booking to expiresAt.
+func (s *MemoryStore) ExtendBooking(bookingID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.booking(bookingID)
+	if b == nil {
+		return sql.ErrNoRows
+	}
+	switch b.Status {
+	case model.BookingStatusReserved:
+		expiresAt = expiresAt.UTC()
+		b.UtcExpiresAt = &expiresAt
+		s.recordEvent(model.WebhookEventBookingUpdated, bookingID)
+		return nil
+	case model.BookingStatusExpired:
+		return ErrBookingExpired
+	case model.BookingStatusCancelled:
+		return ErrBookingCancelled
+	}
+	return ErrBookingNotReserved
+}
+
+// ExpireBookings moves every RESERVED booking whose hold ended at or before now
+// to EXPIRED and gives its units back. It returns how many bookings expired.
+func (s *MemoryStore) ExpireBookings(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := 0
+	for i := range s.bookings {
+		b := &s.bookings[i]
+		if b.Status != model.BookingStatusReserved || b.UtcExpiresAt == nil || b.UtcExpiresAt.After(now) {
+			continue
+		}
+		b.Status = model.BookingStatusExpired
+		s.recordEvent(model.WebhookEventBookingUpdated, b.ID)
+		s.releaseVacancies(b.AvailabilityId, b.Units)
+		expired++
+	}
+	return expired, nil
+}
+
+// releaseVacancies gives units back to an availability and records the
+// change in the outbox. The caller must hold s.mu.
+func (s *MemoryStore) releaseVacancies(availabilityID string, units int) {
+	if a := s.availability(availabilityID); a != nil {
+		a.Vacancies, a.Status = s.policy.Release(a.Status, a.Vacancies, units)
+		a.Available = IsAvailable(a.Status)
+		s.recordEvent(model.WebhookEventAvailabilityUpdated, availabilityID)
+	}
+}
+
+// GetAllBookings returns every booking together with its units, or only
+// those of resellerID when it is not empty.
+func (s *MemoryStore) GetAllBookings(resellerID string) ([]model.BookingPayload_Rs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bookings []model.BookingPayload_Rs
+	for _, b := range s.bookings {
+		if resellerID != "" && b.ResellerId != resellerID {
+			continue
+		}
+		bookings = append(bookings, s.bookingPayload(b))
+	}
+	return bookings, nil
+}
+
+// GetBookingByID retrieves a booking and its units by ID.
+func (s *MemoryStore) GetBookingByID(bookingID string) (*model.BookingPayload_Rs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.booking(bookingID)
+	if b == nil {
+		return nil, sql.ErrNoRows
+	}
+	booking := s.bookingPayload(*b)
+	return &booking, nil
+}
+
+// GetBookingByUUID retrieves a booking and its units by the reseller's UUID.
+func (s *MemoryStore) GetBookingByUUID(uuid string) (*model.BookingPayload_Rs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bookingByUUID(uuid)
+	if b == nil {
+		return nil, sql.ErrNoRows
+	}
+	booking := s.bookingPayload(*b)
+	return &booking, nil
+}
+
+// GetBookingByReference retrieves the booking whose voucher, or one of whose
+// tickets, has the given reference.
+func (s *MemoryStore) GetBookingByReference(reference string) (*model.BookingPayload_Rs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.bookings {
+		booking := s.bookingPayload(b)
+		if booking.Voucher != nil && booking.Voucher.Reference == reference {
+			return &booking, nil
+		}
+		for _, unit := range booking.Units {
+			if unit.Ticket != nil && unit.Ticket.Reference == reference {
+				return &booking, nil
+			}
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// bookingByUUID returns a pointer to the stored booking with the given UUID.
+// The caller must hold s.mu.
+func (s *MemoryStore) bookingByUUID(uuid string) *model.Booking {
+	for i := range s.bookings {
+		if s.bookings[i].UUID == uuid {
+			return &s.bookings[i]
+		}
+	}
+	return nil
+}
+
+// units returns pointers to the stored units of a booking ordered by ID. The
+// caller must hold s.mu.
+func (s *MemoryStore) units(bookingID string) []*model.BookingUnit {
+	var units []*model.BookingUnit
+	for i := range s.bookingUnits {
+		if s.bookingUnits[i].BookingId == bookingID {
+			units = append(units, &s.bookingUnits[i])
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+	return units
+}
+
+// bookingPayload assembles the response shape for a stored booking. The
+// caller must hold s.mu.
+func (s *MemoryStore) bookingPayload(b model.Booking) model.BookingPayload_Rs {
+	booking := model.BookingPayload_Rs{
+		ID:             b.ID,
+		UUID:           b.UUID,
+		Status:         b.Status,
+		AvailabilityId: b.AvailabilityId,
+		OptionId:       b.OptionId,
+		Units:          []model.BookingUnitPayload_Rs{},
+		Price:          b.Price,
+		Currency:       b.Currency,
+		UtcExpiresAt:   b.UtcExpiresAt,
+		Cancellation:   b.Cancellation,
+		Notes:          b.Notes,
+		Contact:        copyContact(b.Contact),
+		Voucher:        issuedVoucher(b.VoucherReference),
+		ResellerId:     b.ResellerId,
+		RequestHash:    b.RequestHash,
+	}
+	for _, u := range s.units(b.ID) {
+		unit := model.BookingUnitPayload_Rs{
+			ID:        u.ID,
+			BookingId: u.BookingId,
+			UnitId:    u.UnitId,
+			Ticket:    issuedVoucher(u.Ticket),
+			Pricing:   u.Pricing,
+			Contact:   u.Contact,
+		}
+		if unit.Ticket != nil && u.UtcRedeemedAt != nil {
+			redeemedAt, redeemedBy := *u.UtcRedeemedAt, *u.RedeemedBy
+			unit.Ticket.UtcRedeemedAt, unit.Ticket.RedeemedBy = &redeemedAt, &redeemedBy
+		}
+		booking.Units = append(booking.Units, unit)
+	}
+	setVoucherRedeemed(&booking)
+	return booking
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"octo-api/model"
+	"time"
+)
+
+// ClaimIdempotencyKey records that a request with the given key and body hash
+// is being handled from at. It returns nil when the key is new, or was claimed
+// before staleBefore by a request that never finished, or a copy of what is
+// recorded under the key.
+func (s *MemoryStore) ClaimIdempotencyKey(key, requestHash string, at, staleBefore time.Time) (*model.IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prior, ok := s.idempotencyKeys[key]; ok && (prior.Status != 0 || !prior.ClaimedAt.Before(staleBefore)) {
+		prior.Header = copyHeader(prior.Header)
+		prior.Body = append([]byte(nil), prior.Body...)
+		return &prior, nil
+	}
+	s.idempotencyKeys[key] = model.IdempotentResponse{Key: key, RequestHash: requestHash, ClaimedAt: at.UTC()}
+	return nil, nil
+}
+
+// SaveIdempotentResponse stores the response a claimed key is replayed with.
+func (s *MemoryStore) SaveIdempotentResponse(key string, status int, header map[string][]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prior, ok := s.idempotencyKeys[key]; ok {
+		prior.Status, prior.Header, prior.Body = status, copyHeader(header), append([]byte(nil), body...)
+		s.idempotencyKeys[key] = prior
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a claimed key so the request can be retried.
+func (s *MemoryStore) ReleaseIdempotencyKey(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotencyKeys, key)
+	return nil
+}
+
+// copyHeader returns a deep copy of header.
+func copyHeader(header map[string][]string) map[string][]string {
+	if header == nil {
+		return nil
+	}
+	copied := make(map[string][]string, len(header))
+	for k, v := range header {
+		copied[k] = append([]string(nil), v...)
+	}
+	return copied
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+	"sort"
+)
+
+// GetProducts returns every product in insertion order.
+func (s *MemoryStore) GetProducts() ([]model.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var products []model.Product
+	for _, p := range s.products {
+		products = append(products, cloneProduct(p))
+	}
+	return products, nil
+}
+
+// GetProduct returns the product with the given ID.
+func (s *MemoryStore) GetProduct(productID string) (*model.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.product(productID)
+	if p == nil {
+		return nil, sql.ErrNoRows
+	}
+	product := cloneProduct(*p)
+	return &product, nil
+}
+
+// InsertProduct stores a new product together with its options and units.
+func (s *MemoryStore) InsertProduct(product model.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.product(product.ID) != nil {
+		return fmt.Errorf("product %s already exists", product.ID)
+	}
+	if s.supplier(product.SupplierId) == nil {
+		return fmt.Errorf("supplier %s does not exist", product.SupplierId)
+	}
+
+	// Match the Postgres ordering: default option first, then by ID
+	product = cloneProduct(product)
+	product.DeliveryMethods = orDefault(product.DeliveryMethods, model.DeliveryMethods)
+	product.DeliveryFormats = orDefault(product.DeliveryFormats, model.DeliveryFormats)
+	sort.SliceStable(product.Options, func(i, j int) bool {
+		a, b := product.Options[i], product.Options[j]
+		if a.Default != b.Default {
+			return a.Default
+		}
+		return a.ID < b.ID
+	})
+	for i := range product.Options {
+		o := &product.Options[i]
+		o.ProductId = product.ID
+		sort.Slice(o.Units, func(i, j int) bool { return o.Units[i].ID < o.Units[j].ID })
+		for j := range o.Units {
+			o.Units[j].OptionId = o.ID
+		}
+	}
+	s.products = append(s.products, product)
+	return nil
+}
+
+// cloneProduct deep-copies a product so callers cannot mutate stored state.
+func cloneProduct(p model.Product) model.Product {
+	options := make([]model.Option, 0, len(p.Options))
+	for _, o := range p.Options {
+		units := make([]model.Unit, 0, len(o.Units))
+		for _, u := range o.Units {
+			u.Restrictions.AccompaniedBy = append([]string{}, u.Restrictions.AccompaniedBy...)
+			units = append(units, u)
+		}
+		o.Units = units
+		options = append(options, o)
+	}
+	p.Options = options
+	p.DeliveryMethods = append([]string(nil), p.DeliveryMethods...)
+	p.DeliveryFormats = append([]string(nil), p.DeliveryFormats...)
+	return p
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetScheduleRules returns the schedule rules of a product, oldest season first.
+func (s *MemoryStore) GetScheduleRules(productID string) ([]model.ScheduleRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.productScheduleRules(productID), nil
+}
+
+// GetScheduleRule returns the schedule rule with the given ID.
+func (s *MemoryStore) GetScheduleRule(ruleID string) (*model.ScheduleRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.scheduleRule(ruleID)
+	if i < 0 {
+		return nil, sql.ErrNoRows
+	}
+	rule := cloneScheduleRule(s.scheduleRules[i])
+	return &rule, nil
+}
+
+// InsertScheduleRule stores a new schedule rule after checking it against its
+// product.
+func (s *MemoryStore) InsertScheduleRule(rule model.ScheduleRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scheduleRule(rule.ID) >= 0 {
+		return fmt.Errorf("schedule rule %s already exists", rule.ID)
+	}
+	p := s.product(rule.ProductId)
+	if p == nil {
+		return sql.ErrNoRows
+	}
+	rule, err := normalizeScheduleRule(cloneScheduleRule(rule), *p)
+	if err != nil {
+		return err
+	}
+	s.scheduleRules = append(s.scheduleRules, rule)
+	return nil
+}
+
+// UpdateScheduleRule replaces a schedule rule. Its product cannot change.
+func (s *MemoryStore) UpdateScheduleRule(rule model.ScheduleRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.scheduleRule(rule.ID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	rule.ProductId = s.scheduleRules[i].ProductId
+	p := s.product(rule.ProductId)
+	if p == nil {
+		return sql.ErrNoRows
+	}
+	rule, err := normalizeScheduleRule(cloneScheduleRule(rule), *p)
+	if err != nil {
+		return err
+	}
+	s.scheduleRules[i] = rule
+	return nil
+}
+
+// DeleteScheduleRule removes a schedule rule together with the availabilities
+// it generated from the local date from on that have no bookings.
+func (s *MemoryStore) DeleteScheduleRule(ruleID string, from time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.scheduleRule(ruleID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.scheduleRules = append(s.scheduleRules[:i], s.scheduleRules[i+1:]...)
+
+	from = truncateDate(from)
+	var deletes []string
+	for id, r := range s.availabilityRules {
+		if r != ruleID {
+			continue
+		}
+		// Booked availabilities stay on as one-off availabilities
+		delete(s.availabilityRules, id)
+		if a := s.availability(id); a != nil && !a.LocalDate.Before(from) && !s.hasBookings(id) {
+			deletes = append(deletes, id)
+		}
+	}
+	s.deleteAvailabilities(deletes)
+	return nil
+}
+
+// MaterializeSchedule brings the availabilities of a product between the local
+// dates from and until in line with its schedule rules and returns how many
+// were created.
+func (s *MemoryStore) MaterializeSchedule(productID string, from, until time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.product(productID)
+	if p == nil {
+		return 0, sql.ErrNoRows
+	}
+	loc := time.UTC
+	if sp := s.supplier(p.SupplierId); sp != nil {
+		loc = loadLocation(sp.Timezone)
+	}
+	from, until = truncateDate(from), truncateDate(until)
+	wanted, err := expandSchedule(s.productScheduleRules(productID), *p, loc, from, until)
+	if err != nil {
+		return 0, err
+	}
+
+	var existing []existingSlot
+	for _, a := range s.availabilities {
+		if a.ProductId != productID || a.LocalDate.Before(from) || a.LocalDate.After(until) {
+			continue
+		}
+		existing = append(existing, existingSlot{
+			id:     a.ID,
+			start:  a.LocalDateTimeStart,
+			ruleID: s.availabilityRules[a.ID],
+			status: a.Status,
+			booked: s.hasBookings(a.ID),
+
+			capacity:  a.Capacity,
+			vacancies: a.Vacancies,
+		})
+	}
+
+	plan := planSchedule(existing, wanted)
+	s.deleteAvailabilities(plan.deletes)
+	for _, u := range plan.updates {
+		a := s.availability(u.id)
+		a.LocalDateTimeEnd = u.end
+		a.Capacity, a.Vacancies = u.capacity, u.vacancies
+		a.Status = s.policy.Status(u.status, u.vacancies)
+		a.Available = IsAvailable(a.Status)
+		a.Price, a.Currency = u.price.Amount, u.price.Currency
+		s.availabilityRules[a.ID] = u.ruleID
+	}
+	for _, w := range plan.inserts {
+		status := s.policy.Status(model.AvailabilityStatusAvailable, w.capacity)
+		a := model.Availability{
+			ID:                 uuid.NewString(),
+			LocalDate:          w.localDate,
+			LocalDateTimeStart: w.start,
+			LocalDateTimeEnd:   w.end,
+			Status:             status,
+			ProductId:          productID,
+			Capacity:           w.capacity,
+			Vacancies:          w.capacity,
+			Available:          IsAvailable(status),
+			Price:              w.price.Amount,
+			Currency:           w.price.Currency,
+		}
+		s.availabilities = append(s.availabilities, a)
+		s.availabilityRules[a.ID] = w.ruleID
+	}
+	return len(plan.inserts), nil
+}
+
+// scheduleRule returns the index of the stored rule with the given ID, or -1.
+// The caller must hold s.mu.
+func (s *MemoryStore) scheduleRule(ruleID string) int {
+	for i := range s.scheduleRules {
+		if s.scheduleRules[i].ID == ruleID {
+			return i
+		}
+	}
+	return -1
+}
+
+// productScheduleRules returns copies of a product's rules in the Postgres
+// order. The caller must hold s.mu.
+func (s *MemoryStore) productScheduleRules(productID string) []model.ScheduleRule {
+	rules := []model.ScheduleRule{}
+	for _, r := range s.scheduleRules {
+		if r.ProductId == productID {
+			rules = append(rules, cloneScheduleRule(r))
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if !rules[i].ValidFrom.Equal(rules[j].ValidFrom) {
+			return rules[i].ValidFrom.Before(rules[j].ValidFrom)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// hasBookings reports whether any booking, in any status, refers to the
+// availability. The caller must hold s.mu.
+func (s *MemoryStore) hasBookings(availabilityID string) bool {
+	for _, b := range s.bookings {
+		if b.AvailabilityId == availabilityID {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteAvailabilities removes the availabilities with the given IDs. The
+// caller must hold s.mu.
+func (s *MemoryStore) deleteAvailabilities(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+		delete(s.availabilityRules, id)
+	}
+	kept := s.availabilities[:0]
+	for _, a := range s.availabilities {
+		if !drop[a.ID] {
+			kept = append(kept, a)
+		}
+	}
+	s.availabilities = kept
+}
+
+// cloneScheduleRule deep-copies a rule so callers cannot mutate stored state.
+func cloneScheduleRule(r model.ScheduleRule) model.ScheduleRule {
+	r.Weekdays = append([]string{}, r.Weekdays...)
+	r.StartTimes = append([]string{}, r.StartTimes...)
+	r.BlackoutDates = append([]time.Time{}, r.BlackoutDates...)
+	if r.ValidUntil != nil {
+		until := *r.ValidUntil
+		r.ValidUntil = &until
+	}
+	if r.Capacity != nil {
+		capacity := *r.Capacity
+		r.Capacity = &capacity
+	}
+	if r.Price != nil {
+		price := *r.Price
+		r.Price = &price
+	}
+	return r
+}
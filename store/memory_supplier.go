@@ -0,0 +1,44 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+	"sort"
+)
+
+// GetSuppliers returns every supplier ordered by ID.
+func (s *MemoryStore) GetSuppliers() ([]model.Supplier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var suppliers []model.Supplier
+	suppliers = append(suppliers, s.suppliers...)
+	sort.Slice(suppliers, func(i, j int) bool { return suppliers[i].ID < suppliers[j].ID })
+	return suppliers, nil
+}
+
+// GetSupplier returns the supplier with the given ID.
+func (s *MemoryStore) GetSupplier(supplierID string) (*model.Supplier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp := s.supplier(supplierID)
+	if sp == nil {
+		return nil, sql.ErrNoRows
+	}
+	supplier := *sp
+	return &supplier, nil
+}
+
+// InsertSupplier stores a new supplier.
+func (s *MemoryStore) InsertSupplier(supplier model.Supplier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.supplier(supplier.ID) != nil {
+		return fmt.Errorf("supplier %s already exists", supplier.ID)
+	}
+	s.suppliers = append(s.suppliers, supplier)
+	return nil
+}
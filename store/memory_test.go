@@ -0,0 +1,530 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"octo-api/model"
+	"octo-api/money"
+	"octo-api/voucher"
+	"testing"
+	"time"
+)
+
+func newSeededMemoryStore(t *testing.T, capacity int) (*MemoryStore, model.Availability) {
+	t.Helper()
+
+	s := NewMemoryStore()
+	if err := s.InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Timezone: "UTC"}); err != nil {
+		t.Fatalf("error was not expected while inserting supplier: %s", err)
+	}
+	if err := s.InsertProduct(model.Product{ID: "product_id", SupplierId: "supplier_id", Name: "Product Name", Capacity: capacity, Price: 5000, Currency: "USD"}); err != nil {
+		t.Fatalf("error was not expected while inserting product: %s", err)
+	}
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.AddAvailability("product_id", day, day.AddDate(0, 0, 2), Timeslots{}, money.New(1000, "USD")); err != nil {
+		t.Fatalf("error was not expected while adding availability: %s", err)
+	}
+
+	availabilities, err := s.GetAvailabilities(day, day)
+	if err != nil || len(availabilities) != 1 {
+		t.Fatalf("expected 1 availability on %s, got %d (%v)", day, len(availabilities), err)
+	}
+	availability, err := s.GetAvailabilityByID(availabilities[0].ID)
+	if err != nil {
+		t.Fatalf("error was not expected while fetching availability: %s", err)
+	}
+	return s, *availability
+}
+
+func TestMemoryStoreGetAvailabilities(t *testing.T) {
+	s, _ := newSeededMemoryStore(t, 10)
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	availabilities, err := s.GetAvailabilities(start, start.AddDate(0, 0, 5))
+	if err != nil {
+		t.Fatalf("error was not expected while fetching availabilities: %s", err)
+	}
+	if len(availabilities) != 3 {
+		t.Errorf("expected 3 availabilities, got %d", len(availabilities))
+	}
+	for _, a := range availabilities {
+		if a.ProductName != "Product Name" || a.Vacancies != 10 || a.Status != "AVAILABLE" {
+			t.Errorf("unexpected availability %+v", a)
+		}
+	}
+}
+
+func TestMemoryStoreAddStartTimeAvailabilities(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Timezone: "America/New_York"}); err != nil {
+		t.Fatalf("error was not expected while inserting supplier: %s", err)
+	}
+	if err := s.InsertProduct(model.Product{ID: "product_id", SupplierId: "supplier_id", Name: "Product Name", Capacity: 8, AvailabilityType: model.AvailabilityTypeStartTime}); err != nil {
+		t.Fatalf("error was not expected while inserting product: %s", err)
+	}
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.AddAvailability("product_id", day, day, Timeslots{}, money.New(1000, "USD")); !errors.Is(err, ErrInvalidTimeslots) {
+		t.Fatalf("expected ErrInvalidTimeslots without start times, got %v", err)
+	}
+	timeslots := Timeslots{StartTimes: []string{"17:00", "09:00", "13:00"}, Duration: time.Hour}
+	if err := s.AddAvailability("product_id", day, day.AddDate(0, 0, 1), timeslots, money.New(1000, "USD")); err != nil {
+		t.Fatalf("error was not expected while adding availability: %s", err)
+	}
+
+	availabilities, err := s.GetAvailabilities(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("error was not expected while fetching availabilities: %s", err)
+	}
+	want := []string{
+		"2024-03-01T09:00:00-05:00", "2024-03-01T13:00:00-05:00", "2024-03-01T17:00:00-05:00",
+		"2024-03-02T09:00:00-05:00", "2024-03-02T13:00:00-05:00", "2024-03-02T17:00:00-05:00",
+	}
+	if len(availabilities) != len(want) {
+		t.Fatalf("expected %d slots, got %d", len(want), len(availabilities))
+	}
+	for i, a := range availabilities {
+		if got := a.LocalDateTimeStart.Format(time.RFC3339); got != want[i] || a.LocalDateTimeEnd.Sub(a.LocalDateTimeStart) != time.Hour || a.Vacancies != 8 {
+			t.Errorf("slot %d: expected a one hour slot at %s with 8 vacancies, got %s-%s with %d", i, want[i], got, a.LocalDateTimeEnd.Format(time.RFC3339), a.Vacancies)
+		}
+	}
+}
+
+func TestMemoryStoreMaterializeSchedule(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Timezone: "UTC"}); err != nil {
+		t.Fatalf("error was not expected while inserting supplier: %s", err)
+	}
+	if err := s.InsertProduct(model.Product{ID: "product_id", SupplierId: "supplier_id", Name: "Product Name", Capacity: 6, Price: 5000, Currency: "USD", AvailabilityType: model.AvailabilityTypeStartTime}); err != nil {
+		t.Fatalf("error was not expected while inserting product: %s", err)
+	}
+
+	// Mondays and Tuesdays at 09:00 and 13:00, from Monday 2024-06-03
+	from, until := date(2024, 6, 3), date(2024, 6, 9)
+	rule := model.ScheduleRule{ID: "rule_id", ProductId: "product_id", Weekdays: []string{"monday", "tuesday"}, StartTimes: []string{"09:00", "13:00"}, ValidFrom: from}
+	if err := s.InsertScheduleRule(rule); err != nil {
+		t.Fatalf("error was not expected while inserting schedule rule: %s", err)
+	}
+	created, err := s.MaterializeSchedule("product_id", from, until)
+	if err != nil || created != 4 {
+		t.Fatalf("expected 4 availabilities created, got %d (%v)", created, err)
+	}
+	availabilities, _ := s.GetAvailabilities(from, until)
+	booked, stopped := availabilities[0], availabilities[1]
+
+	// A stop-sell set by hand survives materializing again
+	vacancies := 0
+	if err := s.UpdateAvailability(stopped.ID, "", &vacancies); err != nil {
+		t.Fatalf("error was not expected while editing vacancies: %s", err)
+	}
+	if created, _ := s.MaterializeSchedule("product_id", from, until); created != 0 {
+		t.Errorf("expected materializing again to create nothing, got %d", created)
+	}
+	if a, _ := s.GetAvailabilityByID(stopped.ID); a.Vacancies != 0 || a.Status != model.AvailabilityStatusSoldOut {
+		t.Errorf("expected the edited slot to stay sold out, got %+v", a)
+	}
+
+	if err := s.CreateBooking(model.Booking{ID: "booking_id", Status: "RESERVED", AvailabilityId: booked.ID, Units: 2}); err != nil {
+		t.Fatalf("error was not expected while booking: %s", err)
+	}
+
+	// Move the tours to 10:00 on Mondays only, with a smaller group
+	capacity := 3
+	rule.Weekdays, rule.StartTimes, rule.Capacity = []string{"MONDAY"}, []string{"10:00"}, &capacity
+	if err := s.UpdateScheduleRule(rule); err != nil {
+		t.Fatalf("error was not expected while updating schedule rule: %s", err)
+	}
+	if _, err := s.MaterializeSchedule("product_id", from, until); err != nil {
+		t.Fatalf("error was not expected while materializing schedule: %s", err)
+	}
+
+	availabilities, _ = s.GetAvailabilities(from, until)
+	if len(availabilities) != 2 {
+		t.Fatalf("expected the booked slot and the new one, got %+v", availabilities)
+	}
+	if availabilities[0].ID != booked.ID || availabilities[0].Vacancies != 4 {
+		t.Errorf("expected the booked 09:00 slot to be untouched, got %+v", availabilities[0])
+	}
+	if availabilities[1].LocalDateTimeStart != time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC) || availabilities[1].Vacancies != 3 {
+		t.Errorf("expected a 10:00 slot with 3 vacancies, got %+v", availabilities[1])
+	}
+
+	if err := s.DeleteScheduleRule("rule_id", from); err != nil {
+		t.Fatalf("error was not expected while deleting schedule rule: %s", err)
+	}
+	availabilities, _ = s.GetAvailabilities(from, until)
+	if len(availabilities) != 1 || availabilities[0].ID != booked.ID {
+		t.Errorf("expected only the booked slot to remain, got %+v", availabilities)
+	}
+	if err := s.DeleteScheduleRule("rule_id", from); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+}
+
+func TestGenerateSchedules(t *testing.T) {
+	s, _ := newSeededMemoryStore(t, 5)
+	if err := s.InsertScheduleRule(model.ScheduleRule{ID: "rule_id", ProductId: "product_id", ValidFrom: date(2024, 3, 1)}); err != nil {
+		t.Fatalf("error was not expected while inserting schedule rule: %s", err)
+	}
+
+	// The seeded 1st to 3rd of March are one-off availabilities and stay as they are
+	created, err := GenerateSchedules(s, time.Date(2024, 3, 2, 15, 0, 0, 0, time.UTC), 7)
+	if err != nil {
+		t.Fatalf("error was not expected while generating schedules: %s", err)
+	}
+	if created != 6 {
+		t.Errorf("expected 6 availabilities for the 4th to the 9th, got %d", created)
+	}
+}
+
+func TestMemoryStoreCreateBookingDecrementsVacancies(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 3)
+
+	err := s.CreateBooking(model.Booking{ID: "booking_1", Status: "RESERVED", AvailabilityId: availability.ID, Units: 2, Price: 12000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+
+	a, _ := s.GetAvailabilityByID(availability.ID)
+	if a.Vacancies != 1 || a.Status != "AVAILABLE" || !a.Available {
+		t.Errorf("expected 1 vacancy left and AVAILABLE, got %+v", a)
+	}
+
+	err = s.CreateBooking(model.Booking{ID: "booking_2", Status: "RESERVED", AvailabilityId: availability.ID, Units: 2, Price: 12000, Currency: "USD"})
+	if !errors.Is(err, ErrInsufficientVacancies) {
+		t.Fatalf("expected ErrInsufficientVacancies, got %v", err)
+	}
+
+	err = s.CreateBooking(model.Booking{ID: "booking_3", Status: "RESERVED", AvailabilityId: availability.ID, Units: 1, Price: 6000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+
+	a, _ = s.GetAvailabilityByID(availability.ID)
+	if a.Vacancies != 0 || a.Status != "SOLD_OUT" || a.Available {
+		t.Errorf("expected availability to be SOLD_OUT, got %+v", a)
+	}
+}
+
+func TestMemoryStoreUpdateBookingAfterCutoff(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 10)
+
+	booking := model.Booking{ID: "booking_id", Status: "RESERVED", AvailabilityId: availability.ID, OptionId: "option_id", Units: 1, UnitItems: []model.BookingUnit{{ID: "booking_unit_1", UnitId: "adult_id"}}, Price: 6000, Currency: "USD"}
+	if err := s.CreateBooking(booking); err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+
+	// Reservations are not bound by the cutoff, confirmed bookings are
+	deadline := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	notes := "Late arrival"
+	booking.Notes, booking.AmendableUntil = &notes, &deadline
+	if err := s.UpdateBooking(booking, deadline.Add(time.Minute)); err != nil {
+		t.Fatalf("error was not expected while updating a reservation: %s", err)
+	}
+	if err := s.ConfirmBooking("booking_id", nil, nil); err != nil {
+		t.Fatalf("error was not expected while confirming booking: %s", err)
+	}
+	if err := s.UpdateBooking(booking, deadline.Add(time.Minute)); !errors.Is(err, ErrAmendmentCutoff) {
+		t.Errorf("expected ErrAmendmentCutoff, got %v", err)
+	}
+	if err := s.UpdateBooking(booking, deadline); err != nil {
+		t.Errorf("error was not expected while updating at the deadline: %s", err)
+	}
+}
+
+func TestMemoryStoreConfirmBooking(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 10)
+
+	booking := model.Booking{
+		ID:             "booking_id",
+		Status:         "RESERVED",
+		AvailabilityId: availability.ID,
+		OptionId:       "option_id",
+		Units:          2,
+		UnitItems: []model.BookingUnit{
+			{ID: "booking_unit_1", UnitId: "adult_id", Pricing: model.Pricing{Original: 6000, Retail: 6000, Net: 6000, Currency: "USD", CurrencyPrecision: 2}},
+			{ID: "booking_unit_2", UnitId: "adult_id", Pricing: model.Pricing{Original: 6000, Retail: 6000, Net: 6000, Currency: "USD", CurrencyPrecision: 2}},
+		},
+		Price:    12000,
+		Currency: "USD",
+	}
+	if err := s.CreateBooking(booking); err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+	if err := s.ConfirmBooking("booking_id", nil, nil); err != nil {
+		t.Fatalf("error was not expected while confirming booking: %s", err)
+	}
+
+	confirmed, err := s.GetBookingByID("booking_id")
+	if err != nil {
+		t.Fatalf("error was not expected while fetching booking by ID: %s", err)
+	}
+	if confirmed.Status != "CONFIRMED" || confirmed.OptionId != "option_id" || len(confirmed.Units) != 2 {
+		t.Fatalf("expected a CONFIRMED booking with 2 units, got %+v", confirmed)
+	}
+	if confirmed.Voucher == nil || len(confirmed.Voucher.Reference) != voucher.ReferenceLength {
+		t.Errorf("expected a voucher reference, got %+v", confirmed.Voucher)
+	}
+	for _, unit := range confirmed.Units {
+		if unit.UnitId != "adult_id" || unit.Ticket == nil || len(unit.Ticket.Reference) != voucher.ReferenceLength {
+			t.Errorf("unexpected booking unit %+v", unit)
+		}
+	}
+	if confirmed.Units[0].Ticket.Reference == confirmed.Units[1].Ticket.Reference {
+		t.Errorf("expected each unit to get its own ticket, got %s twice", confirmed.Units[0].Ticket.Reference)
+	}
+
+	// Confirming again is idempotent and keeps the references
+	if err := s.ConfirmBooking("booking_id", nil, nil); err != nil {
+		t.Errorf("error was not expected while confirming booking twice: %s", err)
+	}
+	reconfirmed, err := s.GetBookingByID("booking_id")
+	if err != nil || reconfirmed.Voucher.Reference != confirmed.Voucher.Reference || reconfirmed.Units[0].Ticket.Reference != confirmed.Units[0].Ticket.Reference {
+		t.Errorf("expected the references to be kept, got %+v (%v)", reconfirmed, err)
+	}
+
+	bookings, err := s.GetAllBookings("")
+	if err != nil || len(bookings) != 1 {
+		t.Errorf("expected 1 booking, got %d (%v)", len(bookings), err)
+	}
+}
+
+func TestMemoryStoreCancelBookingRestoresVacancies(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 2)
+
+	err := s.CreateBooking(model.Booking{ID: "booking_id", Status: "RESERVED", AvailabilityId: availability.ID, Units: 2, Price: 12000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+	if a, _ := s.GetAvailabilityByID(availability.ID); a.Status != "SOLD_OUT" {
+		t.Fatalf("expected availability to be SOLD_OUT, got %+v", a)
+	}
+
+	if err := s.CancelBooking("booking_id", "Customer request"); err != nil {
+		t.Fatalf("error was not expected while cancelling booking: %s", err)
+	}
+
+	a, _ := s.GetAvailabilityByID(availability.ID)
+	if a.Vacancies != 2 || a.Status != "AVAILABLE" || !a.Available {
+		t.Errorf("expected 2 vacancies and AVAILABLE after cancelling, got %+v", a)
+	}
+	cancelled, _ := s.GetBookingByID("booking_id")
+	if cancelled.Status != "CANCELLED" || cancelled.Cancellation == nil || cancelled.Cancellation.Reason != "Customer request" {
+		t.Errorf("unexpected cancelled booking %+v", cancelled)
+	}
+
+	// Cancelling again does not give the units back twice
+	if err := s.CancelBooking("booking_id", ""); err != nil {
+		t.Errorf("error was not expected while cancelling booking twice: %s", err)
+	}
+	if a, _ := s.GetAvailabilityByID(availability.ID); a.Vacancies != 2 {
+		t.Errorf("expected 2 vacancies after cancelling twice, got %d", a.Vacancies)
+	}
+
+	if err := s.ConfirmBooking("booking_id", nil, nil); !errors.Is(err, ErrBookingCancelled) {
+		t.Errorf("expected ErrBookingCancelled when confirming a cancelled booking, got %v", err)
+	}
+}
+
+func TestMemoryStoreExpireBookings(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 3)
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	stale, fresh := now.Add(-time.Minute), now.Add(time.Minute)
+	for _, b := range []model.Booking{
+		{ID: "stale", Status: "RESERVED", AvailabilityId: availability.ID, Units: 2, UtcExpiresAt: &stale},
+		{ID: "fresh", Status: "RESERVED", AvailabilityId: availability.ID, Units: 1, UtcExpiresAt: &fresh},
+	} {
+		if err := s.CreateBooking(b); err != nil {
+			t.Fatalf("error was not expected while creating booking: %s", err)
+		}
+	}
+
+	expired, err := s.ExpireBookings(now)
+	if err != nil || expired != 1 {
+		t.Fatalf("expected 1 expired booking, got %d (%v)", expired, err)
+	}
+	a, _ := s.GetAvailabilityByID(availability.ID)
+	if a.Vacancies != 2 || a.Status != "AVAILABLE" {
+		t.Errorf("expected the stale hold to be released, got %+v", a)
+	}
+	if b, _ := s.GetBookingByID("stale"); b.Status != "EXPIRED" {
+		t.Errorf("expected the stale booking to be EXPIRED, got %s", b.Status)
+	}
+
+	// Expired bookings can be neither extended, confirmed nor cancelled
+	if err := s.ExtendBooking("stale", fresh); !errors.Is(err, ErrBookingExpired) {
+		t.Errorf("expected ErrBookingExpired when extending, got %v", err)
+	}
+	if err := s.ConfirmBooking("stale", nil, nil); !errors.Is(err, ErrBookingExpired) {
+		t.Errorf("expected ErrBookingExpired when confirming, got %v", err)
+	}
+	if err := s.CancelBooking("stale", ""); !errors.Is(err, ErrBookingExpired) {
+		t.Errorf("expected ErrBookingExpired when cancelling, got %v", err)
+	}
+
+	// Extending the fresh hold keeps it past the next sweep
+	later := now.Add(time.Hour)
+	if err := s.ExtendBooking("fresh", later); err != nil {
+		t.Fatalf("error was not expected while extending booking: %s", err)
+	}
+	if expired, _ := s.ExpireBookings(now.Add(30 * time.Minute)); expired != 0 {
+		t.Errorf("expected the extended booking to survive, %d expired", expired)
+	}
+
+	// Confirmed bookings never expire
+	if err := s.ConfirmBooking("fresh", nil, nil); err != nil {
+		t.Fatalf("error was not expected while confirming booking: %s", err)
+	}
+	if expired, _ := s.ExpireBookings(later.Add(time.Hour)); expired != 0 {
+		t.Errorf("expected the confirmed booking to survive, %d expired", expired)
+	}
+	if err := s.ExtendBooking("fresh", later); !errors.Is(err, ErrBookingNotReserved) {
+		t.Errorf("expected ErrBookingNotReserved when extending a confirmed booking, got %v", err)
+	}
+}
+
+func TestRunExpirySweeper(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 3)
+
+	past := time.Now().Add(-time.Minute)
+	if err := s.CreateBooking(model.Booking{ID: "booking_id", Status: "RESERVED", AvailabilityId: availability.ID, Units: 3, UtcExpiresAt: &past}); err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunExpirySweeper(ctx, s, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if b, _ := s.GetBookingByID("booking_id"); b.Status == "EXPIRED" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the sweeper did not expire the reservation")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if a, _ := s.GetAvailabilityByID(availability.ID); a.Vacancies != 3 {
+		t.Errorf("expected 3 vacancies after the sweep, got %d", a.Vacancies)
+	}
+}
+
+func TestMemoryStoreAvailabilityStatusTransitions(t *testing.T) {
+	s := NewMemoryStore(WithStatusPolicy(StatusPolicy{LimitedThreshold: 2}))
+	if err := s.InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Timezone: "UTC"}); err != nil {
+		t.Fatalf("error was not expected while inserting supplier: %s", err)
+	}
+	if err := s.InsertProduct(model.Product{ID: "product_id", SupplierId: "supplier_id", Name: "Product Name", Capacity: 4, Currency: "USD"}); err != nil {
+		t.Fatalf("error was not expected while inserting product: %s", err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.AddAvailability("product_id", day, day, Timeslots{}, money.New(1000, "USD")); err != nil {
+		t.Fatalf("error was not expected while adding availability: %s", err)
+	}
+	availabilities, _ := s.GetAvailabilities(day, day)
+	id := availabilities[0].ID
+
+	expect := func(step string, vacancies int, status string, available bool) {
+		t.Helper()
+		a, _ := s.GetAvailabilityByID(id)
+		if a.Vacancies != vacancies || a.Status != status || a.Available != available {
+			t.Errorf("%s: expected %d %s available=%v, got %d %s available=%v", step, vacancies, status, available, a.Vacancies, a.Status, a.Available)
+		}
+	}
+	book := func(bookingID string, units int) error {
+		return s.CreateBooking(model.Booking{ID: bookingID, Status: "RESERVED", AvailabilityId: id, Units: units})
+	}
+
+	expect("new", 4, "AVAILABLE", true)
+
+	if err := book("booking_1", 2); err != nil {
+		t.Fatalf("error was not expected while booking: %s", err)
+	}
+	expect("booked down to the threshold", 2, "LIMITED", true)
+
+	if err := book("booking_2", 2); err != nil {
+		t.Fatalf("error was not expected while booking: %s", err)
+	}
+	expect("booked out", 0, "SOLD_OUT", false)
+
+	if err := s.CancelBooking("booking_1", ""); err != nil {
+		t.Fatalf("error was not expected while cancelling: %s", err)
+	}
+	expect("cancelled", 2, "LIMITED", true)
+
+	// Closed availabilities refuse bookings and stay closed when units come back
+	if err := s.UpdateAvailability(id, "CLOSED", nil); err != nil {
+		t.Fatalf("error was not expected while closing: %s", err)
+	}
+	if err := book("booking_3", 1); !errors.Is(err, ErrAvailabilityClosed) {
+		t.Errorf("expected ErrAvailabilityClosed, got %v", err)
+	}
+	if err := s.CancelBooking("booking_2", ""); err != nil {
+		t.Fatalf("error was not expected while cancelling: %s", err)
+	}
+	expect("cancelled while closed", 4, "CLOSED", false)
+
+	// Freesale availabilities take any number of units without tracking them
+	if err := s.UpdateAvailability(id, "FREESALE", nil); err != nil {
+		t.Fatalf("error was not expected while switching to freesale: %s", err)
+	}
+	if err := book("booking_4", 100); err != nil {
+		t.Fatalf("error was not expected while booking freesale: %s", err)
+	}
+	expect("booked freesale", 4, "FREESALE", true)
+
+	// Reopening follows the vacancies again
+	vacancies := 1
+	if err := s.UpdateAvailability(id, "AVAILABLE", &vacancies); err != nil {
+		t.Fatalf("error was not expected while reopening: %s", err)
+	}
+	expect("reopened", 1, "LIMITED", true)
+
+	if err := s.UpdateAvailability(id, "SOLD_OUT", nil); err == nil {
+		t.Error("expected an error when setting a derived status by hand")
+	}
+	if err := s.UpdateAvailability("missing", "CLOSED", nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing availability, got %v", err)
+	}
+}
+
+func TestMemoryStoreInsertProductRequiresSupplier(t *testing.T) {
+	s := NewMemoryStore()
+
+	if err := s.InsertProduct(model.Product{ID: "product_id", SupplierId: "missing", Name: "Product Name", Capacity: 1}); err == nil {
+		t.Errorf("expected an error when inserting a product for an unknown supplier")
+	}
+}
+
+func TestMemoryStoreNotFound(t *testing.T) {
+	s := NewMemoryStore()
+
+	if _, err := s.GetSupplier("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing supplier, got %v", err)
+	}
+	if _, err := s.GetProduct("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing product, got %v", err)
+	}
+	if _, err := s.GetAvailabilityByID("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing availability, got %v", err)
+	}
+	if _, err := s.GetBookingByID("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing booking, got %v", err)
+	}
+	if err := s.ConfirmBooking("missing", nil, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing booking, got %v", err)
+	}
+	if err := s.CancelBooking("missing", ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing booking, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"octo-api/model"
+	"time"
+)
+
+// recordEvent adds an event about the booking or availability resourceID to
+// the outbox, with the resource as it stands. The caller must hold s.mu.
+func (s *MemoryStore) recordEvent(event, resourceID string) {
+	var data interface{}
+	if event == model.WebhookEventAvailabilityUpdated {
+		data = s.availability(resourceID)
+	} else {
+		data = s.bookingPayload(*s.booking(resourceID))
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		panic("store: encoding outbox event: " + err.Error())
+	}
+	s.events = append(s.events, model.OutboxEvent{
+		ID:         int64(len(s.events) + 1),
+		Event:      event,
+		ResourceId: resourceID,
+		Data:       encoded,
+		CreatedAt:  time.Now().UTC(),
+	})
+}
+
+// GetWebhookSubscriptions returns every webhook subscription, oldest first.
+func (s *MemoryStore) GetWebhookSubscriptions() ([]model.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := []model.WebhookSubscription{}
+	for _, sub := range s.webhookSubscriptions {
+		sub.Events = append([]string{}, sub.Events...)
+		subscriptions = append(subscriptions, sub)
+	}
+	return subscriptions, nil
+}
+
+// InsertWebhookSubscription stores a new webhook subscription.
+func (s *MemoryStore) InsertWebhookSubscription(sub model.WebhookSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.webhookSubscription(sub.ID) != nil {
+		return fmt.Errorf("webhook subscription %s already exists", sub.ID)
+	}
+	sub.Events = append([]string{}, sub.Events...)
+	sub.CreatedAt = sub.CreatedAt.UTC()
+	s.webhookSubscriptions = append(s.webhookSubscriptions, sub)
+	return nil
+}
+
+// DeleteWebhookSubscription removes a subscription together with its
+// deliveries, including those still pending.
+func (s *MemoryStore) DeleteWebhookSubscription(subscriptionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.webhookSubscription(subscriptionID) == nil {
+		return sql.ErrNoRows
+	}
+	kept := s.webhookSubscriptions[:0]
+	for _, sub := range s.webhookSubscriptions {
+		if sub.ID != subscriptionID {
+			kept = append(kept, sub)
+		}
+	}
+	s.webhookSubscriptions = kept
+
+	deliveries := s.webhookDeliveries[:0]
+	for _, d := range s.webhookDeliveries {
+		if d.SubscriptionId != subscriptionID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	s.webhookDeliveries = deliveries
+	return nil
+}
+
+// GetOutboxEvents returns up to limit events that have not been fanned out
+// yet, in the order they were recorded.
+func (s *MemoryStore) GetOutboxEvents(limit int) ([]model.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []model.OutboxEvent{}
+	for _, e := range s.events {
+		if len(events) == limit {
+			break
+		}
+		if !s.dispatchedEvents[e.ID] {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// QueueWebhookDeliveries stores the deliveries of an outbox event and marks
+// the event dispatched. A delivery the event already has for a subscription
+// is kept.
+func (s *MemoryStore) QueueWebhookDeliveries(eventID int64, deliveries []model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range deliveries {
+		if s.eventDelivery(eventID, d.SubscriptionId) != nil {
+			continue
+		}
+		s.lastDeliveryID++
+		s.webhookDeliveries = append(s.webhookDeliveries, model.WebhookDelivery{
+			ID:             s.lastDeliveryID,
+			EventId:        eventID,
+			SubscriptionId: d.SubscriptionId,
+			Payload:        append([]byte{}, d.Payload...),
+			Status:         model.WebhookDeliveryPending,
+			NextAttemptAt:  d.NextAttemptAt.UTC(),
+			CreatedAt:      time.Now().UTC(),
+		})
+	}
+	s.dispatchedEvents[eventID] = true
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit PENDING deliveries due at now,
+// with the secret of their subscription, and moves their next attempt to
+// until.
+func (s *MemoryStore) ClaimWebhookDeliveries(now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := []model.WebhookDelivery{}
+	for i := range s.webhookDeliveries {
+		d := &s.webhookDeliveries[i]
+		if len(deliveries) == limit {
+			break
+		}
+		if d.Status != model.WebhookDeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		d.NextAttemptAt = until.UTC()
+		claimed := s.deliveryView(*d)
+		claimed.Secret = s.webhookSubscription(d.SubscriptionId).Secret
+		deliveries = append(deliveries, claimed)
+	}
+	return deliveries, nil
+}
+
+// SaveWebhookDelivery stores the outcome of a delivery attempt.
+func (s *MemoryStore) SaveWebhookDelivery(delivery model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.webhookDelivery(delivery.ID)
+	if d == nil {
+		return sql.ErrNoRows
+	}
+	d.Status, d.Attempts = delivery.Status, delivery.Attempts
+	d.NextAttemptAt = delivery.NextAttemptAt.UTC()
+	d.LastStatusCode, d.LastError = delivery.LastStatusCode, delivery.LastError
+	d.DeliveredAt = nil
+	if delivery.DeliveredAt != nil {
+		deliveredAt := delivery.DeliveredAt.UTC()
+		d.DeliveredAt = &deliveredAt
+	}
+	return nil
+}
+
+// GetDeadWebhookDeliveries returns the deliveries that ran out of attempts,
+// oldest first.
+func (s *MemoryStore) GetDeadWebhookDeliveries() ([]model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := []model.WebhookDelivery{}
+	for _, d := range s.webhookDeliveries {
+		if d.Status == model.WebhookDeliveryDead {
+			deliveries = append(deliveries, s.deliveryView(d))
+		}
+	}
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery moves a DEAD delivery back to PENDING with fresh
+// attempts, due at at.
+func (s *MemoryStore) RetryWebhookDelivery(deliveryID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.webhookDelivery(deliveryID)
+	if d == nil || d.Status != model.WebhookDeliveryDead {
+		return sql.ErrNoRows
+	}
+	d.Status, d.Attempts, d.NextAttemptAt = model.WebhookDeliveryPending, 0, at.UTC()
+	return nil
+}
+
+// webhookSubscription returns a pointer to the stored subscription with the
+// given ID. The caller must hold s.mu.
+func (s *MemoryStore) webhookSubscription(subscriptionID string) *model.WebhookSubscription {
+	for i := range s.webhookSubscriptions {
+		if s.webhookSubscriptions[i].ID == subscriptionID {
+			return &s.webhookSubscriptions[i]
+		}
+	}
+	return nil
+}
+
+// webhookDelivery returns a pointer to the stored delivery with the given ID.
+// The caller must hold s.mu.
+func (s *MemoryStore) webhookDelivery(deliveryID int64) *model.WebhookDelivery {
+	for i := range s.webhookDeliveries {
+		if s.webhookDeliveries[i].ID == deliveryID {
+			return &s.webhookDeliveries[i]
+		}
+	}
+	return nil
+}
+
+// eventDelivery returns the delivery of an event to a subscription, or nil.
+// The caller must hold s.mu.
+func (s *MemoryStore) eventDelivery(eventID int64, subscriptionID string) *model.WebhookDelivery {
+	for i := range s.webhookDeliveries {
+		if d := &s.webhookDeliveries[i]; d.EventId == eventID && d.SubscriptionId == subscriptionID {
+			return d
+		}
+	}
+	return nil
+}
+
+// deliveryView returns a copy of a stored delivery with the event and URL it
+// is for, as PostgresStore joins them in. The caller must hold s.mu.
+func (s *MemoryStore) deliveryView(d model.WebhookDelivery) model.WebhookDelivery {
+	d.Event = s.events[d.EventId-1].Event
+	d.URL = s.webhookSubscription(d.SubscriptionId).URL
+	d.Payload = append([]byte{}, d.Payload...)
+	if d.DeliveredAt != nil {
+		deliveredAt := *d.DeliveredAt
+		d.DeliveredAt = &deliveredAt
+	}
+	return d
+}
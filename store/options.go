@@ -0,0 +1,23 @@
+package store
+
+// Option customises a PostgresStore or MemoryStore.
+type Option func(*options)
+
+type options struct {
+	policy StatusPolicy
+}
+
+// WithStatusPolicy sets the availability status state machine.
+func WithStatusPolicy(policy StatusPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
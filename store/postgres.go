@@ -0,0 +1,17 @@
+package store
+
+import "database/sql"
+
+// PostgresStore implements Repository on top of a Postgres connection pool.
+type PostgresStore struct {
+	db     *sql.DB
+	policy StatusPolicy
+}
+
+// NewPostgresStore returns a Repository backed by the given database.
+func NewPostgresStore(db *sql.DB, opts ...Option) *PostgresStore {
+	o := newOptions(opts)
+	return &PostgresStore{db: db, policy: o.policy}
+}
+
+var _ Repository = (*PostgresStore)(nil)
@@ -4,10 +4,13 @@ import (
 	"database/sql"
 	"fmt"
 	"octo-api/model"
+
+	"github.com/lib/pq"
 )
 
-func GetProductsFromDB(db *sql.DB) ([]model.Product, error) {
-	rows, err := db.Query("SELECT id, name, capacity, price, currency FROM products")
+// GetProducts returns every product in the catalogue.
+func (s *PostgresStore) GetProducts() ([]model.Product, error) {
+	rows, err := s.db.Query("SELECT id, supplier_id, name, capacity, price, currency, cancellation_cutoff_amount, cancellation_cutoff_unit, availability_type, delivery_methods, delivery_formats FROM products")
 	if err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
@@ -18,29 +21,41 @@ func GetProductsFromDB(db *sql.DB) ([]model.Product, error) {
 	var products []model.Product
 	for rows.Next() {
 		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Capacity, &p.Price, &p.Currency); err != nil {
+		if err := rows.Scan(&p.ID, &p.SupplierId, &p.Name, &p.Capacity, &p.Price, &p.Currency, &p.CancellationCutoffAmount, &p.CancellationCutoffUnit, &p.AvailabilityType, pq.Array(&p.DeliveryMethods), pq.Array(&p.DeliveryFormats)); err != nil {
 			// log.Fatal(err)
 			fmt.Println(err.Error())
 			return nil, err
 		}
 		products = append(products, p)
 	}
+	rows.Close()
+
+	for i := range products {
+		if products[i].Options, err = s.getOptions(products[i].ID); err != nil {
+			return nil, err
+		}
+	}
 	return products, nil
 }
 
-func GetProductFromDB(db *sql.DB, productId string) (*model.Product, error) {
+// GetProduct returns the product with the given ID.
+func (s *PostgresStore) GetProduct(productId string) (*model.Product, error) {
 	var p model.Product
-	err := db.QueryRow("SELECT id, name, capacity, price, currency FROM products WHERE id = $1", productId).Scan(&p.ID, &p.Name, &p.Capacity, &p.Price, &p.Currency)
+	err := s.db.QueryRow("SELECT id, supplier_id, name, capacity, price, currency, cancellation_cutoff_amount, cancellation_cutoff_unit, availability_type, delivery_methods, delivery_formats FROM products WHERE id = $1", productId).Scan(&p.ID, &p.SupplierId, &p.Name, &p.Capacity, &p.Price, &p.Currency, &p.CancellationCutoffAmount, &p.CancellationCutoffUnit, &p.AvailabilityType, pq.Array(&p.DeliveryMethods), pq.Array(&p.DeliveryFormats))
 	if err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
 		return nil, err
 	}
+	if p.Options, err = s.getOptions(p.ID); err != nil {
+		return nil, err
+	}
 	return &p, nil
 }
 
-func InsertProductIntoDB(db *sql.DB, productInfo model.Product) error {
-	tx, err := db.Begin()
+// InsertProduct stores a new product together with its options and units.
+func (s *PostgresStore) InsertProduct(productInfo model.Product) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		// log.Fatal(err)
 		fmt.Println(err.Error())
@@ -48,8 +63,21 @@ func InsertProductIntoDB(db *sql.DB, productInfo model.Product) error {
 	}
 
 	// Insert the product
-	productStmt := "INSERT INTO products (id, name, capacity, price, currency) VALUES ($1, $2, $3, $4, $5)"
-	_, err = tx.Exec(productStmt, productInfo.ID, productInfo.Name, productInfo.Capacity, productInfo.Price, productInfo.Currency)
+	productStmt := "INSERT INTO products (id, supplier_id, name, capacity, price, currency, cancellation_cutoff_amount, cancellation_cutoff_unit, availability_type, delivery_methods, delivery_formats) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"
+	_, err = tx.Exec(
+		productStmt,
+		productInfo.ID,
+		productInfo.SupplierId,
+		productInfo.Name,
+		productInfo.Capacity,
+		productInfo.Price,
+		productInfo.Currency,
+		productInfo.CancellationCutoffAmount,
+		productInfo.CancellationCutoffUnit,
+		productInfo.AvailabilityType,
+		pq.Array(orDefault(productInfo.DeliveryMethods, model.DeliveryMethods)),
+		pq.Array(orDefault(productInfo.DeliveryFormats, model.DeliveryFormats)),
+	)
 	if err != nil {
 		tx.Rollback()
 		// log.Fatal(err)
@@ -57,5 +85,125 @@ func InsertProductIntoDB(db *sql.DB, productInfo model.Product) error {
 		return err
 	}
 
+	// Insert its options and their units
+	optionStmt := "INSERT INTO options (id, product_id, internal_name, reference, is_default) VALUES ($1, $2, $3, $4, $5)"
+	unitStmt := "INSERT INTO units (id, option_id, internal_name, reference, type, min_age, max_age, id_required, min_quantity, max_quantity, pax_count, accompanied_by, price, currency) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)"
+	for _, o := range productInfo.Options {
+		_, err = tx.Exec(optionStmt, o.ID, productInfo.ID, o.InternalName, o.Reference, o.Default)
+		if err != nil {
+			tx.Rollback()
+			fmt.Println(err.Error())
+			return err
+		}
+		for _, u := range o.Units {
+			_, err = tx.Exec(
+				unitStmt,
+				u.ID,
+				o.ID,
+				u.InternalName,
+				u.Reference,
+				u.Type,
+				u.Restrictions.MinAge,
+				u.Restrictions.MaxAge,
+				u.Restrictions.IdRequired,
+				u.Restrictions.MinQuantity,
+				u.Restrictions.MaxQuantity,
+				u.Restrictions.PaxCount,
+				pq.Array(u.Restrictions.AccompaniedBy),
+				u.Price,
+				u.Currency,
+			)
+			if err != nil {
+				tx.Rollback()
+				fmt.Println(err.Error())
+				return err
+			}
+		}
+	}
+
 	return tx.Commit()
 }
+
+// getOptions loads the options of a product, default option first, together
+// with their units.
+func (s *PostgresStore) getOptions(productID string) ([]model.Option, error) {
+	rows, err := s.db.Query("SELECT id, product_id, internal_name, reference, is_default FROM options WHERE product_id = $1 ORDER BY is_default DESC, id", productID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []model.Option{}
+	for rows.Next() {
+		var o model.Option
+		if err := rows.Scan(&o.ID, &o.ProductId, &o.InternalName, &o.Reference, &o.Default); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	rows.Close()
+
+	for i := range options {
+		if options[i].Units, err = s.getUnits(options[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// getUnits loads the units of an option.
+func (s *PostgresStore) getUnits(optionID string) ([]model.Unit, error) {
+	rows, err := s.db.Query("SELECT id, option_id, internal_name, reference, type, min_age, max_age, id_required, min_quantity, max_quantity, pax_count, accompanied_by, price, currency FROM units WHERE option_id = $1 ORDER BY id", optionID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := []model.Unit{}
+	for rows.Next() {
+		var u model.Unit
+		var minQuantity, maxQuantity sql.NullInt64
+		if err := rows.Scan(
+			&u.ID,
+			&u.OptionId,
+			&u.InternalName,
+			&u.Reference,
+			&u.Type,
+			&u.Restrictions.MinAge,
+			&u.Restrictions.MaxAge,
+			&u.Restrictions.IdRequired,
+			&minQuantity,
+			&maxQuantity,
+			&u.Restrictions.PaxCount,
+			pq.Array(&u.Restrictions.AccompaniedBy),
+			&u.Price,
+			&u.Currency,
+		); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		u.Restrictions.MinQuantity = nullIntPtr(minQuantity)
+		u.Restrictions.MaxQuantity = nullIntPtr(maxQuantity)
+		units = append(units, u)
+	}
+	return units, nil
+}
+
+// orDefault returns values, or a copy of defaults when there are none.
+func orDefault(values, defaults []string) []string {
+	if len(values) == 0 {
+		return append([]string{}, defaults...)
+	}
+	return values
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
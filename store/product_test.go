@@ -7,16 +7,26 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
-func TestGetProductsFromDB(t *testing.T) {
+var (
+	optionColumns  = []string{"id", "product_id", "internal_name", "reference", "is_default"}
+	productColumns = []string{"id", "supplier_id", "name", "capacity", "price", "currency", "cancellation_cutoff_amount", "cancellation_cutoff_unit", "availability_type", "delivery_methods", "delivery_formats"}
+	unitColumns    = []string{"id", "option_id", "internal_name", "reference", "type", "min_age", "max_age", "id_required", "min_quantity", "max_quantity", "pax_count", "accompanied_by", "price", "currency"}
+)
+
+func TestGetProducts(t *testing.T) {
 	db, mock := NewMock()
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, name, capacity, price, currency FROM products").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "capacity", "price", "currency"}).
-			AddRow("product_id", "Product 1", 100, 1000.0, "USD").
-			AddRow("product_id2", "Product 2", 200, 2000.0, "EUR"))
+	mock.ExpectQuery("SELECT id, supplier_id, name, capacity, price, currency, cancellation_cutoff_amount, cancellation_cutoff_unit, availability_type, delivery_methods, delivery_formats FROM products").
+		WillReturnRows(sqlmock.NewRows(productColumns).
+			AddRow("product_id", "supplier_id", "Product 1", 100, 100000, "USD", 24, "hour", "START_TIME", "{VOUCHER,TICKET}", "{QRCODE,CODE128,PDF_URL}").
+			AddRow("product_id2", "supplier_id", "Product 2", 200, 200000, "EUR", 0, "hour", "OPENING_HOURS", "{VOUCHER,TICKET}", "{QRCODE,CODE128,PDF_URL}"))
+	mock.ExpectQuery("SELECT (.+) FROM options WHERE product_id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(optionColumns))
+	mock.ExpectQuery("SELECT (.+) FROM options WHERE product_id = \\$1").WithArgs("product_id2").
+		WillReturnRows(sqlmock.NewRows(optionColumns))
 
-	products, err := GetProductsFromDB(db)
+	products, err := NewPostgresStore(db).GetProducts()
 	if err != nil {
 		t.Fatalf("error was not expected while fetching products: %s", err)
 	}
@@ -30,17 +40,40 @@ func TestGetProductsFromDB(t *testing.T) {
 	}
 }
 
-func TestGetProductFromDB(t *testing.T) {
+func TestGetProduct(t *testing.T) {
 	db, mock := NewMock()
 	defer db.Close()
 
-	query := "SELECT id, name, capacity, price, currency FROM products WHERE id = \\$1"
-	mock.ExpectQuery(query).WithArgs("product_id").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "capacity", "price", "currency"}).
-		AddRow("product_id", "Product Name", 100, 50.0, "USD"))
+	query := "SELECT id, supplier_id, name, capacity, price, currency, cancellation_cutoff_amount, cancellation_cutoff_unit, availability_type, delivery_methods, delivery_formats FROM products WHERE id = \\$1"
+	mock.ExpectQuery(query).WithArgs("product_id").WillReturnRows(sqlmock.NewRows(productColumns).
+		AddRow("product_id", "supplier_id", "Product Name", 100, 5000, "USD", 2, "day", "START_TIME", "{VOUCHER,TICKET}", "{QRCODE,CODE128,PDF_URL}"))
+	mock.ExpectQuery("SELECT (.+) FROM options WHERE product_id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(optionColumns).AddRow("option_id", "product_id", "DEFAULT", nil, true))
+	mock.ExpectQuery("SELECT (.+) FROM units WHERE option_id = \\$1").WithArgs("option_id").
+		WillReturnRows(sqlmock.NewRows(unitColumns).
+			AddRow("adult_id", "option_id", "Adult", nil, "ADULT", 18, 99, false, nil, 10, 1, "{}", 5000, "USD").
+			AddRow("child_id", "option_id", "Child", nil, "CHILD", 4, 17, false, nil, nil, 1, "{adult_id}", 2500, "USD"))
 
-	_, err := GetProductFromDB(db, "product_id")
+	product, err := NewPostgresStore(db).GetProduct("product_id")
 	if err != nil {
-		t.Errorf("error was not expected while fetching product by ID: %s", err)
+		t.Fatalf("error was not expected while fetching product by ID: %s", err)
+	}
+
+	if product.CancellationCutoffAmount != 2 || product.CancellationCutoffUnit != model.CutoffUnitDay {
+		t.Errorf("unexpected cancellation cutoff %d %s", product.CancellationCutoffAmount, product.CancellationCutoffUnit)
+	}
+	if product.AvailabilityType != model.AvailabilityTypeStartTime {
+		t.Errorf("expected availability type %s, got %s", model.AvailabilityTypeStartTime, product.AvailabilityType)
+	}
+	if len(product.Options) != 1 || len(product.Options[0].Units) != 2 {
+		t.Fatalf("expected 1 option with 2 units, got %+v", product.Options)
+	}
+	adult, child := product.Options[0].Units[0], product.Options[0].Units[1]
+	if adult.Restrictions.MaxQuantity == nil || *adult.Restrictions.MaxQuantity != 10 || adult.Restrictions.MinQuantity != nil {
+		t.Errorf("unexpected adult restrictions %+v", adult.Restrictions)
+	}
+	if len(child.Restrictions.AccompaniedBy) != 1 || child.Restrictions.AccompaniedBy[0] != "adult_id" {
+		t.Errorf("unexpected child restrictions %+v", child.Restrictions)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -48,15 +81,42 @@ func TestGetProductFromDB(t *testing.T) {
 	}
 }
 
-func TestInsertProductIntoDB(t *testing.T) {
+func TestInsertProduct(t *testing.T) {
 	db, mock := NewMock()
 	defer db.Close()
 
 	mock.ExpectBegin()
-	mock.ExpectExec("INSERT INTO products").WithArgs(sqlmock.AnyArg(), "Product Name", 100, 50.0, "USD").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO products").WithArgs(sqlmock.AnyArg(), "supplier_id", "Product Name", 100, 5000, "USD", 1, "hour", "OPENING_HOURS", `{"VOUCHER","TICKET"}`, `{"QRCODE","CODE128","PDF_URL"}`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO options").WithArgs("option_id", "product_id", "DEFAULT", nil, true).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO units").
+		WithArgs("unit_id", "option_id", "Adult", nil, "ADULT", 18, 99, false, nil, nil, 1, sqlmock.AnyArg(), 5000, "USD").
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	err := InsertProductIntoDB(db, model.Product{ID: "product_id", Name: "Product Name", Capacity: 100, Price: 50.0, Currency: "USD"})
+	err := NewPostgresStore(db).InsertProduct(model.Product{
+		ID:                       "product_id",
+		SupplierId:               "supplier_id",
+		Name:                     "Product Name",
+		Capacity:                 100,
+		Price:                    5000,
+		Currency:                 "USD",
+		CancellationCutoffAmount: 1,
+		CancellationCutoffUnit:   model.CutoffUnitHour,
+		AvailabilityType:         model.AvailabilityTypeOpeningHours,
+		Options: []model.Option{{
+			ID:           "option_id",
+			Default:      true,
+			InternalName: "DEFAULT",
+			Units: []model.Unit{{
+				ID:           "unit_id",
+				InternalName: "Adult",
+				Type:         model.UnitTypeAdult,
+				Restrictions: model.UnitRestrictions{MinAge: 18, MaxAge: 99, PaxCount: 1},
+				Price:        5000,
+				Currency:     "USD",
+			}},
+		}},
+	})
 	if err != nil {
 		t.Errorf("error was not expected while inserting product: %s", err)
 	}
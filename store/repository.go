@@ -0,0 +1,97 @@
+package store
+
+import (
+	"octo-api/model"
+	"octo-api/money"
+	"time"
+)
+
+// SupplierRepository provides access to the operators that own products.
+type SupplierRepository interface {
+	GetSuppliers() ([]model.Supplier, error)
+	GetSupplier(supplierID string) (*model.Supplier, error)
+	InsertSupplier(supplier model.Supplier) error
+}
+
+// ProductRepository provides access to the product catalogue.
+type ProductRepository interface {
+	GetProducts() ([]model.Product, error)
+	GetProduct(productID string) (*model.Product, error)
+	InsertProduct(product model.Product) error
+}
+
+// AvailabilityRepository provides access to product availabilities.
+type AvailabilityRepository interface {
+	GetAvailabilities(startDate, endDate time.Time) ([]model.AvailabilityShow, error)
+	GetAvailabilityByID(availabilityID string) (*model.Availability, error)
+	GetProductAvailabilities(productID string, startDate, endDate time.Time) ([]model.Availability, error)
+	AddAvailability(productID string, startDate, endDate time.Time, timeslots Timeslots, price money.Money) error
+	UpdateAvailability(availabilityID, status string, vacancies *int) error
+}
+
+// ScheduleRepository provides access to the recurring schedule rules that
+// availabilities are generated from.
+type ScheduleRepository interface {
+	GetScheduleRules(productID string) ([]model.ScheduleRule, error)
+	GetScheduleRule(ruleID string) (*model.ScheduleRule, error)
+	InsertScheduleRule(rule model.ScheduleRule) error
+	UpdateScheduleRule(rule model.ScheduleRule) error
+	DeleteScheduleRule(ruleID string, from time.Time) error
+	MaterializeSchedule(productID string, from, until time.Time) (int, error)
+}
+
+// BookingRepository provides access to bookings and their units.
+type BookingRepository interface {
+	CreateBooking(booking model.Booking) error
+	ConfirmBooking(bookingID string, contact *model.Contact, unitContacts map[string]model.Contact) error
+	CancelBooking(bookingID, reason string) error
+	ExtendBooking(bookingID string, expiresAt time.Time) error
+	UpdateBooking(booking model.Booking, at time.Time) error
+	ExpireBookings(now time.Time) (int, error)
+	GetAllBookings(resellerID string) ([]model.BookingPayload_Rs, error)
+	GetBookingByID(bookingID string) (*model.BookingPayload_Rs, error)
+	GetBookingByUUID(uuid string) (*model.BookingPayload_Rs, error)
+	GetBookingByReference(reference string) (*model.BookingPayload_Rs, error)
+	RedeemTickets(bookingID string, unitIDs []string, device string, at time.Time) error
+}
+
+// IdempotencyRepository records the responses to requests sent with an
+// Idempotency-Key so retries can be replayed.
+type IdempotencyRepository interface {
+	ClaimIdempotencyKey(key, requestHash string, at, staleBefore time.Time) (*model.IdempotentResponse, error)
+	SaveIdempotentResponse(key string, status int, header map[string][]string, body []byte) error
+	ReleaseIdempotencyKey(key string) error
+}
+
+// APIKeyRepository provides access to the API keys callers authenticate with.
+type APIKeyRepository interface {
+	GetAPIKey(keyHash string) (*model.APIKey, error)
+	InsertAPIKey(key model.APIKey) error
+}
+
+// WebhookRepository provides access to webhook subscriptions, the outbox of
+// events written alongside booking and availability changes, and the
+// deliveries of those events.
+type WebhookRepository interface {
+	GetWebhookSubscriptions() ([]model.WebhookSubscription, error)
+	InsertWebhookSubscription(subscription model.WebhookSubscription) error
+	DeleteWebhookSubscription(subscriptionID string) error
+	GetOutboxEvents(limit int) ([]model.OutboxEvent, error)
+	QueueWebhookDeliveries(eventID int64, deliveries []model.WebhookDelivery) error
+	ClaimWebhookDeliveries(now, until time.Time, limit int) ([]model.WebhookDelivery, error)
+	SaveWebhookDelivery(delivery model.WebhookDelivery) error
+	GetDeadWebhookDeliveries() ([]model.WebhookDelivery, error)
+	RetryWebhookDelivery(deliveryID int64, at time.Time) error
+}
+
+// Repository groups every repository the API depends on.
+type Repository interface {
+	SupplierRepository
+	ProductRepository
+	AvailabilityRepository
+	ScheduleRepository
+	BookingRepository
+	IdempotencyRepository
+	APIKeyRepository
+	WebhookRepository
+}
@@ -0,0 +1,347 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"octo-api/helper"
+	"octo-api/model"
+	"octo-api/money"
+	"strings"
+	"time"
+)
+
+// ErrInvalidScheduleRule is returned when a schedule rule cannot generate
+// availabilities for its product.
+var ErrInvalidScheduleRule = errors.New("invalid schedule rule")
+
+// DefaultScheduleHorizonDays is how many days ahead availabilities are
+// generated from schedule rules.
+const DefaultScheduleHorizonDays = 90
+
+// allWeekdays is the weekday mask of a rule that applies every day.
+const allWeekdays = 1<<7 - 1
+
+// ScheduleConfig controls how far ahead and how often schedule rules are
+// materialized into availabilities.
+type ScheduleConfig struct {
+	HorizonDays int
+	Interval    time.Duration
+}
+
+// ScheduleConfigFromEnv reads SCHEDULE_HORIZON_DAYS and SCHEDULE_INTERVAL.
+func ScheduleConfigFromEnv() (ScheduleConfig, error) {
+	var cfg ScheduleConfig
+	var err error
+
+	if cfg.HorizonDays, err = helper.EnvInt("SCHEDULE_HORIZON_DAYS", DefaultScheduleHorizonDays); err != nil {
+		return cfg, err
+	}
+	if cfg.Interval, err = helper.EnvDuration("SCHEDULE_INTERVAL", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.HorizonDays <= 0 || cfg.Interval <= 0 {
+		return cfg, fmt.Errorf("SCHEDULE_HORIZON_DAYS and SCHEDULE_INTERVAL must be positive")
+	}
+	return cfg, nil
+}
+
+// ScheduleWindow returns the local dates availabilities are generated for on
+// the day of now in loc: today and the following horizonDays days.
+func ScheduleWindow(now time.Time, loc *time.Location, horizonDays int) (from, until time.Time) {
+	from = truncateDate(now.In(loc))
+	return from, from.AddDate(0, 0, horizonDays)
+}
+
+// ProductScheduleWindow returns the schedule window of a product, starting on
+// today's date in its supplier's timezone.
+func ProductScheduleWindow(repo Repository, productID string, now time.Time, horizonDays int) (from, until time.Time, err error) {
+	product, err := repo.GetProduct(productID)
+	if err != nil {
+		return from, until, err
+	}
+	supplier, err := repo.GetSupplier(product.SupplierId)
+	if err != nil {
+		return from, until, err
+	}
+	from, until = ScheduleWindow(now, loadLocation(supplier.Timezone), horizonDays)
+	return from, until, nil
+}
+
+// GenerateSchedules materializes the schedule rules of every product for the
+// window starting on the day of now in its supplier's timezone and returns how
+// many availabilities were created.
+func GenerateSchedules(repo Repository, now time.Time, horizonDays int) (int, error) {
+	products, err := repo.GetProducts()
+	if err != nil {
+		return 0, err
+	}
+
+	locations := map[string]*time.Location{}
+	created := 0
+	for _, p := range products {
+		loc, ok := locations[p.SupplierId]
+		if !ok {
+			supplier, err := repo.GetSupplier(p.SupplierId)
+			if err != nil {
+				return created, fmt.Errorf("product %s: %w", p.ID, err)
+			}
+			loc = loadLocation(supplier.Timezone)
+			locations[p.SupplierId] = loc
+		}
+
+		from, until := ScheduleWindow(now, loc, horizonDays)
+		n, err := repo.MaterializeSchedule(p.ID, from, until)
+		if err != nil {
+			return created, fmt.Errorf("product %s: %w", p.ID, err)
+		}
+		created += n
+	}
+	return created, nil
+}
+
+// RunScheduleGenerator keeps availabilities generated for a rolling horizon,
+// once on start and then every interval until ctx is done. It blocks; start it
+// in its own goroutine.
+func RunScheduleGenerator(ctx context.Context, repo Repository, cfg ScheduleConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := GenerateSchedules(repo, time.Now().UTC(), cfg.HorizonDays)
+		if err != nil {
+			log.Printf("failed to generate availabilities: %v", err)
+		} else if created > 0 {
+			log.Printf("generated %d availabilities", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// weekdayMask converts day names such as MONDAY into a bit mask with bit n set
+// for time.Weekday n. No names means every day.
+func weekdayMask(names []string) (int, error) {
+	if len(names) == 0 {
+		return allWeekdays, nil
+	}
+	mask := 0
+	for _, name := range names {
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(name, d.String()) {
+				mask |= 1 << d
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("%w: unknown weekday %q", ErrInvalidScheduleRule, name)
+		}
+	}
+	return mask, nil
+}
+
+// weekdayNames is the inverse of weekdayMask.
+func weekdayNames(mask int) []string {
+	names := []string{}
+	if mask == allWeekdays {
+		return names
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if mask&(1<<d) != 0 {
+			names = append(names, strings.ToUpper(d.String()))
+		}
+	}
+	return names
+}
+
+// normalizeScheduleRule checks a rule against its product and returns it with
+// upper-case weekday names and dates without a time of day.
+func normalizeScheduleRule(rule model.ScheduleRule, product model.Product) (model.ScheduleRule, error) {
+	mask, err := weekdayMask(rule.Weekdays)
+	if err != nil {
+		return rule, err
+	}
+	rule.Weekdays = weekdayNames(mask)
+
+	if rule.ValidFrom.IsZero() {
+		return rule, fmt.Errorf("%w: validFrom is required", ErrInvalidScheduleRule)
+	}
+	rule.ValidFrom = truncateDate(rule.ValidFrom)
+	if rule.ValidUntil != nil {
+		until := truncateDate(*rule.ValidUntil)
+		if until.Before(rule.ValidFrom) {
+			return rule, fmt.Errorf("%w: validUntil is before validFrom", ErrInvalidScheduleRule)
+		}
+		rule.ValidUntil = &until
+	}
+	blackouts := make([]time.Time, 0, len(rule.BlackoutDates))
+	for _, d := range rule.BlackoutDates {
+		blackouts = append(blackouts, truncateDate(d))
+	}
+	rule.BlackoutDates = blackouts
+
+	if rule.Capacity != nil && *rule.Capacity < 0 {
+		return rule, fmt.Errorf("%w: capacity must not be negative", ErrInvalidScheduleRule)
+	}
+	if rule.Price != nil && *rule.Price < 0 {
+		return rule, fmt.Errorf("%w: price must not be negative", ErrInvalidScheduleRule)
+	}
+	if rule.StartTimes == nil {
+		rule.StartTimes = []string{}
+	}
+
+	// The start times must suit the product on any day
+	if _, err := ruleTimeslots(rule).slots(rule.ValidFrom, time.UTC, product.AvailabilityType); err != nil {
+		return rule, fmt.Errorf("%w: %v", ErrInvalidScheduleRule, err)
+	}
+	return rule, nil
+}
+
+// ruleTimeslots returns the slots a rule creates on each day.
+func ruleTimeslots(rule model.ScheduleRule) Timeslots {
+	return Timeslots{StartTimes: rule.StartTimes, Duration: time.Duration(rule.DurationMinutes) * time.Minute}
+}
+
+// appliesOn reports whether a rule generates availabilities on the local date day.
+func appliesOn(rule model.ScheduleRule, mask int, day time.Time) bool {
+	if day.Before(rule.ValidFrom) || (rule.ValidUntil != nil && day.After(*rule.ValidUntil)) {
+		return false
+	}
+	if mask&(1<<day.Weekday()) == 0 {
+		return false
+	}
+	for _, d := range rule.BlackoutDates {
+		if d.Equal(day) {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduledSlot is an availability a schedule rule asks for.
+type scheduledSlot struct {
+	slot
+	ruleID    string
+	localDate time.Time
+	capacity  int
+	price     money.Money
+}
+
+// expandSchedule returns the slots the rules ask for between the local dates
+// from and until, inclusive. Where rules overlap the earlier rule wins.
+func expandSchedule(rules []model.ScheduleRule, product model.Product, loc *time.Location, from, until time.Time) ([]scheduledSlot, error) {
+	masks := make([]int, len(rules))
+	for i, rule := range rules {
+		mask, err := weekdayMask(rule.Weekdays)
+		if err != nil {
+			return nil, err
+		}
+		masks[i] = mask
+	}
+
+	var slots []scheduledSlot
+	for day := truncateDate(from); !day.After(truncateDate(until)); day = day.AddDate(0, 0, 1) {
+		taken := map[int64]bool{}
+		for i, rule := range rules {
+			if !appliesOn(rule, masks[i], day) {
+				continue
+			}
+			daySlots, err := ruleTimeslots(rule).slots(day, loc, product.AvailabilityType)
+			if err != nil {
+				return nil, fmt.Errorf("schedule rule %s: %w", rule.ID, err)
+			}
+
+			capacity := product.Capacity
+			if rule.Capacity != nil {
+				capacity = *rule.Capacity
+			}
+			price := money.New(product.Price, product.Currency)
+			if rule.Price != nil {
+				price.Amount = *rule.Price
+			}
+			if rule.Currency != "" {
+				price.Currency = rule.Currency
+			}
+
+			for _, sl := range daySlots {
+				if taken[sl.start.Unix()] {
+					continue
+				}
+				taken[sl.start.Unix()] = true
+				slots = append(slots, scheduledSlot{slot: sl, ruleID: rule.ID, localDate: day, capacity: capacity, price: price})
+			}
+		}
+	}
+	return slots, nil
+}
+
+// existingSlot is an availability already stored in the generation window.
+type existingSlot struct {
+	id     string
+	start  time.Time
+	ruleID string // empty for one-off availabilities
+	status string
+	booked bool
+
+	capacity  int
+	vacancies int
+}
+
+// slotUpdate refreshes an existing availability from its rule.
+type slotUpdate struct {
+	scheduledSlot
+	id        string
+	status    string // current status
+	vacancies int    // current vacancies moved by the change in capacity
+}
+
+// schedulePlan is what materializing a schedule changes.
+type schedulePlan struct {
+	inserts []scheduledSlot
+	updates []slotUpdate
+	deletes []string
+}
+
+// planSchedule reconciles the stored availabilities with the wanted slots.
+// One-off availabilities and any with bookings are left alone and keep their
+// start time; other generated availabilities are updated in place, or deleted
+// when no rule asks for them any more.
+func planSchedule(existing []existingSlot, wanted []scheduledSlot) schedulePlan {
+	var plan schedulePlan
+
+	byStart := make(map[int64]scheduledSlot, len(wanted))
+	for _, w := range wanted {
+		byStart[w.start.Unix()] = w
+	}
+
+	kept := map[int64]bool{}
+	for _, e := range existing {
+		w, ok := byStart[e.start.Unix()]
+		switch {
+		case e.ruleID == "" || e.booked:
+			kept[e.start.Unix()] = true
+		case ok:
+			kept[e.start.Unix()] = true
+			// Vacancies set by hand stay set; only a new capacity moves them
+			vacancies := e.vacancies + w.capacity - e.capacity
+			if vacancies < 0 {
+				vacancies = 0
+			}
+			plan.updates = append(plan.updates, slotUpdate{scheduledSlot: w, id: e.id, status: e.status, vacancies: vacancies})
+		default:
+			plan.deletes = append(plan.deletes, e.id)
+		}
+	}
+	for _, w := range wanted {
+		if !kept[w.start.Unix()] {
+			plan.inserts = append(plan.inserts, w)
+		}
+	}
+	return plan
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"octo-api/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanScheduleRule reads a schedule_rules row selected in table order.
+func scanScheduleRule(row scanner) (model.ScheduleRule, error) {
+	var r model.ScheduleRule
+	var mask int
+	var validUntil sql.NullTime
+	var blackouts []string
+	var currency sql.NullString
+	err := row.Scan(
+		&r.ID,
+		&r.ProductId,
+		&mask,
+		pq.Array(&r.StartTimes),
+		&r.DurationMinutes,
+		&r.ValidFrom,
+		&validUntil,
+		pq.Array(&blackouts),
+		&r.Capacity,
+		&r.Price,
+		&currency,
+	)
+	if err != nil {
+		return r, err
+	}
+
+	r.Weekdays = weekdayNames(mask)
+	if r.StartTimes == nil {
+		r.StartTimes = []string{}
+	}
+	if validUntil.Valid {
+		until := validUntil.Time
+		r.ValidUntil = &until
+	}
+	r.BlackoutDates = make([]time.Time, 0, len(blackouts))
+	for _, b := range blackouts {
+		d, err := time.Parse("2006-01-02", b)
+		if err != nil {
+			return r, fmt.Errorf("invalid blackout date %q: %w", b, err)
+		}
+		r.BlackoutDates = append(r.BlackoutDates, d)
+	}
+	r.Currency = currency.String
+	return r, nil
+}
+
+// scheduleRuleArgs returns the columns written for a rule, less its ID and product.
+func scheduleRuleArgs(rule model.ScheduleRule) ([]interface{}, error) {
+	mask, err := weekdayMask(rule.Weekdays)
+	if err != nil {
+		return nil, err
+	}
+	blackouts := make([]string, 0, len(rule.BlackoutDates))
+	for _, d := range rule.BlackoutDates {
+		blackouts = append(blackouts, d.Format("2006-01-02"))
+	}
+	var currency sql.NullString
+	if rule.Currency != "" {
+		currency = sql.NullString{String: rule.Currency, Valid: true}
+	}
+	return []interface{}{mask, pq.Array(rule.StartTimes), rule.DurationMinutes, rule.ValidFrom, rule.ValidUntil, pq.Array(blackouts), rule.Capacity, rule.Price, currency}, nil
+}
+
+// checkRowsAffected returns sql.ErrNoRows when result touched no rows.
+func checkRowsAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetScheduleRules returns the schedule rules of a product, oldest season first.
+func (s *PostgresStore) GetScheduleRules(productID string) ([]model.ScheduleRule, error) {
+	rows, err := s.db.Query("SELECT id, product_id, weekday_mask, start_times, duration_minutes, valid_from, valid_until, blackout_dates, capacity, price, currency FROM schedule_rules WHERE product_id = $1 ORDER BY valid_from, id", productID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []model.ScheduleRule{}
+	for rows.Next() {
+		rule, err := scanScheduleRule(rows)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetScheduleRule returns the schedule rule with the given ID.
+func (s *PostgresStore) GetScheduleRule(ruleID string) (*model.ScheduleRule, error) {
+	rule, err := scanScheduleRule(s.db.QueryRow("SELECT id, product_id, weekday_mask, start_times, duration_minutes, valid_from, valid_until, blackout_dates, capacity, price, currency FROM schedule_rules WHERE id = $1", ruleID))
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// InsertScheduleRule stores a new schedule rule after checking it against its
+// product. It does not generate availabilities; see MaterializeSchedule.
+func (s *PostgresStore) InsertScheduleRule(rule model.ScheduleRule) error {
+	product, err := s.GetProduct(rule.ProductId)
+	if err != nil {
+		return err
+	}
+	if rule, err = normalizeScheduleRule(rule, *product); err != nil {
+		return err
+	}
+	args, err := scheduleRuleArgs(rule)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO schedule_rules (id, product_id, weekday_mask, start_times, duration_minutes, valid_from, valid_until, blackout_dates, capacity, price, currency) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		append([]interface{}{rule.ID, rule.ProductId}, args...)...,
+	)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	return nil
+}
+
+// UpdateScheduleRule replaces a schedule rule. Its product cannot change.
+func (s *PostgresStore) UpdateScheduleRule(rule model.ScheduleRule) error {
+	current, err := s.GetScheduleRule(rule.ID)
+	if err != nil {
+		return err
+	}
+	rule.ProductId = current.ProductId
+	product, err := s.GetProduct(rule.ProductId)
+	if err != nil {
+		return err
+	}
+	if rule, err = normalizeScheduleRule(rule, *product); err != nil {
+		return err
+	}
+	args, err := scheduleRuleArgs(rule)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(
+		"UPDATE schedule_rules SET weekday_mask = $1, start_times = $2, duration_minutes = $3, valid_from = $4, valid_until = $5, blackout_dates = $6, capacity = $7, price = $8, currency = $9 WHERE id = $10",
+		append(args, rule.ID)...,
+	)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	return checkRowsAffected(result)
+}
+
+// DeleteScheduleRule removes a schedule rule together with the availabilities
+// it generated from the local date from on that have no bookings. Booked ones
+// are kept as one-off availabilities.
+func (s *PostgresStore) DeleteScheduleRule(ruleID string, from time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM availabilities a WHERE a.schedule_rule_id = $1 AND a.local_date >= $2 AND NOT EXISTS (SELECT 1 FROM bookings b WHERE b.availability_id = a.id)", ruleID, truncateDate(from))
+	if err != nil {
+		tx.Rollback()
+		fmt.Println(err.Error())
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM schedule_rules WHERE id = $1", ruleID)
+	if err != nil {
+		tx.Rollback()
+		fmt.Println(err.Error())
+		return err
+	}
+	if err := checkRowsAffected(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MaterializeSchedule brings the availabilities of a product between the local
+// dates from and until in line with its schedule rules and returns how many
+// were created. Availabilities with bookings and one-off availabilities are
+// never changed.
+func (s *PostgresStore) MaterializeSchedule(productID string, from, until time.Time) (int, error) {
+	product, err := s.GetProduct(productID)
+	if err != nil {
+		return 0, err
+	}
+	supplier, err := s.GetSupplier(product.SupplierId)
+	if err != nil {
+		return 0, err
+	}
+	rules, err := s.GetScheduleRules(productID)
+	if err != nil {
+		return 0, err
+	}
+	from, until = truncateDate(from), truncateDate(until)
+	wanted, err := expandSchedule(rules, *product, loadLocation(supplier.Timezone), from, until)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// Lock the window so no booking lands on a slot about to be removed
+	rows, err := tx.Query("SELECT a.id, a.local_date_time_start, a.schedule_rule_id, a.status, EXISTS (SELECT 1 FROM bookings b WHERE b.availability_id = a.id), a.capacity, a.vacancies FROM availabilities a WHERE a.product_id = $1 AND a.local_date BETWEEN $2 AND $3 FOR UPDATE", productID, from, until)
+	if err != nil {
+		tx.Rollback()
+		fmt.Println(err.Error())
+		return 0, err
+	}
+	var existing []existingSlot
+	for rows.Next() {
+		var e existingSlot
+		var ruleID sql.NullString
+		if err := rows.Scan(&e.id, &e.start, &ruleID, &e.status, &e.booked, &e.capacity, &e.vacancies); err != nil {
+			rows.Close()
+			tx.Rollback()
+			fmt.Println(err.Error())
+			return 0, err
+		}
+		e.ruleID = ruleID.String
+		existing = append(existing, e)
+	}
+	rows.Close()
+
+	plan := planSchedule(existing, wanted)
+	for _, id := range plan.deletes {
+		if _, err := tx.Exec("DELETE FROM availabilities WHERE id = $1", id); err != nil {
+			tx.Rollback()
+			fmt.Println(err.Error())
+			return 0, err
+		}
+	}
+	for _, u := range plan.updates {
+		status := s.policy.Status(u.status, u.vacancies)
+		_, err := tx.Exec(
+			"UPDATE availabilities SET local_date_time_end = $1, capacity = $2, vacancies = $3, status = $4, available = $5, price = $6, currency = $7, schedule_rule_id = $8 WHERE id = $9",
+			u.end, u.capacity, u.vacancies, status, IsAvailable(status), u.price.Amount, u.price.Currency, u.ruleID, u.id,
+		)
+		if err != nil {
+			tx.Rollback()
+			fmt.Println(err.Error())
+			return 0, err
+		}
+	}
+	for _, w := range plan.inserts {
+		status := s.policy.Status(model.AvailabilityStatusAvailable, w.capacity)
+		_, err := tx.Exec(
+			"INSERT INTO availabilities (id, local_date, local_date_time_start, local_date_time_end, status, product_id, capacity, vacancies, available, price, currency, schedule_rule_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+			uuid.NewString(), w.localDate, w.start, w.end, status, productID, w.capacity, w.capacity, IsAvailable(status), w.price.Amount, w.price.Currency, w.ruleID,
+		)
+		if err != nil {
+			tx.Rollback()
+			fmt.Println(err.Error())
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(plan.inserts), nil
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"octo-api/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var scheduleRuleColumns = []string{"id", "product_id", "weekday_mask", "start_times", "duration_minutes", "valid_from", "valid_until", "blackout_dates", "capacity", "price", "currency"}
+
+func TestGetScheduleRules(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM schedule_rules WHERE product_id = \\$1 ORDER BY valid_from, id").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(scheduleRuleColumns).
+			AddRow("rule_id", "product_id", 1<<time.Monday|1<<time.Friday, "{09:00,14:00}", 90, date(2024, 6, 1), date(2024, 8, 31), "{2024-07-04}", 4, nil, nil))
+
+	rules, err := NewPostgresStore(db).GetScheduleRules("product_id")
+	if err != nil {
+		t.Fatalf("error was not expected while fetching schedule rules: %s", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	rule := rules[0]
+	if len(rule.Weekdays) != 2 || rule.Weekdays[0] != "MONDAY" || len(rule.StartTimes) != 2 || rule.StartTimes[1] != "14:00" {
+		t.Errorf("unexpected weekdays or start times %+v", rule)
+	}
+	if rule.ValidUntil == nil || !rule.ValidUntil.Equal(date(2024, 8, 31)) || len(rule.BlackoutDates) != 1 || !rule.BlackoutDates[0].Equal(date(2024, 7, 4)) {
+		t.Errorf("unexpected season %+v", rule)
+	}
+	if rule.Capacity == nil || *rule.Capacity != 4 || rule.Price != nil || rule.Currency != "" {
+		t.Errorf("unexpected overrides %+v", rule)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestInsertScheduleRuleRejectsInvalidRule(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow("product_id", "supplier_id", "Product Name", 10, 5000, "USD", 0, "hour", "START_TIME", "{VOUCHER,TICKET}", "{QRCODE,CODE128,PDF_URL}"))
+	mock.ExpectQuery("SELECT (.+) FROM options WHERE product_id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(optionColumns))
+
+	err := NewPostgresStore(db).InsertScheduleRule(model.ScheduleRule{ID: "rule_id", ProductId: "product_id", ValidFrom: date(2024, 6, 1)})
+	if err == nil {
+		t.Fatalf("expected an error for a START_TIME rule without start times")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestMaterializeSchedule(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	day := date(2024, 6, 3)
+	at := func(hour int) time.Time { return time.Date(2024, 6, 3, hour, 0, 0, 0, time.UTC) }
+
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow("product_id", "supplier_id", "Product Name", 10, 5000, "USD", 0, "hour", "START_TIME", "{VOUCHER,TICKET}", "{QRCODE,CODE128,PDF_URL}"))
+	mock.ExpectQuery("SELECT (.+) FROM options WHERE product_id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(optionColumns))
+	mock.ExpectQuery("SELECT (.+) FROM suppliers WHERE id = \\$1").WithArgs("supplier_id").
+		WillReturnRows(sqlmock.NewRows(supplierColumns).AddRow("supplier_id", "Supplier", "http://localhost:8080", nil, nil, nil, nil, "UTC"))
+	mock.ExpectQuery("SELECT (.+) FROM schedule_rules WHERE product_id = \\$1").WithArgs("product_id").
+		WillReturnRows(sqlmock.NewRows(scheduleRuleColumns).
+			AddRow("rule_id", "product_id", allWeekdays, "{09:00,11:00,13:00}", 60, day, nil, "{}", nil, nil, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM availabilities a WHERE a.product_id = \\$1 AND a.local_date BETWEEN \\$2 AND \\$3 FOR UPDATE").
+		WithArgs("product_id", day, day).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "local_date_time_start", "schedule_rule_id", "status", "exists", "capacity", "vacancies"}).
+			AddRow("unbooked", at(9), "rule_id", "SOLD_OUT", false, 10, 0).
+			AddRow("booked", at(11), "rule_id", "AVAILABLE", true, 10, 8).
+			AddRow("stale", at(15), "rule_id", "AVAILABLE", false, 10, 10))
+	mock.ExpectExec("DELETE FROM availabilities WHERE id = \\$1").WithArgs("stale").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE availabilities SET local_date_time_end").
+		WithArgs(at(10), 10, 0, "SOLD_OUT", false, int64(5000), "USD", "rule_id", "unbooked").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO availabilities").
+		WithArgs(sqlmock.AnyArg(), day, at(13), at(14), "AVAILABLE", "product_id", 10, 10, true, int64(5000), "USD", "rule_id").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	created, err := NewPostgresStore(db).MaterializeSchedule("product_id", day, day)
+	if err != nil {
+		t.Fatalf("error was not expected while materializing schedule: %s", err)
+	}
+	if created != 1 {
+		t.Errorf("expected 1 availability created, got %d", created)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
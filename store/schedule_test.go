@@ -0,0 +1,138 @@
+package store
+
+import (
+	"errors"
+	"octo-api/model"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestWeekdayMask(t *testing.T) {
+	mask, err := weekdayMask([]string{"monday", "FRIDAY"})
+	if err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if mask != 1<<time.Monday|1<<time.Friday {
+		t.Errorf("unexpected mask %07b", mask)
+	}
+	if names := weekdayNames(mask); len(names) != 2 || names[0] != "MONDAY" || names[1] != "FRIDAY" {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	if mask, _ := weekdayMask(nil); mask != allWeekdays {
+		t.Errorf("expected every day without names, got %07b", mask)
+	}
+	if _, err := weekdayMask([]string{"MON"}); !errors.Is(err, ErrInvalidScheduleRule) {
+		t.Errorf("expected ErrInvalidScheduleRule, got %v", err)
+	}
+}
+
+func TestScheduleWindow(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("timezone data not available: %s", err)
+	}
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("timezone data not available: %s", err)
+	}
+
+	// 20:00 UTC on the 1st is already the 2nd in Tokyo but still the 1st in Los Angeles
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	if from, until := ScheduleWindow(now, tokyo, 7); !from.Equal(date(2024, 3, 2)) || !until.Equal(date(2024, 3, 9)) {
+		t.Errorf("expected the 2nd to the 9th in Tokyo, got %s to %s", from, until)
+	}
+	if from, _ := ScheduleWindow(now, losAngeles, 7); !from.Equal(date(2024, 3, 1)) {
+		t.Errorf("expected the window to start on the 1st in Los Angeles, got %s", from)
+	}
+}
+
+func TestExpandSchedule(t *testing.T) {
+	product := model.Product{ID: "product_id", Capacity: 10, Price: 5000, Currency: "USD", AvailabilityType: model.AvailabilityTypeStartTime}
+	summerEnd := date(2024, 6, 30)
+	capacity, price := 4, int64(7500)
+	rules := []model.ScheduleRule{
+		// Weekday tours all season except a blackout
+		{ID: "weekdays", Weekdays: []string{"MONDAY", "WEDNESDAY"}, StartTimes: []string{"09:00", "14:00"}, DurationMinutes: 120,
+			ValidFrom: date(2024, 6, 1), ValidUntil: &summerEnd, BlackoutDates: []time.Time{date(2024, 6, 5)}},
+		// A smaller, dearer evening tour every day, also at 14:00 which the first rule keeps
+		{ID: "evenings", StartTimes: []string{"14:00", "19:00"}, DurationMinutes: 60, ValidFrom: date(2024, 6, 1),
+			Capacity: &capacity, Price: &price, Currency: "EUR"},
+	}
+
+	// Monday 3rd, Tuesday 4th and the blacked out Wednesday 5th
+	slots, err := expandSchedule(rules, product, time.UTC, date(2024, 6, 3), date(2024, 6, 5))
+	if err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+
+	type want struct {
+		start    string
+		ruleID   string
+		capacity int
+		price    int64
+		currency string
+	}
+	wants := []want{
+		{"2024-06-03T09:00:00Z", "weekdays", 10, 5000, "USD"},
+		{"2024-06-03T14:00:00Z", "weekdays", 10, 5000, "USD"},
+		{"2024-06-03T19:00:00Z", "evenings", 4, 7500, "EUR"},
+		{"2024-06-04T14:00:00Z", "evenings", 4, 7500, "EUR"},
+		{"2024-06-04T19:00:00Z", "evenings", 4, 7500, "EUR"},
+		{"2024-06-05T14:00:00Z", "evenings", 4, 7500, "EUR"},
+		{"2024-06-05T19:00:00Z", "evenings", 4, 7500, "EUR"},
+	}
+	if len(slots) != len(wants) {
+		t.Fatalf("expected %d slots, got %d", len(wants), len(slots))
+	}
+	for i, w := range wants {
+		sl := slots[i]
+		if sl.start.Format(time.RFC3339) != w.start || sl.ruleID != w.ruleID || sl.capacity != w.capacity || sl.price.Amount != w.price || sl.price.Currency != w.currency {
+			t.Errorf("slot %d: expected %+v, got %s %s %d %d %s", i, w, sl.start.Format(time.RFC3339), sl.ruleID, sl.capacity, sl.price.Amount, sl.price.Currency)
+		}
+	}
+
+	if slots, _ := expandSchedule(rules[:1], product, time.UTC, date(2024, 7, 1), date(2024, 7, 3)); len(slots) != 0 {
+		t.Errorf("expected no slots after the season, got %d", len(slots))
+	}
+}
+
+func TestPlanSchedule(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 6, 3, hour, 0, 0, 0, time.UTC) }
+	wanted := []scheduledSlot{
+		{slot: slot{start: at(9)}, ruleID: "rule", capacity: 4},
+		{slot: slot{start: at(11)}, ruleID: "rule"},
+		{slot: slot{start: at(13)}, ruleID: "rule"},
+		{slot: slot{start: at(15)}, ruleID: "rule"},
+	}
+	existing := []existingSlot{
+		{id: "kept", start: at(9), ruleID: "rule", capacity: 6, vacancies: 3},
+		{id: "booked", start: at(11), ruleID: "rule", booked: true},
+		{id: "one_off", start: at(13)},
+		{id: "stale", start: at(17), ruleID: "rule"},
+		{id: "stale_booked", start: at(18), ruleID: "rule", booked: true},
+	}
+
+	plan := planSchedule(existing, wanted)
+	if len(plan.updates) != 1 || plan.updates[0].id != "kept" {
+		t.Errorf("expected to update only kept, got %+v", plan.updates)
+	} else if plan.updates[0].vacancies != 1 {
+		t.Errorf("expected vacancies to shrink with the capacity to 1, got %d", plan.updates[0].vacancies)
+	}
+
+	// Vacancies never drop below zero
+	existing[0].vacancies = 1
+	if plan := planSchedule(existing, wanted); plan.updates[0].vacancies != 0 {
+		t.Errorf("expected vacancies to stop at 0, got %d", plan.updates[0].vacancies)
+	}
+	if len(plan.deletes) != 1 || plan.deletes[0] != "stale" {
+		t.Errorf("expected to delete only stale, got %v", plan.deletes)
+	}
+	if len(plan.inserts) != 1 || !plan.inserts[0].start.Equal(at(15)) {
+		t.Errorf("expected to insert only the 15:00 slot, got %+v", plan.inserts)
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"octo-api/model"
+)
+
+// GetSuppliers returns every supplier.
+func (s *PostgresStore) GetSuppliers() ([]model.Supplier, error) {
+	rows, err := s.db.Query("SELECT id, name, endpoint, contact_website, contact_email, contact_telephone, contact_address, timezone FROM suppliers ORDER BY id")
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []model.Supplier
+	for rows.Next() {
+		var sp model.Supplier
+		if err := rows.Scan(
+			&sp.ID,
+			&sp.Name,
+			&sp.Endpoint,
+			&sp.Contact.Website,
+			&sp.Contact.Email,
+			&sp.Contact.Telephone,
+			&sp.Contact.Address,
+			&sp.Timezone,
+		); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		suppliers = append(suppliers, sp)
+	}
+	return suppliers, nil
+}
+
+// GetSupplier returns the supplier with the given ID.
+func (s *PostgresStore) GetSupplier(supplierID string) (*model.Supplier, error) {
+	var sp model.Supplier
+	err := s.db.QueryRow(
+		"SELECT id, name, endpoint, contact_website, contact_email, contact_telephone, contact_address, timezone FROM suppliers WHERE id = $1",
+		supplierID,
+	).Scan(
+		&sp.ID,
+		&sp.Name,
+		&sp.Endpoint,
+		&sp.Contact.Website,
+		&sp.Contact.Email,
+		&sp.Contact.Telephone,
+		&sp.Contact.Address,
+		&sp.Timezone,
+	)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	return &sp, nil
+}
+
+// InsertSupplier stores a new supplier.
+func (s *PostgresStore) InsertSupplier(supplier model.Supplier) error {
+	supplierStmt := "INSERT INTO suppliers (id, name, endpoint, contact_website, contact_email, contact_telephone, contact_address, timezone) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	_, err := s.db.Exec(
+		supplierStmt,
+		supplier.ID,
+		supplier.Name,
+		supplier.Endpoint,
+		supplier.Contact.Website,
+		supplier.Contact.Email,
+		supplier.Contact.Telephone,
+		supplier.Contact.Address,
+		supplier.Timezone,
+	)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	return nil
+}
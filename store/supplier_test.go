@@ -0,0 +1,70 @@
+package store
+
+import (
+	"octo-api/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var supplierColumns = []string{"id", "name", "endpoint", "contact_website", "contact_email", "contact_telephone", "contact_address", "timezone"}
+
+func TestGetSuppliers(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM suppliers ORDER BY id").
+		WillReturnRows(sqlmock.NewRows(supplierColumns).
+			AddRow("supplier_id", "Supplier", "https://api.example.com", "https://example.com", "hello@example.com", nil, nil, "Europe/London"))
+
+	suppliers, err := NewPostgresStore(db).GetSuppliers()
+	if err != nil {
+		t.Fatalf("error was not expected while fetching suppliers: %s", err)
+	}
+	if len(suppliers) != 1 {
+		t.Fatalf("expected 1 supplier, got %d", len(suppliers))
+	}
+	if suppliers[0].Contact.Email == nil || *suppliers[0].Contact.Email != "hello@example.com" || suppliers[0].Contact.Telephone != nil {
+		t.Errorf("unexpected supplier contact %+v", suppliers[0].Contact)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestGetSupplier(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM suppliers WHERE id = \\$1").WithArgs("supplier_id").
+		WillReturnRows(sqlmock.NewRows(supplierColumns).
+			AddRow("supplier_id", "Supplier", "https://api.example.com", nil, nil, nil, nil, "UTC"))
+
+	_, err := NewPostgresStore(db).GetSupplier("supplier_id")
+	if err != nil {
+		t.Errorf("error was not expected while fetching supplier by ID: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestInsertSupplier(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO suppliers").
+		WithArgs("supplier_id", "Supplier", "https://api.example.com", nil, nil, nil, nil, "UTC").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := NewPostgresStore(db).InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Endpoint: "https://api.example.com", Timezone: "UTC"})
+	if err != nil {
+		t.Errorf("error was not expected while inserting supplier: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"octo-api/model"
+	"sort"
+	"time"
+)
+
+// ErrInvalidTimeslots is returned when the requested timeslots do not fit the
+// product's availability type.
+var ErrInvalidTimeslots = errors.New("invalid timeslots")
+
+// Timeslots describes the availabilities AddAvailability creates on each day.
+type Timeslots struct {
+	// StartTimes are local "15:04" times in the supplier's timezone. START_TIME
+	// products need at least one; OPENING_HOURS products take at most one, the
+	// opening time, and open at midnight otherwise.
+	StartTimes []string
+	// Duration is the length of each slot. Zero runs to the end of the day.
+	Duration time.Duration
+}
+
+// slot is one availability window.
+type slot struct {
+	start, end time.Time
+}
+
+// slots returns the windows of the given local day in loc, ordered by start.
+func (t Timeslots) slots(day time.Time, loc *time.Location, availabilityType string) ([]slot, error) {
+	startTimes := t.StartTimes
+	switch availabilityType {
+	case model.AvailabilityTypeStartTime:
+		if len(startTimes) == 0 {
+			return nil, fmt.Errorf("%w: START_TIME products need at least one start time", ErrInvalidTimeslots)
+		}
+	case model.AvailabilityTypeOpeningHours, "":
+		if len(startTimes) > 1 {
+			return nil, fmt.Errorf("%w: OPENING_HOURS products take a single opening time", ErrInvalidTimeslots)
+		}
+		if len(startTimes) == 0 {
+			startTimes = []string{"00:00"}
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown availability type %q", ErrInvalidTimeslots, availabilityType)
+	}
+	if t.Duration < 0 {
+		return nil, fmt.Errorf("%w: duration must not be negative", ErrInvalidTimeslots)
+	}
+
+	endOfDay := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+
+	seen := make(map[string]bool, len(startTimes))
+	slots := make([]slot, 0, len(startTimes))
+	for _, startTime := range startTimes {
+		clock, err := time.Parse("15:04", startTime)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid start time %q, use HH:MM", ErrInvalidTimeslots, startTime)
+		}
+		if seen[clock.Format("15:04")] {
+			return nil, fmt.Errorf("%w: duplicate start time %q", ErrInvalidTimeslots, startTime)
+		}
+		seen[clock.Format("15:04")] = true
+
+		start := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		end := endOfDay
+		if t.Duration > 0 {
+			end = start.Add(t.Duration)
+		}
+		slots = append(slots, slot{start: start, end: end})
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].start.Before(slots[j].start) })
+	return slots, nil
+}
+
+// loadLocation returns the named timezone, falling back to UTC when it is
+// empty or unknown.
+func loadLocation(timezone string) *time.Location {
+	if loc, err := time.LoadLocation(timezone); err == nil && timezone != "" {
+		return loc
+	}
+	return time.UTC
+}
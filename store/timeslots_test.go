@@ -0,0 +1,74 @@
+package store
+
+import (
+	"errors"
+	"octo-api/model"
+	"testing"
+	"time"
+)
+
+func TestTimeslotsSlots(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("error was not expected while loading timezone: %s", err)
+	}
+	// The clocks go forward on 2024-03-31, a 23 hour day in London
+	day := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		timeslots        Timeslots
+		availabilityType string
+		want             []string
+	}{
+		{"whole day", Timeslots{}, model.AvailabilityTypeOpeningHours, []string{"2024-03-31T00:00:00Z/2024-04-01T00:00:00+01:00"}},
+		{"opening time", Timeslots{StartTimes: []string{"10:00"}, Duration: 8 * time.Hour}, "", []string{"2024-03-31T10:00:00+01:00/2024-03-31T18:00:00+01:00"}},
+		{"start times", Timeslots{StartTimes: []string{"17:00", "09:00", "13:00"}, Duration: 2 * time.Hour}, model.AvailabilityTypeStartTime, []string{
+			"2024-03-31T09:00:00+01:00/2024-03-31T11:00:00+01:00",
+			"2024-03-31T13:00:00+01:00/2024-03-31T15:00:00+01:00",
+			"2024-03-31T17:00:00+01:00/2024-03-31T19:00:00+01:00",
+		}},
+		{"start time until midnight", Timeslots{StartTimes: []string{"21:30"}}, model.AvailabilityTypeStartTime, []string{"2024-03-31T21:30:00+01:00/2024-04-01T00:00:00+01:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots, err := tt.timeslots.slots(day, london, tt.availabilityType)
+			if err != nil {
+				t.Fatalf("error was not expected: %s", err)
+			}
+			if len(slots) != len(tt.want) {
+				t.Fatalf("expected %d slots, got %d", len(tt.want), len(slots))
+			}
+			for i, sl := range slots {
+				if got := sl.start.Format(time.RFC3339) + "/" + sl.end.Format(time.RFC3339); got != tt.want[i] {
+					t.Errorf("slot %d: expected %s, got %s", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeslotsSlotsInvalid(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		timeslots        Timeslots
+		availabilityType string
+	}{
+		{"start time product without start times", Timeslots{}, model.AvailabilityTypeStartTime},
+		{"opening hours with several start times", Timeslots{StartTimes: []string{"09:00", "13:00"}}, model.AvailabilityTypeOpeningHours},
+		{"malformed start time", Timeslots{StartTimes: []string{"9am"}}, model.AvailabilityTypeStartTime},
+		{"out of range start time", Timeslots{StartTimes: []string{"24:00"}}, model.AvailabilityTypeStartTime},
+		{"duplicate start time", Timeslots{StartTimes: []string{"09:00", "09:00"}}, model.AvailabilityTypeStartTime},
+		{"negative duration", Timeslots{StartTimes: []string{"09:00"}, Duration: -time.Hour}, model.AvailabilityTypeStartTime},
+		{"unknown availability type", Timeslots{}, "ALL_DAY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.timeslots.slots(day, time.UTC, tt.availabilityType); !errors.Is(err, ErrInvalidTimeslots) {
+				t.Errorf("expected ErrInvalidTimeslots, got %v", err)
+			}
+		})
+	}
+}
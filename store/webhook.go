@@ -0,0 +1,264 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"octo-api/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// recordBookingEvent writes an event about a booking to the outbox inside tx,
+// with the booking as tx has left it.
+func recordBookingEvent(tx *sql.Tx, event, bookingID string) error {
+	booking, err := getBooking(tx, "id = $1", bookingID)
+	if err != nil {
+		return err
+	}
+	return recordEvent(tx, event, bookingID, booking)
+}
+
+// recordAvailabilityEvent writes availability.updated to the outbox inside tx,
+// with the availability as tx has left it.
+func recordAvailabilityEvent(tx *sql.Tx, availabilityID string) error {
+	a, err := scanAvailability(tx.QueryRow(availabilitySelect+" WHERE a.id = $1", availabilityID))
+	if err != nil {
+		return err
+	}
+	return recordEvent(tx, model.WebhookEventAvailabilityUpdated, availabilityID, a)
+}
+
+// recordEvent writes an event about resourceID to the outbox inside tx, so it
+// is only sent if the change it describes commits.
+func recordEvent(tx *sql.Tx, event, resourceID string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO outbox_events (event, resource_id, data) VALUES ($1, $2, $3)", event, resourceID, string(encoded))
+	return err
+}
+
+// GetWebhookSubscriptions returns every webhook subscription, oldest first.
+func (s *PostgresStore) GetWebhookSubscriptions() ([]model.WebhookSubscription, error) {
+	rows, err := s.db.Query("SELECT id, url, events, secret, created_at FROM webhook_subscriptions ORDER BY created_at, id")
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []model.WebhookSubscription{}
+	for rows.Next() {
+		var sub model.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.URL, pq.Array(&sub.Events), &sub.Secret, &sub.CreatedAt); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		sub.CreatedAt = sub.CreatedAt.UTC()
+		subscriptions = append(subscriptions, sub)
+	}
+	return subscriptions, rows.Err()
+}
+
+// InsertWebhookSubscription stores a new webhook subscription.
+func (s *PostgresStore) InsertWebhookSubscription(sub model.WebhookSubscription) error {
+	_, err := s.db.Exec(
+		"INSERT INTO webhook_subscriptions (id, url, events, secret, created_at) VALUES ($1, $2, $3, $4, $5)",
+		sub.ID, sub.URL, pq.Array(sub.Events), sub.Secret, sub.CreatedAt,
+	)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	return err
+}
+
+// DeleteWebhookSubscription removes a subscription together with its
+// deliveries, including those still pending.
+func (s *PostgresStore) DeleteWebhookSubscription(subscriptionID string) error {
+	result, err := s.db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", subscriptionID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetOutboxEvents returns up to limit events that have not been fanned out
+// yet, in the order they were recorded.
+func (s *PostgresStore) GetOutboxEvents(limit int) ([]model.OutboxEvent, error) {
+	rows, err := s.db.Query("SELECT id, event, resource_id, data, created_at FROM outbox_events WHERE dispatched_at IS NULL ORDER BY id LIMIT $1", limit)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.OutboxEvent{}
+	for rows.Next() {
+		var e model.OutboxEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.Event, &e.ResourceId, &data, &e.CreatedAt); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		e.Data = data
+		e.CreatedAt = e.CreatedAt.UTC()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// QueueWebhookDeliveries stores the deliveries of an outbox event and marks
+// the event dispatched in one transaction. A delivery the event already has
+// for a subscription is kept, so fanning out an event twice sends it once.
+func (s *PostgresStore) QueueWebhookDeliveries(eventID int64, deliveries []model.WebhookDelivery) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	insertStmt := "INSERT INTO webhook_deliveries (event_id, subscription_id, payload, next_attempt_at) VALUES ($1, $2, $3, $4) ON CONFLICT (event_id, subscription_id) DO NOTHING"
+	for _, d := range deliveries {
+		if _, err := tx.Exec(insertStmt, eventID, d.SubscriptionId, []byte(d.Payload), d.NextAttemptAt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE outbox_events SET dispatched_at = NOW() WHERE id = $1", eventID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deliveryColumns are the columns scanDelivery reads, qualified for a join of
+// webhook_deliveries d, outbox_events e and webhook_subscriptions s.
+const deliveryColumns = "d.id, d.event_id, e.event, d.subscription_id, s.url, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at"
+
+// scanDelivery reads a row selected with deliveryColumns, followed by the
+// columns in extra.
+func scanDelivery(row scanner, extra ...interface{}) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	var payload []byte
+	var statusCode sql.NullInt64
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+	err := row.Scan(append([]interface{}{
+		&d.ID,
+		&d.EventId,
+		&d.Event,
+		&d.SubscriptionId,
+		&d.URL,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&statusCode,
+		&lastError,
+		&deliveredAt,
+		&d.CreatedAt,
+	}, extra...)...)
+	if err != nil {
+		return d, err
+	}
+	d.Payload = payload
+	d.NextAttemptAt, d.CreatedAt = d.NextAttemptAt.UTC(), d.CreatedAt.UTC()
+	d.LastStatusCode, d.LastError = int(statusCode.Int64), lastError.String
+	d.DeliveredAt = utcTime(deliveredAt)
+	return d, nil
+}
+
+// ClaimWebhookDeliveries returns up to limit PENDING deliveries due at now,
+// with the secret of their subscription, and holds them until until: their
+// next attempt moves there, so other workers skip them meanwhile and a worker
+// that dies mid-delivery only delays them.
+func (s *PostgresStore) ClaimWebhookDeliveries(now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	claimStmt := "UPDATE webhook_deliveries d SET next_attempt_at = $2 FROM outbox_events e, webhook_subscriptions s " +
+		"WHERE d.event_id = e.id AND d.subscription_id = s.id AND d.id IN (" +
+		"SELECT id FROM webhook_deliveries WHERE status = 'PENDING' AND next_attempt_at <= $1 ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED" +
+		") RETURNING " + deliveryColumns + ", s.secret"
+	rows, err := s.db.Query(claimStmt, now, until, limit)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		var secret string
+		d, err := scanDelivery(rows, &secret)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		d.Secret = secret
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// SaveWebhookDelivery stores the outcome of a delivery attempt: its status,
+// attempts, next attempt and last response.
+func (s *PostgresStore) SaveWebhookDelivery(d model.WebhookDelivery) error {
+	updateStmt := "UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, delivered_at = $6 WHERE id = $7"
+	statusCode := sql.NullInt64{Int64: int64(d.LastStatusCode), Valid: d.LastStatusCode != 0}
+	_, err := s.db.Exec(updateStmt, d.Status, d.Attempts, d.NextAttemptAt, statusCode, nullString(d.LastError), d.DeliveredAt, d.ID)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	return err
+}
+
+// GetDeadWebhookDeliveries returns the deliveries that ran out of attempts,
+// oldest first.
+func (s *PostgresStore) GetDeadWebhookDeliveries() ([]model.WebhookDelivery, error) {
+	rows, err := s.db.Query("SELECT id, event_id, event, subscription_id, url, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at FROM webhook_dead_letters ORDER BY id")
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RetryWebhookDelivery moves a DEAD delivery back to PENDING with fresh
+// attempts, due at at. It returns sql.ErrNoRows when no dead delivery has the
+// ID.
+func (s *PostgresStore) RetryWebhookDelivery(deliveryID int64, at time.Time) error {
+	result, err := s.db.Exec("UPDATE webhook_deliveries SET status = 'PENDING', attempts = 0, next_attempt_at = $1 WHERE id = $2 AND status = 'DEAD'", at, deliveryID)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"octo-api/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var deliveryColumnNames = []string{"id", "event_id", "event", "subscription_id", "url", "payload", "status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at", "created_at"}
+
+func TestQueueWebhookDeliveries(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	for _, sub := range []string{"sub_a", "sub_b"} {
+		mock.ExpectExec("INSERT INTO webhook_deliveries (.+) ON CONFLICT \\(event_id, subscription_id\\) DO NOTHING").
+			WithArgs(int64(7), sub, []byte(`{"id":7}`), now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec("UPDATE outbox_events SET dispatched_at = NOW\\(\\) WHERE id = \\$1").
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deliveries := []model.WebhookDelivery{
+		{SubscriptionId: "sub_a", Payload: json.RawMessage(`{"id":7}`), NextAttemptAt: now},
+		{SubscriptionId: "sub_b", Payload: json.RawMessage(`{"id":7}`), NextAttemptAt: now},
+	}
+	if err := NewPostgresStore(db).QueueWebhookDeliveries(7, deliveries); err != nil {
+		t.Fatalf("error was not expected while queueing deliveries: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestClaimWebhookDeliveries(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	until := now.Add(time.Minute)
+	mock.ExpectQuery("UPDATE webhook_deliveries d SET next_attempt_at = \\$2 (.+) FOR UPDATE SKIP LOCKED\\) RETURNING (.+), s.secret").
+		WithArgs(now, until, 20).
+		WillReturnRows(sqlmock.NewRows(append(deliveryColumnNames, "secret")).
+			AddRow(1, 7, "booking.confirmed", "sub_a", "https://crm.example.com/hooks", []byte(`{"id":7}`), "PENDING", 2, until, 500, "unexpected status 500", nil, now, "whsec"))
+
+	deliveries, err := NewPostgresStore(db).ClaimWebhookDeliveries(now, until, 20)
+	if err != nil {
+		t.Fatalf("error was not expected while claiming deliveries: %s", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(deliveries))
+	}
+	d := deliveries[0]
+	if d.Event != "booking.confirmed" || d.Secret != "whsec" || d.Attempts != 2 || d.LastStatusCode != 500 || string(d.Payload) != `{"id":7}` || d.DeliveredAt != nil {
+		t.Errorf("unexpected delivery %+v", d)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestRetryWebhookDelivery(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = 'PENDING', attempts = 0, next_attempt_at = \\$1 WHERE id = \\$2 AND status = 'DEAD'").
+		WithArgs(now, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = 'PENDING'").
+		WithArgs(now, int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s := NewPostgresStore(db)
+	if err := s.RetryWebhookDelivery(1, now); err != nil {
+		t.Fatalf("error was not expected while retrying delivery: %s", err)
+	}
+	if err := s.RetryWebhookDelivery(2, now); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a delivery that is not dead, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %s", err)
+	}
+}
+
+func TestMemoryStoreWebhookOutbox(t *testing.T) {
+	s, availability := newSeededMemoryStore(t, 10)
+
+	sub := model.WebhookSubscription{ID: "sub_a", URL: "https://crm.example.com/hooks", Events: []string{model.WebhookEventBookingCreated}, Secret: "whsec"}
+	if err := s.InsertWebhookSubscription(sub); err != nil {
+		t.Fatalf("error was not expected while inserting subscription: %s", err)
+	}
+
+	// A booking records its own event and the availability it takes from
+	booking := model.Booking{ID: "booking_id", Status: model.BookingStatusReserved, AvailabilityId: availability.ID, Units: 2}
+	if err := s.CreateBooking(booking); err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+	if err := s.CancelBooking("booking_id", ""); err != nil {
+		t.Fatalf("error was not expected while cancelling booking: %s", err)
+	}
+	events, _ := s.GetOutboxEvents(10)
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Event+" "+e.ResourceId)
+	}
+	want := []string{
+		"booking.created booking_id",
+		"availability.updated " + availability.ID,
+		"booking.cancelled booking_id",
+		"availability.updated " + availability.ID,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected events %v, got %v", want, got)
+			break
+		}
+	}
+
+	// Fanning an event out twice queues it once, and claims hold it
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	delivery := model.WebhookDelivery{SubscriptionId: "sub_a", Payload: json.RawMessage(`{}`), NextAttemptAt: now}
+	for i := 0; i < 2; i++ {
+		if err := s.QueueWebhookDeliveries(events[0].ID, []model.WebhookDelivery{delivery}); err != nil {
+			t.Fatalf("error was not expected while queueing deliveries: %s", err)
+		}
+	}
+	if pending, _ := s.GetOutboxEvents(10); len(pending) != 3 {
+		t.Errorf("expected 3 events left to fan out, got %d", len(pending))
+	}
+	claimed, _ := s.ClaimWebhookDeliveries(now, now.Add(time.Minute), 10)
+	if len(claimed) != 1 || claimed[0].Event != model.WebhookEventBookingCreated || claimed[0].Secret != "whsec" || claimed[0].URL != sub.URL {
+		t.Fatalf("expected the queued delivery to be claimed, got %+v", claimed)
+	}
+	if again, _ := s.ClaimWebhookDeliveries(now, now.Add(time.Minute), 10); len(again) != 0 {
+		t.Errorf("expected a claimed delivery to be held, got %+v", again)
+	}
+
+	// Dead deliveries can be retried, and go with their subscription
+	dead := claimed[0]
+	dead.Status, dead.Attempts = model.WebhookDeliveryDead, 10
+	if err := s.SaveWebhookDelivery(dead); err != nil {
+		t.Fatalf("error was not expected while saving delivery: %s", err)
+	}
+	if letters, _ := s.GetDeadWebhookDeliveries(); len(letters) != 1 || letters[0].Attempts != 10 {
+		t.Errorf("expected 1 dead delivery, got %+v", letters)
+	}
+	if err := s.RetryWebhookDelivery(dead.ID, now); err != nil {
+		t.Fatalf("error was not expected while retrying delivery: %s", err)
+	}
+	if err := s.RetryWebhookDelivery(dead.ID, now); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows retrying a pending delivery, got %v", err)
+	}
+	if err := s.DeleteWebhookSubscription("sub_a"); err != nil {
+		t.Fatalf("error was not expected while deleting subscription: %s", err)
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(now, now, 10); len(claimed) != 0 {
+		t.Errorf("expected deliveries to go with their subscription, got %+v", claimed)
+	}
+}
@@ -0,0 +1,117 @@
+package voucher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page layout in PDF points: A4 with 2 cm margins.
+const (
+	pageWidth   = 595
+	pageHeight  = 842
+	margin      = 56
+	headingSize = 18
+	bodySize    = 11
+)
+
+// line is a line of text and its font size.
+type line struct {
+	text string
+	size float64
+}
+
+// writePDF writes lines as a PDF document set in Helvetica, wrapping long
+// lines and starting new pages as needed.
+func writePDF(w io.Writer, lines []line) error {
+	// Lay the lines out on pages
+	var pages []string
+	var content strings.Builder
+	y := float64(pageHeight - margin)
+	for _, l := range lines {
+		for _, text := range wrap(l.text, l.size) {
+			leading := l.size * 1.4
+			if y-leading < margin {
+				pages = append(pages, content.String())
+				content.Reset()
+				y = pageHeight - margin
+			}
+			y -= leading
+			fmt.Fprintf(&content, "BT /F1 %g Tf %d %.1f Td (%s) Tj ET\n", l.size, margin, y, escape(text))
+		}
+	}
+	pages = append(pages, content.String())
+
+	// Objects 1 to 3 are the catalog, page tree and font; each page is
+	// followed by its content stream
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// wrap splits text into lines that fit the page at the given font size. It
+// assumes an average Helvetica glyph is half as wide as the font size.
+func wrap(text string, size float64) []string {
+	width := int((pageWidth - 2*margin) / (size / 2))
+	var lines []string
+	var current string
+	for _, word := range strings.Fields(text) {
+		if current != "" && len(current)+1+len(word) > width {
+			lines = append(lines, current)
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += word
+	}
+	return append(lines, current)
+}
+
+// escape makes text safe inside a PDF string literal. Characters outside
+// Latin-1 cannot be set in the standard fonts and become "?".
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,25 @@
+// Package voucher issues the references printed on booking vouchers and unit
+// tickets, and renders vouchers as PDF documents from a text template.
+package voucher
+
+import "crypto/rand"
+
+// alphabet is Crockford's base32, which leaves out I, L, O and U so
+// references survive being read out or typed in by hand.
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ReferenceLength is the number of characters in a reference, 50 random bits.
+const ReferenceLength = 10
+
+// NewReference returns a random short reference, e.g. "7K3QX9MZ2D". It is
+// both the code printed on a voucher and the payload of its barcodes.
+func NewReference() string {
+	b := make([]byte, ReferenceLength)
+	if _, err := rand.Read(b); err != nil {
+		panic("voucher: reading random bytes: " + err.Error())
+	}
+	for i := range b {
+		b[i] = alphabet[b[i]%byte(len(alphabet))]
+	}
+	return string(b)
+}
@@ -0,0 +1,95 @@
+package voucher
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+//go:embed voucher.tmpl
+var defaultTemplate string
+
+// Data is what a voucher shows. Ticket is set when the document is the ticket
+// of a single unit rather than the voucher of the whole booking.
+type Data struct {
+	Supplier      string
+	Product       string
+	Option        string
+	Start         string
+	BookingID     string
+	Reference     string
+	LeadTraveller string
+	Ticket        bool
+	Tickets       []Ticket
+}
+
+// Ticket is one unit listed on a voucher.
+type Ticket struct {
+	Unit      string
+	Reference string
+	Guest     string
+}
+
+// Template lays out a voucher. Each line the text template produces becomes a
+// line of the PDF; lines starting with "# " are set as headings.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse returns a Template from the text of a Go text/template.
+func Parse(text string) (*Template, error) {
+	tmpl, err := template.New("voucher").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Default returns the built-in voucher template.
+func Default() *Template {
+	t, err := Parse(defaultTemplate)
+	if err != nil {
+		panic("voucher: parsing the default template: " + err.Error())
+	}
+	return t
+}
+
+// TemplateFromEnv reads the template file named by VOUCHER_TEMPLATE, or
+// returns the built-in template when it is unset.
+func TemplateFromEnv() (*Template, error) {
+	path := os.Getenv("VOUCHER_TEMPLATE")
+	if path == "" {
+		return Default(), nil
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("VOUCHER_TEMPLATE: %w", err)
+	}
+	t, err := Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("VOUCHER_TEMPLATE: %w", err)
+	}
+	return t, nil
+}
+
+// Render writes data to w as a PDF document.
+func (t *Template) Render(w io.Writer, data Data) error {
+	var text bytes.Buffer
+	if err := t.tmpl.Execute(&text, data); err != nil {
+		return err
+	}
+
+	var lines []line
+	for _, l := range strings.Split(strings.TrimRight(text.String(), "\n"), "\n") {
+		if strings.HasPrefix(l, "# ") {
+			lines = append(lines, line{text: strings.TrimPrefix(l, "# "), size: headingSize})
+			continue
+		}
+		lines = append(lines, line{text: l, size: bodySize})
+	}
+	return writePDF(w, lines)
+}
@@ -0,0 +1,120 @@
+package voucher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewReference(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		ref := NewReference()
+		if len(ref) != ReferenceLength || strings.Trim(ref, alphabet) != "" {
+			t.Fatalf("unexpected reference %q", ref)
+		}
+		if seen[ref] {
+			t.Fatalf("reference %q issued twice", ref)
+		}
+		seen[ref] = true
+	}
+}
+
+func TestRender(t *testing.T) {
+	var buf bytes.Buffer
+	err := Default().Render(&buf, Data{
+		Supplier:      "Harbour Tours",
+		Product:       "Boat Tour (Sunset)",
+		Start:         "Fri 1 Mar 2024 18:00",
+		BookingID:     "booking_id",
+		Reference:     "7K3QX9MZ2D",
+		LeadTraveller: "Zoë Lovelace",
+		Tickets:       []Ticket{{Unit: "Adult", Reference: "A1B2C3D4E5"}, {Unit: "Child", Reference: "F6G7H8J9K0", Guest: "Byron"}},
+	})
+	if err != nil {
+		t.Fatalf("error was not expected while rendering: %s", err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Errorf("expected a PDF document, got %q", pdf)
+	}
+	for _, want := range []string{"(Voucher 7K3QX9MZ2D)", `(Boat Tour \(Sunset\))`, `(Lead traveller: Zo\353 Lovelace)`, "(Child: F6G7H8J9K0 \\(Byron\\))", "/Count 1"} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("expected the PDF to contain %s", want)
+		}
+	}
+	checkXref(t, pdf)
+}
+
+func TestRenderPages(t *testing.T) {
+	tmpl, err := Parse("{{range .Tickets}}{{.Reference}}\n{{end}}")
+	if err != nil {
+		t.Fatalf("error was not expected while parsing: %s", err)
+	}
+	var tickets []Ticket
+	for i := 0; i < 60; i++ {
+		tickets = append(tickets, Ticket{Reference: fmt.Sprintf("REF%03d", i)})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Render(&buf, Data{Tickets: tickets}); err != nil {
+		t.Fatalf("error was not expected while rendering: %s", err)
+	}
+	pdf := buf.String()
+	if !strings.Contains(pdf, "/Count 2") || !strings.Contains(pdf, "(REF059)") {
+		t.Errorf("expected the tickets to run onto a second page")
+	}
+	checkXref(t, pdf)
+}
+
+func TestTemplateFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "voucher.tmpl")
+	if err := os.WriteFile(path, []byte("# {{.Reference}}\n"), 0o644); err != nil {
+		t.Fatalf("error was not expected while writing template: %s", err)
+	}
+	t.Setenv("VOUCHER_TEMPLATE", path)
+
+	tmpl, err := TemplateFromEnv()
+	if err != nil {
+		t.Fatalf("error was not expected while loading template: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Render(&buf, Data{Reference: "7K3QX9MZ2D"}); err != nil {
+		t.Fatalf("error was not expected while rendering: %s", err)
+	}
+	if !strings.Contains(buf.String(), "/F1 18 Tf 56 760.8 Td (7K3QX9MZ2D)") {
+		t.Errorf("expected the reference as a heading, got %q", buf.String())
+	}
+
+	t.Setenv("VOUCHER_TEMPLATE", filepath.Join(t.TempDir(), "missing.tmpl"))
+	if _, err := TemplateFromEnv(); err == nil {
+		t.Errorf("expected an error for a missing template")
+	}
+}
+
+// checkXref checks that every cross-reference entry points at its object.
+func checkXref(t *testing.T, pdf string) {
+	t.Helper()
+
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if start == nil {
+		t.Fatalf("expected a startxref")
+	}
+	xref, _ := strconv.Atoi(start[1])
+	if !strings.HasPrefix(pdf[xref:], "xref\n") {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[offset:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:offset+10])
+		}
+	}
+}
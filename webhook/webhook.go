@@ -0,0 +1,291 @@
+// Package webhook delivers booking and availability events to webhook
+// subscribers. Stores write events to an outbox in the same transaction as the
+// change; a Dispatcher fans them out into one delivery per subscription and
+// sends each with an HMAC signature, retrying failures with exponential
+// backoff until they run out of attempts and become dead letters.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"octo-api/helper"
+	"octo-api/model"
+	"octo-api/store"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers sent with every delivery. Webhook-Id is the event ID, the same on
+// every retry, so receivers can drop duplicates.
+const (
+	IdHeader        = "Webhook-Id"
+	EventHeader     = "Webhook-Event"
+	TimestampHeader = "Webhook-Timestamp"
+	SignatureHeader = "Webhook-Signature"
+)
+
+// MaxBackoff caps the wait between two attempts of a delivery.
+const MaxBackoff = 6 * time.Hour
+
+// batchSize is how many events are fanned out, and deliveries sent, per
+// round.
+const batchSize = 20
+
+// Config controls how often the outbox is dispatched and how failed
+// deliveries are retried.
+type Config struct {
+	Interval    time.Duration
+	Timeout     time.Duration
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// ConfigFromEnv reads WEBHOOK_INTERVAL, WEBHOOK_TIMEOUT, WEBHOOK_MAX_ATTEMPTS
+// and WEBHOOK_BACKOFF.
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+	var err error
+
+	if cfg.Interval, err = helper.EnvDuration("WEBHOOK_INTERVAL", 5*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.Timeout, err = helper.EnvDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttempts, err = helper.EnvInt("WEBHOOK_MAX_ATTEMPTS", 10); err != nil {
+		return cfg, err
+	}
+	if cfg.Backoff, err = helper.EnvDuration("WEBHOOK_BACKOFF", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.Interval <= 0 || cfg.Timeout <= 0 || cfg.MaxAttempts <= 0 || cfg.Backoff <= 0 {
+		return cfg, fmt.Errorf("WEBHOOK_INTERVAL, WEBHOOK_TIMEOUT, WEBHOOK_MAX_ATTEMPTS and WEBHOOK_BACKOFF must be positive")
+	}
+	return cfg, nil
+}
+
+// RetryAfter returns how long to wait after a delivery's attempts-th failed
+// attempt: Backoff, doubling with every attempt, up to MaxBackoff.
+func (cfg Config) RetryAfter(attempts int) time.Duration {
+	wait := cfg.Backoff
+	for i := 1; i < attempts && wait < MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxBackoff {
+		return MaxBackoff
+	}
+	return wait
+}
+
+// Sign returns the Webhook-Signature of a delivery: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret, prefixed with
+// "sha256=". Receivers recompute it and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Payload is the body of a delivery. Data is the booking or availability the
+// event is about, as the change left it.
+type Payload struct {
+	ID        int64           `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher fans outbox events out to subscriptions and sends the
+// deliveries that are due.
+type Dispatcher struct {
+	repo   store.WebhookRepository
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// Option customises a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithHTTPClient replaces the client deliveries are sent with.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithClock replaces the wall clock used to sign and schedule deliveries.
+func WithClock(now func() time.Time) Option {
+	return func(d *Dispatcher) {
+		d.now = now
+	}
+}
+
+// NewDispatcher returns a Dispatcher that reads and records deliveries in
+// repo.
+func NewDispatcher(repo store.WebhookRepository, cfg Config, opts ...Option) *Dispatcher {
+	d := &Dispatcher{repo: repo, cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}, now: time.Now}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run dispatches the outbox every interval until ctx is done. It blocks;
+// start it in its own goroutine.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Dispatch(ctx); err != nil {
+				log.Printf("failed to dispatch webhooks: %v", err)
+			}
+		}
+	}
+}
+
+// Dispatch fans out the events waiting in the outbox, then sends every
+// delivery that is due.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	if err := d.fanOut(); err != nil {
+		return err
+	}
+	for {
+		// Hold claimed deliveries for longer than an attempt can take
+		now := d.now().UTC()
+		deliveries, err := d.repo.ClaimWebhookDeliveries(now, now.Add(2*d.cfg.Timeout), batchSize)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(deliveries))
+		for i := range deliveries {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = d.repo.SaveWebhookDelivery(d.send(ctx, deliveries[i]))
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fanOut queues a delivery of every waiting outbox event for each
+// subscription that wants it.
+func (d *Dispatcher) fanOut() error {
+	for {
+		events, err := d.repo.GetOutboxEvents(batchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		subscriptions, err := d.repo.GetWebhookSubscriptions()
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			body, err := json.Marshal(Payload{ID: e.ID, Event: e.Event, CreatedAt: e.CreatedAt, Data: e.Data})
+			if err != nil {
+				return err
+			}
+			var deliveries []model.WebhookDelivery
+			for _, sub := range subscriptions {
+				if !subscribed(sub, e.Event) {
+					continue
+				}
+				deliveries = append(deliveries, model.WebhookDelivery{SubscriptionId: sub.ID, Payload: body, NextAttemptAt: d.now().UTC()})
+			}
+			if err := d.repo.QueueWebhookDeliveries(e.ID, deliveries); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// subscribed reports whether sub asked for event.
+func subscribed(sub model.WebhookSubscription, event string) bool {
+	for _, e := range sub.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// send makes one attempt at a delivery and returns it updated with the
+// outcome: DELIVERED on a 2xx response, otherwise PENDING until the next
+// retry, or DEAD once it has used up its attempts.
+func (d *Dispatcher) send(ctx context.Context, delivery model.WebhookDelivery) model.WebhookDelivery {
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.LastStatusCode, delivery.LastError = 0, ""
+
+	err := d.post(ctx, &delivery, now)
+	if err == nil {
+		delivery.Status, delivery.DeliveredAt, delivery.NextAttemptAt = model.WebhookDeliveryDelivered, &now, now
+		return delivery
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		delivery.Status, delivery.NextAttemptAt = model.WebhookDeliveryDead, now
+		log.Printf("webhook delivery %d to %s is dead after %d attempts: %v", delivery.ID, delivery.URL, delivery.Attempts, err)
+		return delivery
+	}
+	delivery.Status, delivery.NextAttemptAt = model.WebhookDeliveryPending, now.Add(d.cfg.RetryAfter(delivery.Attempts))
+	return delivery
+}
+
+// post sends a delivery signed at now and records the response status on it.
+// Any status outside 2xx is an error.
+func (d *Dispatcher) post(ctx context.Context, delivery *model.WebhookDelivery, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdHeader, strconv.FormatInt(delivery.EventId, 10))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	delivery.LastStatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"octo-api/model"
+	"octo-api/money"
+	"octo-api/store"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	got := Sign("whsec_test", 1709283600, []byte(`{"id":1}`))
+	want := "sha256=d0ec3507057243137735dd0dacd05d6643dfa2e6fdab9ae55bc97f168d0607da"
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cfg := Config{Backoff: 30 * time.Second}
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{50, MaxBackoff},
+	} {
+		if got := cfg.RetryAfter(tt.attempts); got != tt.want {
+			t.Errorf("after %d attempts expected %s, got %s", tt.attempts, tt.want, got)
+		}
+	}
+}
+
+// newBookedStore returns a memory store with one reserved booking and a
+// subscription of url to events.
+func newBookedStore(t *testing.T, url string, events ...string) *store.MemoryStore {
+	t.Helper()
+
+	s := store.NewMemoryStore()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.InsertSupplier(model.Supplier{ID: "supplier_id", Name: "Supplier", Timezone: "UTC"}); err != nil {
+		t.Fatalf("error was not expected while inserting supplier: %s", err)
+	}
+	if err := s.InsertProduct(model.Product{ID: "product_id", SupplierId: "supplier_id", Name: "Product", Capacity: 10, Price: 5000, Currency: "USD"}); err != nil {
+		t.Fatalf("error was not expected while inserting product: %s", err)
+	}
+	if err := s.AddAvailability("product_id", day, day, store.Timeslots{}, money.New(5000, "USD")); err != nil {
+		t.Fatalf("error was not expected while adding availability: %s", err)
+	}
+	availabilities, err := s.GetProductAvailabilities("product_id", day, day)
+	if err != nil || len(availabilities) != 1 {
+		t.Fatalf("expected 1 availability, got %d (%v)", len(availabilities), err)
+	}
+	if err := s.InsertWebhookSubscription(model.WebhookSubscription{ID: "sub_id", URL: url, Events: events, Secret: "whsec_test"}); err != nil {
+		t.Fatalf("error was not expected while inserting subscription: %s", err)
+	}
+	booking := model.Booking{ID: "booking_id", Status: model.BookingStatusReserved, AvailabilityId: availabilities[0].ID, Units: 2, Price: 10000, Currency: "USD"}
+	if err := s.CreateBooking(booking); err != nil {
+		t.Fatalf("error was not expected while creating booking: %s", err)
+	}
+	return s
+}
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received, bodies = append(received, r), append(bodies, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	s := newBookedStore(t, srv.URL, model.WebhookEventBookingCreated, model.WebhookEventBookingConfirmed)
+	d := NewDispatcher(s, Config{Timeout: time.Second, MaxAttempts: 3, Backoff: time.Minute}, WithClock(func() time.Time { return now }))
+
+	if err := s.ConfirmBooking("booking_id", nil, nil); err != nil {
+		t.Fatalf("error was not expected while confirming booking: %s", err)
+	}
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatalf("error was not expected while dispatching: %s", err)
+	}
+	// Everything was sent, so a second round has nothing to do
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatalf("error was not expected while dispatching: %s", err)
+	}
+
+	// availability.updated was not subscribed to. Deliveries go out
+	// concurrently, so put them back in event order.
+	if len(received) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(received))
+	}
+	if received[0].Header.Get(IdHeader) > received[1].Header.Get(IdHeader) {
+		received[0], received[1] = received[1], received[0]
+		bodies[0], bodies[1] = bodies[1], bodies[0]
+	}
+	for i, want := range []struct{ event, status string }{
+		{model.WebhookEventBookingCreated, model.BookingStatusReserved},
+		{model.WebhookEventBookingConfirmed, model.BookingStatusConfirmed},
+	} {
+		r, body := received[i], bodies[i]
+		if r.Header.Get(EventHeader) != want.event || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if timestamp != now.Unix() || r.Header.Get(SignatureHeader) != Sign("whsec_test", timestamp, body) {
+			t.Errorf("expected a signature of the body at %d, got %v", now.Unix(), r.Header)
+		}
+
+		var payload struct {
+			ID    int64                   `json:"id"`
+			Event string                  `json:"event"`
+			Data  model.BookingPayload_Rs `json:"data"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("error was not expected while decoding payload: %s", err)
+		}
+		// Data is the booking as the event left it
+		if payload.Event != want.event || strconv.FormatInt(payload.ID, 10) != r.Header.Get(IdHeader) || payload.Data.ID != "booking_id" || payload.Data.Status != want.status {
+			t.Errorf("unexpected payload %s", body)
+		}
+	}
+}
+
+func TestDispatcherRetriesUntilDead(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	s := newBookedStore(t, srv.URL, model.WebhookEventBookingCreated)
+	d := NewDispatcher(s, Config{Timeout: time.Second, MaxAttempts: 3, Backoff: time.Minute}, WithClock(func() time.Time { return now }))
+	dispatch := func() {
+		t.Helper()
+		if err := d.Dispatch(context.Background()); err != nil {
+			t.Fatalf("error was not expected while dispatching: %s", err)
+		}
+	}
+
+	// Retries wait one minute, then two
+	dispatch()
+	now = now.Add(59 * time.Second)
+	dispatch()
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt before the backoff ends, got %d", attempts)
+	}
+	now = now.Add(time.Second)
+	dispatch()
+	now = now.Add(time.Minute)
+	dispatch()
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts before the backoff doubles, got %d", attempts)
+	}
+	now = now.Add(time.Minute)
+	dispatch()
+	now = now.Add(time.Hour)
+	dispatch()
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts in total, got %d", attempts)
+	}
+
+	dead, err := s.GetDeadWebhookDeliveries()
+	if err != nil {
+		t.Fatalf("error was not expected while fetching dead deliveries: %s", err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastStatusCode != http.StatusServiceUnavailable || dead[0].Event != model.WebhookEventBookingCreated {
+		t.Fatalf("expected 1 dead delivery, got %+v", dead)
+	}
+
+	// A retried dead letter gets fresh attempts
+	if err := s.RetryWebhookDelivery(dead[0].ID, now); err != nil {
+		t.Fatalf("error was not expected while retrying delivery: %s", err)
+	}
+	dispatch()
+	if attempts != 4 {
+		t.Errorf("expected the dead letter to be sent again, got %d attempts", attempts)
+	}
+}